@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"slices"
@@ -100,12 +102,43 @@ func validate(config *Config) error {
 	}
 
 	// custom validations
+	if config.OgcAPI.Tiles != nil {
+		if err = validateTilesConfig(config.OgcAPI.Tiles); err != nil {
+			return err
+		}
+	}
 	if config.OgcAPI.Features != nil {
 		return validateCollectionsTemporalConfig(config.OgcAPI.Features.Collections)
 	}
 	return nil
 }
 
+// built-in tile matrix sets, by projection
+var defaultTileMatrixSets = map[string]string{
+	"EPSG:3035":  "EuropeanETRS89_LAEAQuad",
+	"EPSG:28992": "NetherlandsRDNewQuad",
+	"EPSG:3857":  "WebMercatorQuad",
+}
+
+func validateTilesConfig(tiles *OgcAPITiles) error {
+	for _, srs := range tiles.SupportedSrs {
+		if srs.TileMatrixSetID() == "" {
+			return fmt.Errorf("invalid config provided:\n"+
+				"field 'TileMatrixSet' is required for %s, since there's no built-in tile matrix set for it", srs.Srs)
+		}
+		if tiles.HasTileServer() {
+			continue
+		}
+		for _, tilesType := range tiles.Types {
+			if tiles.ArchiveForTileMatrixSet(srs.TileMatrixSetID(), tilesType) == nil {
+				return fmt.Errorf("invalid config provided:\n"+
+					"field 'TileServer' is required when tiles in %s aren't served from a local archive (of type %s)", srs.Srs, tilesType)
+			}
+		}
+	}
+	return nil
+}
+
 func validateCollectionsTemporalConfig(collections GeoSpatialCollections) error {
 	var errMessages []string
 	for _, collection := range collections {
@@ -427,7 +460,36 @@ func (gv *CollectionEntry3dGeoVolumes) HasDTM() bool {
 
 // +kubebuilder:object:generate=true
 type CollectionEntryTiles struct {
-	// placeholder
+	// Generate vector tiles of this collection on-the-fly from its features, instead of proxying tiles from the
+	// tileserver. Requires the collection to be served as features (OGC API Features) in the projection of
+	// each supported tile matrix set. Meant for smaller datasets, since all features within a tile are read.
+	// +optional
+	OnTheFly *OnTheFlyTiles `yaml:"onTheFly,omitempty" json:"onTheFly,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+type OnTheFlyTiles struct {
+	// Size of a tile in tile coordinates, geometries are quantized to this (integer) grid.
+	// +kubebuilder:default=4096
+	// +optional
+	Extent int `yaml:"extent,omitempty" json:"extent,omitempty" validate:"gte=256" default:"4096"`
+
+	// Buffer around a tile in tile coordinates. Geometries are clipped to the tile including
+	// this buffer, to prevent rendering artifacts at the edges of tiles.
+	// +kubebuilder:default=64
+	// +optional
+	Buffer int `yaml:"buffer,omitempty" json:"buffer,omitempty" validate:"gte=0" default:"64"`
+
+	// Max number of features in a single tile. Tiles with more features are truncated (and not cached),
+	// which is logged as a warning.
+	// +kubebuilder:default=10000
+	// +optional
+	MaxFeatures int `yaml:"maxFeatures,omitempty" json:"maxFeatures,omitempty" validate:"gt=0" default:"10000"`
+
+	// Number of generated tiles to keep in memory.
+	// +kubebuilder:default=1000
+	// +optional
+	CacheSize int `yaml:"cacheSize,omitempty" json:"cacheSize,omitempty" validate:"gt=0" default:"1000"`
 }
 
 // +kubebuilder:object:generate=true
@@ -443,6 +505,35 @@ type CollectionEntryFeatures struct {
 	// Filters available for this collection
 	// +optional
 	Filters FeatureFilters `yaml:"filters,omitempty" json:"filters,omitempty"`
+
+	// Properties (column names in the feature table) by which features of this collection can
+	// be sorted using the 'sortby' parameter. These columns need to be indexed.
+	// +optional
+	Sortables []string `yaml:"sortables,omitempty" json:"sortables,omitempty" validate:"dive,required"`
+
+	// Optional column in the feature table holding an externally stable identifier (e.g. a UUID or national
+	// object identifier) to expose as the feature id, instead of the internal feature id (fid). The internal
+	// fid is still used for pagination. This column needs to be unique and indexed.
+	// +optional
+	ExternalFid string `yaml:"externalFid,omitempty" json:"externalFid,omitempty"`
+
+	// Whether features of this collection can be created, replaced, updated and deleted (OAF Part 4).
+	// Only supported for collections backed by a local GeoPackage, in a single projection. Note: GoKoala
+	// doesn't authenticate requests, so writable collections should be exposed behind an authenticating proxy.
+	// +optional
+	Writable bool `yaml:"writable,omitempty" json:"writable,omitempty"`
+
+	// Whether to report the number of features matching the request (numberMatched). Requires an additional
+	// count query, which is cached per set of filters. When the count doesn't finish in time it's omitted.
+	// +optional
+	NumberMatched bool `yaml:"numberMatched,omitempty" json:"numberMatched,omitempty"`
+
+	// Optional number of decimals to round the coordinates of output geometries to, in order to reduce the size
+	// of responses. Applies to projected CRSs (e.g. 2 for centimetres), geographic CRSs (like WGS84) get 5 additional
+	// decimals for a similar precision in degrees.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	CoordinatePrecision *int `yaml:"coordinatePrecision,omitempty" json:"coordinatePrecision,omitempty" validate:"omitempty,gte=0,lte=10"`
 }
 
 // +kubebuilder:object:generate=true
@@ -481,26 +572,183 @@ const (
 	TilesTypeVector TilesType = "vector"
 )
 
+// +kubebuilder:validation:Enum=png;jpeg;webp
+type RasterTilesFormat string
+
+const (
+	RasterTilesFormatPNG  RasterTilesFormat = "png"
+	RasterTilesFormatJPEG RasterTilesFormat = "jpeg"
+	RasterTilesFormatWebP RasterTilesFormat = "webp"
+)
+
 // +kubebuilder:object:generate=true
 type OgcAPITiles struct {
-	// Reference to the server (or object storage) hosting the tiles
-	TileServer URL `yaml:"tileServer" json:"tileServer" validate:"required"`
+	// Reference to the server (or object storage) hosting the tiles. Required unless
+	// the tiles of all supported projections are served from local archives.
+	// +optional
+	TileServer URL `yaml:"tileServer,omitempty" json:"tileServer,omitempty"`
+
+	// Serve tiles from archives (MBTiles or PMTiles) on local disk instead of the tileserver, one archive per tile
+	// matrix set. Tile matrix sets without an archive are still served from the tileserver.
+	// +optional
+	Archives []TilesArchive `yaml:"archives,omitempty" json:"archives,omitempty" validate:"dive"`
 
 	// Could be 'vector' and/or 'raster' to indicate the types of tiles offered
 	Types []TilesType `yaml:"types" json:"types" validate:"required"`
 
+	// Image format of the raster tiles, applicable when 'raster' is one of the types
+	// +kubebuilder:default="png"
+	// +optional
+	RasterFormat RasterTilesFormat `yaml:"rasterFormat,omitempty" json:"rasterFormat,omitempty" default:"png" validate:"oneof=png jpeg webp"`
+
 	// Specifies in what projections (SRS/CRS) the tiles are offered
 	SupportedSrs []SupportedSrs `yaml:"supportedSrs" json:"supportedSrs" validate:"required,dive"`
 
+	// Extent of the dataset, limits the available tiles in each tile matrix set to the tiles
+	// covering this extent. The bbox is in the axis order of the given srs (lat/lon for EPSG:4326).
+	// +optional
+	Extent *Extent `yaml:"extent,omitempty" json:"extent,omitempty"`
+
+	// Custom tile matrix sets (tiling schemes), in addition to the built-in EuropeanETRS89_LAEAQuad,
+	// NetherlandsRDNewQuad and WebMercatorQuad. For example for UTM-based or other national grids.
+	// +optional
+	TileMatrixSets []TileMatrixSet `yaml:"tileMatrixSets,omitempty" json:"tileMatrixSets,omitempty" validate:"dive"`
+
 	// Optional template to the vector tiles on the tileserver. Defaults to {tms}/{z}/{x}/{y}.pbf.
 	// +optional
 	URITemplateTiles *string `yaml:"uriTemplateTiles,omitempty" json:"uriTemplateTiles,omitempty"`
 
+	// Optional template to the raster tiles on the tileserver. Defaults to {tms}/{z}/{x}/{y}.png,
+	// or .jpg/.webp depending on the raster format.
+	// +optional
+	URITemplateRasterTiles *string `yaml:"uriTemplateRasterTiles,omitempty" json:"uriTemplateRasterTiles,omitempty"`
+
 	// The collections to offer as tiles. When no collection is specified the tiles are hosted at the root of the API (/tiles endpoint).
 	// +optional
 	Collections GeoSpatialCollections `yaml:"collections,omitempty" json:"collections,omitempty"`
 }
 
+// HasTileServer true when (some of) the tiles are proxied from the tileserver, instead of served from local archives
+func (t *OgcAPITiles) HasTileServer() bool {
+	return t.TileServer.URL != nil
+}
+
+// HasType true when tiles of the given type (vector or raster) are offered
+func (t *OgcAPITiles) HasType(tilesType TilesType) bool {
+	return slices.Contains(t.Types, tilesType)
+}
+
+// ArchiveForTileMatrixSet returns the local archive holding the tiles of the given type and tile matrix set, if any
+func (t *OgcAPITiles) ArchiveForTileMatrixSet(tileMatrixSetID string, tilesType TilesType) *TilesArchive {
+	for i := range t.Archives {
+		if t.Archives[i].TileMatrixSet == tileMatrixSetID && t.Archives[i].Type == tilesType {
+			return &t.Archives[i]
+		}
+	}
+	return nil
+}
+
+// +kubebuilder:object:generate=true
+type TileMatrixSet struct {
+	// Location of the tile matrix set definition on local disk, in the JSON encoding of
+	// OGC Two Dimensional Tile Matrix Set (https://docs.ogc.org/is/17-083r4/17-083r4.html).
+	// Alternatively, specify the definition inline.
+	// +optional
+	File string `yaml:"file,omitempty" json:"file,omitempty" validate:"required_without=ID,omitempty,file"`
+
+	// Inline definition of the tile matrix set, alternative to File.
+	// +optional
+	TileMatrixSetDefinition `yaml:",inline" json:",inline"`
+}
+
+// TileMatrixSetDefinition a tile matrix set (tiling scheme) according to OGC Two Dimensional Tile Matrix Set,
+// limited to (the properties of) tile matrices of equal size. Property names follow the JSON encoding.
+// +kubebuilder:object:generate=true
+type TileMatrixSetDefinition struct {
+	// Unique ID of the tile matrix set, used in the URLs of tiles
+	// +optional
+	ID string `yaml:"id,omitempty" json:"id,omitempty"`
+
+	// Human-readable title of the tile matrix set
+	// +optional
+	Title string `yaml:"title,omitempty" json:"title,omitempty"`
+
+	// URI of the tile matrix set, when registered (e.g. in the OGC NA definitions server)
+	// +optional
+	URI string `yaml:"uri,omitempty" json:"uri,omitempty" validate:"omitempty,url"`
+
+	// URI of the coordinate reference system, e.g. http://www.opengis.net/def/crs/EPSG/0/32631
+	// +optional
+	CRS string `yaml:"crs,omitempty" json:"crs,omitempty" validate:"required_with=ID,omitempty,url"`
+
+	// Order of the axes of the coordinates of the points of origin, e.g. ["X", "Y"] or ["Y", "X"] (northing first)
+	// +optional
+	OrderedAxes []string `yaml:"orderedAxes,omitempty" json:"orderedAxes,omitempty" validate:"omitempty,len=2"`
+
+	// URI of the well-known scale set this tile matrix set is compatible with
+	// +optional
+	WellKnownScaleSet string `yaml:"wellKnownScaleSet,omitempty" json:"wellKnownScaleSet,omitempty"`
+
+	// Tile matrices (zoom levels) of the tile matrix set, from the smallest scale (least detail) to the largest
+	// +optional
+	TileMatrices []TileMatrix `yaml:"tileMatrices,omitempty" json:"tileMatrices,omitempty" validate:"required_with=ID,dive"`
+}
+
+// +kubebuilder:object:generate=true
+type TileMatrix struct {
+	// ID of the tile matrix, the zoom level
+	ID string `yaml:"id" json:"id" validate:"required,number"`
+
+	// Scale denominator of the tile matrix
+	// +kubebuilder:validation:Type=number
+	ScaleDenominator float64 `yaml:"scaleDenominator" json:"scaleDenominator" validate:"gt=0"`
+
+	// Size of a cell (pixel) in units of the CRS
+	// +kubebuilder:validation:Type=number
+	CellSize float64 `yaml:"cellSize" json:"cellSize" validate:"gt=0"`
+
+	// Position of the top left corner of the tile matrix, in the order of the axes of the tile matrix set
+	// +kubebuilder:validation:Type=array
+	PointOfOrigin [2]float64 `yaml:"pointOfOrigin" json:"pointOfOrigin"`
+
+	// Width of a tile in cells (pixels)
+	TileWidth int `yaml:"tileWidth" json:"tileWidth" validate:"gt=0"`
+
+	// Height of a tile in cells (pixels)
+	TileHeight int `yaml:"tileHeight" json:"tileHeight" validate:"gt=0"`
+
+	// Number of tiles in the width of the tile matrix
+	MatrixWidth int `yaml:"matrixWidth" json:"matrixWidth" validate:"gt=0"`
+
+	// Number of tiles in the height of the tile matrix
+	MatrixHeight int `yaml:"matrixHeight" json:"matrixHeight" validate:"gt=0"`
+}
+
+// +kubebuilder:object:generate=true
+type TilesArchive struct {
+	// ID of the tile matrix set of the tiles in this archive
+	TileMatrixSet string `yaml:"tileMatrixSet" json:"tileMatrixSet" validate:"required"`
+
+	// Type of the tiles in this archive, 'vector' or 'raster'
+	// +kubebuilder:default="vector"
+	// +optional
+	Type TilesType `yaml:"type,omitempty" json:"type,omitempty" default:"vector" validate:"oneof=raster vector"`
+
+	// MBTiles (SQLite) archive to get the tiles from.
+	// +optional
+	MBTiles *TilesArchiveFile `yaml:"mbtiles,omitempty" json:"mbtiles,omitempty" validate:"required_without_all=PMTiles"`
+
+	// PMTiles archive to get the tiles from.
+	// +optional
+	PMTiles *TilesArchiveFile `yaml:"pmtiles,omitempty" json:"pmtiles,omitempty" validate:"required_without_all=MBTiles"`
+}
+
+// +kubebuilder:object:generate=true
+type TilesArchiveFile struct {
+	// Location of the archive on local disk
+	File string `yaml:"file" json:"file" validate:"file"`
+}
+
 // +kubebuilder:object:generate=true
 type OgcAPIStyles struct {
 	// ID of the style to use a default
@@ -539,6 +787,25 @@ type OgcAPIFeatures struct {
 	// +kubebuilder:default=true
 	// +optional
 	ValidateResponses *bool `yaml:"validateResponses,omitempty" json:"validateResponses,omitempty" default:"true"` // ptr due to https://github.com/creasty/defaults/issues/49
+
+	// Percentage of GeoJSON/JSON-FG responses to validate against the OpenAPI spec (when 'validateResponses'
+	// is enabled). Validated responses are marshalled in-memory first, while the other responses are streamed
+	// to the client. By default only a sample of the responses is validated, raise this percentage (up to 100)
+	// for stricter validation at the cost of memory usage when serving large feature collections.
+	//
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	ValidateResponsesPercentage int `yaml:"validateResponsesPercentage,omitempty" json:"validateResponsesPercentage,omitempty" validate:"gte=1,lte=100" default:"10"`
+
+	// Max size (in bytes) of the request body when creating, replacing or updating features of writable
+	// collections. Larger requests are rejected with 413 Payload Too Large.
+	//
+	// +kubebuilder:default=1048576
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxRequestBodySize int64 `yaml:"maxRequestBodySize,omitempty" json:"maxRequestBodySize,omitempty" validate:"gte=1" default:"1048576"`
 }
 
 func (oaf *OgcAPIFeatures) ProjectionsForCollections() []string {
@@ -551,12 +818,18 @@ func (oaf *OgcAPIFeatures) ProjectionsForCollection(collectionID string) []strin
 		for _, a := range oaf.Datasources.Additional {
 			uniqueSRSs[a.Srs] = struct{}{}
 		}
+		for _, srs := range oaf.Datasources.Reprojections {
+			uniqueSRSs[srs] = struct{}{}
+		}
 	}
 	for _, coll := range oaf.Collections {
 		if (coll.ID == collectionID || collectionID == "") && coll.Features != nil && coll.Features.Datasources != nil {
 			for _, a := range coll.Features.Datasources.Additional {
 				uniqueSRSs[a.Srs] = struct{}{}
 			}
+			for _, srs := range coll.Features.Datasources.Reprojections {
+				uniqueSRSs[srs] = struct{}{}
+			}
 			break
 		}
 	}
@@ -574,6 +847,67 @@ func (oaf *OgcAPIFeatures) PropertyFiltersForCollection(collectionID string) []P
 	return []PropertyFilter{}
 }
 
+func (oaf *OgcAPIFeatures) SortablesForCollection(collectionID string) []string {
+	for _, coll := range oaf.Collections {
+		if coll.ID == collectionID && coll.Features != nil && coll.Features.Sortables != nil {
+			return coll.Features.Sortables
+		}
+	}
+	return []string{}
+}
+
+// ExternalFidForCollection returns the column holding the external feature id of the given collection,
+// or an empty string when the collection uses the internal feature id.
+func (oaf *OgcAPIFeatures) ExternalFidForCollection(collectionID string) string {
+	for _, coll := range oaf.Collections {
+		if coll.ID == collectionID && coll.Features != nil {
+			return coll.Features.ExternalFid
+		}
+	}
+	return ""
+}
+
+// IsWritableCollection returns true when features of the given collection can be created, replaced, updated and deleted
+func (oaf *OgcAPIFeatures) IsWritableCollection(collectionID string) bool {
+	for _, coll := range oaf.Collections {
+		if coll.ID == collectionID && coll.Features != nil {
+			return coll.Features.Writable
+		}
+	}
+	return false
+}
+
+// NumberMatchedForCollection returns true when numberMatched should be reported for the given collection
+func (oaf *OgcAPIFeatures) NumberMatchedForCollection(collectionID string) bool {
+	for _, coll := range oaf.Collections {
+		if coll.ID == collectionID && coll.Features != nil {
+			return coll.Features.NumberMatched
+		}
+	}
+	return false
+}
+
+// CoordinatePrecisionForCollection returns the number of decimals to round coordinates of the given collection to,
+// or nil when coordinates shouldn't be rounded.
+func (oaf *OgcAPIFeatures) CoordinatePrecisionForCollection(collectionID string) *int {
+	for _, coll := range oaf.Collections {
+		if coll.ID == collectionID && coll.Features != nil {
+			return coll.Features.CoordinatePrecision
+		}
+	}
+	return nil
+}
+
+// HasWritableCollections returns true when features of at least one collection can be created, replaced, updated and deleted
+func (oaf *OgcAPIFeatures) HasWritableCollections() bool {
+	for _, coll := range oaf.Collections {
+		if coll.Features != nil && coll.Features.Writable {
+			return true
+		}
+	}
+	return false
+}
+
 // +kubebuilder:object:generate=true
 type OgcAPIProcesses struct {
 	// Enable to advertise dismiss operations on the conformance page
@@ -607,20 +941,31 @@ type Datasources struct {
 	// This specifies the datasource to be used for features in the WGS84 projection
 	DefaultWGS84 Datasource `yaml:"defaultWGS84" json:"defaultWGS84" validate:"required"`
 
-	// One or more additional datasources for features in other projections. GoKoala doesn't do
-	// any on-the-fly reprojection so additional datasources need to be reprojected ahead of time.
+	// One or more additional datasources for features in other projections. These datasources need
+	// to be reprojected ahead of time, see Reprojections for on-the-fly reprojection instead.
 	Additional []AdditionalDatasource `yaml:"additional" json:"additional" validate:"dive"`
+
+	// Projections (SRS/CRS) in which the features of the WGS84 datasource are served by reprojecting them
+	// on-the-fly, as alternative to additional datasources. Supported are EPSG:28992 (RD New), EPSG:3035
+	// (ETRS89-LAEA), EPSG:3857 (Web Mercator), EPSG:326xx/327xx (WGS84 UTM), EPSG:258xx (ETRS89 UTM),
+	// EPSG:4258 and EPSG:4326. Additional datasources take precedence over on-the-fly reprojection.
+	// +optional
+	Reprojections []string `yaml:"reprojections,omitempty" json:"reprojections,omitempty" validate:"dive,startswith=EPSG:"`
 }
 
 // +kubebuilder:object:generate=true
 type Datasource struct {
 	// GeoPackage to get the features from.
 	// +optional
-	GeoPackage *GeoPackage `yaml:"geopackage,omitempty" json:"geopackage,omitempty" validate:"required_without_all=PostGIS"`
+	GeoPackage *GeoPackage `yaml:"geopackage,omitempty" json:"geopackage,omitempty" validate:"required_without_all=PostGIS GeoJSON"`
 
-	// PostGIS database to get the features from (not implemented yet).
+	// PostGIS database to get the features from.
 	// +optional
-	PostGIS *PostGIS `yaml:"postgis,omitempty" json:"postgis,omitempty" validate:"required_without_all=GeoPackage"`
+	PostGIS *PostGIS `yaml:"postgis,omitempty" json:"postgis,omitempty" validate:"required_without_all=GeoPackage GeoJSON"`
+
+	// GeoJSON file to get the features from, loaded in memory. Meant for small datasets.
+	// +optional
+	GeoJSON *GeoJSON `yaml:"geojson,omitempty" json:"geojson,omitempty" validate:"required_without_all=GeoPackage PostGIS"`
 
 	// Add more datasources here such as Mongo, Elastic, etc
 }
@@ -637,7 +982,70 @@ type AdditionalDatasource struct {
 
 // +kubebuilder:object:generate=true
 type PostGIS struct {
-	// placeholder
+	// Hostname of the PostgreSQL server
+	// +kubebuilder:default="localhost"
+	// +optional
+	Host string `yaml:"host,omitempty" json:"host,omitempty" validate:"required" default:"localhost"`
+
+	// Port of the PostgreSQL server
+	// +kubebuilder:default=5432
+	// +optional
+	Port int `yaml:"port,omitempty" json:"port,omitempty" validate:"required" default:"5432"`
+
+	// Name of the database containing the feature tables
+	DatabaseName string `yaml:"databaseName" json:"databaseName" validate:"required"`
+
+	// Database schema containing the feature tables
+	// +kubebuilder:default="public"
+	// +optional
+	Schema string `yaml:"schema,omitempty" json:"schema,omitempty" validate:"required" default:"public"`
+
+	// Username to connect with the database
+	User string `yaml:"user" json:"user" validate:"required"`
+
+	// Password to connect with the database. Use an environment variable (e.g. ${DB_PASSWORD}) to keep it out of the config file
+	Password string `yaml:"password" json:"password" validate:"required"`
+
+	// SSL mode to connect with the database, see https://www.postgresql.org/docs/current/libpq-ssl.html
+	// +kubebuilder:default="disable"
+	// +kubebuilder:validation:Enum=disable;allow;prefer;require;verify-ca;verify-full
+	// +optional
+	SSLMode string `yaml:"sslMode,omitempty" json:"sslMode,omitempty" default:"disable" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+
+	// Feature id column name
+	// +kubebuilder:default="fid"
+	// +optional
+	Fid string `yaml:"fid,omitempty" json:"fid,omitempty" validate:"required" default:"fid"`
+
+	// Optional timeout after which queries are canceled
+	// +kubebuilder:default="15s"
+	// +optional
+	QueryTimeout Duration `yaml:"queryTimeout,omitempty" json:"queryTimeout,omitempty" validate:"required" default:"15s"`
+
+	// Maximum number of open connections to the database
+	// +kubebuilder:default=25
+	// +optional
+	MaxOpenConnections int `yaml:"maxOpenConnections,omitempty" json:"maxOpenConnections,omitempty" validate:"gte=1" default:"25"`
+}
+
+// ConnectionString returns the PostgreSQL connection string (in URL form) for this datasource
+func (pg *PostGIS) ConnectionString() string {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(pg.User, pg.Password),
+		Host:     net.JoinHostPort(pg.Host, strconv.Itoa(pg.Port)),
+		Path:     pg.DatabaseName,
+		RawQuery: url.Values{"sslmode": []string{pg.SSLMode}}.Encode(),
+	}
+	return dsn.String()
+}
+
+// +kubebuilder:object:generate=true
+type GeoJSON struct {
+	// Location of the GeoJSON file on disk. Either a FeatureCollection or a sequence of Features (GeoJSON Text
+	// Sequences or newline-delimited GeoJSON). The file holds the features of a single collection, so it's
+	// typically configured as a collection specific datasource.
+	File string `yaml:"file" json:"file" validate:"file"`
 }
 
 // +kubebuilder:object:generate=true
@@ -764,6 +1172,20 @@ type SupportedSrs struct {
 
 	// Available zoom levels
 	ZoomLevelRange ZoomLevelRange `yaml:"zoomLevelRange" json:"zoomLevelRange" validate:"required"`
+
+	// ID of the tile matrix set (tiling scheme) of the tiles in this projection. Either one of the built-in tile
+	// matrix sets or a custom one, see OgcAPITiles.TileMatrixSets. Defaults to the built-in tile matrix set of
+	// the projection: NetherlandsRDNewQuad (EPSG:28992), EuropeanETRS89_LAEAQuad (EPSG:3035) or WebMercatorQuad (EPSG:3857).
+	// +optional
+	TileMatrixSet string `yaml:"tileMatrixSet,omitempty" json:"tileMatrixSet,omitempty"`
+}
+
+// TileMatrixSetID returns the ID of the tile matrix set of the tiles in this projection, see TileMatrixSet
+func (s SupportedSrs) TileMatrixSetID() string {
+	if s.TileMatrixSet != "" {
+		return s.TileMatrixSet
+	}
+	return defaultTileMatrixSets[s.Srs]
 }
 
 // +kubebuilder:object:generate=true
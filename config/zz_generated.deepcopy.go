@@ -75,6 +75,16 @@ func (in *CollectionEntryFeatures) DeepCopyInto(out *CollectionEntryFeatures) {
 		(*in).DeepCopyInto(*out)
 	}
 	in.Filters.DeepCopyInto(&out.Filters)
+	if in.Sortables != nil {
+		in, out := &in.Sortables, &out.Sortables
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CoordinatePrecision != nil {
+		in, out := &in.CoordinatePrecision, &out.CoordinatePrecision
+		*out = new(int)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CollectionEntryFeatures.
@@ -90,6 +100,11 @@ func (in *CollectionEntryFeatures) DeepCopy() *CollectionEntryFeatures {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CollectionEntryTiles) DeepCopyInto(out *CollectionEntryTiles) {
 	*out = *in
+	if in.OnTheFly != nil {
+		in, out := &in.OnTheFly, &out.OnTheFly
+		*out = new(OnTheFlyTiles)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CollectionEntryTiles.
@@ -212,6 +227,11 @@ func (in *Datasource) DeepCopyInto(out *Datasource) {
 		*out = new(PostGIS)
 		**out = **in
 	}
+	if in.GeoJSON != nil {
+		in, out := &in.GeoJSON, &out.GeoJSON
+		*out = new(GeoJSON)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Datasource.
@@ -235,6 +255,11 @@ func (in *Datasources) DeepCopyInto(out *Datasources) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Reprojections != nil {
+		in, out := &in.Reprojections, &out.Reprojections
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Datasources.
@@ -292,6 +317,21 @@ func (in *FeatureFilters) DeepCopy() *FeatureFilters {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GeoJSON) DeepCopyInto(out *GeoJSON) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GeoJSON.
+func (in *GeoJSON) DeepCopy() *GeoJSON {
+	if in == nil {
+		return nil
+	}
+	out := new(GeoJSON)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GeoPackage) DeepCopyInto(out *GeoPackage) {
 	*out = *in
@@ -402,7 +442,7 @@ func (in *GeoSpatialCollection) DeepCopyInto(out *GeoSpatialCollection) {
 	if in.Tiles != nil {
 		in, out := &in.Tiles, &out.Tiles
 		*out = new(CollectionEntryTiles)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Features != nil {
 		in, out := &in.Features, &out.Features
@@ -650,6 +690,13 @@ func (in *OgcAPIStyles) DeepCopy() *OgcAPIStyles {
 func (in *OgcAPITiles) DeepCopyInto(out *OgcAPITiles) {
 	*out = *in
 	in.TileServer.DeepCopyInto(&out.TileServer)
+	if in.Archives != nil {
+		in, out := &in.Archives, &out.Archives
+		*out = make([]TilesArchive, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Types != nil {
 		in, out := &in.Types, &out.Types
 		*out = make([]TilesType, len(*in))
@@ -660,11 +707,28 @@ func (in *OgcAPITiles) DeepCopyInto(out *OgcAPITiles) {
 		*out = make([]SupportedSrs, len(*in))
 		copy(*out, *in)
 	}
+	if in.Extent != nil {
+		in, out := &in.Extent, &out.Extent
+		*out = new(Extent)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TileMatrixSets != nil {
+		in, out := &in.TileMatrixSets, &out.TileMatrixSets
+		*out = make([]TileMatrixSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.URITemplateTiles != nil {
 		in, out := &in.URITemplateTiles, &out.URITemplateTiles
 		*out = new(string)
 		**out = **in
 	}
+	if in.URITemplateRasterTiles != nil {
+		in, out := &in.URITemplateRasterTiles, &out.URITemplateRasterTiles
+		*out = new(string)
+		**out = **in
+	}
 	if in.Collections != nil {
 		in, out := &in.Collections, &out.Collections
 		*out = make(GeoSpatialCollections, len(*in))
@@ -684,6 +748,21 @@ func (in *OgcAPITiles) DeepCopy() *OgcAPITiles {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OnTheFlyTiles) DeepCopyInto(out *OnTheFlyTiles) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnTheFlyTiles.
+func (in *OnTheFlyTiles) DeepCopy() *OnTheFlyTiles {
+	if in == nil {
+		return nil
+	}
+	out := new(OnTheFlyTiles)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostGIS) DeepCopyInto(out *PostGIS) {
 	*out = *in
@@ -845,6 +924,103 @@ func (in *TemporalProperties) DeepCopy() *TemporalProperties {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TileMatrix) DeepCopyInto(out *TileMatrix) {
+	*out = *in
+	out.PointOfOrigin = in.PointOfOrigin
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TileMatrix.
+func (in *TileMatrix) DeepCopy() *TileMatrix {
+	if in == nil {
+		return nil
+	}
+	out := new(TileMatrix)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TileMatrixSet) DeepCopyInto(out *TileMatrixSet) {
+	*out = *in
+	in.TileMatrixSetDefinition.DeepCopyInto(&out.TileMatrixSetDefinition)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TileMatrixSet.
+func (in *TileMatrixSet) DeepCopy() *TileMatrixSet {
+	if in == nil {
+		return nil
+	}
+	out := new(TileMatrixSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TileMatrixSetDefinition) DeepCopyInto(out *TileMatrixSetDefinition) {
+	*out = *in
+	if in.OrderedAxes != nil {
+		in, out := &in.OrderedAxes, &out.OrderedAxes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TileMatrices != nil {
+		in, out := &in.TileMatrices, &out.TileMatrices
+		*out = make([]TileMatrix, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TileMatrixSetDefinition.
+func (in *TileMatrixSetDefinition) DeepCopy() *TileMatrixSetDefinition {
+	if in == nil {
+		return nil
+	}
+	out := new(TileMatrixSetDefinition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TilesArchive) DeepCopyInto(out *TilesArchive) {
+	*out = *in
+	if in.MBTiles != nil {
+		in, out := &in.MBTiles, &out.MBTiles
+		*out = new(TilesArchiveFile)
+		**out = **in
+	}
+	if in.PMTiles != nil {
+		in, out := &in.PMTiles, &out.PMTiles
+		*out = new(TilesArchiveFile)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TilesArchive.
+func (in *TilesArchive) DeepCopy() *TilesArchive {
+	if in == nil {
+		return nil
+	}
+	out := new(TilesArchive)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TilesArchiveFile) DeepCopyInto(out *TilesArchiveFile) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TilesArchiveFile.
+func (in *TilesArchiveFile) DeepCopy() *TilesArchiveFile {
+	if in == nil {
+		return nil
+	}
+	out := new(TilesArchiveFile)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZoomLevelRange) DeepCopyInto(out *ZoomLevelRange) {
 	*out = *in
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NewETag returns a weak entity tag (ETag) for the given content. Weak since responses may be compressed
+// afterward (see middleware.Compress), while a strong ETag isn't allowed for different content codings.
+func NewETag(content []byte) string {
+	hasher := fnv.New64a() // fast non-cryptographic hash
+	_, _ = hasher.Write(content)
+	return fmt.Sprintf(`W/"%x"`, hasher.Sum64())
+}
+
+// NotModified adds the given validators (ETag and/or Last-Modified, when non-empty) to the response and evaluates
+// the conditional headers (If-None-Match and If-Modified-Since) of the given request, see RFC 9110 section 13.
+// When the client already has the current representation a 304 Not Modified is sent and true is returned, in
+// which case the caller shouldn't write a response body.
+func NotModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	if etag != "" {
+		w.Header().Set(HeaderETag, etag)
+	}
+	if !lastModified.IsZero() {
+		w.Header().Set(HeaderLastModified, lastModified.UTC().Format(http.TimeFormat))
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if ifNoneMatch := r.Header.Get(HeaderIfNoneMatch); ifNoneMatch != "" {
+		// If-Modified-Since must be ignored when If-None-Match is present
+		if etag == "" || !etagMatches(ifNoneMatch, etag) {
+			return false
+		}
+	} else {
+		ifModifiedSince, err := http.ParseTime(r.Header.Get(HeaderIfModifiedSince))
+		if err != nil || lastModified.IsZero() || lastModified.Truncate(time.Second).After(ifModifiedSince) {
+			return false
+		}
+	}
+
+	w.Header().Del(HeaderContentType)
+	w.Header().Del(HeaderContentLength)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches performs a weak comparison of the given ETag against the list of ETags in an If-None-Match header
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewETag(t *testing.T) {
+	assert.True(t, strings.HasPrefix(NewETag([]byte("foo")), `W/"`), "should be weak, since responses may be compressed")
+	assert.Equal(t, NewETag([]byte("foo")), NewETag([]byte("foo")))
+	assert.NotEqual(t, NewETag([]byte("foo")), NewETag([]byte("bar")))
+}
+
+func TestNotModified(t *testing.T) {
+	etag := NewETag([]byte("foo"))
+	lastModified := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+
+	tests := []struct {
+		name         string
+		method       string
+		headers      map[string]string
+		etag         string
+		lastModified time.Time
+		want         bool
+	}{
+		{
+			name:   "no conditional headers",
+			method: http.MethodGet,
+			etag:   etag,
+			want:   false,
+		},
+		{
+			name:    "matching etag",
+			method:  http.MethodGet,
+			headers: map[string]string{HeaderIfNoneMatch: etag},
+			etag:    etag,
+			want:    true,
+		},
+		{
+			name:    "matching etag in list, weak comparison",
+			method:  http.MethodHead,
+			headers: map[string]string{HeaderIfNoneMatch: `"bar", ` + strings.TrimPrefix(etag, "W/")},
+			etag:    etag,
+			want:    true,
+		},
+		{
+			name:    "wildcard",
+			method:  http.MethodGet,
+			headers: map[string]string{HeaderIfNoneMatch: "*"},
+			etag:    etag,
+			want:    true,
+		},
+		{
+			name:    "different etag",
+			method:  http.MethodGet,
+			headers: map[string]string{HeaderIfNoneMatch: NewETag([]byte("bar"))},
+			etag:    etag,
+			want:    false,
+		},
+		{
+			name:    "matching etag for unsafe method",
+			method:  http.MethodPost,
+			headers: map[string]string{HeaderIfNoneMatch: etag},
+			etag:    etag,
+			want:    false,
+		},
+		{
+			name:         "not modified since",
+			method:       http.MethodGet,
+			headers:      map[string]string{HeaderIfModifiedSince: "Wed, 01 May 2024 12:00:00 GMT"},
+			lastModified: lastModified,
+			want:         true,
+		},
+		{
+			name:         "modified since",
+			method:       http.MethodGet,
+			headers:      map[string]string{HeaderIfModifiedSince: "Wed, 01 May 2024 11:59:59 GMT"},
+			lastModified: lastModified,
+			want:         false,
+		},
+		{
+			name:         "if-modified-since is ignored when if-none-match is present",
+			method:       http.MethodGet,
+			headers:      map[string]string{HeaderIfNoneMatch: `"bar"`, HeaderIfModifiedSince: "Wed, 01 May 2024 12:00:00 GMT"},
+			etag:         etag,
+			lastModified: lastModified,
+			want:         false,
+		},
+		{
+			name:    "invalid if-modified-since",
+			method:  http.MethodGet,
+			headers: map[string]string{HeaderIfModifiedSince: "yesterday"},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "http://localhost:8080/", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			w.Header().Set(HeaderContentType, MediaTypeJSON)
+
+			assert.Equal(t, tt.want, NotModified(w, r, tt.etag, tt.lastModified))
+			assert.Equal(t, tt.etag, w.Header().Get(HeaderETag))
+			if !tt.lastModified.IsZero() {
+				assert.Equal(t, "Wed, 01 May 2024 12:00:00 GMT", w.Header().Get(HeaderLastModified))
+			}
+			if tt.want {
+				assert.Equal(t, http.StatusNotModified, w.Code)
+				assert.Empty(t, w.Header().Get(HeaderContentType))
+			} else {
+				assert.Equal(t, MediaTypeJSON, w.Header().Get(HeaderContentType))
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package engine
 import (
 	"log"
 	"net/http"
+	"slices"
 
 	"github.com/PDOK/gokoala/config"
 	"github.com/PDOK/gokoala/engine/util"
@@ -24,23 +25,38 @@ const (
 	MediaTypeGeoJSON       = "application/geo+json"
 	MediaTypeJSONFG        = "application/vnd.ogc.fg+json" // https://docs.ogc.org/per/21-017r1.html#toc17
 	MediaTypeQuantizedMesh = "application/vnd.quantized-mesh"
+	MediaTypeCSV           = "text/csv"
+	MediaTypeFlatGeobuf    = "application/flatgeobuf"
+	MediaTypeGML           = "application/gml+xml;version=3.2"
+	MediaTypeXML           = "application/xml"
+	MediaTypePNG           = "image/png"
+	MediaTypeJPEG          = "image/jpeg"
+	MediaTypeWebP          = "image/webp"
 
-	FormatHTML           = "html"
-	FormatJSON           = "json"
-	FormatTileJSON       = "tilejson"
-	FormatMVT            = "mvt"
-	FormatMVTAlternative = "pbf"
-	FormatMapboxStyle    = "mapbox"
-	FormatSLD            = "sld10"
-	FormatGeoJSON        = "geojson" // ?=json should also work for geojson
-	FormatJSONFG         = "jsonfg"
-	FormatGzip           = "gzip"
+	FormatHTML            = "html"
+	FormatJSON            = "json"
+	FormatTileJSON        = "tilejson"
+	FormatMVT             = "mvt"
+	FormatMVTAlternative  = "pbf"
+	FormatMapboxStyle     = "mapbox"
+	FormatSLD             = "sld10"
+	FormatGeoJSON         = "geojson" // ?=json should also work for geojson
+	FormatJSONFG          = "jsonfg"
+	FormatCSV             = "csv"
+	FormatFlatGeobuf      = "flatgeobuf"
+	FormatGML             = "gml"
+	FormatXSD             = "xsd"
+	FormatGzip            = "gzip"
+	FormatPNG             = "png"
+	FormatJPEG            = "jpeg"
+	FormatJPEGAlternative = "jpg"
+	FormatWebP            = "webp"
 )
 
 var (
 	MediaTypeJSONFamily    = []string{MediaTypeTileJSON, MediaTypeMapboxStyle, MediaTypeGeoJSON, MediaTypeJSONFG}
 	OutputFormatDefault    = map[string]string{FormatJSON: "JSON"}
-	OutputFormatFeatures   = map[string]string{FormatJSON: "GeoJSON", FormatJSONFG: "JSON-FG"}
+	OutputFormatFeatures   = map[string]string{FormatJSON: "GeoJSON", FormatJSONFG: "JSON-FG", FormatCSV: "CSV", FormatFlatGeobuf: "FlatGeobuf", FormatGML: "GML"}
 	CompressibleMediaTypes = []string{
 		MediaTypeJSON,
 		MediaTypeGeoJSON,
@@ -49,6 +65,9 @@ var (
 		MediaTypeMapboxStyle,
 		MediaTypeOpenAPI,
 		MediaTypeHTML,
+		MediaTypeCSV,
+		MediaTypeXML,
+		"application/gml+xml", // without parameters, since these are ignored when compressing
 		// common web media types
 		"text/css",
 		"text/plain",
@@ -56,6 +75,10 @@ var (
 		"application/javascript",
 		"image/svg+xml",
 	}
+	FormatAlternatives = map[string]string{
+		FormatMVTAlternative:  FormatMVT,
+		FormatJPEGAlternative: FormatJPEG,
+	}
 	StyleFormatExtension = map[string]string{
 		FormatMapboxStyle: ".json",
 		FormatSLD:         ".sld",
@@ -81,6 +104,12 @@ func newContentNegotiation(availableLanguages []config.Language) *ContentNegotia
 		contenttype.NewMediaType(MediaTypeMVT),
 		contenttype.NewMediaType(MediaTypeMapboxStyle),
 		contenttype.NewMediaType(MediaTypeSLD),
+		contenttype.NewMediaType(MediaTypeCSV),
+		contenttype.NewMediaType(MediaTypeFlatGeobuf),
+		contenttype.NewMediaType(MediaTypeGML),
+		contenttype.NewMediaType(MediaTypePNG),
+		contenttype.NewMediaType(MediaTypeJPEG),
+		contenttype.NewMediaType(MediaTypeWebP),
 	}
 
 	formatsByMediaType := map[string]string{
@@ -92,6 +121,13 @@ func newContentNegotiation(availableLanguages []config.Language) *ContentNegotia
 		MediaTypeMVT:         FormatMVT,
 		MediaTypeMapboxStyle: FormatMapboxStyle,
 		MediaTypeSLD:         FormatSLD,
+		MediaTypeCSV:         FormatCSV,
+		MediaTypeFlatGeobuf:  FormatFlatGeobuf,
+		MediaTypeGML:         FormatGML,
+		MediaTypePNG:         FormatPNG,
+		MediaTypeJPEG:        FormatJPEG,
+		MediaTypeWebP:        FormatWebP,
+		MediaTypeXML:         FormatXSD, // only used for XML schemas, so not part of the available media types
 	}
 
 	mediaTypesByFormat := util.ReverseMap(formatsByMediaType)
@@ -132,6 +168,30 @@ func (cn *ContentNegotiation) NegotiateFormat(req *http.Request) string {
 	return requestedFormat
 }
 
+// NegotiateFormatOf performs content negotiation limited to the given formats, defaults to the first
+// given format. Returns an empty string when an unavailable format is requested using the ?f= param.
+// Not idempotent (since it removes the ?f= param)
+func (cn *ContentNegotiation) NegotiateFormatOf(req *http.Request, formats []string) string {
+	if requestedFormat := cn.getFormatFromQueryParam(req); requestedFormat != "" {
+		if alternative, ok := FormatAlternatives[requestedFormat]; ok {
+			requestedFormat = alternative
+		}
+		if slices.Contains(formats, requestedFormat) {
+			return requestedFormat
+		}
+		return ""
+	}
+	mediaTypes := make([]contenttype.MediaType, 0, len(formats))
+	for _, format := range formats {
+		mediaTypes = append(mediaTypes, contenttype.NewMediaType(cn.formatToMediaType(format)))
+	}
+	accepted, _, err := contenttype.GetAcceptableMediaType(req, mediaTypes)
+	if err != nil {
+		return formats[0] // default, also when nothing in the Accept header matches
+	}
+	return cn.formatsByMediaType[accepted.String()]
+}
+
 // NegotiateLanguage performs language negotiation, not idempotent (since it removes the ?lang= param)
 func (cn *ContentNegotiation) NegotiateLanguage(w http.ResponseWriter, req *http.Request) language.Tag {
 	requestedLanguage := cn.getLanguageFromQueryParam(w, req)
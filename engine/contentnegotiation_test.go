@@ -24,6 +24,14 @@ func TestContentNegotiation_NegotiateFormat(t *testing.T) {
 	testFormat(t, cn, "application/json", "http://pdok.example/ogc/api?f=json", "json")
 	testFormat(t, cn, "", "http://pdok.example/ogc/api?f=json", "json")
 	testFormat(t, cn, "application/xml, application/json, text/css, text/html", "http://pdok.example/ogc/api/", "json")
+	testFormat(t, cn, "text/csv", "http://pdok.example/ogc/api/collections/foo/items", "csv")
+	testFormat(t, cn, "", "http://pdok.example/ogc/api/collections/foo/items?f=csv", "csv")
+	testFormat(t, cn, "application/flatgeobuf", "http://pdok.example/ogc/api/collections/foo/items", "flatgeobuf")
+	testFormat(t, cn, "application/gml+xml", "http://pdok.example/ogc/api/collections/foo/items", "gml")
+	testFormat(t, cn, "application/gml+xml;version=3.2", "http://pdok.example/ogc/api/collections/foo/items", "gml")
+	testFormat(t, cn, "", "http://pdok.example/ogc/api/collections/foo/schema?f=xsd", "xsd")
+	testFormat(t, cn, "image/png", "http://pdok.example/ogc/api/tiles/foo/0/0/0", "png")
+	testFormat(t, cn, "image/webp,*/*;q=0.8", "http://pdok.example/ogc/api/tiles/foo/0/0/0", "webp")
 	testLanguage(t, cn, "nl;q=1", "http://pdok.example/ogc/api", language.Dutch)
 	testLanguage(t, cn, "fr;q=0.8, de;q=0.5", "http://pdok.example/ogc/api", language.Dutch)
 	testLanguage(t, cn, "en;q=1", "http://pdok.example/ogc/api", language.English)
@@ -33,6 +41,24 @@ func TestContentNegotiation_NegotiateFormat(t *testing.T) {
 	testLanguageWithCookie(t, cn, "en", "http://pdok.example/ogc/api", language.English)
 }
 
+func TestContentNegotiation_NegotiateFormatOf(t *testing.T) {
+	// given
+	cn := newContentNegotiation([]config.Language{{Tag: language.Dutch}})
+	tileFormats := []string{FormatMVT, FormatPNG}
+	imgAcceptHeader := "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8"
+
+	// when/then
+	testFormatOf(t, cn, "", "http://pdok.example/ogc/api/tiles/foo/0/0/0", tileFormats, "mvt")
+	testFormatOf(t, cn, "application/json", "http://pdok.example/ogc/api/tiles/foo/0/0/0", tileFormats, "mvt")
+	testFormatOf(t, cn, "application/vnd.mapbox-vector-tile", "http://pdok.example/ogc/api/tiles/foo/0/0/0", tileFormats, "mvt")
+	testFormatOf(t, cn, "image/png", "http://pdok.example/ogc/api/tiles/foo/0/0/0", tileFormats, "png")
+	testFormatOf(t, cn, imgAcceptHeader, "http://pdok.example/ogc/api/tiles/foo/0/0/0", tileFormats, "png")
+	testFormatOf(t, cn, "", "http://pdok.example/ogc/api/tiles/foo/0/0/0?f=pbf", tileFormats, "mvt")
+	testFormatOf(t, cn, "", "http://pdok.example/ogc/api/tiles/foo/0/0/0?f=png", tileFormats, "png")
+	testFormatOf(t, cn, "", "http://pdok.example/ogc/api/tiles/foo/0/0/0?f=jpg", []string{FormatJPEG}, "jpeg")
+	testFormatOf(t, cn, "image/png", "http://pdok.example/ogc/api/tiles/foo/0/0/0?f=webp", tileFormats, "")
+}
+
 func testFormat(t *testing.T, cn *ContentNegotiation, acceptHeader string, givenURL string, expectedFormat string) {
 	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, givenURL, nil)
 	req.Header.Set(HeaderAccept, acceptHeader)
@@ -45,6 +71,20 @@ func testFormat(t *testing.T, cn *ContentNegotiation, acceptHeader string, given
 	}
 }
 
+func testFormatOf(t *testing.T, cn *ContentNegotiation, acceptHeader string, givenURL string, formats []string, expectedFormat string) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, givenURL, nil)
+	if acceptHeader != "" {
+		req.Header.Set(HeaderAccept, acceptHeader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := cn.NegotiateFormatOf(req, formats)
+	if format != expectedFormat {
+		t.Fatalf("Expected %s for input %s, got %s", expectedFormat, givenURL, format)
+	}
+}
+
 func testLanguage(t *testing.T, cn *ContentNegotiation, acceptLanguageHeader string, givenURL string, expectedLanguage language.Tag) {
 	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, givenURL, nil)
 	req.Header.Set(HeaderAcceptLanguage, acceptLanguageHeader)
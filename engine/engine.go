@@ -31,13 +31,19 @@ const (
 	HeaderLink            = "Link"
 	HeaderAccept          = "Accept"
 	HeaderAcceptLanguage  = "Accept-Language"
+	HeaderAcceptEncoding  = "Accept-Encoding"
 	HeaderContentType     = "Content-Type"
 	HeaderContentLength   = "Content-Length"
 	HeaderContentCrs      = "Content-Crs"
 	HeaderContentEncoding = "Content-Encoding"
+	HeaderVary            = "Vary"
 	HeaderBaseURL         = "X-BaseUrl"
 	HeaderRequestedWith   = "X-Requested-With"
 	HeaderAPIVersion      = "API-Version"
+	HeaderETag            = "ETag"
+	HeaderLastModified    = "Last-Modified"
+	HeaderIfNoneMatch     = "If-None-Match"
+	HeaderIfModifiedSince = "If-Modified-Since"
 )
 
 // Engine encapsulates shared non-OGC API specific logic
@@ -47,6 +53,8 @@ type Engine struct {
 	Templates *Templates
 	CN        *ContentNegotiation
 	Router    *chi.Mux
+	// when the engine was built, rendered output (templates, config) may differ from before this moment
+	Started time.Time
 
 	shutdownHooks []func()
 }
@@ -73,6 +81,7 @@ func NewEngineWithConfig(config *config.Config, openAPIFile string, enableTraili
 		Templates: templates,
 		CN:        contentNegotiation,
 		Router:    router,
+		Started:   Now(),
 	}
 
 	if config.Resources != nil {
@@ -232,14 +241,18 @@ func (e *Engine) RenderAndServePage(w http.ResponseWriter, r *http.Request, key
 		return
 	}
 
-	// return response output to client
+	// return response output to client, unless the client already has it
+	if NotModified(w, r, NewETag(output), time.Time{}) {
+		return
+	}
 	if contentType != "" {
 		w.Header().Set(HeaderContentType, contentType)
 	}
 	SafeWrite(w.Write, output)
 }
 
-// ServePage serves a pre-rendered template while also validating against the OpenAPI spec
+// ServePage serves a pre-rendered template while also validating against the OpenAPI spec.
+// Since pre-rendered templates don't change at runtime, conditional requests are answered with 304 Not Modified.
 func (e *Engine) ServePage(w http.ResponseWriter, r *http.Request, templateKey TemplateKey) {
 	// validate request
 	if err := e.OpenAPI.ValidateRequest(r); err != nil {
@@ -264,7 +277,10 @@ func (e *Engine) ServePage(w http.ResponseWriter, r *http.Request, templateKey T
 		return
 	}
 
-	// return response output to client
+	// return response output to client, unless the client already has it
+	if NotModified(w, r, e.Templates.getRenderedETag(templateKey), time.Time{}) {
+		return
+	}
 	if contentType != "" {
 		w.Header().Set(HeaderContentType, contentType)
 	}
@@ -291,7 +307,10 @@ func (e *Engine) ServeResponse(w http.ResponseWriter, r *http.Request,
 		}
 	}
 
-	// return response output to client
+	// return response output to client, unless the client already has it
+	if NotModified(w, r, NewETag(response), time.Time{}) {
+		return
+	}
 	if contentType != "" {
 		w.Header().Set(HeaderContentType, contentType)
 	}
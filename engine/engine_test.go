@@ -55,6 +55,31 @@ func TestEngine_ServePage_LandingPage(t *testing.T) {
 	assert.Contains(t, recorder.Body.String(), "This is a minimal OGC API, offering only OGC API Common")
 }
 
+func TestEngine_ServePage_NotModified(t *testing.T) {
+	// given
+	engine, err := NewEngine("engine/testdata/config_minimal.yaml", "", false, true)
+	assert.NoError(t, err)
+
+	templateKey := NewTemplateKey("ogc/common/core/templates/landing-page.go.json")
+	engine.RenderTemplates("/", nil, templateKey)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://localhost:8080/", nil)
+	engine.ServePage(recorder, req, templateKey)
+	etag := recorder.Header().Get(HeaderETag)
+	assert.NotEmpty(t, etag)
+
+	// when
+	recorder = httptest.NewRecorder()
+	req.Header.Set(HeaderIfNoneMatch, etag)
+	engine.ServePage(recorder, req, templateKey)
+
+	// then
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+	assert.Equal(t, etag, recorder.Header().Get(HeaderETag))
+	assert.Empty(t, recorder.Body.String())
+}
+
 func TestEngine_ReverseProxy(t *testing.T) {
 	// given
 	mockTargetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
@@ -120,6 +121,25 @@ func setupRequestResponseValidation() {
 				return value, nil
 			})
 	}
+
+	openapi3filter.RegisterBodyDecoder(MediaTypeXML,
+		func(body io.Reader, _ http.Header, _ *openapi3.SchemaRef,
+			_ openapi3filter.EncodingFn) (any, error) {
+
+			data, err := io.ReadAll(body)
+			if err != nil {
+				return nil, errors.New("failed to read response body")
+			}
+			dec := xml.NewDecoder(bytes.NewReader(data))
+			for {
+				if _, err = dec.Token(); err != nil {
+					if errors.Is(err, io.EOF) {
+						return string(data), nil
+					}
+					return nil, errors.New("response doesn't contain valid XML")
+				}
+			}
+		})
 }
 
 // mergeSpecs merges the given OpenAPI specs.
@@ -182,6 +182,7 @@ func Test_newOpenAPI(t *testing.T) {
 												},
 											},
 										},
+										Sortables: []string{"street"},
 									},
 								},
 							},
@@ -195,6 +196,7 @@ func Test_newOpenAPI(t *testing.T) {
 				"/api",
 				"Vector Tiles",
 				"Features",
+				"sortby",
 			},
 		},
 	}
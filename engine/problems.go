@@ -18,11 +18,14 @@ var Now = time.Now // allow mocking
 
 // The following problems should be added to openapi/problems.go.json
 var (
-	ProblemBadRequest    = problem.Of(http.StatusBadRequest)
-	ProblemNotFound      = problem.Of(http.StatusNotFound)
-	ProblemNotAcceptable = problem.Of(http.StatusNotAcceptable)
-	ProblemServerError   = problem.Of(http.StatusInternalServerError).Append(problem.Detail(defaultMessageServerErr))
-	ProblemBadGateway    = problem.Of(http.StatusBadGateway).Append(problem.Detail(defaultMessageBadGateway))
+	ProblemBadRequest       = problem.Of(http.StatusBadRequest)
+	ProblemNotFound         = problem.Of(http.StatusNotFound)
+	ProblemMethodNotAllowed = problem.Of(http.StatusMethodNotAllowed)
+	ProblemNotAcceptable    = problem.Of(http.StatusNotAcceptable)
+	ProblemConflict         = problem.Of(http.StatusConflict)
+	ProblemPayloadTooLarge  = problem.Of(http.StatusRequestEntityTooLarge)
+	ProblemServerError      = problem.Of(http.StatusInternalServerError).Append(problem.Detail(defaultMessageServerErr))
+	ProblemBadGateway       = problem.Of(http.StatusBadGateway).Append(problem.Detail(defaultMessageBadGateway))
 )
 
 func RenderProblem(p *problem.Problem, w http.ResponseWriter, details ...string) {
@@ -23,8 +23,8 @@ func newRouter(version string, enableTrailingSlash bool, enableCORS bool) *chi.M
 		router.Use(cors.Handler(cors.Options{
 			AllowedOrigins:   []string{"*"},
 			AllowedMethods:   []string{http.MethodGet, http.MethodHead, http.MethodOptions},
-			AllowedHeaders:   []string{HeaderRequestedWith},
-			ExposedHeaders:   []string{HeaderContentCrs, HeaderLink},
+			AllowedHeaders:   []string{HeaderRequestedWith, HeaderIfNoneMatch, HeaderIfModifiedSince},
+			ExposedHeaders:   []string{HeaderContentCrs, HeaderLink, HeaderETag},
 			AllowCredentials: false,
 			MaxAge:           int((time.Hour * 24).Seconds()),
 		}))
@@ -144,6 +144,9 @@ type Templates struct {
 	// We prefer pre-rendered templates whenever possible. These are stored in this map.
 	RenderedTemplates map[TemplateKey][]byte
 
+	// renderedETags (weak) ETags of the pre-rendered templates, to answer conditional requests
+	renderedETags map[TemplateKey]string
+
 	config     *config.Config
 	localizers map[language.Tag]i18n.Localizer
 }
@@ -152,6 +155,7 @@ func newTemplates(config *config.Config) *Templates {
 	templates := &Templates{
 		ParsedTemplates:   make(map[TemplateKey]any),
 		RenderedTemplates: make(map[TemplateKey][]byte),
+		renderedETags:     make(map[TemplateKey]string),
 		config:            config,
 		localizers:        newLocalizers(config.AvailableLanguages),
 	}
@@ -172,6 +176,10 @@ func (t *Templates) getRenderedTemplate(key TemplateKey) ([]byte, error) {
 	return nil, fmt.Errorf("no rendered template with name %s", key.Name)
 }
 
+func (t *Templates) getRenderedETag(key TemplateKey) string {
+	return t.renderedETags[key]
+}
+
 func (t *Templates) parseAndSaveTemplate(key TemplateKey) {
 	for lang := range t.localizers {
 		keyWithLang := ExpandTemplateKey(key, lang)
@@ -199,6 +207,7 @@ func (t *Templates) renderAndSaveTemplate(key TemplateKey, breadcrumbs []Breadcr
 		// Store rendered template per language
 		key.Language = lang
 		t.RenderedTemplates[key] = result
+		t.renderedETags[key] = NewETag(result)
 	}
 }
 
@@ -123,18 +123,19 @@ func setupOGCBuildingBlocks(engine *eng.Engine) {
 	if engine.Config.OgcAPI.GeoVolumes != nil {
 		geovolumes.NewThreeDimensionalGeoVolumes(engine)
 	}
+	// OGC Features API, before tiles since tiles may be generated from features
+	var f *features.Features
+	if engine.Config.OgcAPI.Features != nil {
+		f = features.NewFeatures(engine)
+	}
 	// OGC Tiles API
 	if engine.Config.OgcAPI.Tiles != nil {
-		tiles.NewTiles(engine)
+		tiles.NewTiles(engine, f)
 	}
 	// OGC Styles API
 	if engine.Config.OgcAPI.Styles != nil {
 		styles.NewStyles(engine)
 	}
-	// OGC Features API
-	if engine.Config.OgcAPI.Features != nil {
-		features.NewFeatures(engine)
-	}
 	// OGC Processes API
 	if engine.Config.OgcAPI.Processes != nil {
 		processes.NewProcesses(engine)
@@ -0,0 +1,343 @@
+package cql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-spatial/geom"
+)
+
+// Expression is a boolean (filter) expression. This is the root of the datasource-neutral
+// abstract syntax tree (AST) produced by parsing a CQL2 filter. Datasources are expected to
+// translate this AST to their native query language (e.g. SQL).
+type Expression interface {
+	expression()
+}
+
+// Operand is a value used in a predicate: a property, literal, etc.
+type Operand interface {
+	operand()
+}
+
+// --- boolean expressions
+
+// And logical conjunction of two or more expressions
+type And struct {
+	Children []Expression
+}
+
+// Or logical disjunction of two or more expressions
+type Or struct {
+	Children []Expression
+}
+
+// Not logical negation of an expression
+type Not struct {
+	Child Expression
+}
+
+// BooleanLiteral the literal true or false used as a filter expression
+type BooleanLiteral struct {
+	Value bool
+}
+
+// ComparisonOperator binary comparison operator (=, <>, <, >, <=, >=)
+type ComparisonOperator string
+
+const (
+	Equal              ComparisonOperator = "="
+	NotEqual           ComparisonOperator = "<>"
+	LessThan           ComparisonOperator = "<"
+	GreaterThan        ComparisonOperator = ">"
+	LessThanOrEqual    ComparisonOperator = "<="
+	GreaterThanOrEqual ComparisonOperator = ">="
+)
+
+// Comparison binary comparison predicate
+type Comparison struct {
+	Operator ComparisonOperator
+	Left     Operand
+	Right    Operand
+}
+
+// Like pattern matching predicate, % matches zero or more characters, _ matches a single character
+type Like struct {
+	Value   Operand
+	Pattern Operand
+	Negate  bool
+}
+
+// Between range predicate (inclusive)
+type Between struct {
+	Value  Operand
+	Lower  Operand
+	Upper  Operand
+	Negate bool
+}
+
+// In list membership predicate
+type In struct {
+	Value  Operand
+	List   []Operand
+	Negate bool
+}
+
+// IsNull null check predicate
+type IsNull struct {
+	Value  Operand
+	Negate bool
+}
+
+// SpatialOperator spatial comparison function (DE-9IM), see https://docs.ogc.org/is/21-065r2/21-065r2.html#spatial-functions
+type SpatialOperator string
+
+const (
+	SIntersects SpatialOperator = "S_INTERSECTS"
+	SEquals     SpatialOperator = "S_EQUALS"
+	SDisjoint   SpatialOperator = "S_DISJOINT"
+	STouches    SpatialOperator = "S_TOUCHES"
+	SWithin     SpatialOperator = "S_WITHIN"
+	SOverlaps   SpatialOperator = "S_OVERLAPS"
+	SCrosses    SpatialOperator = "S_CROSSES"
+	SContains   SpatialOperator = "S_CONTAINS"
+)
+
+// SpatialPredicate spatial comparison between two geometries (properties or literals)
+type SpatialPredicate struct {
+	Operator SpatialOperator
+	Left     Operand
+	Right    Operand
+}
+
+// TemporalOperator temporal comparison function, see https://docs.ogc.org/is/21-065r2/21-065r2.html#temporal-functions
+type TemporalOperator string
+
+const (
+	TAfter        TemporalOperator = "T_AFTER"
+	TBefore       TemporalOperator = "T_BEFORE"
+	TContains     TemporalOperator = "T_CONTAINS"
+	TDisjoint     TemporalOperator = "T_DISJOINT"
+	TDuring       TemporalOperator = "T_DURING"
+	TEquals       TemporalOperator = "T_EQUALS"
+	TFinishedBy   TemporalOperator = "T_FINISHEDBY"
+	TFinishes     TemporalOperator = "T_FINISHES"
+	TIntersects   TemporalOperator = "T_INTERSECTS"
+	TMeets        TemporalOperator = "T_MEETS"
+	TMetBy        TemporalOperator = "T_METBY"
+	TOverlappedBy TemporalOperator = "T_OVERLAPPEDBY"
+	TOverlaps     TemporalOperator = "T_OVERLAPS"
+	TStartedBy    TemporalOperator = "T_STARTEDBY"
+	TStarts       TemporalOperator = "T_STARTS"
+)
+
+// TemporalPredicate temporal comparison between two instants or intervals
+type TemporalPredicate struct {
+	Operator TemporalOperator
+	Left     Operand
+	Right    Operand
+}
+
+func (And) expression()               {}
+func (Or) expression()                {}
+func (Not) expression()               {}
+func (BooleanLiteral) expression()    {}
+func (Comparison) expression()        {}
+func (Like) expression()              {}
+func (Between) expression()           {}
+func (In) expression()                {}
+func (IsNull) expression()            {}
+func (SpatialPredicate) expression()  {}
+func (TemporalPredicate) expression() {}
+
+// --- operands
+
+// Property reference to a property (column) of a feature
+type Property struct {
+	Name string
+}
+
+// String literal
+type String struct {
+	Value string
+}
+
+// Number literal
+type Number struct {
+	Value float64
+}
+
+// Boolean literal
+type Boolean struct {
+	Value bool
+}
+
+// Geometry literal, either from WKT, GeoJSON or a BBOX
+type Geometry struct {
+	Geometry geom.Geometry
+}
+
+// Date literal (without time)
+type Date struct {
+	Value time.Time
+}
+
+// Timestamp literal (UTC)
+type Timestamp struct {
+	Value time.Time
+}
+
+// Unbounded open start or end of an interval ("..")
+type Unbounded struct{}
+
+// Interval time interval between two instants. Start and End are either a
+// Date, Timestamp, Property, String or Unbounded.
+type Interval struct {
+	Start Operand
+	End   Operand
+}
+
+func (Property) operand()  {}
+func (String) operand()    {}
+func (Number) operand()    {}
+func (Boolean) operand()   {}
+func (Geometry) operand()  {}
+func (Date) operand()      {}
+func (Timestamp) operand() {}
+func (Unbounded) operand() {}
+func (Interval) operand()  {}
+
+// Properties returns the names of all properties referenced in the given expression
+func Properties(expr Expression) []string {
+	result := make([]string, 0)
+	seen := make(map[string]bool)
+	Walk(expr, func(op Operand) {
+		if p, ok := op.(Property); ok && !seen[p.Name] {
+			seen[p.Name] = true
+			result = append(result, p.Name)
+		}
+	})
+	return result
+}
+
+// HasGeometryLiteral returns true when the given expression contains a geometry literal (and therefore
+// depends on the filter-crs), false otherwise
+func HasGeometryLiteral(expr Expression) bool {
+	result := false
+	Walk(expr, func(op Operand) {
+		if _, ok := op.(Geometry); ok {
+			result = true
+		}
+	})
+	return result
+}
+
+// MapGeometries returns a copy of the given expression in which each geometry literal is replaced by
+// the result of the given function, e.g. to reproject geometry literals to the CRS of a datasource
+//
+//nolint:cyclop
+func MapGeometries(expr Expression, fn func(geom.Geometry) (geom.Geometry, error)) (Expression, error) {
+	var err error
+	mapOperand := func(op Operand) Operand {
+		if g, ok := op.(Geometry); ok && err == nil {
+			var mapped geom.Geometry
+			if mapped, err = fn(g.Geometry); err == nil {
+				return Geometry{Geometry: mapped}
+			}
+		}
+		return op
+	}
+	mapChildren := func(children []Expression) []Expression {
+		result := make([]Expression, 0, len(children))
+		for _, child := range children {
+			mapped, childErr := MapGeometries(child, fn)
+			if childErr != nil && err == nil {
+				err = childErr
+			}
+			result = append(result, mapped)
+		}
+		return result
+	}
+
+	var result Expression
+	switch e := expr.(type) {
+	case And:
+		result = And{Children: mapChildren(e.Children)}
+	case Or:
+		result = Or{Children: mapChildren(e.Children)}
+	case Not:
+		result = Not{Child: mapChildren([]Expression{e.Child})[0]}
+	case Comparison:
+		result = Comparison{Operator: e.Operator, Left: mapOperand(e.Left), Right: mapOperand(e.Right)}
+	case In:
+		list := make([]Operand, 0, len(e.List))
+		for _, op := range e.List {
+			list = append(list, mapOperand(op))
+		}
+		result = In{Value: mapOperand(e.Value), List: list, Negate: e.Negate}
+	case Like:
+		result = Like{Value: mapOperand(e.Value), Pattern: mapOperand(e.Pattern), Negate: e.Negate}
+	case Between:
+		result = Between{Value: mapOperand(e.Value), Lower: mapOperand(e.Lower), Upper: mapOperand(e.Upper), Negate: e.Negate}
+	case IsNull:
+		result = IsNull{Value: mapOperand(e.Value), Negate: e.Negate}
+	case SpatialPredicate:
+		result = SpatialPredicate{Operator: e.Operator, Left: mapOperand(e.Left), Right: mapOperand(e.Right)}
+	case TemporalPredicate:
+		result = TemporalPredicate{Operator: e.Operator, Left: mapOperand(e.Left), Right: mapOperand(e.Right)}
+	default:
+		result = expr
+	}
+	return result, err
+}
+
+// Walk visits all operands in the given expression (depth-first)
+//
+//nolint:cyclop
+func Walk(expr Expression, visit func(Operand)) {
+	walkOperands := func(ops ...Operand) {
+		for _, op := range ops {
+			if interval, ok := op.(Interval); ok {
+				visit(interval.Start)
+				visit(interval.End)
+			}
+			visit(op)
+		}
+	}
+	switch e := expr.(type) {
+	case And:
+		for _, child := range e.Children {
+			Walk(child, visit)
+		}
+	case Or:
+		for _, child := range e.Children {
+			Walk(child, visit)
+		}
+	case Not:
+		Walk(e.Child, visit)
+	case Comparison:
+		walkOperands(e.Left, e.Right)
+	case Like:
+		walkOperands(e.Value, e.Pattern)
+	case Between:
+		walkOperands(e.Value, e.Lower, e.Upper)
+	case In:
+		walkOperands(e.Value)
+		walkOperands(e.List...)
+	case IsNull:
+		walkOperands(e.Value)
+	case SpatialPredicate:
+		walkOperands(e.Left, e.Right)
+	case TemporalPredicate:
+		walkOperands(e.Left, e.Right)
+	}
+}
+
+// UnsupportedOperatorError is returned when a filter contains an operator or function
+// that is valid CQL2 but isn't (yet) supported by this implementation
+type UnsupportedOperatorError struct {
+	Operator string
+}
+
+func (e UnsupportedOperatorError) Error() string {
+	return fmt.Sprintf("operator '%s' is not supported", e.Operator)
+}
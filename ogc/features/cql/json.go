@@ -0,0 +1,373 @@
+package cql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/geojson"
+)
+
+// ParseJSON parses a CQL2-JSON filter (Basic CQL2, spatial and temporal functions)
+// to the same datasource-neutral AST as ParseText. See https://docs.ogc.org/is/21-065r2/21-065r2.html#cql2-json
+func ParseJSON(input string) (Expression, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, errEmptyFilter
+	}
+	decoder := json.NewDecoder(strings.NewReader(input))
+	decoder.UseNumber()
+	var node any
+	if err := decoder.Decode(&node); err != nil {
+		return nil, fmt.Errorf("invalid CQL2-JSON filter: %w", err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("invalid CQL2-JSON filter: unexpected data after filter")
+	}
+	expr, err := parseJSONExpression(node)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CQL2-JSON filter: %w", err)
+	}
+	return expr, nil
+}
+
+// operation {"op": "...", "args": [...]}
+type jsonOperation struct {
+	op   string
+	args []any
+}
+
+func toJSONOperation(node any) (*jsonOperation, bool, error) {
+	obj, ok := node.(map[string]any)
+	if !ok {
+		return nil, false, nil
+	}
+	rawOp, ok := obj["op"]
+	if !ok {
+		return nil, false, nil
+	}
+	op, ok := rawOp.(string)
+	if !ok {
+		return nil, true, fmt.Errorf("expected string as op, got %v", rawOp)
+	}
+	args, ok := obj["args"].([]any)
+	if !ok {
+		return nil, true, fmt.Errorf("expected array of args for operator '%s'", op)
+	}
+	return &jsonOperation{op: op, args: args}, true, nil
+}
+
+func (o *jsonOperation) expectArgs(count int) error {
+	if len(o.args) != count {
+		return fmt.Errorf("operator '%s' expects %d argument(s), got %d", o.op, count, len(o.args))
+	}
+	return nil
+}
+
+//nolint:cyclop,funlen
+func parseJSONExpression(node any) (Expression, error) {
+	if b, ok := node.(bool); ok {
+		return BooleanLiteral{Value: b}, nil
+	}
+	o, ok, err := toJSONOperation(node)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("expected boolean expression (object with 'op' and 'args' or true/false), got %s", describeJSON(node))
+	}
+
+	switch strings.ToLower(o.op) {
+	case "and", "or":
+		if len(o.args) < 2 {
+			return nil, fmt.Errorf("operator '%s' expects at least 2 arguments, got %d", o.op, len(o.args))
+		}
+		children := make([]Expression, 0, len(o.args))
+		for _, arg := range o.args {
+			child, err := parseJSONExpression(arg)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, child)
+		}
+		if strings.EqualFold(o.op, "and") {
+			return And{Children: children}, nil
+		}
+		return Or{Children: children}, nil
+	case "not":
+		if err = o.expectArgs(1); err != nil {
+			return nil, err
+		}
+		child, err := parseJSONExpression(o.args[0])
+		if err != nil {
+			return nil, err
+		}
+		return Not{Child: child}, nil
+	case string(Equal), string(NotEqual), string(LessThan), string(GreaterThan), string(LessThanOrEqual), string(GreaterThanOrEqual):
+		operands, err := parseJSONScalars(o, 2)
+		if err != nil {
+			return nil, err
+		}
+		return Comparison{Operator: ComparisonOperator(o.op), Left: operands[0], Right: operands[1]}, nil
+	case "like":
+		operands, err := parseJSONScalars(o, 2)
+		if err != nil {
+			return nil, err
+		}
+		return Like{Value: operands[0], Pattern: operands[1]}, nil
+	case "between":
+		operands, err := parseJSONScalars(o, 3)
+		if err != nil {
+			return nil, err
+		}
+		return Between{Value: operands[0], Lower: operands[1], Upper: operands[2]}, nil
+	case "isnull":
+		operands, err := parseJSONScalars(o, 1)
+		if err != nil {
+			return nil, err
+		}
+		return IsNull{Value: operands[0]}, nil
+	case "in":
+		return parseJSONIn(o)
+	}
+
+	if op, ok := spatialOperators[strings.ToUpper(o.op)]; ok {
+		left, right, err := parseJSONFunctionArgs(o, assertSpatial)
+		if err != nil {
+			return nil, err
+		}
+		return SpatialPredicate{Operator: op, Left: left, Right: right}, nil
+	}
+	if op, ok := temporalOperators[strings.ToUpper(o.op)]; ok {
+		left, right, err := parseJSONFunctionArgs(o, assertTemporal)
+		if err != nil {
+			return nil, err
+		}
+		return TemporalPredicate{Operator: op, Left: left, Right: right}, nil
+	}
+	return nil, UnsupportedOperatorError{Operator: o.op}
+}
+
+func parseJSONScalars(o *jsonOperation, count int) ([]Operand, error) {
+	if err := o.expectArgs(count); err != nil {
+		return nil, err
+	}
+	operands := make([]Operand, 0, count)
+	for _, arg := range o.args {
+		operand, err := parseJSONOperand(arg)
+		if err != nil {
+			return nil, err
+		}
+		if err = assertScalar(operand); err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	return operands, nil
+}
+
+// {"op": "in", "args": [{"property": "foo"}, ["a", "b"]]}
+func parseJSONIn(o *jsonOperation) (Expression, error) {
+	if err := o.expectArgs(2); err != nil {
+		return nil, err
+	}
+	values, ok := o.args[1].([]any)
+	if !ok || len(values) == 0 {
+		return nil, fmt.Errorf("operator '%s' expects a non-empty array as second argument", o.op)
+	}
+	value, err := parseJSONOperand(o.args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err = assertScalar(value); err != nil {
+		return nil, err
+	}
+	list := make([]Operand, 0, len(values))
+	for _, v := range values {
+		item, err := parseJSONOperand(v)
+		if err != nil {
+			return nil, err
+		}
+		if err = assertScalar(item); err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return In{Value: value, List: list}, nil
+}
+
+func parseJSONFunctionArgs(o *jsonOperation, assert func(Operand) error) (Operand, Operand, error) {
+	if err := o.expectArgs(2); err != nil {
+		return nil, nil, err
+	}
+	operands := make([]Operand, 0, 2)
+	for _, arg := range o.args {
+		operand, err := parseJSONOperand(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = assert(operand); err != nil {
+			return nil, nil, err
+		}
+		operands = append(operands, operand)
+	}
+	return operands[0], operands[1], nil
+}
+
+//nolint:cyclop
+func parseJSONOperand(node any) (Operand, error) {
+	switch v := node.(type) {
+	case string:
+		return String{Value: v}, nil
+	case json.Number:
+		number, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s'", v)
+		}
+		return Number{Value: number}, nil
+	case bool:
+		return Boolean{Value: v}, nil
+	case map[string]any:
+		if o, ok, err := toJSONOperation(v); ok {
+			if err != nil {
+				return nil, err
+			}
+			// functions (e.g. casei, accenti) and arithmetic aren't supported as operands
+			return nil, UnsupportedOperatorError{Operator: o.op}
+		}
+		if property, ok := v["property"]; ok {
+			name, ok := property.(string)
+			if !ok || name == "" {
+				return nil, fmt.Errorf("expected property name as string, got %v", property)
+			}
+			return Property{Name: name}, nil
+		}
+		if date, ok := v["date"]; ok {
+			value, ok := date.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected date as string, got %v", date)
+			}
+			return parseDate(value)
+		}
+		if timestamp, ok := v["timestamp"]; ok {
+			value, ok := timestamp.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected timestamp as string, got %v", timestamp)
+			}
+			return parseTimestamp(value)
+		}
+		if interval, ok := v["interval"]; ok {
+			return parseJSONInterval(interval)
+		}
+		if bbox, ok := v["bbox"]; ok {
+			return parseJSONBbox(bbox)
+		}
+		if _, ok := v["type"]; ok {
+			return parseGeoJSON(v)
+		}
+	}
+	return nil, fmt.Errorf("expected property, literal, geometry, date, timestamp or interval as operand, got %s", describeJSON(node))
+}
+
+// {"interval": ["2020-01-01", ".."]}
+func parseJSONInterval(node any) (Operand, error) {
+	boundaries, ok := node.([]any)
+	if !ok || len(boundaries) != 2 {
+		return nil, errors.New("interval should contain exactly 2 values (start, end)")
+	}
+	operands := make([]Operand, 0, 2)
+	for _, boundary := range boundaries {
+		var operand Operand
+		var err error
+		if value, ok := boundary.(string); ok {
+			operand, err = parseIntervalBoundary(value)
+		} else {
+			operand, err = parseJSONOperand(boundary)
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch operand.(type) {
+		case Property, Date, Timestamp, Unbounded:
+			operands = append(operands, operand)
+		default:
+			return nil, fmt.Errorf("expected date, timestamp, property or '..' as interval boundary, got %T", operand)
+		}
+	}
+	return Interval{Start: operands[0], End: operands[1]}, nil
+}
+
+// {"bbox": [minx, miny, maxx, maxy]}
+func parseJSONBbox(node any) (Operand, error) {
+	values, ok := node.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected bbox as array of numbers, got %v", node)
+	}
+	coords := make([]float64, 0, len(values))
+	for _, value := range values {
+		number, ok := value.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("expected bbox as array of numbers, got %v", value)
+		}
+		coord, err := number.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s'", number)
+		}
+		coords = append(coords, coord)
+	}
+	return bboxToGeometry(coords)
+}
+
+// {"type": "Point", "coordinates": [1, 2]}
+func parseGeoJSON(node map[string]any) (Operand, error) {
+	raw, err := json.Marshal(node)
+	if err != nil {
+		return nil, err
+	}
+	var geometry geojson.Geometry
+	if err = json.Unmarshal(raw, &geometry); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON geometry: %w", err)
+	}
+	return Geometry{Geometry: openRings(geometry.Geometry)}, nil
+}
+
+// GeoJSON polygon rings are closed (first and last position are equal), while
+// WKT-decoded rings (and the rest of go-spatial/geom) assume an implicitly closed ring
+func openRings(geometry geom.Geometry) geom.Geometry {
+	openRing := func(ring [][2]float64) [][2]float64 {
+		if len(ring) > 3 && ring[0] == ring[len(ring)-1] {
+			return ring[:len(ring)-1]
+		}
+		return ring
+	}
+	switch g := geometry.(type) {
+	case geom.Polygon:
+		for i := range g {
+			g[i] = openRing(g[i])
+		}
+		return g
+	case geom.MultiPolygon:
+		for i := range g {
+			for j := range g[i] {
+				g[i][j] = openRing(g[i][j])
+			}
+		}
+		return g
+	case geom.Collection:
+		for i := range g {
+			g[i] = openRings(g[i])
+		}
+		return g
+	}
+	return geometry
+}
+
+func describeJSON(node any) string {
+	raw, err := json.Marshal(node)
+	if err != nil {
+		return fmt.Sprintf("%v", node)
+	}
+	return string(raw)
+}
@@ -0,0 +1,143 @@
+package cql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-spatial/geom"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		want    Expression
+		wantErr string
+	}{
+		{
+			name:   "comparison",
+			filter: `{"op": "=", "args": [{"property": "straatnaam"}, "Silodam"]}`,
+			want:   Comparison{Operator: Equal, Left: Property{Name: "straatnaam"}, Right: String{Value: "Silodam"}},
+		},
+		{
+			name: "and/or/not",
+			filter: `{"op": "and", "args": [
+				{"op": ">=", "args": [{"property": "huisnummer"}, -5.5]},
+				{"op": "not", "args": [{"op": "or", "args": [
+					{"op": "=", "args": [{"property": "b"}, true]},
+					false
+				]}]}
+			]}`,
+			want: And{Children: []Expression{
+				Comparison{Operator: GreaterThanOrEqual, Left: Property{Name: "huisnummer"}, Right: Number{Value: -5.5}},
+				Not{Child: Or{Children: []Expression{
+					Comparison{Operator: Equal, Left: Property{Name: "b"}, Right: Boolean{Value: true}},
+					BooleanLiteral{Value: false},
+				}}},
+			}},
+		},
+		{
+			name: "like, between, in, isNull",
+			filter: `{"op": "and", "args": [
+				{"op": "like", "args": [{"property": "a"}, "Silo%"]},
+				{"op": "between", "args": [{"property": "b"}, 1, 10]},
+				{"op": "in", "args": [{"property": "c"}, ["x", "y"]]},
+				{"op": "not", "args": [{"op": "isNull", "args": [{"property": "d"}]}]}
+			]}`,
+			want: And{Children: []Expression{
+				Like{Value: Property{Name: "a"}, Pattern: String{Value: "Silo%"}},
+				Between{Value: Property{Name: "b"}, Lower: Number{Value: 1}, Upper: Number{Value: 10}},
+				In{Value: Property{Name: "c"}, List: []Operand{String{Value: "x"}, String{Value: "y"}}},
+				Not{Child: IsNull{Value: Property{Name: "d"}}},
+			}},
+		},
+		{
+			name:   "spatial function with GeoJSON",
+			filter: `{"op": "s_intersects", "args": [{"property": "geom"}, {"type": "Polygon", "coordinates": [[[0, 0], [0, 1], [1, 1], [1, 0], [0, 0]]]}]}`,
+			want: SpatialPredicate{Operator: SIntersects, Left: Property{Name: "geom"},
+				Right: Geometry{Geometry: geom.Polygon{{{0, 0}, {0, 1}, {1, 1}, {1, 0}}}}},
+		},
+		{
+			name:   "spatial function with bbox",
+			filter: `{"op": "s_within", "args": [{"property": "geom"}, {"bbox": [4.1, 52.0, 4.2, 52.1]}]}`,
+			want: SpatialPredicate{Operator: SWithin, Left: Property{Name: "geom"},
+				Right: Geometry{Geometry: geom.Polygon{{{4.1, 52.0}, {4.2, 52.0}, {4.2, 52.1}, {4.1, 52.1}}}}},
+		},
+		{
+			name: "temporal functions",
+			filter: `{"op": "or", "args": [
+				{"op": "t_intersects", "args": [{"interval": [{"property": "validfrom"}, {"property": "validto"}]}, {"interval": ["2020-01-01", ".."]}]},
+				{"op": "t_after", "args": [{"property": "updated"}, {"timestamp": "2023-01-01T12:00:00+01:00"}]},
+				{"op": ">", "args": [{"property": "created"}, {"date": "2022-12-31"}]}
+			]}`,
+			want: Or{Children: []Expression{
+				TemporalPredicate{Operator: TIntersects,
+					Left:  Interval{Start: Property{Name: "validfrom"}, End: Property{Name: "validto"}},
+					Right: Interval{Start: Date{Value: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}, End: Unbounded{}}},
+				TemporalPredicate{Operator: TAfter, Left: Property{Name: "updated"},
+					Right: Timestamp{Value: time.Date(2023, 1, 1, 11, 0, 0, 0, time.UTC)}},
+				Comparison{Operator: GreaterThan, Left: Property{Name: "created"},
+					Right: Date{Value: time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC)}},
+			}},
+		},
+		{
+			name:    "fail on empty filter",
+			filter:  "  ",
+			wantErr: "filter is empty",
+		},
+		{
+			name:    "fail on invalid JSON",
+			filter:  `{"op": "=", "args": [`,
+			wantErr: "invalid CQL2-JSON filter: unexpected EOF",
+		},
+		{
+			name:    "fail on trailing data",
+			filter:  `true false`,
+			wantErr: "invalid CQL2-JSON filter: unexpected data after filter",
+		},
+		{
+			name:    "fail on unsupported operator",
+			filter:  `{"op": "a_contains", "args": [{"property": "tags"}, ["a"]]}`,
+			wantErr: "invalid CQL2-JSON filter: operator 'a_contains' is not supported",
+		},
+		{
+			name:    "fail on unsupported function as operand",
+			filter:  `{"op": "=", "args": [{"op": "casei", "args": [{"property": "name"}]}, "foo"]}`,
+			wantErr: "invalid CQL2-JSON filter: operator 'casei' is not supported",
+		},
+		{
+			name:    "fail on wrong number of arguments",
+			filter:  `{"op": "between", "args": [{"property": "a"}, 1]}`,
+			wantErr: "invalid CQL2-JSON filter: operator 'between' expects 3 argument(s), got 2",
+		},
+		{
+			name:    "fail on operand as expression",
+			filter:  `{"property": "a"}`,
+			wantErr: `invalid CQL2-JSON filter: expected boolean expression (object with 'op' and 'args' or true/false), got {"property":"a"}`,
+		},
+		{
+			name:    "fail on geometry in comparison",
+			filter:  `{"op": "=", "args": [{"property": "geom"}, {"type": "Point", "coordinates": [1, 2]}]}`,
+			wantErr: "invalid CQL2-JSON filter: geometry can only be used as argument of a spatial function",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseJSON(tt.filter)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseJSON_UnsupportedOperator(t *testing.T) {
+	_, err := ParseJSON(`{"op": "t_foo", "args": [{"property": "a"}, {"date": "2020-01-01"}]}`)
+	var unsupported UnsupportedOperatorError
+	assert.ErrorAs(t, err, &unsupported)
+	assert.Equal(t, "t_foo", unsupported.Operator)
+}
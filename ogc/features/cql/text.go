@@ -0,0 +1,634 @@
+package cql
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/wkt"
+)
+
+// ParseText parses a CQL2-Text filter (Basic CQL2, spatial and temporal functions)
+// to a datasource-neutral AST. See https://docs.ogc.org/is/21-065r2/21-065r2.html#cql2-text
+func ParseText(input string) (Expression, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, errEmptyFilter
+	}
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CQL2-Text filter: %w", err)
+	}
+	p := &textParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CQL2-Text filter: %w", err)
+	}
+	if !p.atEnd() {
+		return nil, fmt.Errorf("invalid CQL2-Text filter: unexpected '%s' at position %d", p.peek().text, p.peek().pos)
+	}
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokenIdentifier tokenKind = iota
+	tokenQuotedIdentifier
+	tokenString
+	tokenNumber
+	tokenGeometry
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+	tokenEOF
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var wktTypes = []string{"POINT", "LINESTRING", "POLYGON", "MULTIPOINT",
+	"MULTILINESTRING", "MULTIPOLYGON", "GEOMETRYCOLLECTION"}
+
+//nolint:cyclop,funlen
+func lex(input string) ([]token, error) {
+	tokens := make([]token, 0)
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokenLeftParen, "(", i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenRightParen, ")", i})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{tokenComma, ",", i})
+			i++
+		case r == '=':
+			tokens = append(tokens, token{tokenOperator, "=", i})
+			i++
+		case r == '<' || r == '>':
+			op := string(r)
+			if i+1 < len(runes) && (runes[i+1] == '=' || (r == '<' && runes[i+1] == '>')) {
+				op += string(runes[i+1])
+			}
+			tokens = append(tokens, token{tokenOperator, op, i})
+			i += len(op)
+		case r == '\'':
+			// string literal, a single quote is escaped by another single quote
+			var sb strings.Builder
+			start := i
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated string starting at position %d", start)
+				}
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						sb.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, token{tokenString, sb.String(), start})
+		case r == '"':
+			// quoted property name
+			start := i
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated quoted property starting at position %d", start)
+			}
+			tokens = append(tokens, token{tokenQuotedIdentifier, string(runes[i+1 : end]), start})
+			i = end + 1
+		case unicode.IsDigit(r) || ((r == '-' || r == '+' || r == '.') && i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.')):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E' ||
+				((runes[i] == '-' || runes[i] == '+') && (runes[i-1] == 'e' || runes[i-1] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[start:i]), start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) ||
+				runes[i] == '_' || runes[i] == '.' || runes[i] == ':') {
+				i++
+			}
+			ident := string(runes[start:i])
+			if isWKTType(ident) {
+				// consume the whole WKT geometry (up to and including the balanced closing paren)
+				end, err := endOfWKT(runes, i)
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, token{tokenGeometry, string(runes[start:end]), start})
+				i = end
+				continue
+			}
+			tokens = append(tokens, token{tokenIdentifier, ident, start})
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' at position %d", r, i)
+		}
+	}
+	tokens = append(tokens, token{tokenEOF, "end of filter", len(runes)})
+	return tokens, nil
+}
+
+func isWKTType(ident string) bool {
+	for _, t := range wktTypes {
+		if strings.EqualFold(ident, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func endOfWKT(runes []rune, i int) (int, error) {
+	start := i
+	for i < len(runes) && unicode.IsSpace(runes[i]) {
+		i++
+	}
+	if i+5 <= len(runes) && strings.EqualFold(string(runes[i:i+5]), "EMPTY") {
+		return i + 5, nil
+	}
+	depth := 0
+	for ; i < len(runes); i++ {
+		switch runes[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		}
+	}
+	return -1, fmt.Errorf("unterminated geometry at position %d", start)
+}
+
+type textParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *textParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *textParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *textParser) atEnd() bool {
+	return p.peek().kind == tokenEOF
+}
+
+// isKeyword returns true when the next token is the given keyword (case-insensitive)
+func (p *textParser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenIdentifier && strings.EqualFold(t.text, keyword)
+}
+
+func (p *textParser) acceptKeyword(keyword string) bool {
+	if p.isKeyword(keyword) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *textParser) expect(kind tokenKind, description string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, fmt.Errorf("expected %s but got '%s' at position %d", description, t.text, t.pos)
+	}
+	return t, nil
+}
+
+func (p *textParser) parseOr() (Expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []Expression{left}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return Or{Children: children}, nil
+}
+
+func (p *textParser) parseAnd() (Expression, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	children := []Expression{left}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return And{Children: children}, nil
+}
+
+func (p *textParser) parseNot() (Expression, error) {
+	if p.acceptKeyword("NOT") {
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Child: child}, nil
+	}
+	return p.parsePrimary()
+}
+
+//nolint:cyclop
+func (p *textParser) parsePrimary() (Expression, error) {
+	t := p.peek()
+	if t.kind == tokenLeftParen {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(tokenRightParen, "')'"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+	if t.kind == tokenIdentifier && p.tokens[p.pos+1].kind == tokenLeftParen {
+		name := strings.ToUpper(t.text)
+		if op, ok := spatialOperators[name]; ok {
+			p.next()
+			left, right, err := p.parseFunctionArgs(p.parseSpatialOperand)
+			if err != nil {
+				return nil, err
+			}
+			return SpatialPredicate{Operator: op, Left: left, Right: right}, nil
+		}
+		if op, ok := temporalOperators[name]; ok {
+			p.next()
+			left, right, err := p.parseFunctionArgs(p.parseTemporalOperand)
+			if err != nil {
+				return nil, err
+			}
+			return TemporalPredicate{Operator: op, Left: left, Right: right}, nil
+		}
+	}
+
+	left, err := p.parseScalar()
+	if err != nil {
+		return nil, err
+	}
+	return p.parsePredicate(left)
+}
+
+//nolint:cyclop
+func (p *textParser) parsePredicate(left Operand) (Expression, error) {
+	t := p.peek()
+	if err := assertScalar(left); err != nil {
+		return nil, err
+	}
+	if t.kind == tokenOperator {
+		p.next()
+		right, err := p.parseScalarValue()
+		if err != nil {
+			return nil, err
+		}
+		return Comparison{Operator: ComparisonOperator(t.text), Left: left, Right: right}, nil
+	}
+	if p.acceptKeyword("IS") {
+		negate := p.acceptKeyword("NOT")
+		if !p.acceptKeyword("NULL") {
+			return nil, fmt.Errorf("expected NULL at position %d", p.peek().pos)
+		}
+		return IsNull{Value: left, Negate: negate}, nil
+	}
+	negate := p.acceptKeyword("NOT")
+	switch {
+	case p.acceptKeyword("LIKE"):
+		pattern, err := p.parseScalarValue()
+		if err != nil {
+			return nil, err
+		}
+		return Like{Value: left, Pattern: pattern, Negate: negate}, nil
+	case p.acceptKeyword("BETWEEN"):
+		lower, err := p.parseScalarValue()
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptKeyword("AND") {
+			return nil, fmt.Errorf("expected AND in BETWEEN predicate at position %d", p.peek().pos)
+		}
+		upper, err := p.parseScalarValue()
+		if err != nil {
+			return nil, err
+		}
+		return Between{Value: left, Lower: lower, Upper: upper, Negate: negate}, nil
+	case p.acceptKeyword("IN"):
+		if _, err := p.expect(tokenLeftParen, "'('"); err != nil {
+			return nil, err
+		}
+		list := make([]Operand, 0)
+		for {
+			item, err := p.parseScalarValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+		if _, err := p.expect(tokenRightParen, "')'"); err != nil {
+			return nil, err
+		}
+		return In{Value: left, List: list, Negate: negate}, nil
+	}
+	if negate {
+		return nil, fmt.Errorf("expected LIKE, BETWEEN or IN after NOT at position %d", p.peek().pos)
+	}
+	if b, ok := left.(Boolean); ok {
+		return BooleanLiteral(b), nil
+	}
+	return nil, fmt.Errorf("expected comparison operator or predicate but got '%s' at position %d", t.text, t.pos)
+}
+
+func (p *textParser) parseFunctionArgs(parseArg func() (Operand, error)) (Operand, Operand, error) {
+	if _, err := p.expect(tokenLeftParen, "'('"); err != nil {
+		return nil, nil, err
+	}
+	left, err := parseArg()
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err = p.expect(tokenComma, "','"); err != nil {
+		return nil, nil, err
+	}
+	right, err := parseArg()
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err = p.expect(tokenRightParen, "')'"); err != nil {
+		return nil, nil, err
+	}
+	return left, right, nil
+}
+
+//nolint:cyclop
+func (p *textParser) parseScalar() (Operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return String{Value: t.text}, nil
+	case tokenNumber:
+		val, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s' at position %d", t.text, t.pos)
+		}
+		return Number{Value: val}, nil
+	case tokenQuotedIdentifier:
+		return Property{Name: t.text}, nil
+	case tokenGeometry:
+		return parseWKT(t)
+	case tokenIdentifier:
+		switch strings.ToUpper(t.text) {
+		case "TRUE":
+			return Boolean{Value: true}, nil
+		case "FALSE":
+			return Boolean{Value: false}, nil
+		case "DATE", "TIMESTAMP":
+			return p.parseInstant(t)
+		case "INTERVAL":
+			return p.parseInterval()
+		case "BBOX":
+			return p.parseBbox()
+		case "AND", "OR", "NOT", "LIKE", "BETWEEN", "IN", "IS", "NULL":
+			return nil, fmt.Errorf("unexpected keyword '%s' at position %d", t.text, t.pos)
+		}
+		if p.peek().kind == tokenLeftParen {
+			// function call other than the supported spatial/temporal functions (e.g. CASEI, ACCENTI, A_CONTAINS)
+			return nil, UnsupportedOperatorError{Operator: t.text}
+		}
+		return Property{Name: t.text}, nil
+	default:
+		return nil, fmt.Errorf("unexpected '%s' at position %d", t.text, t.pos)
+	}
+}
+
+// geometries and intervals can only be used in spatial/temporal functions, not in scalar predicates
+func assertScalar(op Operand) error {
+	switch op.(type) {
+	case Geometry:
+		return errors.New("geometry can only be used as argument of a spatial function")
+	case Interval:
+		return errors.New("interval can only be used as argument of a temporal function")
+	}
+	return nil
+}
+
+func (p *textParser) parseScalarValue() (Operand, error) {
+	op, err := p.parseScalar()
+	if err != nil {
+		return nil, err
+	}
+	return op, assertScalar(op)
+}
+
+func (p *textParser) parseSpatialOperand() (Operand, error) {
+	op, err := p.parseScalar()
+	if err != nil {
+		return nil, err
+	}
+	return op, assertSpatial(op)
+}
+
+func (p *textParser) parseTemporalOperand() (Operand, error) {
+	op, err := p.parseScalar()
+	if err != nil {
+		return nil, err
+	}
+	return op, assertTemporal(op)
+}
+
+func assertSpatial(op Operand) error {
+	switch op.(type) {
+	case Property, Geometry:
+		return nil
+	default:
+		return fmt.Errorf("expected property or geometry as argument of spatial function, got %T", op)
+	}
+}
+
+func assertTemporal(op Operand) error {
+	switch op.(type) {
+	case Property, Date, Timestamp, Interval:
+		return nil
+	default:
+		return fmt.Errorf("expected property, date, timestamp or interval as argument of temporal function, got %T", op)
+	}
+}
+
+// DATE('2020-01-01') or TIMESTAMP('2020-01-01T12:00:00Z')
+func (p *textParser) parseInstant(keyword token) (Operand, error) {
+	if _, err := p.expect(tokenLeftParen, "'('"); err != nil {
+		return nil, err
+	}
+	value, err := p.expect(tokenString, "date/timestamp string")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(tokenRightParen, "')'"); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(keyword.text, "DATE") {
+		return parseDate(value.text)
+	}
+	return parseTimestamp(value.text)
+}
+
+// INTERVAL('2020-01-01', '..')
+func (p *textParser) parseInterval() (Operand, error) {
+	start, end, err := p.parseFunctionArgs(p.parseIntervalBoundary)
+	if err != nil {
+		return nil, err
+	}
+	return Interval{Start: start, End: end}, nil
+}
+
+func (p *textParser) parseIntervalBoundary() (Operand, error) {
+	op, err := p.parseScalar()
+	if err != nil {
+		return nil, err
+	}
+	switch v := op.(type) {
+	case String:
+		return parseIntervalBoundary(v.Value)
+	case Property, Date, Timestamp:
+		return op, nil
+	default:
+		return nil, fmt.Errorf("expected date, timestamp, property or '..' as interval boundary, got %T", op)
+	}
+}
+
+// BBOX(minx, miny, maxx, maxy)
+func (p *textParser) parseBbox() (Operand, error) {
+	if _, err := p.expect(tokenLeftParen, "'('"); err != nil {
+		return nil, err
+	}
+	var coords []float64
+	for {
+		t, err := p.expect(tokenNumber, "number")
+		if err != nil {
+			return nil, err
+		}
+		val, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s' at position %d", t.text, t.pos)
+		}
+		coords = append(coords, val)
+		if p.peek().kind != tokenComma {
+			break
+		}
+		p.next()
+	}
+	if _, err := p.expect(tokenRightParen, "')'"); err != nil {
+		return nil, err
+	}
+	return bboxToGeometry(coords)
+}
+
+func parseWKT(t token) (Operand, error) {
+	geometry, err := wkt.DecodeString(t.text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid geometry at position %d: %w", t.pos, err)
+	}
+	return Geometry{Geometry: geometry}, nil
+}
+
+func bboxToGeometry(coords []float64) (Operand, error) {
+	if len(coords) != 4 {
+		return nil, fmt.Errorf("bbox should contain exactly 4 values (minx, miny, maxx, maxy), got %d", len(coords))
+	}
+	extent := geom.Extent{coords[0], coords[1], coords[2], coords[3]}
+	return Geometry{Geometry: geom.Polygon{extent.Vertices()}}, nil
+}
+
+func parseIntervalBoundary(value string) (Operand, error) {
+	if value == ".." {
+		return Unbounded{}, nil
+	}
+	if strings.Contains(value, "T") {
+		return parseTimestamp(value)
+	}
+	return parseDate(value)
+}
+
+func parseDate(value string) (Operand, error) {
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date '%s', expected format YYYY-MM-DD", value)
+	}
+	return Date{Value: date}, nil
+}
+
+func parseTimestamp(value string) (Operand, error) {
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp '%s', expected RFC 3339 format", value)
+	}
+	return Timestamp{Value: timestamp.UTC()}, nil
+}
+
+var (
+	spatialOperators = map[string]SpatialOperator{
+		string(SIntersects): SIntersects, string(SEquals): SEquals, string(SDisjoint): SDisjoint,
+		string(STouches): STouches, string(SWithin): SWithin, string(SOverlaps): SOverlaps,
+		string(SCrosses): SCrosses, string(SContains): SContains,
+	}
+	temporalOperators = map[string]TemporalOperator{
+		string(TAfter): TAfter, string(TBefore): TBefore, string(TContains): TContains,
+		string(TDisjoint): TDisjoint, string(TDuring): TDuring, string(TEquals): TEquals,
+		string(TFinishedBy): TFinishedBy, string(TFinishes): TFinishes, string(TIntersects): TIntersects,
+		string(TMeets): TMeets, string(TMetBy): TMetBy, string(TOverlappedBy): TOverlappedBy,
+		string(TOverlaps): TOverlaps, string(TStartedBy): TStartedBy, string(TStarts): TStarts,
+	}
+
+	errEmptyFilter = errors.New("filter is empty")
+)
@@ -0,0 +1,177 @@
+package cql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-spatial/geom"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseText(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		want    Expression
+		wantErr string
+	}{
+		{
+			name:   "comparison",
+			filter: "straatnaam = 'Silodam'",
+			want:   Comparison{Operator: Equal, Left: Property{Name: "straatnaam"}, Right: String{Value: "Silodam"}},
+		},
+		{
+			name:   "comparison with quoted property, escaped quote and case-insensitive keywords",
+			filter: `"naam" <> 'Sint ''t Gasthuis' or huisnummer >= -5.5`,
+			want: Or{Children: []Expression{
+				Comparison{Operator: NotEqual, Left: Property{Name: "naam"}, Right: String{Value: "Sint 't Gasthuis"}},
+				Comparison{Operator: GreaterThanOrEqual, Left: Property{Name: "huisnummer"}, Right: Number{Value: -5.5}},
+			}},
+		},
+		{
+			name:   "precedence of and/or/not with parenthesis",
+			filter: "a = 1 AND NOT (b = 2 OR c = 3)",
+			want: And{Children: []Expression{
+				Comparison{Operator: Equal, Left: Property{Name: "a"}, Right: Number{Value: 1}},
+				Not{Child: Or{Children: []Expression{
+					Comparison{Operator: Equal, Left: Property{Name: "b"}, Right: Number{Value: 2}},
+					Comparison{Operator: Equal, Left: Property{Name: "c"}, Right: Number{Value: 3}},
+				}}},
+			}},
+		},
+		{
+			name:   "like, between, in, is null",
+			filter: "a LIKE 'Silo%' and b not between 1 and 10 and c in ('x', 'y') and d is not null and e not like '_'",
+			want: And{Children: []Expression{
+				Like{Value: Property{Name: "a"}, Pattern: String{Value: "Silo%"}},
+				Between{Value: Property{Name: "b"}, Lower: Number{Value: 1}, Upper: Number{Value: 10}, Negate: true},
+				In{Value: Property{Name: "c"}, List: []Operand{String{Value: "x"}, String{Value: "y"}}},
+				IsNull{Value: Property{Name: "d"}, Negate: true},
+				Like{Value: Property{Name: "e"}, Pattern: String{Value: "_"}, Negate: true},
+			}},
+		},
+		{
+			name:   "boolean literals",
+			filter: "true and active = false",
+			want: And{Children: []Expression{
+				BooleanLiteral{Value: true},
+				Comparison{Operator: Equal, Left: Property{Name: "active"}, Right: Boolean{Value: false}},
+			}},
+		},
+		{
+			name:   "spatial function with WKT",
+			filter: "S_INTERSECTS(geom, POLYGON((0 0, 0 1, 1 1, 1 0, 0 0)))",
+			want: SpatialPredicate{Operator: SIntersects, Left: Property{Name: "geom"},
+				Right: Geometry{Geometry: geom.Polygon{{{0, 0}, {0, 1}, {1, 1}, {1, 0}}}}},
+		},
+		{
+			name:   "spatial function with bbox",
+			filter: "s_within(geom, BBOX(4.1, 52.0, 4.2, 52.1))",
+			want: SpatialPredicate{Operator: SWithin, Left: Property{Name: "geom"},
+				Right: Geometry{Geometry: geom.Polygon{{{4.1, 52.0}, {4.2, 52.0}, {4.2, 52.1}, {4.1, 52.1}}}}},
+		},
+		{
+			name:   "temporal function with interval",
+			filter: "T_INTERSECTS(INTERVAL(validfrom, validto), INTERVAL('2020-01-01', '..'))",
+			want: TemporalPredicate{Operator: TIntersects,
+				Left:  Interval{Start: Property{Name: "validfrom"}, End: Property{Name: "validto"}},
+				Right: Interval{Start: Date{Value: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}, End: Unbounded{}}},
+		},
+		{
+			name:   "temporal comparison with timestamp",
+			filter: "T_AFTER(updated, TIMESTAMP('2023-01-01T12:00:00+01:00')) or created > DATE('2022-12-31')",
+			want: Or{Children: []Expression{
+				TemporalPredicate{Operator: TAfter, Left: Property{Name: "updated"},
+					Right: Timestamp{Value: time.Date(2023, 1, 1, 11, 0, 0, 0, time.UTC)}},
+				Comparison{Operator: GreaterThan, Left: Property{Name: "created"},
+					Right: Date{Value: time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC)}},
+			}},
+		},
+		{
+			name:    "fail on empty filter",
+			filter:  "  ",
+			wantErr: "filter is empty",
+		},
+		{
+			name:    "fail on unterminated string",
+			filter:  "a = 'foo",
+			wantErr: "invalid CQL2-Text filter: unterminated string starting at position 4",
+		},
+		{
+			name:    "fail on missing closing parenthesis",
+			filter:  "(a = 1",
+			wantErr: "invalid CQL2-Text filter: expected ')' but got 'end of filter' at position 6",
+		},
+		{
+			name:    "fail on trailing tokens",
+			filter:  "a = 1 b",
+			wantErr: "invalid CQL2-Text filter: unexpected 'b' at position 6",
+		},
+		{
+			name:    "fail on geometry in comparison",
+			filter:  "geom = POINT(1 2)",
+			wantErr: "invalid CQL2-Text filter: geometry can only be used as argument of a spatial function",
+		},
+		{
+			name:    "fail on invalid date",
+			filter:  "a > DATE('2020-13-01')",
+			wantErr: "invalid CQL2-Text filter: invalid date '2020-13-01', expected format YYYY-MM-DD",
+		},
+		{
+			name:    "fail on unsupported function",
+			filter:  "CASEI(straatnaam) = casei('silodam')",
+			wantErr: "invalid CQL2-Text filter: operator 'CASEI' is not supported",
+		},
+		{
+			name:    "fail on invalid bbox",
+			filter:  "S_INTERSECTS(geom, BBOX(1, 2, 3))",
+			wantErr: "invalid CQL2-Text filter: bbox should contain exactly 4 values (minx, miny, maxx, maxy), got 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseText(tt.filter)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestProperties(t *testing.T) {
+	expr, err := ParseText("a = 1 and (b like 'x%' or a > 2) and T_DURING(INTERVAL(c, d), INTERVAL('2020-01-01', '..'))")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, Properties(expr))
+	assert.False(t, HasGeometryLiteral(expr))
+
+	expr, err = ParseText("S_INTERSECTS(geom, POINT(1 2))")
+	assert.NoError(t, err)
+	assert.True(t, HasGeometryLiteral(expr))
+}
+
+func TestMapGeometries(t *testing.T) {
+	expr, err := ParseText("a = 1 and not S_INTERSECTS(geom, POINT(1 2))")
+	assert.NoError(t, err)
+
+	mapped, err := MapGeometries(expr, func(g geom.Geometry) (geom.Geometry, error) {
+		point := g.(geom.Point)
+		return geom.Point{point.X() * 10, point.Y() * 10}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, And{Children: []Expression{
+		Comparison{Operator: Equal, Left: Property{Name: "a"}, Right: Number{Value: 1}},
+		Not{Child: SpatialPredicate{Operator: SIntersects, Left: Property{Name: "geom"}, Right: Geometry{Geometry: geom.Point{10, 20}}}},
+	}}, mapped)
+
+	// original expression is left untouched
+	assert.Equal(t, Geometry{Geometry: geom.Point{1, 2}}, expr.(And).Children[1].(Not).Child.(SpatialPredicate).Right)
+
+	_, err = MapGeometries(expr, func(geom.Geometry) (geom.Geometry, error) {
+		return nil, errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+}
@@ -0,0 +1,136 @@
+package features
+
+import (
+	"bytes"
+	"encoding/csv"
+	stdjson "encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/PDOK/gokoala/engine"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom/encoding/wkt"
+)
+
+const (
+	csvColumnID       = "id"
+	csvColumnGeometry = "geometry"
+)
+
+// featuresAsCSV serves features as CSV (RFC 4180), with one column per property and the geometry as WKT.
+// CSV has no notion of links, so these (e.g. for pagination) are exposed as Link headers (RFC 8288).
+func featuresAsCSV(w http.ResponseWriter, collectionID string, cursor domain.Cursors,
+	featuresURL featureCollectionURL, fc *domain.FeatureCollection) {
+
+	setLinkHeaders(w, createLinkHeaderFeatureCollectionLinks(engine.FormatCSV, engine.MediaTypeCSV,
+		collectionID, cursor, featuresURL))
+	serveCSV(fc.Features, w)
+}
+
+// featureAsCSV serves a single feature as CSV, see featuresAsCSV
+func featureAsCSV(w http.ResponseWriter, collectionID string, feat *domain.Feature, url featureURL) {
+	setLinkHeaders(w, createLinkHeaderFeatureLinks(engine.FormatCSV, engine.MediaTypeCSV, url, collectionID, feat.ID))
+	serveCSV([]*domain.Feature{feat}, w)
+}
+
+// serveCSV writes the given features as CSV. The header contains the feature id, all properties
+// (sorted by name, since properties may differ between features) and the geometry.
+func serveCSV(features []*domain.Feature, w http.ResponseWriter) {
+	columns := csvPropertyColumns(features)
+
+	result := &bytes.Buffer{}
+	writer := csv.NewWriter(result)
+	writer.UseCRLF = true
+	header := append(append([]string{csvColumnID}, columns...), csvColumnGeometry)
+	if err := writer.Write(header); err != nil {
+		handleCSVEncodingFailure(err, w)
+		return
+	}
+	for _, feature := range features {
+		record, err := featureToCSVRecord(feature, columns)
+		if err != nil {
+			handleCSVEncodingFailure(err, w)
+			return
+		}
+		if err = writer.Write(record); err != nil {
+			handleCSVEncodingFailure(err, w)
+			return
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		handleCSVEncodingFailure(err, w)
+		return
+	}
+
+	w.Header().Set(engine.HeaderContentType, engine.MediaTypeCSV)
+	if _, err := w.Write(result.Bytes()); err != nil {
+		log.Printf("failed to write CSV response: %v", err)
+	}
+}
+
+func csvPropertyColumns(features []*domain.Feature) []string {
+	unique := make(map[string]struct{})
+	for _, feature := range features {
+		for name := range feature.Properties {
+			unique[name] = struct{}{}
+		}
+	}
+	columns := make([]string, 0, len(unique))
+	for name := range unique {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func featureToCSVRecord(feature *domain.Feature, columns []string) ([]string, error) {
+	record := make([]string, 0, len(columns)+2)
+	id, err := toCSVValue(feature.ID)
+	if err != nil {
+		return nil, err
+	}
+	record = append(record, id)
+	for _, column := range columns {
+		value, err := toCSVValue(feature.Properties[column])
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode property %s of feature %v, error: %w", column, feature.ID, err)
+		}
+		record = append(record, value)
+	}
+	geometry := ""
+	if feature.Geometry != nil && feature.Geometry.Geometry != nil {
+		if geometry, err = wkt.EncodeString(feature.Geometry.Geometry); err != nil {
+			return nil, fmt.Errorf("failed to encode geometry of feature %v as WKT, error: %w", feature.ID, err)
+		}
+	}
+	return append(record, geometry), nil
+}
+
+func toCSVValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case map[string]any, []any:
+		// nested values are encoded as JSON
+		result, err := stdjson.Marshal(v)
+		return string(result), err
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func handleCSVEncodingFailure(err error, w http.ResponseWriter) {
+	log.Printf("CSV encoding failed: %v", err)
+	engine.RenderProblem(engine.ProblemServerError, w, "Failed to write CSV response")
+}
@@ -0,0 +1,69 @@
+package features
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/PDOK/gokoala/engine"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/geojson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeaturesAsCSV(t *testing.T) {
+	baseURL, _ := url.Parse("http://localhost:8080")
+	featuresURL := featureCollectionURL{baseURL: *baseURL, params: url.Values{"limit": []string{"2"}}}
+	fc := &domain.FeatureCollection{
+		Features: []*domain.Feature{
+			{
+				ID: int64(1),
+				Feature: geojson.Feature{Properties: map[string]any{
+					"straatnaam":  "Silodam, \"Amsterdam\"",
+					"huisnummer":  int64(1),
+					"oppervlakte": 1234567.5,
+					"datum":       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				}},
+				Geometry: &geojson.Geometry{Geometry: geom.Point{4.89, 52.38}},
+			},
+			{
+				ID: int64(2),
+				Feature: geojson.Feature{Properties: map[string]any{
+					"straatnaam": "Damrak",
+					"toevoeging": nil,
+				}},
+			},
+		},
+	}
+
+	w := httptest.NewRecorder()
+	featuresAsCSV(w, "foo", domain.Cursors{HasNext: true, Next: "abc"}, featuresURL, fc)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, engine.MediaTypeCSV, w.Header().Get(engine.HeaderContentType))
+	assert.Equal(t, "id,datum,huisnummer,oppervlakte,straatnaam,toevoeging,geometry\r\n"+
+		"1,2024-01-02T03:04:05Z,1,1234567.5,\"Silodam, \"\"Amsterdam\"\"\",,POINT (4.89 52.38)\r\n"+
+		"2,,,,Damrak,,\r\n", w.Body.String())
+	assert.Equal(t, []string{
+		`<http://localhost:8080/collections/foo/items?f=csv&limit=2>; rel="self"; type="text/csv"; title="This document as CSV"`,
+		`<http://localhost:8080/collections/foo/items?f=json&limit=2>; rel="alternate"; type="application/geo+json"; title="This document as GeoJSON"`,
+		`<http://localhost:8080/collections/foo/items?cursor=abc&f=csv&limit=2>; rel="next"; type="text/csv"; title="Next page"`,
+	}, w.Header().Values(engine.HeaderLink))
+}
+
+func TestFeatureAsCSV(t *testing.T) {
+	baseURL, _ := url.Parse("http://localhost:8080")
+	feat := &domain.Feature{
+		ID:      "a3f1",
+		Feature: geojson.Feature{Properties: map[string]any{"straatnaam": "Silodam"}},
+	}
+
+	w := httptest.NewRecorder()
+	featureAsCSV(w, "foo", feat, featureURL{baseURL: *baseURL, params: url.Values{}})
+
+	assert.Equal(t, "id,straatnaam,geometry\r\na3f1,Silodam,\r\n", w.Body.String())
+	assert.Len(t, w.Header().Values(engine.HeaderLink), 3)
+}
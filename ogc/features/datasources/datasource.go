@@ -2,8 +2,11 @@ package datasources
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
 
+	"github.com/PDOK/gokoala/ogc/features/cql"
 	"github.com/PDOK/gokoala/ogc/features/domain"
 	"github.com/go-spatial/geom"
 )
@@ -16,13 +19,23 @@ type Datasource interface {
 	GetFeatureIDs(ctx context.Context, collection string, criteria FeaturesCriteria) ([]int64, domain.Cursors, error)
 
 	// GetFeaturesByID returns a collection of Features with the given IDs. To be used in concert with GetFeatureIDs
-	GetFeaturesByID(ctx context.Context, collection string, featureIDs []int64) (*domain.FeatureCollection, error)
+	GetFeaturesByID(ctx context.Context, collection string, featureIDs []int64, selection PropertySelection) (*domain.FeatureCollection, error)
 
 	// GetFeatures returns all Features matching the given criteria and Cursors for pagination
 	GetFeatures(ctx context.Context, collection string, criteria FeaturesCriteria) (*domain.FeatureCollection, domain.Cursors, error)
 
-	// GetFeature returns a specific Feature
-	GetFeature(ctx context.Context, collection string, featureID int64) (*domain.Feature, error)
+	// StreamFeatures same as GetFeatures, but returns an iterator over the Features instead of a FeatureCollection.
+	// This allows Features to be written to the client while these are read from the datasource. The iterator
+	// (nil when no Features match the criteria) must be closed by the caller.
+	StreamFeatures(ctx context.Context, collection string, criteria FeaturesCriteria) (domain.FeatureIterator, domain.Cursors, error)
+
+	// CountFeatures returns the number of Features matching the given criteria, regardless of pagination
+	// (cursor and limit are ignored). Used to report numberMatched, which can be expensive for large collections.
+	CountFeatures(ctx context.Context, collection string, criteria FeaturesCriteria) (int, error)
+
+	// GetFeature returns a specific Feature, by its internal feature id (int64) or - when configured
+	// for the collection - by its external feature id (string)
+	GetFeature(ctx context.Context, collection string, featureID any, selection PropertySelection) (*domain.Feature, error)
 
 	// GetFeatureTableMetadata returns metadata about a feature table associated with the given collection
 	GetFeatureTableMetadata(collection string) (FeatureTableMetadata, error)
@@ -31,6 +44,60 @@ type Datasource interface {
 	Close()
 }
 
+// DatasourceWriter optional extension of Datasource to create, replace, update and delete Features (OAF Part 4).
+// Features are identified by their internal feature id (int64) or - when configured for the
+// collection - by their external feature id (string). Errors caused by the given Feature wrap
+// ErrInvalidFeature or ErrFeatureConflict, other errors are considered internal errors.
+type DatasourceWriter interface {
+
+	// CreateFeature inserts the given Feature and returns the id of the new Feature
+	CreateFeature(ctx context.Context, collection string, feature *domain.Feature) (any, error)
+
+	// ReplaceFeature replaces the properties and geometry of an existing Feature.
+	// Returns false when the Feature doesn't exist
+	ReplaceFeature(ctx context.Context, collection string, featureID any, feature *domain.Feature) (bool, error)
+
+	// UpdateFeature updates only the given properties (and geometry when not nil) of an existing Feature.
+	// Returns false when the Feature doesn't exist
+	UpdateFeature(ctx context.Context, collection string, featureID any, feature *domain.Feature) (bool, error)
+
+	// DeleteFeature deletes an existing Feature. Returns false when the Feature doesn't exist
+	DeleteFeature(ctx context.Context, collection string, featureID any) (bool, error)
+}
+
+var (
+	// ErrInvalidFeature the Feature to write is invalid, e.g. it has a property which isn't a (writable) column
+	ErrInvalidFeature = errors.New("invalid feature")
+
+	// ErrFeatureConflict the Feature to write conflicts with an existing Feature, e.g. a duplicate external feature id
+	ErrFeatureConflict = errors.New("feature conflicts with existing feature")
+)
+
+// CQLFilterer optional extension of Datasource, implemented by datasources that are able to
+// evaluate CQL filters (OAF Part 3). Requests with a filter are rejected for other datasources.
+type CQLFilterer interface {
+
+	// SupportsCQLFilter returns true when FeaturesCriteria.Filter is supported
+	SupportsCQLFilter() bool
+}
+
+// Sorter optional extension of Datasource, implemented by datasources that are able to sort
+// features (and paginate over sorted features). Requests with sortby are rejected for other datasources.
+type Sorter interface {
+
+	// SupportsSortBy returns true when FeaturesCriteria.SortBy is supported
+	SupportsSortBy() bool
+}
+
+// PrefixFilterer optional extension of Datasource, implemented by datasources that are only able to
+// match property filters by prefix (values ending with a wildcard) on some properties. Other datasources
+// support prefix matching on all properties.
+type PrefixFilterer interface {
+
+	// SupportsPrefixFilter returns true when the given property of the given collection can be matched by prefix
+	SupportsPrefixFilter(collection string, property string) bool
+}
+
 // FeaturesCriteria to select a certain set of Features
 type FeaturesCriteria struct {
 	// pagination
@@ -47,27 +114,101 @@ type FeaturesCriteria struct {
 	// filtering by reference date
 	TemporalCriteria TemporalCriteria
 
-	// filtering by properties
+	// filtering by properties, values may hold multiple (comma-separated) values
+	// and/or values ending with a wildcard, see SplitPropertyFilter
 	PropertyFilters map[string]string
 
-	// filtering by CQL
-	Filter     string
-	FilterLang string
+	// filtering by CQL, parsed to a datasource-neutral AST
+	Filter cql.Expression
+
+	// sorting by properties, features are always (also) sorted by feature id
+	SortBy []SortKey
+
+	// selecting a subset of the properties and/or omitting the geometry
+	PropertySelection PropertySelection
+}
+
+const (
+	PropertyFilterSeparator = ","
+	PropertyFilterWildcard  = "*"
+)
+
+// SplitPropertyFilter splits the value of a property filter in the values to match exactly and the
+// prefixes to match (values ending with a wildcard, without the wildcard itself).
+func SplitPropertyFilter(value string) (values []string, prefixes []string) {
+	for _, v := range strings.Split(value, PropertyFilterSeparator) {
+		if prefix, ok := strings.CutSuffix(v, PropertyFilterWildcard); ok {
+			prefixes = append(prefixes, prefix)
+		} else {
+			values = append(values, v)
+		}
+	}
+	return values, prefixes
+}
+
+// PropertySelection the properties (and geometry) of Features to return
+type PropertySelection struct {
+	// properties to return, all properties are returned when empty
+	Properties []string
+
+	// return features without geometry
+	SkipGeometry bool
+}
+
+// IsEmpty returns true when no selection is made, meaning all properties and the geometry should be returned
+func (p PropertySelection) IsEmpty() bool {
+	return len(p.Properties) == 0 && !p.SkipGeometry
+}
+
+// SortKey property to sort features by
+type SortKey struct {
+	Property   string
+	Descending bool
 }
 
 type TemporalCriteria struct {
 	// reference date
 	ReferenceDate time.Time
 
+	// reference interval, mutually exclusive with reference date
+	ReferenceInterval *Interval
+
 	// startDate and endDate properties
 	StartDateProperty string
 	EndDateProperty   string
 }
 
+// Interval time interval (inclusive), a zero start or end denotes an open (unbounded) start or end
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
 // FeatureTableMetadata abstraction to access metadata of a feature table (aka attribute table)
 type FeatureTableMetadata interface {
 
 	// ColumnsWithDataType returns a mapping from column names to column data types.
 	// Note: data types can be datasource specific.
 	ColumnsWithDataType() map[string]string
+
+	// IDColumn returns the name of the column holding the feature IDs
+	IDColumn() string
+
+	// GeometryColumn returns metadata about the column holding the feature geometries
+	GeometryColumn() GeometryColumn
+
+	// LastModified returns the time the features in this table were last changed,
+	// or the zero time when unknown (e.g. because the table is writable).
+	LastModified() time.Time
+}
+
+// GeometryColumn metadata about the geometry column of a feature table
+type GeometryColumn struct {
+	Name string
+
+	// geometry type, e.g. POINT or MULTIPOLYGON. Note: can be datasource specific.
+	Type string
+
+	// spatial reference system identifier, e.g. 28992
+	SRID int64
 }
@@ -0,0 +1,57 @@
+package geojson
+
+import (
+	"strings"
+	"time"
+
+	"github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+)
+
+// matchesPropertyFilters returns true when the given feature matches all property filters. Same semantics as
+// the GeoPackage datasource: a property matches when it equals one of the (comma-separated) values or starts
+// with one of the prefixes (values ending with a wildcard). Features without the property never match.
+func (ft *featureTable) matchesPropertyFilters(feature *domain.Feature, propertyFilters map[string]string) bool {
+	for property, filter := range propertyFilters {
+		value, ok := feature.Properties[property]
+		if !ok || value == nil {
+			return false
+		}
+		formatted := formatValue(value, ft.columns[property])
+		values, prefixes := datasources.SplitPropertyFilter(filter)
+		matches := false
+		for _, v := range values {
+			matches = matches || formatted == v
+		}
+		for _, prefix := range prefixes {
+			matches = matches || strings.HasPrefix(formatted, prefix)
+		}
+		if !matches {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesTemporalCriteria returns true when the validity of the given feature (start/end date) contains the
+// reference date or overlaps with the reference interval. A missing end date denotes an open-ended validity.
+func matchesTemporalCriteria(feature *domain.Feature, criteria datasources.TemporalCriteria) bool {
+	if criteria.ReferenceDate.IsZero() && criteria.ReferenceInterval == nil {
+		return true
+	}
+	startDate, hasStartDate := feature.Properties[criteria.StartDateProperty].(time.Time)
+	endDate, hasEndDate := feature.Properties[criteria.EndDateProperty].(time.Time)
+
+	if !criteria.ReferenceDate.IsZero() {
+		return hasStartDate && !startDate.After(criteria.ReferenceDate) &&
+			(!hasEndDate || !endDate.Before(criteria.ReferenceDate))
+	}
+	interval := criteria.ReferenceInterval
+	if !interval.End.IsZero() && (!hasStartDate || startDate.After(interval.End)) {
+		return false
+	}
+	if !interval.Start.IsZero() && hasEndDate && endDate.Before(interval.Start) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,265 @@
+package geojson
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/PDOK/gokoala/config"
+	"github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom/encoding/geojson"
+)
+
+const (
+	// fidColumn name of the (virtual) column holding the internal feature id
+	fidColumn = "fid"
+
+	// geometryColumn name of the (virtual) column holding the feature geometry
+	geometryColumn = "geometry"
+
+	// RFC 7946 mandates WGS84 coordinates for GeoJSON
+	wgs84SRID = 4326
+)
+
+// GeoJSON datasource which serves the features of a GeoJSON file from memory. Features are filtered by
+// bounding box using an in-memory spatial index, other filters are evaluated against every feature. Meant
+// for small datasets.
+type GeoJSON struct {
+	features []*domain.Feature // ordered by fid
+	index    *rtree
+	table    *featureTable
+
+	externalFidByCollectionID map[string]string
+}
+
+func NewGeoJSON(collections config.GeoSpatialCollections, geoJSONConfig config.GeoJSON) *GeoJSON {
+	features, table, err := readGeoJSONFile(geoJSONConfig.File)
+	if err != nil {
+		log.Fatalf("failed to read GeoJSON file %s: %v", geoJSONConfig.File, err)
+	}
+	g := &GeoJSON{
+		features:                  features,
+		index:                     newRTree(features),
+		table:                     table,
+		externalFidByCollectionID: make(map[string]string),
+	}
+	for _, collection := range collections {
+		if collection.Features != nil && collection.Features.ExternalFid != "" {
+			if _, ok := table.columns[collection.Features.ExternalFid]; !ok {
+				log.Fatalf("external fid property '%s' of collection '%s' doesn't exist in GeoJSON file %s",
+					collection.Features.ExternalFid, collection.ID, geoJSONConfig.File)
+			}
+			g.externalFidByCollectionID[collection.ID] = collection.Features.ExternalFid
+		}
+	}
+	log.Printf("loaded %d features from GeoJSON file %s", len(features), geoJSONConfig.File)
+	return g
+}
+
+func (g *GeoJSON) Close() {
+	// noop, features are held in memory
+}
+
+func (g *GeoJSON) GetFeatureIDs(_ context.Context, _ string, criteria datasources.FeaturesCriteria) ([]int64, domain.Cursors, error) {
+	page, cursors, err := g.selectPage(criteria)
+	if err != nil || page == nil {
+		return nil, cursors, err
+	}
+	featureIDs := make([]int64, 0, len(page))
+	for _, feature := range page {
+		featureIDs = append(featureIDs, feature.FID)
+	}
+	return featureIDs, cursors, nil
+}
+
+func (g *GeoJSON) GetFeaturesByID(_ context.Context, collection string, featureIDs []int64,
+	selection datasources.PropertySelection) (*domain.FeatureCollection, error) {
+
+	fc := domain.FeatureCollection{Features: make([]*domain.Feature, 0, len(featureIDs))}
+	for _, featureID := range featureIDs {
+		if feature := g.findByFID(featureID); feature != nil {
+			fc.Features = append(fc.Features, g.copyFeature(collection, feature, selection))
+		}
+	}
+	fc.NumberReturned = len(fc.Features)
+	return &fc, nil
+}
+
+func (g *GeoJSON) GetFeatures(ctx context.Context, collection string, criteria datasources.FeaturesCriteria) (*domain.FeatureCollection, domain.Cursors, error) {
+	it, cursors, err := g.StreamFeatures(ctx, collection, criteria)
+	if err != nil || it == nil {
+		return nil, cursors, err
+	}
+	defer it.Close()
+
+	fc := domain.FeatureCollection{}
+	if fc.Features, err = domain.ReadFeatures(it); err != nil {
+		return nil, domain.Cursors{}, err
+	}
+	fc.NumberReturned = len(fc.Features)
+	return &fc, cursors, nil
+}
+
+func (g *GeoJSON) StreamFeatures(_ context.Context, collection string, criteria datasources.FeaturesCriteria) (domain.FeatureIterator, domain.Cursors, error) {
+	page, cursors, err := g.selectPage(criteria)
+	if err != nil || page == nil {
+		return nil, cursors, err
+	}
+	features := make([]*domain.Feature, 0, len(page))
+	for _, feature := range page {
+		features = append(features, g.copyFeature(collection, feature, criteria.PropertySelection))
+	}
+	return domain.NewSliceFeatureIterator(features), cursors, nil
+}
+
+func (g *GeoJSON) CountFeatures(_ context.Context, _ string, criteria datasources.FeaturesCriteria) (int, error) {
+	matches, err := g.filter(criteria)
+	if err != nil {
+		return 0, err
+	}
+	return len(matches), nil
+}
+
+func (g *GeoJSON) GetFeature(_ context.Context, collection string, featureID any,
+	selection datasources.PropertySelection) (*domain.Feature, error) {
+
+	externalFid := g.externalFidByCollectionID[collection]
+	if externalFid == "" {
+		fid, ok := featureID.(int64)
+		if !ok {
+			return nil, fmt.Errorf("expected numeric feature id, got %v", featureID)
+		}
+		if feature := g.findByFID(fid); feature != nil {
+			return g.copyFeature(collection, feature, selection), nil
+		}
+		return nil, nil
+	}
+	for _, feature := range g.features {
+		if value, ok := feature.Properties[externalFid]; ok && fmt.Sprint(value) == fmt.Sprint(featureID) {
+			return g.copyFeature(collection, feature, selection), nil
+		}
+	}
+	return nil, nil
+}
+
+func (g *GeoJSON) GetFeatureTableMetadata(_ string) (datasources.FeatureTableMetadata, error) {
+	return g.table, nil
+}
+
+// selectPage returns the features on the page starting at the cursor, as well as the cursors to the previous and
+// next page. Same semantics as the GeoPackage datasource: the previous page is only available when there are at
+// least 'limit' features before the cursor. Returns nil when there are no features on the page.
+func (g *GeoJSON) selectPage(criteria datasources.FeaturesCriteria) ([]*domain.Feature, domain.Cursors, error) {
+	matches, err := g.filter(criteria)
+	if err != nil {
+		return nil, domain.Cursors{}, err
+	}
+	start := sort.Search(len(matches), func(i int) bool {
+		return matches[i].FID >= criteria.Cursor.FID
+	})
+	end := min(start+criteria.Limit, len(matches))
+	if start >= end {
+		return nil, domain.Cursors{}, nil
+	}
+	prevNext := domain.PrevNextFID{}
+	if start-criteria.Limit >= 0 {
+		prevNext.Prev = matches[start-criteria.Limit].FID
+	}
+	if end < len(matches) {
+		prevNext.Next = matches[end].FID
+	}
+	return matches[start:end], domain.NewCursors(prevNext, criteria.Cursor.FiltersChecksum), nil
+}
+
+// filter returns all features matching the given criteria, ordered by fid
+func (g *GeoJSON) filter(criteria datasources.FeaturesCriteria) ([]*domain.Feature, error) {
+	if criteria.Filter != nil {
+		return nil, errors.New("CQL filters are not supported by the GeoJSON datasource")
+	}
+	if len(criteria.SortBy) > 0 {
+		return nil, errors.New("sortby is not supported by the GeoJSON datasource")
+	}
+	candidates := g.features
+	if criteria.Bbox != nil {
+		candidates = g.index.search(*criteria.Bbox)
+	}
+	matches := make([]*domain.Feature, 0, len(candidates))
+	for _, feature := range candidates {
+		if criteria.Bbox != nil && !intersects(*criteria.Bbox, feature.Geometry.Geometry) {
+			continue
+		}
+		if !g.table.matchesPropertyFilters(feature, criteria.PropertyFilters) ||
+			!matchesTemporalCriteria(feature, criteria.TemporalCriteria) {
+			continue
+		}
+		matches = append(matches, feature)
+	}
+	return matches, nil
+}
+
+func (g *GeoJSON) findByFID(fid int64) *domain.Feature {
+	i := sort.Search(len(g.features), func(i int) bool {
+		return g.features[i].FID >= fid
+	})
+	if i < len(g.features) && g.features[i].FID == fid {
+		return g.features[i]
+	}
+	return nil
+}
+
+// copyFeature returns a copy of the given feature with only the selected properties, since
+// features held in memory must not be modified (e.g. when reprojected or adding links).
+func (g *GeoJSON) copyFeature(collection string, feature *domain.Feature, selection datasources.PropertySelection) *domain.Feature {
+	result := &domain.Feature{ID: feature.FID, FID: feature.FID}
+	result.Properties = make(map[string]any, len(feature.Properties))
+	if len(selection.Properties) == 0 {
+		maps.Copy(result.Properties, feature.Properties)
+	} else {
+		for _, property := range selection.Properties {
+			if value, ok := feature.Properties[property]; ok {
+				result.Properties[property] = value
+			}
+		}
+	}
+	if externalFid := g.externalFidByCollectionID[collection]; externalFid != "" {
+		if value, ok := feature.Properties[externalFid]; ok {
+			result.ID = fmt.Sprint(value)
+		}
+		delete(result.Properties, externalFid)
+	}
+	if feature.Geometry != nil && !selection.SkipGeometry {
+		result.Geometry = &geojson.Geometry{Geometry: feature.Geometry.Geometry}
+	}
+	return result
+}
+
+// featureTable metadata about the features in the GeoJSON file, which is treated as a single feature table
+type featureTable struct {
+	columns      map[string]string // property name -> data type (SQLite flavor, e.g. TEXT or INTEGER)
+	geometryType string
+	lastModified time.Time
+}
+
+func (ft *featureTable) ColumnsWithDataType() map[string]string {
+	result := maps.Clone(ft.columns)
+	result[fidColumn] = "INTEGER"
+	result[geometryColumn] = ft.geometryType
+	return result
+}
+
+func (ft *featureTable) IDColumn() string {
+	return fidColumn
+}
+
+func (ft *featureTable) GeometryColumn() datasources.GeometryColumn {
+	return datasources.GeometryColumn{Name: geometryColumn, Type: ft.geometryType, SRID: wgs84SRID}
+}
+
+func (ft *featureTable) LastModified() time.Time {
+	return ft.lastModified
+}
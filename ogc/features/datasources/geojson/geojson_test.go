@@ -0,0 +1,308 @@
+package geojson
+
+import (
+	"context"
+	"path"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/PDOK/gokoala/config"
+	"github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pwd string
+
+func init() {
+	_, filename, _, _ := runtime.Caller(0)
+	pwd = path.Dir(filename)
+}
+
+func newAddressesGeoJSON(externalFid string) *GeoJSON {
+	collections := config.GeoSpatialCollections{
+		{ID: "addresses", Features: &config.CollectionEntryFeatures{ExternalFid: externalFid}},
+	}
+	return NewGeoJSON(collections, config.GeoJSON{File: pwd + "/testdata/addresses.geojson"})
+}
+
+func TestNewGeoJSON(t *testing.T) {
+	tests := []struct {
+		name             string
+		file             string
+		wantFIDs         []int64
+		wantColumns      map[string]string
+		wantGeometryType string
+	}{
+		{
+			name:     "feature collection",
+			file:     "addresses.geojson",
+			wantFIDs: []int64{1, 2, 3, 4, 5, 6, 7, 8},
+			wantColumns: map[string]string{
+				"fid":         "INTEGER",
+				"geometry":    "GEOMETRY",
+				"straatnaam":  "TEXT",
+				"huisnummer":  "INTEGER",
+				"postcode":    "TEXT",
+				"oppervlakte": "REAL",
+				"datum_strt":  "DATE",
+				"datum_eind":  "DATE",
+			},
+			wantGeometryType: "GEOMETRY",
+		},
+		{
+			name:     "feature sequence without ids",
+			file:     "districts.geojsonseq",
+			wantFIDs: []int64{1, 2, 3},
+			wantColumns: map[string]string{
+				"fid":      "INTEGER",
+				"geometry": "GEOMETRY",
+				"naam":     "TEXT",
+				"code":     "TEXT",
+			},
+			wantGeometryType: "GEOMETRY",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGeoJSON(config.GeoSpatialCollections{{ID: "foo"}}, config.GeoJSON{File: pwd + "/testdata/" + tt.file})
+			fids := make([]int64, 0, len(g.features))
+			for _, feature := range g.features {
+				fids = append(fids, feature.FID)
+			}
+			assert.Equal(t, tt.wantFIDs, fids)
+
+			metadata, err := g.GetFeatureTableMetadata("foo")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantColumns, metadata.ColumnsWithDataType())
+			assert.Equal(t, "fid", metadata.IDColumn())
+			assert.Equal(t, datasources.GeometryColumn{Name: "geometry", Type: tt.wantGeometryType, SRID: 4326}, metadata.GeometryColumn())
+			assert.False(t, metadata.LastModified().IsZero())
+		})
+	}
+}
+
+func TestInferDataTypes(t *testing.T) {
+	features, err := decodeFeatures([]byte(`
+		{"type": "Feature", "properties": {"a": 1, "b": 1, "c": true, "d": "2024-01-01T12:00:00Z", "e": null, "f": "x"}, "geometry": null}
+		{"type": "Feature", "properties": {"a": 2, "b": 1.5, "c": false, "d": null, "e": null, "f": 3}, "geometry": null}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"a": "INTEGER",
+		"b": "REAL",
+		"c": "BOOLEAN",
+		"d": "DATETIME",
+		"e": "TEXT",
+		"f": "TEXT",
+	}, inferDataTypes(features))
+}
+
+func TestGeoJSON_GetFeatures(t *testing.T) {
+	g := newAddressesGeoJSON("")
+	refDate := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		criteria    datasources.FeaturesCriteria
+		wantFIDs    []int64
+		wantCursors domain.Cursors
+		wantErr     bool
+	}{
+		{
+			name:        "first page",
+			criteria:    datasources.FeaturesCriteria{Cursor: domain.DecodedCursor{FID: 0}, Limit: 3},
+			wantFIDs:    []int64{1, 2, 3},
+			wantCursors: domain.NewCursors(domain.PrevNextFID{Prev: 0, Next: 4}, nil),
+		},
+		{
+			name:        "second page",
+			criteria:    datasources.FeaturesCriteria{Cursor: domain.DecodedCursor{FID: 4}, Limit: 3},
+			wantFIDs:    []int64{4, 5, 6},
+			wantCursors: domain.NewCursors(domain.PrevNextFID{Prev: 1, Next: 7}, nil),
+		},
+		{
+			name:        "last page",
+			criteria:    datasources.FeaturesCriteria{Cursor: domain.DecodedCursor{FID: 7}, Limit: 3},
+			wantFIDs:    []int64{7, 8},
+			wantCursors: domain.NewCursors(domain.PrevNextFID{Prev: 4, Next: 0}, nil),
+		},
+		{
+			name:        "beyond last page",
+			criteria:    datasources.FeaturesCriteria{Cursor: domain.DecodedCursor{FID: 9}, Limit: 3},
+			wantFIDs:    nil,
+			wantCursors: domain.Cursors{},
+		},
+		{
+			name: "bbox",
+			criteria: datasources.FeaturesCriteria{
+				Limit: 10,
+				Bbox:  &geom.Extent{4.890, 52.385, 4.8936, 52.388},
+			},
+			wantFIDs:    []int64{1, 2, 3},
+			wantCursors: domain.NewCursors(domain.PrevNextFID{}, nil),
+		},
+		{
+			name: "bbox intersecting polygon",
+			criteria: datasources.FeaturesCriteria{
+				Limit: 10,
+				Bbox:  &geom.Extent{4.886, 52.385, 4.887, 52.3855},
+			},
+			wantFIDs:    []int64{7},
+			wantCursors: domain.NewCursors(domain.PrevNextFID{}, nil),
+		},
+		{
+			name: "property filter with multiple values",
+			criteria: datasources.FeaturesCriteria{
+				Limit:           10,
+				PropertyFilters: map[string]string{"straatnaam": "Damrak,Westerdok"},
+			},
+			wantFIDs:    []int64{5, 6, 7, 8},
+			wantCursors: domain.NewCursors(domain.PrevNextFID{}, nil),
+		},
+		{
+			name: "property filter with prefix and numeric value",
+			criteria: datasources.FeaturesCriteria{
+				Limit:           10,
+				PropertyFilters: map[string]string{"postcode": "1013*", "huisnummer": "3"},
+			},
+			wantFIDs:    []int64{2},
+			wantCursors: domain.NewCursors(domain.PrevNextFID{}, nil),
+		},
+		{
+			name: "paging with filter",
+			criteria: datasources.FeaturesCriteria{
+				Cursor:          domain.DecodedCursor{FID: 3},
+				Limit:           2,
+				PropertyFilters: map[string]string{"postcode": "1013*"},
+			},
+			wantFIDs:    []int64{3, 4},
+			wantCursors: domain.NewCursors(domain.PrevNextFID{Prev: 1, Next: 7}, nil),
+		},
+		{
+			name: "reference date",
+			criteria: datasources.FeaturesCriteria{
+				Limit: 10,
+				TemporalCriteria: datasources.TemporalCriteria{
+					ReferenceDate:     refDate,
+					StartDateProperty: "datum_strt",
+					EndDateProperty:   "datum_eind",
+				},
+			},
+			wantFIDs:    []int64{1, 3, 4, 5, 6, 7, 8},
+			wantCursors: domain.NewCursors(domain.PrevNextFID{}, nil),
+		},
+		{
+			name: "reference interval",
+			criteria: datasources.FeaturesCriteria{
+				Limit: 10,
+				TemporalCriteria: datasources.TemporalCriteria{
+					ReferenceInterval: &datasources.Interval{
+						Start: time.Date(2011, 1, 1, 0, 0, 0, 0, time.UTC),
+						End:   time.Date(2013, 1, 1, 0, 0, 0, 0, time.UTC),
+					},
+					StartDateProperty: "datum_strt",
+					EndDateProperty:   "datum_eind",
+				},
+			},
+			wantFIDs:    []int64{1, 2, 5, 6},
+			wantCursors: domain.NewCursors(domain.PrevNextFID{}, nil),
+		},
+		{
+			name:     "sortby is not supported",
+			criteria: datasources.FeaturesCriteria{Limit: 10, SortBy: []datasources.SortKey{{Property: "straatnaam"}}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc, cursors, err := g.GetFeatures(context.Background(), "addresses", tt.criteria)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCursors, cursors)
+			var fids []int64
+			if fc != nil {
+				assert.Equal(t, len(fc.Features), fc.NumberReturned)
+				for _, feature := range fc.Features {
+					fids = append(fids, feature.FID)
+				}
+			}
+			assert.Equal(t, tt.wantFIDs, fids)
+
+			ids, idCursors, err := g.GetFeatureIDs(context.Background(), "addresses", tt.criteria)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFIDs, ids)
+			assert.Equal(t, tt.wantCursors, idCursors)
+		})
+	}
+}
+
+func TestGeoJSON_CountFeatures(t *testing.T) {
+	g := newAddressesGeoJSON("")
+	count, err := g.CountFeatures(context.Background(), "addresses", datasources.FeaturesCriteria{})
+	require.NoError(t, err)
+	assert.Equal(t, 8, count)
+
+	count, err = g.CountFeatures(context.Background(), "addresses", datasources.FeaturesCriteria{
+		PropertyFilters: map[string]string{"straatnaam": "Silodam"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestGeoJSON_GetFeature(t *testing.T) {
+	g := newAddressesGeoJSON("")
+	feature, err := g.GetFeature(context.Background(), "addresses", int64(3), datasources.PropertySelection{})
+	require.NoError(t, err)
+	require.NotNil(t, feature)
+	assert.Equal(t, int64(3), feature.ID)
+	assert.Equal(t, "Van Diemenkade", feature.Properties["straatnaam"])
+	assert.Equal(t, int64(10), feature.Properties["huisnummer"])
+	assert.Equal(t, 95.25, feature.Properties["oppervlakte"])
+	assert.Equal(t, time.Date(2015, 3, 15, 0, 0, 0, 0, time.UTC), feature.Properties["datum_strt"])
+	assert.Equal(t, geom.Point{4.8935, 52.3872}, feature.Geometry.Geometry)
+
+	feature, err = g.GetFeature(context.Background(), "addresses", int64(99), datasources.PropertySelection{})
+	require.NoError(t, err)
+	assert.Nil(t, feature)
+}
+
+func TestGeoJSON_GetFeature_ExternalFid(t *testing.T) {
+	g := newAddressesGeoJSON("postcode")
+	feature, err := g.GetFeature(context.Background(), "addresses", "1012LG", datasources.PropertySelection{})
+	require.NoError(t, err)
+	require.NotNil(t, feature)
+	assert.Equal(t, "1012LG", feature.ID)
+	assert.Equal(t, int64(5), feature.FID)
+	assert.NotContains(t, feature.Properties, "postcode")
+}
+
+func TestGeoJSON_GetFeaturesByID(t *testing.T) {
+	g := newAddressesGeoJSON("")
+	selection := datasources.PropertySelection{Properties: []string{"straatnaam"}, SkipGeometry: true}
+	fc, err := g.GetFeaturesByID(context.Background(), "addresses", []int64{6, 99, 2}, selection)
+	require.NoError(t, err)
+	require.Len(t, fc.Features, 2)
+	assert.Equal(t, 2, fc.NumberReturned)
+	assert.Equal(t, int64(6), fc.Features[0].FID)
+	assert.Equal(t, int64(2), fc.Features[1].FID)
+	assert.Equal(t, map[string]any{"straatnaam": "Silodam"}, fc.Features[1].Properties)
+	assert.Nil(t, fc.Features[1].Geometry)
+}
+
+func TestGeoJSON_doesNotModifyFeatures(t *testing.T) {
+	g := newAddressesGeoJSON("")
+	feature, err := g.GetFeature(context.Background(), "addresses", int64(1), datasources.PropertySelection{})
+	require.NoError(t, err)
+	feature.Properties["straatnaam"] = "changed"
+	feature.Geometry.Geometry = geom.Point{0, 0}
+
+	feature, err = g.GetFeature(context.Background(), "addresses", int64(1), datasources.PropertySelection{})
+	require.NoError(t, err)
+	assert.Equal(t, "Silodam", feature.Properties["straatnaam"])
+	assert.Equal(t, geom.Point{4.8906, 52.3859}, feature.Geometry.Geometry)
+}
@@ -0,0 +1,201 @@
+package geojson
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom"
+)
+
+// maximum number of entries in a node of the R-tree
+const nodeCapacity = 16
+
+// rtree is a static R-tree over the envelopes of feature geometries, bulk-loaded once using the
+// Sort-Tile-Recursive (STR) algorithm since the features don't change after they're loaded.
+type rtree struct {
+	root *rtreeNode
+}
+
+type rtreeNode struct {
+	extent   geom.Extent
+	children []*rtreeNode    // for non-leaf nodes
+	feature  *domain.Feature // for leaf entries
+}
+
+func newRTree(features []*domain.Feature) *rtree {
+	entries := make([]*rtreeNode, 0, len(features))
+	for _, feature := range features {
+		if feature.Geometry == nil {
+			continue
+		}
+		extent, err := geom.NewExtentFromGeometry(feature.Geometry.Geometry)
+		if err != nil {
+			continue // e.g. empty geometry
+		}
+		entries = append(entries, &rtreeNode{extent: *extent, feature: feature})
+	}
+	if len(entries) == 0 {
+		return &rtree{}
+	}
+	for len(entries) > 1 {
+		entries = packNodes(entries)
+	}
+	return &rtree{root: entries[0]}
+}
+
+// packNodes groups the given nodes in parent nodes: sorted in vertical slices by x, each slice sorted by y
+func packNodes(nodes []*rtreeNode) []*rtreeNode {
+	parentCount := int(math.Ceil(float64(len(nodes)) / nodeCapacity))
+	sliceSize := int(math.Ceil(math.Sqrt(float64(parentCount)))) * nodeCapacity
+
+	slices.SortFunc(nodes, func(a, b *rtreeNode) int {
+		return cmp.Compare(a.extent.MinX()+a.extent.MaxX(), b.extent.MinX()+b.extent.MaxX())
+	})
+	parents := make([]*rtreeNode, 0, parentCount)
+	for i := 0; i < len(nodes); i += sliceSize {
+		slice := nodes[i:min(i+sliceSize, len(nodes))]
+		slices.SortFunc(slice, func(a, b *rtreeNode) int {
+			return cmp.Compare(a.extent.MinY()+a.extent.MaxY(), b.extent.MinY()+b.extent.MaxY())
+		})
+		for j := 0; j < len(slice); j += nodeCapacity {
+			children := slice[j:min(j+nodeCapacity, len(slice))]
+			parent := &rtreeNode{extent: children[0].extent, children: children}
+			for _, child := range children[1:] {
+				parent.extent.Add(&child.extent)
+			}
+			parents = append(parents, parent)
+		}
+	}
+	return parents
+}
+
+// search returns the features of which the envelope intersects the given bbox, ordered by fid
+func (t *rtree) search(bbox geom.Extent) []*domain.Feature {
+	var result []*domain.Feature
+	if t.root == nil {
+		return result
+	}
+	stack := []*rtreeNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !overlaps(node.extent, bbox) {
+			continue
+		}
+		if node.feature != nil {
+			result = append(result, node.feature)
+			continue
+		}
+		stack = append(stack, node.children...)
+	}
+	slices.SortFunc(result, func(a, b *domain.Feature) int {
+		return cmp.Compare(a.FID, b.FID)
+	})
+	return result
+}
+
+// overlaps returns true when the given extents intersect, including touching edges
+func overlaps(a, b geom.Extent) bool {
+	return a.MinX() <= b.MaxX() && a.MaxX() >= b.MinX() && a.MinY() <= b.MaxY() && a.MaxY() >= b.MinY()
+}
+
+// intersects returns true when the given geometry intersects the given bbox, like ST_Intersects
+func intersects(bbox geom.Extent, geometry geom.Geometry) bool {
+	switch g := geometry.(type) {
+	case geom.Pointer:
+		return bbox.ContainsPoint(g.XY())
+	case geom.LineStringer: // before MultiPointer, since linestrings implement both
+		return lineIntersects(bbox, g.Vertices())
+	case geom.MultiPointer:
+		return slices.ContainsFunc(g.Points(), bbox.ContainsPoint)
+	case geom.MultiLineStringer:
+		return slices.ContainsFunc(g.LineStrings(), func(line [][2]float64) bool {
+			return lineIntersects(bbox, line)
+		})
+	case geom.Polygoner:
+		return polygonIntersects(bbox, g.LinearRings())
+	case geom.MultiPolygoner:
+		return slices.ContainsFunc(g.Polygons(), func(rings [][][2]float64) bool {
+			return polygonIntersects(bbox, rings)
+		})
+	case geom.Collectioner:
+		return slices.ContainsFunc(g.Geometries(), func(member geom.Geometry) bool {
+			return intersects(bbox, member)
+		})
+	}
+	return false
+}
+
+func lineIntersects(bbox geom.Extent, line [][2]float64) bool {
+	if len(line) == 1 {
+		return bbox.ContainsPoint(line[0])
+	}
+	for i := 1; i < len(line); i++ {
+		if segmentIntersects(bbox, line[i-1], line[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func polygonIntersects(bbox geom.Extent, rings [][][2]float64) bool {
+	if len(rings) == 0 {
+		return false
+	}
+	// boundary of polygon intersects bbox (this includes the polygon being inside the bbox)
+	for _, ring := range rings {
+		if lineIntersects(bbox, ring) {
+			return true
+		}
+	}
+	// otherwise the bbox is either completely inside or outside the polygon
+	corner := bbox.Min()
+	if !pointInRing(corner, rings[0]) {
+		return false
+	}
+	for _, hole := range rings[1:] {
+		if pointInRing(corner, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+// segmentIntersects returns true when the line segment from a to b intersects the bbox (Liang-Barsky clipping)
+func segmentIntersects(bbox geom.Extent, a, b [2]float64) bool {
+	tMin, tMax := 0.0, 1.0
+	d := [2]float64{b[0] - a[0], b[1] - a[1]}
+	for axis := 0; axis < 2; axis++ {
+		lower, upper := bbox.Min()[axis], bbox.Max()[axis]
+		if d[axis] == 0 {
+			if a[axis] < lower || a[axis] > upper {
+				return false
+			}
+			continue
+		}
+		t1, t2 := (lower-a[axis])/d[axis], (upper-a[axis])/d[axis]
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tMin, tMax = math.Max(tMin, t1), math.Min(tMax, t2)
+		if tMin > tMax {
+			return false
+		}
+	}
+	return true
+}
+
+// pointInRing returns true when the given point is inside the given ring (ray casting)
+func pointInRing(point [2]float64, ring [][2]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > point[1]) != (b[1] > point[1]) &&
+			point[0] < (b[0]-a[0])*(point[1]-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
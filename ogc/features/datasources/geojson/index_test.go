@@ -0,0 +1,61 @@
+package geojson
+
+import (
+	"testing"
+
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/geojson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntersects(t *testing.T) {
+	bbox := geom.Extent{0, 0, 10, 10}
+	square := [][2]float64{{-5, -5}, {15, -5}, {15, 15}, {-5, 15}, {-5, -5}}
+	hole := [][2]float64{{-2, -2}, {12, -2}, {12, 12}, {-2, 12}, {-2, -2}}
+	tests := []struct {
+		name     string
+		geometry geom.Geometry
+		want     bool
+	}{
+		{"point inside", geom.Point{5, 5}, true},
+		{"point on edge", geom.Point{10, 5}, true},
+		{"point outside", geom.Point{11, 5}, false},
+		{"linestring crossing bbox", geom.LineString{{-5, 5}, {15, 5}}, true},
+		{"linestring outside bbox", geom.LineString{{-5, 11}, {15, 11}}, false},
+		{"linestring passing corner", geom.LineString{{-5, 4}, {4, -5}}, false},
+		{"multipoint", geom.MultiPoint{{20, 20}, {1, 1}}, true},
+		{"polygon containing bbox", geom.Polygon{square}, true},
+		{"polygon with hole containing bbox", geom.Polygon{square, hole}, false},
+		{"polygon inside bbox", geom.Polygon{{{1, 1}, {2, 1}, {2, 2}, {1, 1}}}, true},
+		{"polygon outside bbox", geom.Polygon{{{11, 11}, {12, 11}, {12, 12}, {11, 11}}}, false},
+		{"multipolygon", geom.MultiPolygon{{{{11, 11}, {12, 11}, {12, 12}, {11, 11}}}, {square}}, true},
+		{"collection", geom.Collection{geom.Point{20, 20}, geom.LineString{{5, -5}, {5, 15}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, intersects(bbox, tt.geometry))
+		})
+	}
+}
+
+func TestRTree_search(t *testing.T) {
+	var features []*domain.Feature
+	for i := 0; i < 1000; i++ {
+		x, y := float64(i%50), float64(i/50)
+		features = append(features, &domain.Feature{
+			FID:      int64(i + 1),
+			Geometry: &geojson.Geometry{Geometry: geom.Point{x, y}},
+		})
+	}
+	features = append(features, &domain.Feature{FID: 1001}) // without geometry
+	index := newRTree(features)
+
+	var fids []int64
+	for _, feature := range index.search(geom.Extent{10, 2, 11.5, 3}) {
+		fids = append(fids, feature.FID)
+	}
+	assert.Equal(t, []int64{111, 112, 161, 162}, fids)
+	assert.Empty(t, index.search(geom.Extent{100, 100, 200, 200}))
+	assert.Empty(t, newRTree(nil).search(geom.Extent{0, 0, 10, 10}))
+}
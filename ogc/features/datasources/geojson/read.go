@@ -0,0 +1,296 @@
+package geojson
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom"
+)
+
+const (
+	dateFormat = time.DateOnly
+
+	// record separator used in GeoJSON Text Sequences (RFC 8142)
+	recordSeparator = 0x1E
+)
+
+// readGeoJSONFile reads all features from the given GeoJSON file, which is either a FeatureCollection or a
+// sequence of Features. Features are assigned an internal feature id (fid): the numeric 'id' of the features
+// when all features have a unique positive integer id, otherwise the (1-based) position of the feature in the file.
+func readGeoJSONFile(file string) ([]*domain.Feature, *featureTable, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileInfo, err := os.Stat(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	features, err := decodeFeatures(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	assignFIDs(features)
+
+	table := &featureTable{
+		columns:      inferDataTypes(features),
+		geometryType: inferGeometryType(features),
+		lastModified: fileInfo.ModTime(),
+	}
+	for _, reserved := range []string{fidColumn, geometryColumn} {
+		if _, ok := table.columns[reserved]; ok {
+			return nil, nil, fmt.Errorf("property name '%s' is reserved, rename this property", reserved)
+		}
+	}
+	for _, feature := range features {
+		convertProperties(feature, table.columns)
+	}
+	return features, table, nil
+}
+
+// decodeFeatures decodes a FeatureCollection or a sequence of Features
+func decodeFeatures(content []byte) ([]*domain.Feature, error) {
+	content = bytes.ReplaceAll(content, []byte{recordSeparator}, []byte{' '})
+	decoder := json.NewDecoder(bytes.NewReader(content))
+
+	var features []*domain.Feature
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		var object struct {
+			Type     string            `json:"type"`
+			Features []json.RawMessage `json:"features"`
+		}
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return nil, err
+		}
+		switch object.Type {
+		case "FeatureCollection":
+			for _, rawFeature := range object.Features {
+				feature, err := decodeFeature(rawFeature)
+				if err != nil {
+					return nil, err
+				}
+				features = append(features, feature)
+			}
+		case "Feature":
+			feature, err := decodeFeature(raw)
+			if err != nil {
+				return nil, err
+			}
+			features = append(features, feature)
+		default:
+			return nil, fmt.Errorf("expected a GeoJSON FeatureCollection or Feature, got '%s'", object.Type)
+		}
+	}
+	return features, nil
+}
+
+func decodeFeature(raw json.RawMessage) (*domain.Feature, error) {
+	var feature domain.Feature
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber() // to distinguish integers from floating point numbers
+	if err := decoder.Decode(&feature); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON feature: %w", err)
+	}
+	if feature.Properties == nil {
+		feature.Properties = make(map[string]any)
+	}
+	if feature.Geometry != nil && feature.Geometry.Geometry == nil {
+		feature.Geometry = nil
+	}
+	return &feature, nil
+}
+
+func assignFIDs(features []*domain.Feature) {
+	fids := make(map[int64]bool, len(features))
+	for _, feature := range features {
+		number, ok := feature.ID.(json.Number)
+		if !ok {
+			break
+		}
+		fid, err := number.Int64()
+		if err != nil || fid <= 0 || fids[fid] {
+			break
+		}
+		fids[fid] = true
+	}
+	useIDs := len(fids) == len(features)
+	for i, feature := range features {
+		if useIDs {
+			feature.FID, _ = feature.ID.(json.Number).Int64()
+		} else {
+			feature.FID = int64(i + 1)
+		}
+		feature.ID = feature.FID
+	}
+	slices.SortFunc(features, func(a, b *domain.Feature) int {
+		return cmp.Compare(a.FID, b.FID)
+	})
+}
+
+// inferDataTypes determines the data type of each property based on the (non-null) values of all features.
+// Data types match the ones used by the GeoPackage datasource, properties with mixed values are TEXT.
+func inferDataTypes(features []*domain.Feature) map[string]string {
+	result := make(map[string]string)
+	for _, feature := range features {
+		for property, value := range feature.Properties {
+			dataType, ok := result[property]
+			if value == nil {
+				if !ok {
+					result[property] = ""
+				}
+				continue
+			}
+			valueType := dataTypeOf(value)
+			switch {
+			case !ok || dataType == "":
+				result[property] = valueType
+			case dataType == "INTEGER" && valueType == "REAL":
+				result[property] = "REAL"
+			case dataType == "REAL" && valueType == "INTEGER":
+				continue
+			case dataType != valueType:
+				result[property] = "TEXT"
+			}
+		}
+	}
+	for property, dataType := range result {
+		if dataType == "" {
+			result[property] = "TEXT" // only null values
+		}
+	}
+	return result
+}
+
+func dataTypeOf(value any) string {
+	switch v := value.(type) {
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "INTEGER"
+		}
+		return "REAL"
+	case bool:
+		return "BOOLEAN"
+	case string:
+		if _, err := time.Parse(dateFormat, v); err == nil {
+			return "DATE"
+		}
+		if _, err := time.Parse(time.RFC3339, v); err == nil {
+			return "DATETIME"
+		}
+	}
+	return "TEXT"
+}
+
+// inferGeometryType returns the geometry type of all features, or GEOMETRY when features have different geometry types
+func inferGeometryType(features []*domain.Feature) string {
+	result := ""
+	for _, feature := range features {
+		if feature.Geometry == nil {
+			continue
+		}
+		geometryType := geometryTypeOf(feature.Geometry.Geometry)
+		if result != "" && result != geometryType {
+			return "GEOMETRY"
+		}
+		result = geometryType
+	}
+	if result == "" {
+		return "GEOMETRY"
+	}
+	return result
+}
+
+func geometryTypeOf(geometry geom.Geometry) string {
+	switch geometry.(type) {
+	case geom.Point:
+		return "POINT"
+	case geom.LineString:
+		return "LINESTRING"
+	case geom.Polygon:
+		return "POLYGON"
+	case geom.MultiPoint:
+		return "MULTIPOINT"
+	case geom.MultiLineString:
+		return "MULTILINESTRING"
+	case geom.MultiPolygon:
+		return "MULTIPOLYGON"
+	case geom.Collection:
+		return "GEOMETRYCOLLECTION"
+	default:
+		return "GEOMETRY"
+	}
+}
+
+// convertProperties converts the property values of the given feature to the values returned by the GeoPackage
+// datasource for the given data types: int64 for integers, float64 for other numbers and time.Time for dates.
+func convertProperties(feature *domain.Feature, dataTypes map[string]string) {
+	for property, value := range feature.Properties {
+		switch v := value.(type) {
+		case json.Number:
+			if asInt, err := v.Int64(); err == nil && dataTypes[property] != "REAL" {
+				feature.Properties[property] = asInt
+			} else if asFloat, err := v.Float64(); err == nil {
+				feature.Properties[property] = asFloat
+			}
+		case string:
+			switch dataTypes[property] {
+			case "DATE":
+				feature.Properties[property], _ = time.Parse(dateFormat, v)
+			case "DATETIME":
+				feature.Properties[property], _ = time.Parse(time.RFC3339, v)
+			}
+		case map[string]any, []any:
+			feature.Properties[property] = normalizeNumbers(v)
+		}
+	}
+}
+
+// normalizeNumbers replaces json.Number in nested objects/arrays with regular numbers
+func normalizeNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if asInt, err := v.Int64(); err == nil {
+			return asInt
+		}
+		asFloat, _ := v.Float64()
+		return asFloat
+	case map[string]any:
+		for key, nested := range v {
+			v[key] = normalizeNumbers(nested)
+		}
+	case []any:
+		for i, nested := range v {
+			v[i] = normalizeNumbers(nested)
+		}
+	}
+	return value
+}
+
+// formatValue formats the given property value like it's stored in a GeoPackage, used to match property filters
+func formatValue(value any, dataType string) string {
+	switch v := value.(type) {
+	case time.Time:
+		if dataType == "DATE" {
+			return v.Format(dateFormat)
+		}
+		return v.Format(time.RFC3339)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -48,6 +48,20 @@ func assertIndexesExist(
 						return err
 					}
 				}
+
+				// assert the column for each sortable is indexed (as first column), required for keyset pagination
+				for _, sortable := range coll.Features.Sortables {
+					if err := assertIndexExists(table.TableName, db, sortable, true); err != nil {
+						return err
+					}
+				}
+
+				// assert the external feature id is indexed (as first column), needed to lookup features by this id
+				if coll.Features.ExternalFid != "" {
+					if err := assertIndexExists(table.TableName, db, coll.Features.ExternalFid, true); err != nil {
+						return err
+					}
+				}
 				break
 			}
 		}
@@ -14,12 +14,16 @@ type localGeoPackage struct {
 	db *sqlx.DB
 }
 
-func newLocalGeoPackage(gpkg *config.GeoPackageLocal) geoPackageBackend {
-	db, err := sqlx.Open(sqliteDriverName, fmt.Sprintf("file:%s?mode=ro", gpkg.File))
+func newLocalGeoPackage(gpkg *config.GeoPackageLocal, writable bool) geoPackageBackend {
+	mode := "ro"
+	if writable {
+		mode = "rw"
+	}
+	db, err := sqlx.Open(sqliteDriverName, fmt.Sprintf("file:%s?mode=%s", gpkg.File, mode))
 	if err != nil {
 		log.Fatalf("failed to open GeoPackage: %v", err)
 	}
-	log.Printf("connected to local GeoPackage: %s", gpkg.File)
+	log.Printf("connected to local GeoPackage: %s (mode: %s)", gpkg.File, mode)
 
 	return &localGeoPackage{db}
 }
@@ -0,0 +1,380 @@
+package geopackage
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/PDOK/gokoala/ogc/features/cql"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/wkt"
+)
+
+const (
+	// GeoPackage stores datetime values as ISO-8601 text, see http://www.geopackage.org/spec/#_data_types
+	gpkgDateTimeFormat = "2006-01-02T15:04:05.000Z"
+
+	// used to substitute NULL start/end dates of an interval, so open intervals can be compared
+	minDate = "0000-01-01"
+	maxDate = "9999-12-31T23:59:59.999Z"
+)
+
+var spatialFunctions = map[cql.SpatialOperator]string{
+	cql.SIntersects: "st_intersects",
+	cql.SEquals:     "st_equals",
+	cql.SDisjoint:   "st_disjoint",
+	cql.STouches:    "st_touches",
+	cql.SWithin:     "st_within",
+	cql.SOverlaps:   "st_overlaps",
+	cql.SCrosses:    "st_crosses",
+	cql.SContains:   "st_contains",
+}
+
+// filterToSQL translates the given CQL filter (AST) to a SQL where-clause (starting with 'and')
+// for the given feature table. Literals are never added to the SQL itself, they're added as named params.
+// Optionally columns are prefixed with the given table alias (e.g. "f.") to prevent ambiguity in joins.
+func filterToSQL(filter cql.Expression, table *featureTable, inputSRID int, columnPrefix string) (sql string, namedParams map[string]any, err error) {
+	namedParams = make(map[string]any)
+	if filter == nil {
+		return "", namedParams, nil
+	}
+	b := &filterBuilder{table: table, inputSRID: inputSRID, columnPrefix: columnPrefix, namedParams: namedParams}
+	sql, err = b.expression(filter)
+	if err != nil {
+		return "", nil, err
+	}
+	return " and " + sql, namedParams, nil
+}
+
+type filterBuilder struct {
+	table        *featureTable
+	inputSRID    int
+	columnPrefix string
+	namedParams  map[string]any
+}
+
+// add named param for given value, returns the placeholder
+func (b *filterBuilder) param(value any) string {
+	name := fmt.Sprintf("cql%d", len(b.namedParams)+1)
+	b.namedParams[name] = value
+	return ":" + name
+}
+
+//nolint:cyclop,funlen
+func (b *filterBuilder) expression(expr cql.Expression) (string, error) {
+	switch e := expr.(type) {
+	case cql.And:
+		return b.logical(e.Children, "and")
+	case cql.Or:
+		return b.logical(e.Children, "or")
+	case cql.Not:
+		child, err := b.expression(e.Child)
+		if err != nil {
+			return "", err
+		}
+		return "not " + child, nil
+	case cql.BooleanLiteral:
+		return booleanSQL(e.Value), nil
+	case cql.Comparison:
+		left, right, err := b.scalars(e.Left, e.Right)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s %s", left, e.Operator, right), nil
+	case cql.Like:
+		value, pattern, err := b.scalars(e.Value, e.Pattern)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %slike %s escape '\\'", value, negate(e.Negate), pattern), nil
+	case cql.Between:
+		value, lower, err := b.scalars(e.Value, e.Lower)
+		if err != nil {
+			return "", err
+		}
+		upper, err := b.scalar(e.Upper)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %sbetween %s and %s", value, negate(e.Negate), lower, upper), nil
+	case cql.In:
+		value, err := b.scalar(e.Value)
+		if err != nil {
+			return "", err
+		}
+		list := make([]string, 0, len(e.List))
+		for _, item := range e.List {
+			itemSQL, err := b.scalar(item)
+			if err != nil {
+				return "", err
+			}
+			list = append(list, itemSQL)
+		}
+		return fmt.Sprintf("%s %sin (%s)", value, negate(e.Negate), strings.Join(list, ", ")), nil
+	case cql.IsNull:
+		value, err := b.scalar(e.Value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s is %snull", value, negate(e.Negate)), nil
+	case cql.SpatialPredicate:
+		return b.spatial(e)
+	case cql.TemporalPredicate:
+		return b.temporal(e)
+	default:
+		return "", fmt.Errorf("unsupported filter expression: %T", expr)
+	}
+}
+
+func (b *filterBuilder) logical(children []cql.Expression, operator string) (string, error) {
+	result := make([]string, 0, len(children))
+	for _, child := range children {
+		childSQL, err := b.expression(child)
+		if err != nil {
+			return "", err
+		}
+		result = append(result, childSQL)
+	}
+	return "(" + strings.Join(result, " "+operator+" ") + ")", nil
+}
+
+func (b *filterBuilder) scalars(left cql.Operand, right cql.Operand) (string, string, error) {
+	leftSQL, err := b.scalar(left)
+	if err != nil {
+		return "", "", err
+	}
+	rightSQL, err := b.scalar(right)
+	return leftSQL, rightSQL, err
+}
+
+func (b *filterBuilder) scalar(operand cql.Operand) (string, error) {
+	switch o := operand.(type) {
+	case cql.Property:
+		return b.column(o)
+	case cql.String:
+		return b.param(o.Value), nil
+	case cql.Number:
+		if o.Value == math.Trunc(o.Value) && math.Abs(o.Value) < math.MaxInt64 {
+			return b.param(int64(o.Value)), nil
+		}
+		return b.param(o.Value), nil
+	case cql.Boolean:
+		return b.param(o.Value), nil
+	case cql.Date:
+		return b.param(o.Value.Format(time.DateOnly)), nil
+	case cql.Timestamp:
+		return b.param(o.Value.Format(gpkgDateTimeFormat)), nil
+	default:
+		return "", fmt.Errorf("unsupported operand in scalar predicate: %T", operand)
+	}
+}
+
+// column name in double quotes in case it is a reserved keyword.
+// Only existing columns are allowed, this also guards against SQL injection
+func (b *filterBuilder) column(property cql.Property) (string, error) {
+	if _, ok := b.table.ColumnsWithDateType[property.Name]; !ok {
+		return "", fmt.Errorf("property '%s' doesn't exist in table '%s'", property.Name, b.table.TableName)
+	}
+	return fmt.Sprintf("%s\"%s\"", b.columnPrefix, property.Name), nil
+}
+
+func (b *filterBuilder) spatial(predicate cql.SpatialPredicate) (string, error) {
+	function, ok := spatialFunctions[predicate.Operator]
+	if !ok {
+		return "", fmt.Errorf("unsupported spatial operator: %s", predicate.Operator)
+	}
+	left, err := b.geometry(predicate.Left)
+	if err != nil {
+		return "", err
+	}
+	right, err := b.geometry(predicate.Right)
+	if err != nil {
+		return "", err
+	}
+	sql := fmt.Sprintf("%s(%s, %s) = 1", function, left, right)
+
+	// prefilter on the (indexed) bbox columns when comparing the geometry column with a literal.
+	// Except for disjoint, since features outside the bbox of the literal are disjoint by definition
+	if predicate.Operator != cql.SDisjoint {
+		if envelope := b.envelopePrefilter(predicate.Left, predicate.Right); envelope != "" {
+			sql = envelope + " and " + sql
+		}
+	}
+	return "(" + sql + ")", nil
+}
+
+func (b *filterBuilder) geometry(operand cql.Operand) (string, error) {
+	switch o := operand.(type) {
+	case cql.Property:
+		if o.Name != b.table.GeometryColumnName {
+			return "", fmt.Errorf("property '%s' isn't a geometry, expected '%s'", o.Name, b.table.GeometryColumnName)
+		}
+		return fmt.Sprintf("castautomagic(%s\"%s\")", b.columnPrefix, o.Name), nil
+	case cql.Geometry:
+		geomAsWKT, err := wkt.EncodeString(o.Geometry)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode geometry in filter as WKT: %w", err)
+		}
+		return fmt.Sprintf("geomfromtext(%s, %s)", b.param(geomAsWKT), b.param(b.inputSRID)), nil
+	default:
+		return "", fmt.Errorf("unsupported operand in spatial predicate: %T", operand)
+	}
+}
+
+func (b *filterBuilder) envelopePrefilter(left cql.Operand, right cql.Operand) string {
+	var literal cql.Geometry
+	if p, ok := left.(cql.Property); ok && p.Name == b.table.GeometryColumnName {
+		literal, ok = right.(cql.Geometry)
+		if !ok {
+			return ""
+		}
+	} else if p, ok := right.(cql.Property); ok && p.Name == b.table.GeometryColumnName {
+		literal, ok = left.(cql.Geometry)
+		if !ok {
+			return ""
+		}
+	} else {
+		return ""
+	}
+	extent, err := geom.NewExtentFromGeometry(literal.Geometry)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%[1]sminx <= %[2]s and %[1]smaxx >= %[3]s and %[1]sminy <= %[4]s and %[1]smaxy >= %[5]s",
+		b.columnPrefix, b.param(extent.MaxX()), b.param(extent.MinX()), b.param(extent.MaxY()), b.param(extent.MinY()))
+}
+
+// bound of a time instant or interval in SQL. A bound is either a SQL expression or unbounded (-inf/+inf)
+type bound struct {
+	sql       string
+	unbounded int // -1 for -infinity, +1 for +infinity, 0 for a bounded value
+}
+
+// Translates temporal functions to comparisons between the start/end of both operands
+// according to https://docs.ogc.org/is/21-065r2/21-065r2.html#temporal-functions
+//
+//nolint:cyclop
+func (b *filterBuilder) temporal(predicate cql.TemporalPredicate) (string, error) {
+	s1, e1, err := b.temporalBounds(predicate.Left)
+	if err != nil {
+		return "", err
+	}
+	s2, e2, err := b.temporalBounds(predicate.Right)
+	if err != nil {
+		return "", err
+	}
+	var conditions []string
+	switch predicate.Operator {
+	case cql.TAfter:
+		conditions = []string{compare(s1, ">", e2)}
+	case cql.TBefore:
+		conditions = []string{compare(e1, "<", s2)}
+	case cql.TContains:
+		conditions = []string{compare(s1, "<", s2), compare(e1, ">", e2)}
+	case cql.TDisjoint:
+		return fmt.Sprintf("(%s or %s)", compare(e1, "<", s2), compare(s1, ">", e2)), nil
+	case cql.TDuring:
+		conditions = []string{compare(s1, ">", s2), compare(e1, "<", e2)}
+	case cql.TEquals:
+		conditions = []string{compare(s1, "=", s2), compare(e1, "=", e2)}
+	case cql.TFinishedBy:
+		conditions = []string{compare(s1, "<", s2), compare(e1, "=", e2)}
+	case cql.TFinishes:
+		conditions = []string{compare(s1, ">", s2), compare(e1, "=", e2)}
+	case cql.TIntersects:
+		conditions = []string{compare(s1, "<=", e2), compare(e1, ">=", s2)}
+	case cql.TMeets:
+		conditions = []string{compare(e1, "=", s2)}
+	case cql.TMetBy:
+		conditions = []string{compare(s1, "=", e2)}
+	case cql.TOverlappedBy:
+		conditions = []string{compare(s1, ">", s2), compare(s1, "<", e2), compare(e1, ">", e2)}
+	case cql.TOverlaps:
+		conditions = []string{compare(s1, "<", s2), compare(e1, ">", s2), compare(e1, "<", e2)}
+	case cql.TStartedBy:
+		conditions = []string{compare(s1, "=", s2), compare(e1, ">", e2)}
+	case cql.TStarts:
+		conditions = []string{compare(s1, "=", s2), compare(e1, "<", e2)}
+	default:
+		return "", fmt.Errorf("unsupported temporal operator: %s", predicate.Operator)
+	}
+	return "(" + strings.Join(conditions, " and ") + ")", nil
+}
+
+// returns start and end of given instant or interval
+func (b *filterBuilder) temporalBounds(operand cql.Operand) (start bound, end bound, err error) {
+	switch o := operand.(type) {
+	case cql.Property, cql.Date, cql.Timestamp:
+		instant, err := b.scalar(o)
+		if err != nil {
+			return bound{}, bound{}, err
+		}
+		return bound{sql: instant}, bound{sql: instant}, nil
+	case cql.Interval:
+		start, err = b.intervalBoundary(o.Start, -1)
+		if err != nil {
+			return bound{}, bound{}, err
+		}
+		end, err = b.intervalBoundary(o.End, 1)
+		return start, end, err
+	default:
+		return bound{}, bound{}, fmt.Errorf("unsupported operand in temporal predicate: %T", operand)
+	}
+}
+
+func (b *filterBuilder) intervalBoundary(operand cql.Operand, unbounded int) (bound, error) {
+	switch o := operand.(type) {
+	case cql.Unbounded:
+		return bound{unbounded: unbounded}, nil
+	case cql.Property:
+		// a NULL start/end date in the datasource means the interval is open
+		column, err := b.column(o)
+		if err != nil {
+			return bound{}, err
+		}
+		substitute := minDate
+		if unbounded > 0 {
+			substitute = maxDate
+		}
+		return bound{sql: fmt.Sprintf("coalesce(%s, '%s')", column, substitute)}, nil
+	default:
+		sql, err := b.scalar(o)
+		return bound{sql: sql}, err
+	}
+}
+
+// compare two bounds, when one of the bounds is unbounded the comparison is evaluated statically
+func compare(left bound, operator string, right bound) string {
+	if left.unbounded == 0 && right.unbounded == 0 {
+		return fmt.Sprintf("%s %s %s", left.sql, operator, right.sql)
+	}
+	var result bool
+	switch operator {
+	case "=":
+		result = left.unbounded != 0 && left.unbounded == right.unbounded
+	case "<":
+		result = left.unbounded < right.unbounded
+	case "<=":
+		result = left.unbounded <= right.unbounded
+	case ">":
+		result = left.unbounded > right.unbounded
+	case ">=":
+		result = left.unbounded >= right.unbounded
+	}
+	return booleanSQL(result)
+}
+
+func booleanSQL(value bool) string {
+	if value {
+		return "1 = 1"
+	}
+	return "1 = 0"
+}
+
+func negate(negate bool) string {
+	if negate {
+		return "not "
+	}
+	return ""
+}
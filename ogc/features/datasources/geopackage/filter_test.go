@@ -0,0 +1,103 @@
+package geopackage
+
+import (
+	"testing"
+
+	"github.com/PDOK/gokoala/ogc/features/cql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterToSQL(t *testing.T) {
+	table := &featureTable{
+		TableName:          "ligplaatsen",
+		GeometryColumnName: "geom",
+		ColumnsWithDateType: map[string]string{
+			"feature_id": "INTEGER",
+			"straatnaam": "TEXT",
+			"huisnummer": "INTEGER",
+			"validfrom":  "DATE",
+			"validto":    "DATE",
+			"geom":       "POLYGON",
+		},
+	}
+	tests := []struct {
+		name         string
+		filter       string
+		columnPrefix string
+		wantSQL      string
+		wantParams   map[string]any
+		wantErr      string
+	}{
+		{
+			name:       "no filter",
+			wantSQL:    "",
+			wantParams: map[string]any{},
+		},
+		{
+			name:       "comparison and like",
+			filter:     "straatnaam = 'Silodam' or (huisnummer > 10.5 and straatnaam not like 'Silo%')",
+			wantSQL:    ` and ("straatnaam" = :cql1 or ("huisnummer" > :cql2 and "straatnaam" not like :cql3 escape '\'))`,
+			wantParams: map[string]any{"cql1": "Silodam", "cql2": 10.5, "cql3": "Silo%"},
+		},
+		{
+			name:         "between, in and is null with column prefix",
+			filter:       "huisnummer between 1 and 10 and straatnaam in ('a', 'b') and not validto is null",
+			columnPrefix: "f.",
+			wantSQL:      ` and (f."huisnummer" between :cql1 and :cql2 and f."straatnaam" in (:cql3, :cql4) and not f."validto" is null)`,
+			wantParams:   map[string]any{"cql1": int64(1), "cql2": int64(10), "cql3": "a", "cql4": "b"},
+		},
+		{
+			name:    "spatial with envelope prefilter",
+			filter:  "S_INTERSECTS(geom, BBOX(1, 2, 3, 4))",
+			wantSQL: ` and (minx <= :cql3 and maxx >= :cql4 and miny <= :cql5 and maxy >= :cql6 and st_intersects(castautomagic("geom"), geomfromtext(:cql1, :cql2)) = 1)`,
+			wantParams: map[string]any{"cql1": "POLYGON ((1 2,3 2,3 4,1 4,1 2))", "cql2": 28992,
+				"cql3": 3.0, "cql4": 1.0, "cql5": 4.0, "cql6": 2.0},
+		},
+		{
+			name:       "spatial disjoint without envelope prefilter",
+			filter:     "S_DISJOINT(geom, POINT(1 2))",
+			wantSQL:    ` and (st_disjoint(castautomagic("geom"), geomfromtext(:cql1, :cql2)) = 1)`,
+			wantParams: map[string]any{"cql1": "POINT (1 2)", "cql2": 28992},
+		},
+		{
+			name:       "temporal with open interval",
+			filter:     "T_INTERSECTS(INTERVAL(validfrom, validto), INTERVAL('2020-01-01', '..'))",
+			wantSQL:    ` and (1 = 1 and coalesce("validto", '9999-12-31T23:59:59.999Z') >= :cql1)`,
+			wantParams: map[string]any{"cql1": "2020-01-01"},
+		},
+		{
+			name:       "temporal with instants",
+			filter:     "T_BEFORE(validfrom, TIMESTAMP('2020-01-01T12:00:00Z'))",
+			wantSQL:    ` and ("validfrom" < :cql1)`,
+			wantParams: map[string]any{"cql1": "2020-01-01T12:00:00.000Z"},
+		},
+		{
+			name:    "fail on unknown property",
+			filter:  "foo = 'bar'",
+			wantErr: "property 'foo' doesn't exist in table 'ligplaatsen'",
+		},
+		{
+			name:    "fail on non-geometry property in spatial function",
+			filter:  "S_INTERSECTS(straatnaam, POINT(1 2))",
+			wantErr: "property 'straatnaam' isn't a geometry, expected 'geom'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var filter cql.Expression
+			if tt.filter != "" {
+				var err error
+				filter, err = cql.ParseText(tt.filter)
+				assert.NoError(t, err)
+			}
+			sql, params, err := filterToSQL(filter, table, 28992, tt.columnPrefix)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSQL, sql)
+			assert.Equal(t, tt.wantParams, params)
+		})
+	}
+}
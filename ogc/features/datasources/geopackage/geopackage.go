@@ -8,6 +8,9 @@ import (
 	"maps"
 	"os"
 	"path"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,6 +31,9 @@ import (
 
 const (
 	sqliteDriverName = "sqlite3_with_extensions"
+
+	// SRID of CRS84 (WGS84 with longitude/latitude axis order), the CRS of GeoJSON input
+	crs84SRID = 100000
 )
 
 var once sync.Once
@@ -64,12 +70,30 @@ type featureTable struct {
 	SRS                int64     `db:"srs_id"`
 
 	ColumnsWithDateType map[string]string
+	fidColumn           string
+	externalFidColumn   string // optional, configured per collection
+	writable            bool   // optional, configured per collection
 }
 
 func (ft featureTable) ColumnsWithDataType() map[string]string {
 	return ft.ColumnsWithDateType
 }
 
+func (ft featureTable) IDColumn() string {
+	return ft.fidColumn
+}
+
+func (ft featureTable) GeometryColumn() datasources.GeometryColumn {
+	return datasources.GeometryColumn{Name: ft.GeometryColumnName, Type: ft.GeometryType, SRID: ft.SRS}
+}
+
+func (ft featureTable) LastModified() time.Time {
+	if ft.writable {
+		return time.Time{} // last_change in gpkg_contents isn't maintained on writes
+	}
+	return ft.LastChange
+}
+
 type GeoPackage struct {
 	backend           geoPackageBackend
 	preparedStmtCache *PreparedStatementCache
@@ -78,6 +102,8 @@ type GeoPackage struct {
 	featureTableByCollectionID map[string]*featureTable
 	queryTimeout               time.Duration
 	maxBBoxSizeToUseWithRTree  int
+
+	writeLock sync.Mutex // SQLite supports only a single writer at a time
 }
 
 func NewGeoPackage(collections config.GeoSpatialCollections, gpkgConfig config.GeoPackage) *GeoPackage {
@@ -89,7 +115,7 @@ func NewGeoPackage(collections config.GeoSpatialCollections, gpkgConfig config.G
 
 	switch {
 	case gpkgConfig.Local != nil:
-		g.backend = newLocalGeoPackage(gpkgConfig.Local)
+		g.backend = newLocalGeoPackage(gpkgConfig.Local, false)
 		g.fidColumn = gpkgConfig.Local.Fid
 		g.queryTimeout = gpkgConfig.Local.QueryTimeout.Duration
 		g.maxBBoxSizeToUseWithRTree = gpkgConfig.Local.MaxBBoxSizeToUseWithRTree
@@ -109,7 +135,7 @@ func NewGeoPackage(collections config.GeoSpatialCollections, gpkgConfig config.G
 	}
 	log.Println(metadata)
 
-	g.featureTableByCollectionID, err = readGpkgContents(collections, g.backend.getDB())
+	g.featureTableByCollectionID, err = readGpkgContents(collections, g.backend.getDB(), g.fidColumn)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -117,6 +143,17 @@ func NewGeoPackage(collections config.GeoSpatialCollections, gpkgConfig config.G
 	if err = assertIndexesExist(collections, g.featureTableByCollectionID, g.backend.getDB(), g.fidColumn); err != nil {
 		log.Fatal(err)
 	}
+	if hasWritableFeatureTables(g.featureTableByCollectionID) {
+		if gpkgConfig.Local == nil {
+			log.Fatal("writable collections are only supported for local GeoPackages")
+		}
+		if err = assertWritableFeatureTables(g.featureTableByCollectionID, g.backend.getDB()); err != nil {
+			log.Fatal(err)
+		}
+		// reopen in read-write mode, the GeoPackage is only opened read-write when it's actually written to
+		g.backend.close()
+		g.backend = newLocalGeoPackage(gpkgConfig.Local, true)
+	}
 	if warmUp {
 		// perform warmup async since it can take a long time
 		go func() {
@@ -163,7 +200,8 @@ func (g *GeoPackage) GetFeatureIDs(ctx context.Context, collection string, crite
 	return featureIDs, domain.NewCursors(*prevNext, criteria.Cursor.FiltersChecksum), nil
 }
 
-func (g *GeoPackage) GetFeaturesByID(ctx context.Context, collection string, featureIDs []int64) (*domain.FeatureCollection, error) {
+func (g *GeoPackage) GetFeaturesByID(ctx context.Context, collection string, featureIDs []int64,
+	selection datasources.PropertySelection) (*domain.FeatureCollection, error) {
 	table, err := g.getFeatureTable(collection)
 	if err != nil {
 		return nil, err
@@ -173,7 +211,8 @@ func (g *GeoPackage) GetFeaturesByID(ctx context.Context, collection string, fea
 	defer cancel()
 
 	fids := map[string]any{"fids": featureIDs}
-	query, queryArgs, err := sqlx.Named(fmt.Sprintf("select * from %s where %s in (:fids)", table.TableName, g.fidColumn), fids)
+	query, queryArgs, err := sqlx.Named(fmt.Sprintf("select %s from %s where %s in (:fids)",
+		g.selectClause(table, selection, ""), table.TableName, g.fidColumn), fids)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make features query, error: %w", err)
 	}
@@ -189,48 +228,85 @@ func (g *GeoPackage) GetFeaturesByID(ctx context.Context, collection string, fea
 	defer rows.Close()
 
 	fc := domain.FeatureCollection{}
-	fc.Features, _, err = domain.MapRowsToFeatures(rows, g.fidColumn, table.GeometryColumnName, readGpkgGeometry)
+	fc.Features, _, err = domain.MapRowsToFeatures(rows, g.fidColumn, table.externalFidColumn, table.GeometryColumnName, readGpkgGeometry)
 	if err != nil {
 		return nil, err
 	}
+	sortFeaturesByID(fc.Features, featureIDs)
 	fc.NumberReturned = len(fc.Features)
 	return &fc, nil
 }
 
 func (g *GeoPackage) GetFeatures(ctx context.Context, collection string, criteria datasources.FeaturesCriteria) (*domain.FeatureCollection, domain.Cursors, error) {
+	it, cursors, err := g.StreamFeatures(ctx, collection, criteria)
+	if err != nil || it == nil {
+		return nil, cursors, err
+	}
+	defer it.Close()
+
+	fc := domain.FeatureCollection{}
+	if fc.Features, err = domain.ReadFeatures(it); err != nil {
+		return nil, domain.Cursors{}, err
+	}
+	fc.NumberReturned = len(fc.Features)
+	return &fc, cursors, nil
+}
+
+func (g *GeoPackage) StreamFeatures(ctx context.Context, collection string, criteria datasources.FeaturesCriteria) (domain.FeatureIterator, domain.Cursors, error) {
 	table, err := g.getFeatureTable(collection)
 	if err != nil {
 		return nil, domain.Cursors{}, err
 	}
 
+	// the query context is canceled when the iterator is closed, since rows are read while streaming
 	queryCtx, cancel := context.WithTimeout(ctx, g.queryTimeout) // https://go.dev/doc/database/cancel-operations
-	defer cancel()
 
 	stmt, query, queryArgs, err := g.makeFeaturesQuery(queryCtx, table, false, criteria) //nolint:sqlclosecheck // prepared statement is cached, will be closed when evicted from cache
 	if err != nil {
+		cancel()
 		return nil, domain.Cursors{}, fmt.Errorf("failed to create query '%s' error: %w", query, err)
 	}
 
 	rows, err := stmt.QueryxContext(queryCtx, queryArgs)
 	if err != nil {
+		cancel()
 		return nil, domain.Cursors{}, fmt.Errorf("failed to execute query '%s' error: %w", query, err)
 	}
-	defer rows.Close()
 
-	var prevNext *domain.PrevNextFID
-	fc := domain.FeatureCollection{}
-	fc.Features, prevNext, err = domain.MapRowsToFeatures(rows, g.fidColumn, table.GeometryColumnName, readGpkgGeometry)
-	if err != nil {
+	it, prevNext, err := domain.NewRowsFeatureIterator(rows, g.fidColumn, table.externalFidColumn, table.GeometryColumnName, readGpkgGeometry, cancel)
+	if err != nil || prevNext == nil {
+		it.Close()
 		return nil, domain.Cursors{}, err
 	}
-	if prevNext == nil {
-		return nil, domain.Cursors{}, nil
+	return it, domain.NewCursors(*prevNext, criteria.Cursor.FiltersChecksum), nil
+}
+
+func (g *GeoPackage) CountFeatures(ctx context.Context, collection string, criteria datasources.FeaturesCriteria) (int, error) {
+	table, err := g.getFeatureTable(collection)
+	if err != nil {
+		return 0, err
 	}
-	fc.NumberReturned = len(fc.Features)
-	return &fc, domain.NewCursors(*prevNext, criteria.Cursor.FiltersChecksum), nil
+
+	queryCtx, cancel := context.WithTimeout(ctx, g.queryTimeout) // https://go.dev/doc/database/cancel-operations
+	defer cancel()
+
+	query, queryArgs, err := g.makeCountQuery(table, criteria)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create count query '%s' error: %w", query, err)
+	}
+	stmt, err := g.preparedStmtCache.Lookup(queryCtx, g.backend.getDB(), query) //nolint:sqlclosecheck // prepared statement is cached, will be closed when evicted from cache
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare count query '%s' error: %w", query, err)
+	}
+	var count int
+	if err = stmt.GetContext(queryCtx, &count, queryArgs); err != nil {
+		return 0, fmt.Errorf("failed to execute count query '%s' error: %w", query, err)
+	}
+	return count, nil
 }
 
-func (g *GeoPackage) GetFeature(ctx context.Context, collection string, featureID int64) (*domain.Feature, error) {
+func (g *GeoPackage) GetFeature(ctx context.Context, collection string, featureID any,
+	selection datasources.PropertySelection) (*domain.Feature, error) {
 	table, err := g.getFeatureTable(collection)
 	if err != nil {
 		return nil, err
@@ -239,14 +315,19 @@ func (g *GeoPackage) GetFeature(ctx context.Context, collection string, featureI
 	queryCtx, cancel := context.WithTimeout(ctx, g.queryTimeout) // https://go.dev/doc/database/cancel-operations
 	defer cancel()
 
-	query := fmt.Sprintf("select * from %s f where f.%s = :fid limit 1", table.TableName, g.fidColumn)
+	idColumn := g.fidColumn
+	if table.externalFidColumn != "" {
+		idColumn = table.externalFidColumn
+	}
+	query := fmt.Sprintf("select %s from %s f where f.\"%s\" = :fid limit 1",
+		g.selectClause(table, selection, "f."), table.TableName, idColumn)
 	rows, err := g.backend.getDB().NamedQueryContext(queryCtx, query, map[string]any{"fid": featureID})
 	if err != nil {
 		return nil, fmt.Errorf("query '%s' failed: %w", query, err)
 	}
 	defer rows.Close()
 
-	features, _, err := domain.MapRowsToFeatures(rows, g.fidColumn, table.GeometryColumnName, readGpkgGeometry)
+	features, _, err := domain.MapRowsToFeatures(rows, g.fidColumn, table.externalFidColumn, table.GeometryColumnName, readGpkgGeometry)
 	if err != nil {
 		return nil, err
 	}
@@ -264,6 +345,14 @@ func (g *GeoPackage) GetFeatureTableMetadata(collection string) (datasources.Fea
 	return val, nil
 }
 
+func (g *GeoPackage) SupportsCQLFilter() bool {
+	return true
+}
+
+func (g *GeoPackage) SupportsSortBy() bool {
+	return true
+}
+
 // Build specific features queries based on the given options.
 // Make sure to use SQL bind variables and return named params: https://jmoiron.github.io/sqlx/#namedParams
 func (g *GeoPackage) makeFeaturesQuery(ctx context.Context, table *featureTable, onlyFIDs bool,
@@ -276,50 +365,61 @@ func (g *GeoPackage) makeFeaturesQuery(ctx context.Context, table *featureTable,
 			return
 		}
 	} else {
-		query, queryArgs = g.makeDefaultQuery(table, criteria)
+		query, queryArgs, err = g.makeDefaultQuery(table, onlyFIDs, criteria)
+		if err != nil {
+			return
+		}
 	}
 	// lookup prepared statement for given query, or create new one
 	stmt, err = g.preparedStmtCache.Lookup(ctx, g.backend.getDB(), query)
 	return
 }
 
-func (g *GeoPackage) makeDefaultQuery(table *featureTable, criteria datasources.FeaturesCriteria) (string, map[string]any) {
-	pfClause, pfNamedParams := propertyFiltersToSQL(criteria.PropertyFilters)
-	temporalClause, temporalNamedParams := temporalCriteriaToSQL(criteria.TemporalCriteria)
+func (g *GeoPackage) makeDefaultQuery(table *featureTable, onlyFIDs bool, criteria datasources.FeaturesCriteria) (string, map[string]any, error) {
+	ks := newKeyset(g.fidColumn, criteria)
+	featureColumns, resultColumns := g.pagedSelectClauses(table, onlyFIDs, criteria, ks, "")
+
+	pfClause, temporalClause, filterClause, filterNamedParams, err := filterClauses(table, criteria, "")
+	if err != nil {
+		return "", nil, err
+	}
 
 	defaultQuery := fmt.Sprintf(`
 with
-    next as (select * from "%[1]s" where "%[2]s" >= :fid %[3]s %[4]s order by %[2]s asc limit :limit + 1),
-    prev as (select * from "%[1]s" where "%[2]s" < :fid %[3]s %[4]s order by %[2]s desc limit :limit),
+    next as (select %[11]s from "%[1]s" where %[2]s %[3]s %[4]s %[5]s order by %[6]s limit :limit + 1),
+    prev as (select %[11]s from "%[1]s" where %[7]s %[3]s %[4]s %[5]s order by %[8]s limit :limit),
     nextprev as (select * from next union all select * from prev),
-    nextprevfeat as (select *, lag("%[2]s", :limit) over (order by %[2]s) as prevfid, lead("%[2]s", :limit) over (order by "%[2]s") as nextfid from nextprev)
-select * from nextprevfeat where "%[2]s" >= :fid %[3]s %[4]s limit :limit
-`, table.TableName, g.fidColumn, temporalClause, pfClause) // don't add user input here, use named params for user input!
-
-	namedParams := map[string]any{
-		"fid":   criteria.Cursor.FID,
-		"limit": criteria.Limit,
-	}
-	maps.Copy(namedParams, pfNamedParams)
-	maps.Copy(namedParams, temporalNamedParams)
-	return defaultQuery, namedParams
+    nextprevfeat as (select *, %[9]s from nextprev)
+select %[10]s from nextprevfeat where %[2]s order by %[6]s limit :limit
+`, table.TableName, ks.onOrAfterCursor(""), temporalClause, pfClause, filterClause, ks.orderBy("", false),
+		ks.beforeCursor(""), ks.orderBy("", true), ks.prevNextColumns(), resultColumns, featureColumns) // don't add user input here, use named params for user input!
+
+	namedParams := ks.namedParams()
+	namedParams["limit"] = criteria.Limit
+	maps.Copy(namedParams, filterNamedParams)
+	return defaultQuery, namedParams, nil
 }
 
 func (g *GeoPackage) makeBboxQuery(table *featureTable, onlyFIDs bool, criteria datasources.FeaturesCriteria) (string, map[string]any, error) {
-	selectClause := "*"
-	if onlyFIDs {
-		selectClause = "\"" + g.fidColumn + "\", prevfid, nextfid"
-	}
+	ks := newKeyset(g.fidColumn, criteria)
+	featureColumns, resultColumns := g.pagedSelectClauses(table, onlyFIDs, criteria, ks, "f.")
 
 	btreeIndexHint := fmt.Sprintf("indexed by \"%s_spatial_idx\"", table.TableName)
 
-	pfClause, pfNamedParams := propertyFiltersToSQL(criteria.PropertyFilters)
+	// prefix columns in filter since the feature table is joined with the rtree
+	pfClause, temporalClause, filterClause, filterNamedParams, err := filterClauses(table, criteria, "f.")
+	if err != nil {
+		return "", nil, err
+	}
 	if pfClause != "" {
 		// don't force btree index when using property filter, let SQLite decide
 		// whether to use the BTree index or the property filter index
 		btreeIndexHint = ""
 	}
-	temporalClause, temporalNamedParams := temporalCriteriaToSQL(criteria.TemporalCriteria)
+	if filterClause != "" || len(criteria.SortBy) > 0 {
+		// don't force btree index when using a filter or sorting, let SQLite decide
+		btreeIndexHint = ""
+	}
 
 	bboxQuery := fmt.Sprintf(`
 with
@@ -328,58 +428,110 @@ with
                      from (select id from rtree_%[1]s_%[4]s
                            where minx <= :maxx and maxx >= :minx and miny <= :maxy and maxy >= :miny
                            limit %[3]d)),
-     next_bbox_rtree as (select f.*
+     next_bbox_rtree as (select %[17]s
                          from "%[1]s" f inner join rtree_%[1]s_%[4]s rf on f."%[2]s" = rf.id
                          where rf.minx <= :maxx and rf.maxx >= :minx and rf.miny <= :maxy and rf.maxy >= :miny
                            and st_intersects((select * from given_bbox), castautomagic(f.%[4]s)) = 1
-                           and f."%[2]s" >= :fid %[6]s %[7]s
-                         order by f."%[2]s" asc
+                           and %[10]s %[6]s %[7]s %[9]s
+                         order by %[11]s
                          limit (select iif(bbox_size == 'small', :limit + 1, 0) from bbox_size)),
-     next_bbox_btree as (select f.*
+     next_bbox_btree as (select %[17]s
                          from "%[1]s" f %[8]s
                          where f.minx <= :maxx and f.maxx >= :minx and f.miny <= :maxy and f.maxy >= :miny
                            and st_intersects((select * from given_bbox), castautomagic(f.%[4]s)) = 1
-                           and f."%[2]s" >= :fid %[6]s %[7]s
-                         order by f."%[2]s" asc
+                           and %[10]s %[6]s %[7]s %[9]s
+                         order by %[11]s
                          limit (select iif(bbox_size == 'big', :limit + 1, 0) from bbox_size)),
      next as (select * from next_bbox_rtree union all select * from next_bbox_btree),
-     prev_bbox_rtree as (select f.*
+     prev_bbox_rtree as (select %[17]s
                          from "%[1]s" f inner join rtree_%[1]s_%[4]s rf on f."%[2]s" = rf.id
                          where rf.minx <= :maxx and rf.maxx >= :minx and rf.miny <= :maxy and rf.maxy >= :miny
                            and st_intersects((select * from given_bbox), castautomagic(f.%[4]s)) = 1
-                           and f."%[2]s" < :fid %[6]s %[7]s
-                         order by f."%[2]s" desc
+                           and %[12]s %[6]s %[7]s %[9]s
+                         order by %[13]s
                          limit (select iif(bbox_size == 'small', :limit, 0) from bbox_size)),
-     prev_bbox_btree as (select f.*
+     prev_bbox_btree as (select %[17]s
                          from "%[1]s" f %[8]s
                          where f.minx <= :maxx and f.maxx >= :minx and f.miny <= :maxy and f.maxy >= :miny
                            and st_intersects((select * from given_bbox), castautomagic(f.%[4]s)) = 1
-                           and f."%[2]s" < :fid %[6]s %[7]s
-                         order by f."%[2]s" desc
+                           and %[12]s %[6]s %[7]s %[9]s
+                         order by %[13]s
                          limit (select iif(bbox_size == 'big', :limit, 0) from bbox_size)),
      prev as (select * from prev_bbox_rtree union all select * from prev_bbox_btree),
      nextprev as (select * from next union all select * from prev),
-     nextprevfeat as (select *, lag("%[2]s", :limit) over (order by "%[2]s") as prevfid, lead("%[2]s", :limit) over (order by "%[2]s") as nextfid from nextprev)
-select %[5]s from nextprevfeat where "%[2]s" >= :fid %[6]s %[7]s limit :limit
+     nextprevfeat as (select *, %[14]s from nextprev)
+select %[5]s from nextprevfeat where %[15]s order by %[16]s limit :limit
 `, table.TableName, g.fidColumn, g.maxBBoxSizeToUseWithRTree, table.GeometryColumnName,
-		selectClause, temporalClause, pfClause, btreeIndexHint) // don't add user input here, use named params for user input!
+		resultColumns, temporalClause, pfClause, btreeIndexHint, filterClause,
+		ks.onOrAfterCursor("f."), ks.orderBy("f.", false), ks.beforeCursor("f."), ks.orderBy("f.", true),
+		ks.prevNextColumns(), ks.onOrAfterCursor(""), ks.orderBy("", false), featureColumns) // don't add user input here, use named params for user input!
 
-	bboxAsWKT, err := wkt.EncodeString(criteria.Bbox)
+	namedParams, err := bboxNamedParams(criteria)
+	if err != nil {
+		return "", nil, err
+	}
+	namedParams["limit"] = criteria.Limit
+	maps.Copy(namedParams, ks.namedParams())
+	maps.Copy(namedParams, filterNamedParams)
+	return bboxQuery, namedParams, nil
+}
+
+// makeCountQuery counts the features matching the given criteria, regardless of pagination. Uses the
+// same filter clauses as makeDefaultQuery and makeBboxQuery, so the count matches the features returned.
+func (g *GeoPackage) makeCountQuery(table *featureTable, criteria datasources.FeaturesCriteria) (string, map[string]any, error) {
+	pfClause, temporalClause, filterClause, namedParams, err := filterClauses(table, criteria, "f.")
 	if err != nil {
 		return "", nil, err
 	}
-	namedParams := map[string]any{
-		"fid":      criteria.Cursor.FID,
-		"limit":    criteria.Limit,
+	bboxClause := ""
+	if criteria.Bbox != nil {
+		bboxClause = fmt.Sprintf(`
+  and f."%[2]s" in (select id from rtree_%[1]s_%[3]s
+                    where minx <= :maxx and maxx >= :minx and miny <= :maxy and maxy >= :miny)
+  and st_intersects(geomfromtext(:bboxWkt, :bboxSrid), castautomagic(f.%[3]s)) = 1`,
+			table.TableName, g.fidColumn, table.GeometryColumnName)
+		bboxParams, err := bboxNamedParams(criteria)
+		if err != nil {
+			return "", nil, err
+		}
+		maps.Copy(namedParams, bboxParams)
+	}
+
+	countQuery := fmt.Sprintf(`
+select count(*) from "%[1]s" f where 1 = 1 %[2]s %[3]s %[4]s %[5]s
+`, table.TableName, bboxClause, temporalClause, pfClause, filterClause) // don't add user input here, use named params for user input!
+
+	return countQuery, namedParams, nil
+}
+
+// filterClauses returns the SQL clauses (and named params) to filter features by property, time and CQL filter.
+// The given column prefix is only applied to the CQL filter.
+func filterClauses(table *featureTable, criteria datasources.FeaturesCriteria, columnPrefix string) (pfClause string,
+	temporalClause string, filterClause string, namedParams map[string]any, err error) {
+
+	pfClause, namedParams = propertyFiltersToSQL(criteria.PropertyFilters)
+	temporalClause, temporalNamedParams := temporalCriteriaToSQL(criteria.TemporalCriteria)
+	filterClause, filterNamedParams, err := filterToSQL(criteria.Filter, table, criteria.InputSRID, columnPrefix)
+	if err != nil {
+		return "", "", "", nil, err
+	}
+	maps.Copy(namedParams, temporalNamedParams)
+	maps.Copy(namedParams, filterNamedParams)
+	return pfClause, temporalClause, filterClause, namedParams, nil
+}
+
+func bboxNamedParams(criteria datasources.FeaturesCriteria) (map[string]any, error) {
+	bboxAsWKT, err := wkt.EncodeString(criteria.Bbox)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
 		"bboxWkt":  bboxAsWKT,
 		"maxx":     criteria.Bbox.MaxX(),
 		"minx":     criteria.Bbox.MinX(),
 		"maxy":     criteria.Bbox.MaxY(),
 		"miny":     criteria.Bbox.MinY(),
-		"bboxSrid": criteria.InputSRID}
-	maps.Copy(namedParams, pfNamedParams)
-	maps.Copy(namedParams, temporalNamedParams)
-	return bboxQuery, namedParams, nil
+		"bboxSrid": criteria.InputSRID}, nil
 }
 
 func (g *GeoPackage) getFeatureTable(collection string) (*featureTable, error) {
@@ -399,29 +551,153 @@ func readGpkgGeometry(rawGeom []byte) (geom.Geometry, error) {
 	return geometry.Geometry, nil
 }
 
+// pagedSelectClauses returns the columns to select from the feature table (in the next/prev CTEs) and
+// the columns to select in the final result. The feature table columns include the sort keys, since
+// these are needed to determine the prev/next page.
+func (g *GeoPackage) pagedSelectClauses(table *featureTable, onlyFIDs bool, criteria datasources.FeaturesCriteria,
+	ks keyset, columnPrefix string) (featureColumns string, resultColumns string) {
+
+	var columns []string
+	switch {
+	case onlyFIDs:
+		columns = []string{g.fidColumn}
+	case !criteria.PropertySelection.IsEmpty():
+		columns = g.selectColumns(table, criteria.PropertySelection)
+	default:
+		return columnPrefix + "*", "*"
+	}
+	return quoteColumns(columnPrefix, ks.withSortKeys(columns)),
+		quoteColumns("", columns) + ", " + strings.Join(ks.prevNextColumnNames(), ", ")
+}
+
+// selectClause returns the columns to select from the feature table given the property selection
+func (g *GeoPackage) selectClause(table *featureTable, selection datasources.PropertySelection, columnPrefix string) string {
+	if selection.IsEmpty() {
+		return columnPrefix + "*"
+	}
+	return quoteColumns(columnPrefix, g.selectColumns(table, selection))
+}
+
+// selectColumns returns the fid, external fid (when configured), geometry (unless skipped) and selected
+// properties (or all properties when none are selected) of the feature table
+func (g *GeoPackage) selectColumns(table *featureTable, selection datasources.PropertySelection) []string {
+	columns := []string{g.fidColumn}
+	if table.externalFidColumn != "" {
+		columns = append(columns, table.externalFidColumn)
+	}
+	if !selection.SkipGeometry {
+		columns = append(columns, table.GeometryColumnName)
+	}
+	properties := selection.Properties
+	if len(properties) == 0 {
+		properties = make([]string, 0, len(table.ColumnsWithDateType))
+		for column := range table.ColumnsWithDateType {
+			properties = append(properties, column)
+		}
+		sort.Strings(properties) // stable query string, needed for the prepared statement cache
+	}
+	for _, property := range properties {
+		if property == table.GeometryColumnName || slices.Contains(columns, property) {
+			continue
+		}
+		columns = append(columns, property)
+	}
+	return columns
+}
+
+// quoteColumns column names in double quotes in case these are reserved keywords
+func quoteColumns(columnPrefix string, columns []string) string {
+	quoted := make([]string, 0, len(columns))
+	for _, column := range columns {
+		quoted = append(quoted, fmt.Sprintf("%s\"%s\"", columnPrefix, column))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// sortFeaturesByID restores the order of the given feature IDs (e.g. sorted using sortby), since the
+// IN-clause in GetFeaturesByID doesn't guarantee any order
+func sortFeaturesByID(features []*domain.Feature, featureIDs []int64) {
+	positions := make(map[int64]int, len(featureIDs))
+	for i, fid := range featureIDs {
+		positions[fid] = i
+	}
+	sort.SliceStable(features, func(i, j int) bool {
+		return positions[features[i].FID] < positions[features[j].FID]
+	})
+}
+
+// propertyFiltersToSQL translates property filters to an equality comparison, an IN clause (multiple values)
+// and/or range comparisons (values ending with a wildcard). We don't use LIKE for wildcards, since LIKE is
+// case-insensitive in SQLite and therefore doesn't use the index, while a range comparison does.
 func propertyFiltersToSQL(pf map[string]string) (sql string, namedParams map[string]any) {
 	namedParams = make(map[string]any)
-	if len(pf) > 0 {
-		position := 0
-		for k, v := range pf {
-			position++
-			namedParam := fmt.Sprintf("pf%d", position)
-			// column name in double quotes in case it is a reserved keyword
-			// also: we don't currently support LIKE since wildcard searches don't use the index
-			sql += fmt.Sprintf(" and \"%s\" = :%s", k, namedParam)
-			namedParams[namedParam] = v
+	position := 0
+	nextParam := func(value string) string {
+		position++
+		namedParam := fmt.Sprintf("pf%d", position)
+		namedParams[namedParam] = value
+		return namedParam
+	}
+	for k, v := range pf {
+		values, prefixes := datasources.SplitPropertyFilter(v)
+		conditions := make([]string, 0, len(prefixes)+1)
+		// column name in double quotes in case it is a reserved keyword
+		switch len(values) {
+		case 0:
+			// only prefixes
+		case 1:
+			conditions = append(conditions, fmt.Sprintf("\"%s\" = :%s", k, nextParam(values[0])))
+		default:
+			params := make([]string, 0, len(values))
+			for _, value := range values {
+				params = append(params, ":"+nextParam(value))
+			}
+			conditions = append(conditions, fmt.Sprintf("\"%s\" in (%s)", k, strings.Join(params, ", ")))
+		}
+		for _, prefix := range prefixes {
+			conditions = append(conditions, fmt.Sprintf("(\"%[1]s\" >= :%[2]s and \"%[1]s\" < :%[3]s)",
+				k, nextParam(prefix), nextParam(prefixUpperBound(prefix))))
+		}
+		if len(conditions) == 1 {
+			sql += " and " + conditions[0]
+		} else {
+			sql += fmt.Sprintf(" and (%s)", strings.Join(conditions, " or "))
 		}
 	}
 	return sql, namedParams
 }
 
+// prefixUpperBound returns the smallest string (in binary order) greater than all strings
+// starting with the given prefix, by incrementing the last byte of the prefix.
+func prefixUpperBound(prefix string) string {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1])
+		}
+	}
+	return prefix + "\xff" // unreachable for valid UTF-8, since it never contains 0xff bytes
+}
+
 func temporalCriteriaToSQL(temporalCriteria datasources.TemporalCriteria) (sql string, namedParams map[string]any) {
 	namedParams = make(map[string]any)
-	if !temporalCriteria.ReferenceDate.IsZero() {
+	startDate := temporalCriteria.StartDateProperty
+	endDate := temporalCriteria.EndDateProperty
+	switch {
+	case !temporalCriteria.ReferenceDate.IsZero():
 		namedParams["referenceDate"] = temporalCriteria.ReferenceDate
-		startDate := temporalCriteria.StartDateProperty
-		endDate := temporalCriteria.EndDateProperty
 		sql = fmt.Sprintf(" and \"%[1]s\" <= :referenceDate and (\"%[2]s\" >= :referenceDate or \"%[2]s\" is null)", startDate, endDate)
+	case temporalCriteria.ReferenceInterval != nil:
+		// select features of which the validity overlaps with the (possibly open-ended) interval
+		if !temporalCriteria.ReferenceInterval.End.IsZero() {
+			namedParams["referenceEndDate"] = temporalCriteria.ReferenceInterval.End
+			sql += fmt.Sprintf(" and \"%[1]s\" <= :referenceEndDate", startDate)
+		}
+		if !temporalCriteria.ReferenceInterval.Start.IsZero() {
+			namedParams["referenceStartDate"] = temporalCriteria.ReferenceInterval.Start
+			sql += fmt.Sprintf(" and (\"%[1]s\" >= :referenceStartDate or \"%[1]s\" is null)", endDate)
+		}
 	}
 	return sql, namedParams
 }
@@ -31,7 +31,7 @@ func newAddressesGeoPackage() geoPackageBackend {
 			MaxBBoxSizeToUseWithRTree: 30000,
 		},
 		File: pwd + "/testdata/bag.gpkg",
-	})
+	}, false)
 }
 
 func newTemporalAddressesGeoPackage() geoPackageBackend {
@@ -43,7 +43,7 @@ func newTemporalAddressesGeoPackage() geoPackageBackend {
 			MaxBBoxSizeToUseWithRTree: 30000,
 		},
 		File: pwd + "/testdata/bag-temporal.gpkg",
-	})
+	}, false)
 }
 
 func TestNewGeoPackage(t *testing.T) {
@@ -148,6 +148,51 @@ func TestGeoPackage_GetFeatures(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "get first page of features with property selection and without geometry",
+			fields: fields{
+				backend:          newAddressesGeoPackage(),
+				fidColumn:        "feature_id",
+				featureTableByID: map[string]*featureTable{"ligplaatsen": {TableName: "ligplaatsen", GeometryColumnName: "geom"}},
+				queryTimeout:     60 * time.Second,
+			},
+			args: args{
+				ctx:        context.Background(),
+				collection: "ligplaatsen",
+				queryParams: datasources.FeaturesCriteria{
+					Cursor: domain.DecodedCursor{FID: 0, FiltersChecksum: []byte{}},
+					Limit:  2,
+					PropertySelection: datasources.PropertySelection{
+						Properties:   []string{"straatnaam"},
+						SkipGeometry: true,
+					},
+				},
+			},
+			wantFC: &domain.FeatureCollection{
+				NumberReturned: 2,
+				Features: []*domain.Feature{
+					{
+						Feature: geojson.Feature{
+							Properties: map[string]any{
+								"straatnaam": "Van Diemenkade",
+							},
+						},
+					},
+					{
+						Feature: geojson.Feature{
+							Properties: map[string]any{
+								"straatnaam": "Realengracht",
+							},
+						},
+					},
+				},
+			},
+			wantCursor: domain.Cursors{
+				Prev: "|",
+				Next: "Dv4|", // 3838
+			},
+			wantErr: false,
+		},
 		{
 			name: "get second page of features",
 			fields: fields{
@@ -202,6 +247,62 @@ func TestGeoPackage_GetFeatures(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "get second page of features sorted by straatnaam descending",
+			fields: fields{
+				backend:          newAddressesGeoPackage(),
+				fidColumn:        "feature_id",
+				featureTableByID: map[string]*featureTable{"ligplaatsen": {TableName: "ligplaatsen", GeometryColumnName: "geom"}},
+				queryTimeout:     5 * time.Second,
+			},
+			args: args{
+				ctx:        context.Background(),
+				collection: "ligplaatsen",
+				queryParams: datasources.FeaturesCriteria{
+					Cursor: domain.DecodedCursor{
+						FID:             5390,
+						FiltersChecksum: []byte{},
+						SortValues:      []any{"Zoutkeetsgracht"},
+					},
+					Limit:  3,
+					SortBy: []datasources.SortKey{{Property: "straatnaam", Descending: true}},
+				},
+			},
+			wantFC: &domain.FeatureCollection{
+				NumberReturned: 3,
+				Features: []*domain.Feature{
+					{
+						Feature: geojson.Feature{
+							Properties: map[string]any{
+								"straatnaam": "Zoutkeetsgracht",
+								"nummer_id":  "0363200000519175",
+							},
+						},
+					},
+					{
+						Feature: geojson.Feature{
+							Properties: map[string]any{
+								"straatnaam": "Zoutkeetsgracht",
+								"nummer_id":  "0363200000519176",
+							},
+						},
+					},
+					{
+						Feature: geojson.Feature{
+							Properties: map[string]any{
+								"straatnaam": "Zoutkeetsgracht",
+								"nummer_id":  "0363200000519177",
+							},
+						},
+					},
+				},
+			},
+			wantCursor: domain.Cursors{
+				Prev: "|",
+				Next: "FRE||WyJab3V0a2VldHNncmFjaHQiXQ", // 5393, Zoutkeetsgracht
+			},
+			wantErr: false,
+		},
 		{
 			name: "get first page of features with reference date",
 			fields: fields{
@@ -293,6 +394,7 @@ func TestGeoPackage_GetFeatures(t *testing.T) {
 			for i, wantedFeature := range tt.wantFC.Features {
 				assert.Equal(t, wantedFeature.Properties["straatnaam"], fc.Features[i].Properties["straatnaam"])
 				assert.Equal(t, wantedFeature.Properties["nummer_id"], fc.Features[i].Properties["nummer_id"])
+				assert.Equal(t, tt.args.queryParams.PropertySelection.SkipGeometry, fc.Features[i].Geometry == nil)
 			}
 			assert.Equal(t, tt.wantCursor.Prev, cursor.Prev)
 			assert.Equal(t, tt.wantCursor.Next, cursor.Next)
@@ -310,7 +412,8 @@ func TestGeoPackage_GetFeature(t *testing.T) {
 	type args struct {
 		ctx        context.Context
 		collection string
-		featureID  int64
+		featureID  any
+		selection  datasources.PropertySelection
 	}
 	tests := []struct {
 		name    string
@@ -333,7 +436,7 @@ func TestGeoPackage_GetFeature(t *testing.T) {
 				featureID:  3837,
 			},
 			want: &domain.Feature{
-				ID:    0,
+				ID:    int64(3837),
 				Links: nil,
 				Feature: geojson.Feature{
 					Properties: map[string]any{
@@ -344,6 +447,53 @@ func TestGeoPackage_GetFeature(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "get feature with property selection and without geometry",
+			fields: fields{
+				backend:          newAddressesGeoPackage(),
+				fidColumn:        "feature_id",
+				featureTableByID: map[string]*featureTable{"ligplaatsen": {TableName: "ligplaatsen", GeometryColumnName: "geom"}},
+				queryTimeout:     5 * time.Second,
+			},
+			args: args{
+				ctx:        context.Background(),
+				collection: "ligplaatsen",
+				featureID:  3837,
+				selection:  datasources.PropertySelection{Properties: []string{"straatnaam"}, SkipGeometry: true},
+			},
+			want: &domain.Feature{
+				Feature: geojson.Feature{
+					Properties: map[string]any{
+						"straatnaam": "Realengracht",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "get feature by external feature id",
+			fields: fields{
+				backend:   newAddressesGeoPackage(),
+				fidColumn: "feature_id",
+				featureTableByID: map[string]*featureTable{"ligplaatsen": {TableName: "ligplaatsen", GeometryColumnName: "geom",
+					externalFidColumn: "nummer_id"}},
+				queryTimeout: 5 * time.Second,
+			},
+			args: args{
+				ctx:        context.Background(),
+				collection: "ligplaatsen",
+				featureID:  "0363200000398886",
+			},
+			want: &domain.Feature{
+				ID: "0363200000398886",
+				Feature: geojson.Feature{
+					Properties: map[string]any{
+						"straatnaam": "Realengracht",
+					},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "get non existing feature",
 			fields: fields{
@@ -385,7 +535,7 @@ func TestGeoPackage_GetFeature(t *testing.T) {
 				featureTableByCollectionID: tt.fields.featureTableByID,
 				queryTimeout:               tt.fields.queryTimeout,
 			}
-			got, err := g.GetFeature(tt.args.ctx, tt.args.collection, tt.args.featureID)
+			got, err := g.GetFeature(tt.args.ctx, tt.args.collection, tt.args.featureID, tt.args.selection)
 			if err != nil {
 				if !tt.wantErr {
 					t.Errorf("GetFeature, error %v, wantErr %v", err, tt.wantErr)
@@ -395,11 +545,48 @@ func TestGeoPackage_GetFeature(t *testing.T) {
 			if tt.want != nil {
 				assert.Equal(t, tt.want.Properties["straatnaam"], got.Properties["straatnaam"])
 				assert.Equal(t, tt.want.Properties["nummer_id"], got.Properties["nummer_id"])
+				assert.Equal(t, tt.args.selection.SkipGeometry, got.Geometry == nil)
+				if tt.want.ID != nil {
+					assert.Equal(t, tt.want.ID, got.ID)
+				}
 			}
 		})
 	}
 }
 
+func TestGeoPackage_CountFeatures(t *testing.T) {
+	g := &GeoPackage{
+		backend:                    newAddressesGeoPackage(),
+		fidColumn:                  "feature_id",
+		featureTableByCollectionID: map[string]*featureTable{"ligplaatsen": {TableName: "ligplaatsen", GeometryColumnName: "geom"}},
+		queryTimeout:               60 * time.Second,
+	}
+	g.preparedStmtCache = NewCache()
+
+	t.Run("count all features, regardless of cursor and limit", func(t *testing.T) {
+		count, err := g.CountFeatures(context.Background(), "ligplaatsen", datasources.FeaturesCriteria{
+			Cursor: domain.DecodedCursor{FID: 10, FiltersChecksum: []byte{}},
+			Limit:  2,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 67, count)
+	})
+
+	t.Run("count features matching property filter", func(t *testing.T) {
+		count, err := g.CountFeatures(context.Background(), "ligplaatsen", datasources.FeaturesCriteria{
+			Limit:           2,
+			PropertyFilters: map[string]string{"straatnaam": "Van Diemenkade"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 18, count)
+	})
+
+	t.Run("count features of unknown collection", func(t *testing.T) {
+		_, err := g.CountFeatures(context.Background(), "foo", datasources.FeaturesCriteria{})
+		assert.Error(t, err)
+	})
+}
+
 func TestGeoPackage_Warmup(t *testing.T) {
 	t.Run("warmup", func(t *testing.T) {
 		g := &GeoPackage{
@@ -419,3 +606,101 @@ func TestGeoPackage_Warmup(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestTemporalCriteriaToSQL(t *testing.T) {
+	refDate := time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		criteria        datasources.TemporalCriteria
+		wantSQL         string
+		wantNamedParams map[string]any
+	}{
+		{
+			name:            "no reference date",
+			criteria:        datasources.TemporalCriteria{StartDateProperty: "validfrom", EndDateProperty: "validto"},
+			wantSQL:         "",
+			wantNamedParams: map[string]any{},
+		},
+		{
+			name:            "reference date",
+			criteria:        datasources.TemporalCriteria{ReferenceDate: refDate, StartDateProperty: "validfrom", EndDateProperty: "validto"},
+			wantSQL:         ` and "validfrom" <= :referenceDate and ("validto" >= :referenceDate or "validto" is null)`,
+			wantNamedParams: map[string]any{"referenceDate": refDate},
+		},
+		{
+			name: "reference interval",
+			criteria: datasources.TemporalCriteria{ReferenceInterval: &datasources.Interval{Start: refDate, End: refDate.AddDate(1, 0, 0)},
+				StartDateProperty: "validfrom", EndDateProperty: "validto"},
+			wantSQL:         ` and "validfrom" <= :referenceEndDate and ("validto" >= :referenceStartDate or "validto" is null)`,
+			wantNamedParams: map[string]any{"referenceStartDate": refDate, "referenceEndDate": refDate.AddDate(1, 0, 0)},
+		},
+		{
+			name: "reference interval with open start",
+			criteria: datasources.TemporalCriteria{ReferenceInterval: &datasources.Interval{End: refDate},
+				StartDateProperty: "validfrom", EndDateProperty: "validto"},
+			wantSQL:         ` and "validfrom" <= :referenceEndDate`,
+			wantNamedParams: map[string]any{"referenceEndDate": refDate},
+		},
+		{
+			name: "reference interval with open end",
+			criteria: datasources.TemporalCriteria{ReferenceInterval: &datasources.Interval{Start: refDate},
+				StartDateProperty: "validfrom", EndDateProperty: "validto"},
+			wantSQL:         ` and ("validto" >= :referenceStartDate or "validto" is null)`,
+			wantNamedParams: map[string]any{"referenceStartDate": refDate},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, namedParams := temporalCriteriaToSQL(tt.criteria)
+			assert.Equal(t, tt.wantSQL, sql)
+			assert.Equal(t, tt.wantNamedParams, namedParams)
+		})
+	}
+}
+
+func TestPropertyFiltersToSQL(t *testing.T) {
+	tests := []struct {
+		name            string
+		propertyFilters map[string]string
+		wantSQL         string
+		wantNamedParams map[string]any
+	}{
+		{
+			name:            "no property filters",
+			propertyFilters: map[string]string{},
+			wantSQL:         "",
+			wantNamedParams: map[string]any{},
+		},
+		{
+			name:            "single value",
+			propertyFilters: map[string]string{"straatnaam": "Silodam"},
+			wantSQL:         ` and "straatnaam" = :pf1`,
+			wantNamedParams: map[string]any{"pf1": "Silodam"},
+		},
+		{
+			name:            "multiple values",
+			propertyFilters: map[string]string{"straatnaam": "Silodam,Zandhoek"},
+			wantSQL:         ` and "straatnaam" in (:pf1, :pf2)`,
+			wantNamedParams: map[string]any{"pf1": "Silodam", "pf2": "Zandhoek"},
+		},
+		{
+			name:            "prefix",
+			propertyFilters: map[string]string{"postcode": "1104*"},
+			wantSQL:         ` and ("postcode" >= :pf1 and "postcode" < :pf2)`,
+			wantNamedParams: map[string]any{"pf1": "1104", "pf2": "1105"},
+		},
+		{
+			name:            "value and prefixes",
+			propertyFilters: map[string]string{"postcode": "1104MM,1013*,109*"},
+			wantSQL:         ` and ("postcode" = :pf1 or ("postcode" >= :pf2 and "postcode" < :pf3) or ("postcode" >= :pf4 and "postcode" < :pf5))`,
+			wantNamedParams: map[string]any{"pf1": "1104MM", "pf2": "1013", "pf3": "1014", "pf4": "109", "pf5": "10:"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, namedParams := propertyFiltersToSQL(tt.propertyFilters)
+			assert.Equal(t, tt.wantSQL, sql)
+			assert.Equal(t, tt.wantNamedParams, namedParams)
+		})
+	}
+}
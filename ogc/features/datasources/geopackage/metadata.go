@@ -44,7 +44,7 @@ spatialite_target_cpu() as arch`).StructScan(&m)
 // collection ID -> feature table metadata. We match each feature table to the collection ID by looking at the
 // 'identifier' column. Also in case there's no exact match between 'collection ID' and 'identifier' we use
 // the explicitly configured table name.
-func readGpkgContents(collections config.GeoSpatialCollections, db *sqlx.DB) (map[string]*featureTable, error) {
+func readGpkgContents(collections config.GeoSpatialCollections, db *sqlx.DB, fidColumn string) (map[string]*featureTable, error) {
 	query := `
 select
 	c.table_name, c.data_type, c.identifier, c.description, c.last_change,
@@ -65,6 +65,7 @@ where
 	for rows.Next() {
 		row := featureTable{
 			ColumnsWithDateType: make(map[string]string),
+			fidColumn:           fidColumn,
 		}
 		if err = rows.StructScan(&row); err != nil {
 			return nil, fmt.Errorf("failed to read gpkg_contents record, error: %w", err)
@@ -77,10 +78,13 @@ where
 		}
 
 		for _, collection := range collections {
-			if row.Identifier == collection.ID {
-				result[collection.ID] = &row
-			} else if hasMatchingTableName(collection, row) {
-				result[collection.ID] = &row
+			if row.Identifier == collection.ID || hasMatchingTableName(collection, row) {
+				table := row // copy, since the external fid is configured per collection
+				if collection.Features != nil {
+					table.externalFidColumn = collection.Features.ExternalFid
+					table.writable = collection.Features.Writable
+				}
+				result[collection.ID] = &table
 			}
 		}
 	}
@@ -0,0 +1,131 @@
+package geopackage
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+)
+
+// keyset supports keyset pagination (aka the seek method) over the configured sort keys. Features are
+// always sorted by the fid last, to guarantee a stable order. The cursor holds the fid and sort key values
+// of the first feature of a page, this allows us to "seek" to that feature using the index on the
+// sort key instead of skipping over all preceding features.
+//
+// Note SQLite considers NULL smaller than any other value: nulls come first when sorting
+// in ascending order and last when sorting in descending order.
+type keyset struct {
+	fidColumn string
+	sortBy    []datasources.SortKey
+	cursor    domain.DecodedCursor
+}
+
+func newKeyset(fidColumn string, criteria datasources.FeaturesCriteria) keyset {
+	cursor := criteria.Cursor
+	if len(cursor.SortValues) != len(criteria.SortBy) {
+		// cursor doesn't match sort keys, start at first page
+		cursor = domain.DecodedCursor{FiltersChecksum: cursor.FiltersChecksum}
+	}
+	return keyset{fidColumn: fidColumn, sortBy: criteria.SortBy, cursor: cursor}
+}
+
+// orderBy clause for the sort keys and fid, use reverse to order from the cursor backwards
+func (k keyset) orderBy(columnPrefix string, reverse bool) string {
+	orderBy := make([]string, 0, len(k.sortBy)+1)
+	for _, key := range k.sortBy {
+		orderBy = append(orderBy, fmt.Sprintf("%s\"%s\" %s", columnPrefix, key.Property, sortDirection(key.Descending != reverse)))
+	}
+	return strings.Join(append(orderBy, fmt.Sprintf("%s\"%s\" %s", columnPrefix, k.fidColumn, sortDirection(reverse))), ", ")
+}
+
+// onOrAfterCursor where clause to select the features on (and after) the cursor
+func (k keyset) onOrAfterCursor(columnPrefix string) string {
+	return k.compareToCursor(0, columnPrefix, false)
+}
+
+// beforeCursor where clause to select the features before the cursor
+func (k keyset) beforeCursor(columnPrefix string) string {
+	return k.compareToCursor(0, columnPrefix, true)
+}
+
+func (k keyset) compareToCursor(i int, columnPrefix string, before bool) string {
+	if i == len(k.cursor.SortValues) {
+		if before {
+			return fmt.Sprintf("%s\"%s\" < :fid", columnPrefix, k.fidColumn)
+		}
+		return fmt.Sprintf("%s\"%s\" >= :fid", columnPrefix, k.fidColumn)
+	}
+	column := fmt.Sprintf("%s\"%s\"", columnPrefix, k.sortBy[i].Property)
+	namedParam := fmt.Sprintf(":sort%d", i+1)
+	next := k.compareToCursor(i+1, columnPrefix, before)
+
+	// seek towards larger values when selecting forward in ascending order or backwards in descending order
+	if k.sortBy[i].Descending == before {
+		if k.cursor.SortValues[i] == nil {
+			return fmt.Sprintf("(%[1]s is not null or %[2]s)", column, next)
+		}
+		return fmt.Sprintf("(%[1]s >= %[2]s and (%[1]s > %[2]s or %[3]s))", column, namedParam, next)
+	}
+	if k.cursor.SortValues[i] == nil {
+		return fmt.Sprintf("(%[1]s is null and %[2]s)", column, next)
+	}
+	return fmt.Sprintf("((%[1]s <= %[2]s and (%[1]s < %[2]s or %[3]s)) or %[1]s is null)", column, namedParam, next)
+}
+
+// prevNextColumns window functions to determine the fid and sort key values of the previous and next page
+func (k keyset) prevNextColumns() string {
+	window := "over (order by " + k.orderBy("", false) + ")"
+	columns := []string{
+		fmt.Sprintf("lag(\"%s\", :limit) %s as prevfid", k.fidColumn, window),
+		fmt.Sprintf("lead(\"%s\", :limit) %s as nextfid", k.fidColumn, window),
+	}
+	for i, key := range k.sortBy {
+		columns = append(columns, fmt.Sprintf("lag(\"%s\", :limit) %s as %s%d", key.Property, window, domain.PrevSortKeyColumnPrefix, i+1))
+	}
+	for i, key := range k.sortBy {
+		columns = append(columns, fmt.Sprintf("lead(\"%s\", :limit) %s as %s%d", key.Property, window, domain.NextSortKeyColumnPrefix, i+1))
+	}
+	return strings.Join(columns, ", ")
+}
+
+// prevNextColumnNames names of the columns holding the prev/next fid and sort key values
+func (k keyset) prevNextColumnNames() []string {
+	columns := []string{"prevfid", "nextfid"}
+	for i := range k.sortBy {
+		columns = append(columns, fmt.Sprintf("%s%d", domain.PrevSortKeyColumnPrefix, i+1))
+	}
+	for i := range k.sortBy {
+		columns = append(columns, fmt.Sprintf("%s%d", domain.NextSortKeyColumnPrefix, i+1))
+	}
+	return columns
+}
+
+// withSortKeys adds the sort keys to the given columns (when missing) since these are needed for sorting/paging
+func (k keyset) withSortKeys(columns []string) []string {
+	result := slices.Clone(columns)
+	for _, key := range k.sortBy {
+		if !slices.Contains(result, key.Property) {
+			result = append(result, key.Property)
+		}
+	}
+	return result
+}
+
+func (k keyset) namedParams() map[string]any {
+	namedParams := map[string]any{"fid": k.cursor.FID}
+	for i, value := range k.cursor.SortValues {
+		if value != nil {
+			namedParams[fmt.Sprintf("sort%d", i+1)] = value
+		}
+	}
+	return namedParams
+}
+
+func sortDirection(descending bool) string {
+	if descending {
+		return "desc"
+	}
+	return "asc"
+}
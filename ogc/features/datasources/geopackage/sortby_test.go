@@ -0,0 +1,66 @@
+package geopackage
+
+import (
+	"testing"
+
+	"github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyset(t *testing.T) {
+	tests := []struct {
+		name            string
+		sortBy          []datasources.SortKey
+		cursor          domain.DecodedCursor
+		wantOrderBy     string
+		wantOnOrAfter   string
+		wantBefore      string
+		wantNamedParams map[string]any
+	}{
+		{
+			name:            "sort by fid only",
+			cursor:          domain.DecodedCursor{FID: 10},
+			wantOrderBy:     `f."fid" asc`,
+			wantOnOrAfter:   `f."fid" >= :fid`,
+			wantBefore:      `f."fid" < :fid`,
+			wantNamedParams: map[string]any{"fid": int64(10)},
+		},
+		{
+			name:            "sort ascending and descending",
+			sortBy:          []datasources.SortKey{{Property: "straatnaam"}, {Property: "huisnummer", Descending: true}},
+			cursor:          domain.DecodedCursor{FID: 10, SortValues: []any{"Silodam", int64(5)}},
+			wantOrderBy:     `f."straatnaam" asc, f."huisnummer" desc, f."fid" asc`,
+			wantOnOrAfter:   `(f."straatnaam" >= :sort1 and (f."straatnaam" > :sort1 or ((f."huisnummer" <= :sort2 and (f."huisnummer" < :sort2 or f."fid" >= :fid)) or f."huisnummer" is null)))`,
+			wantBefore:      `((f."straatnaam" <= :sort1 and (f."straatnaam" < :sort1 or (f."huisnummer" >= :sort2 and (f."huisnummer" > :sort2 or f."fid" < :fid)))) or f."straatnaam" is null)`,
+			wantNamedParams: map[string]any{"fid": int64(10), "sort1": "Silodam", "sort2": int64(5)},
+		},
+		{
+			name:            "sort with null value in cursor",
+			sortBy:          []datasources.SortKey{{Property: "huisletter", Descending: true}},
+			cursor:          domain.DecodedCursor{FID: 10, SortValues: []any{nil}},
+			wantOrderBy:     `f."huisletter" desc, f."fid" asc`,
+			wantOnOrAfter:   `(f."huisletter" is null and f."fid" >= :fid)`,
+			wantBefore:      `(f."huisletter" is not null or f."fid" < :fid)`,
+			wantNamedParams: map[string]any{"fid": int64(10)},
+		},
+		{
+			name:            "start at first page when cursor doesn't match sort keys",
+			sortBy:          []datasources.SortKey{{Property: "straatnaam"}},
+			cursor:          domain.DecodedCursor{FID: 10},
+			wantOrderBy:     `f."straatnaam" asc, f."fid" asc`,
+			wantOnOrAfter:   `f."fid" >= :fid`,
+			wantBefore:      `f."fid" < :fid`,
+			wantNamedParams: map[string]any{"fid": int64(0)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := newKeyset("fid", datasources.FeaturesCriteria{SortBy: tt.sortBy, Cursor: tt.cursor})
+			assert.Equal(t, tt.wantOrderBy, ks.orderBy("f.", false))
+			assert.Equal(t, tt.wantOnOrAfter, ks.onOrAfterCursor("f."))
+			assert.Equal(t, tt.wantBefore, ks.beforeCursor("f."))
+			assert.Equal(t, tt.wantNamedParams, ks.namedParams())
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package geopackage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/datasources/reproject"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/gpkg"
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+// CreateFeature inserts the given feature, returns the (internal) fid of the new feature or
+// the external fid when configured for the collection.
+func (g *GeoPackage) CreateFeature(ctx context.Context, collection string, feature *domain.Feature) (any, error) {
+	table, err := g.getWritableFeatureTable(collection)
+	if err != nil {
+		return nil, err
+	}
+	values, err := g.columnValues(table, feature, true)
+	if err != nil {
+		return nil, err
+	}
+	if table.externalFidColumn != "" {
+		if feature.ID == nil {
+			return nil, fmt.Errorf("%w: feature id is required since collection '%s' uses an external feature id",
+				datasources.ErrInvalidFeature, collection)
+		}
+		values[table.externalFidColumn] = fmt.Sprint(feature.ID)
+	}
+
+	var fid int64
+	err = g.inTransaction(ctx, func(tx *sqlx.Tx) error {
+		if table.externalFidColumn != "" {
+			_, exists, err := g.lookupFID(ctx, tx, table, values[table.externalFidColumn])
+			if err != nil {
+				return err
+			}
+			if exists {
+				return fmt.Errorf("%w: a feature with id '%v' already exists in collection '%s'",
+					datasources.ErrFeatureConflict, values[table.externalFidColumn], collection)
+			}
+		}
+		columns, namedParams := namedColumnParams(values)
+		placeholders := make([]string, 0, len(columns))
+		for i := range columns {
+			placeholders = append(placeholders, fmt.Sprintf(":c%d", i+1))
+		}
+		query := fmt.Sprintf(`insert into "%s" (%s) values (%s)`,
+			table.TableName, quoteColumns("", columns), strings.Join(placeholders, ", "))
+		result, err := tx.NamedExecContext(ctx, query, namedParams)
+		if err != nil {
+			return fmt.Errorf("failed to insert feature, error: %w", wrapConstraintError(err))
+		}
+		fid, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if table.externalFidColumn != "" {
+		return values[table.externalFidColumn], nil
+	}
+	return fid, nil
+}
+
+// ReplaceFeature replaces all properties and the geometry of the given feature,
+// properties missing in the given feature are set to null.
+func (g *GeoPackage) ReplaceFeature(ctx context.Context, collection string, featureID any, feature *domain.Feature) (bool, error) {
+	return g.updateFeature(ctx, collection, featureID, feature, true)
+}
+
+// UpdateFeature updates only the given properties (and geometry when not nil) of the given feature.
+func (g *GeoPackage) UpdateFeature(ctx context.Context, collection string, featureID any, feature *domain.Feature) (bool, error) {
+	return g.updateFeature(ctx, collection, featureID, feature, false)
+}
+
+// DeleteFeature deletes the given feature, the rtree triggers of the GeoPackage remove its entry in the rtree.
+func (g *GeoPackage) DeleteFeature(ctx context.Context, collection string, featureID any) (bool, error) {
+	table, err := g.getWritableFeatureTable(collection)
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	err = g.inTransaction(ctx, func(tx *sqlx.Tx) error {
+		fid, ok, err := g.lookupFID(ctx, tx, table, featureID)
+		if err != nil || !ok {
+			return err
+		}
+		found = true
+		query := fmt.Sprintf(`delete from "%s" where "%s" = ?`, table.TableName, g.fidColumn)
+		if _, err = tx.ExecContext(ctx, query, fid); err != nil {
+			return fmt.Errorf("failed to delete feature, error: %w", err)
+		}
+		return nil
+	})
+	return found, err
+}
+
+func (g *GeoPackage) updateFeature(ctx context.Context, collection string, featureID any,
+	feature *domain.Feature, replace bool) (bool, error) {
+
+	table, err := g.getWritableFeatureTable(collection)
+	if err != nil {
+		return false, err
+	}
+	values, err := g.columnValues(table, feature, replace)
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	err = g.inTransaction(ctx, func(tx *sqlx.Tx) error {
+		fid, ok, err := g.lookupFID(ctx, tx, table, featureID)
+		if err != nil || !ok {
+			return err
+		}
+		found = true
+		if len(values) == 0 {
+			return nil // nothing to update
+		}
+		columns, namedParams := namedColumnParams(values)
+		assignments := make([]string, 0, len(columns))
+		for i, column := range columns {
+			assignments = append(assignments, fmt.Sprintf(`"%s" = :c%d`, column, i+1))
+		}
+		namedParams["fid"] = fid
+		query := fmt.Sprintf(`update "%s" set %s where "%s" = :fid`,
+			table.TableName, strings.Join(assignments, ", "), g.fidColumn)
+		if _, err = tx.NamedExecContext(ctx, query, namedParams); err != nil {
+			return fmt.Errorf("failed to update feature, error: %w", wrapConstraintError(err))
+		}
+		return nil
+	})
+	return found, err
+}
+
+func (g *GeoPackage) getWritableFeatureTable(collection string) (*featureTable, error) {
+	table, err := g.getFeatureTable(collection)
+	if err != nil {
+		return nil, err
+	}
+	if !table.writable {
+		return nil, fmt.Errorf("collection '%s' isn't writable", collection)
+	}
+	return table, nil
+}
+
+// inTransaction executes the given function in a (serialized) write transaction, which is
+// rolled back when the function returns an error.
+func (g *GeoPackage) inTransaction(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	g.writeLock.Lock()
+	defer g.writeLock.Unlock()
+
+	txCtx, cancel := context.WithTimeout(ctx, g.queryTimeout)
+	defer cancel()
+
+	tx, err := g.backend.getDB().BeginTxx(txCtx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction, error: %w", err)
+	}
+	// the rtree triggers of a GeoPackage call ST_* functions on GeoPackage geometries,
+	// which spatialite only supports in amphibious mode
+	if _, err = tx.ExecContext(txCtx, "select EnableGpkgAmphibiousMode()"); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to enable GeoPackage amphibious mode, error: %w", err)
+	}
+	if err = fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// lookupFID returns the internal fid of the feature with the given (internal or external) feature id.
+// Returns false when no such feature exists.
+func (g *GeoPackage) lookupFID(ctx context.Context, tx *sqlx.Tx, table *featureTable, featureID any) (int64, bool, error) {
+	idColumn := g.fidColumn
+	if table.externalFidColumn != "" {
+		idColumn = table.externalFidColumn
+	}
+	var fid int64
+	query := fmt.Sprintf(`select "%s" from "%s" where "%s" = ? limit 1`, g.fidColumn, table.TableName, idColumn)
+	if err := tx.GetContext(ctx, &fid, query, featureID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to lookup feature, error: %w", err)
+	}
+	return fid, true, nil
+}
+
+// columnValues maps the properties and geometry of the given feature to column values. The geometry (GeoJSON,
+// so always in CRS84) is reprojected to the SRS of the feature table. The bounding box columns are derived from
+// the geometry, since these are used (like the rtree) to query features by bbox.
+// When replacing a feature all columns receive a value, properties missing in the given feature become null.
+func (g *GeoPackage) columnValues(table *featureTable, feature *domain.Feature, replace bool) (map[string]any, error) {
+	values := make(map[string]any)
+	if replace {
+		for column := range table.ColumnsWithDateType {
+			if column != g.fidColumn && column != table.externalFidColumn {
+				values[column] = nil
+			}
+		}
+	}
+	for property, value := range feature.Properties {
+		if err := g.assertWritableColumn(table, property); err != nil {
+			return nil, err
+		}
+		switch v := value.(type) {
+		case nil, string, float64, int64, bool:
+			values[property] = value
+		case json.Number:
+			// integers are kept as-is, since these may exceed the precision of float64
+			if i, err := v.Int64(); err == nil {
+				values[property] = i
+			} else if f, err := v.Float64(); err == nil {
+				values[property] = f
+			} else {
+				return nil, fmt.Errorf("%w: property '%s' has an invalid number", datasources.ErrInvalidFeature, property)
+			}
+		default:
+			return nil, fmt.Errorf("%w: property '%s' has an unsupported value, only strings, "+
+				"numbers, booleans or null are allowed", datasources.ErrInvalidFeature, property)
+		}
+	}
+	if feature.Geometry != nil && feature.Geometry.Geometry != nil {
+		geometry, err := reproject.TransformGeometry(feature.Geometry.Geometry, crs84SRID, int(table.SRS))
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to reproject geometry, error: %w", datasources.ErrInvalidFeature, err)
+		}
+		extent, err := geom.NewExtentFromGeometry(geometry)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to determine bounding box of geometry, error: %w", datasources.ErrInvalidFeature, err)
+		}
+		binary, err := gpkg.NewBinary(int32(table.SRS), geometry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode geometry, error: %w", err)
+		}
+		encoded, err := binary.Encode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode geometry, error: %w", err)
+		}
+		values[table.GeometryColumnName] = encoded
+		values["minx"], values["miny"] = extent.MinX(), extent.MinY()
+		values["maxx"], values["maxy"] = extent.MaxX(), extent.MaxY()
+	}
+	return values, nil
+}
+
+// assertWritableColumn asserts the given property is a column in the feature table, other than the
+// (external) fid, geometry or bounding box columns which are managed separately.
+func (g *GeoPackage) assertWritableColumn(table *featureTable, property string) error {
+	if _, ok := table.ColumnsWithDateType[property]; !ok {
+		return fmt.Errorf("%w: property '%s' doesn't exist in feature table '%s'",
+			datasources.ErrInvalidFeature, property, table.TableName)
+	}
+	switch property {
+	case g.fidColumn, table.externalFidColumn, table.GeometryColumnName, "minx", "miny", "maxx", "maxy":
+		return fmt.Errorf("%w: property '%s' can't be written directly", datasources.ErrInvalidFeature, property)
+	}
+	return nil
+}
+
+// namedColumnParams returns the (sorted) columns and their values as positional named params (c1, c2, etc.).
+// Column names aren't used as named params since these may contain characters not allowed in named params.
+func namedColumnParams(values map[string]any) ([]string, map[string]any) {
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	namedParams := make(map[string]any, len(columns)+1)
+	for i, column := range columns {
+		namedParams[fmt.Sprintf("c%d", i+1)] = values[column]
+	}
+	return columns, namedParams
+}
+
+func hasWritableFeatureTables(featureTableByCollectionID map[string]*featureTable) bool {
+	for _, table := range featureTableByCollectionID {
+		if table.writable {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapConstraintError marks violations of unique constraints as conflicts and violations of
+// other constraints (e.g. not null) as invalid features, other errors are returned as-is.
+func wrapConstraintError(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return err
+	}
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return fmt.Errorf("%w: %w", datasources.ErrFeatureConflict, err)
+	default:
+		return fmt.Errorf("%w: %w", datasources.ErrInvalidFeature, err)
+	}
+}
+
+// assertWritableFeatureTables asserts writable feature tables have the rtree triggers defined by the GeoPackage
+// spec, since these keep the rtree in sync on writes. Also asserts geometries can be reprojected from CRS84
+// (GeoJSON) to the SRS of the feature table.
+func assertWritableFeatureTables(featureTableByCollectionID map[string]*featureTable, db *sqlx.DB) error {
+	for _, table := range featureTableByCollectionID {
+		if !table.writable {
+			continue
+		}
+		if !reproject.IsSupported(int(table.SRS)) {
+			return fmt.Errorf("feature table '%s' can't be writable, since geometries can't be "+
+				"reprojected to its SRS %d", table.TableName, table.SRS)
+		}
+		var triggers []string
+		if err := db.Select(&triggers, `select name from sqlite_master where type = 'trigger' and tbl_name = ?`,
+			table.TableName); err != nil {
+			return fmt.Errorf("failed to read triggers of feature table '%s', error: %w", table.TableName, err)
+		}
+		rtree := fmt.Sprintf("rtree_%s_%s_", table.TableName, table.GeometryColumnName)
+		if !slices.Contains(triggers, rtree+"insert") || !slices.Contains(triggers, rtree+"delete") ||
+			!slices.ContainsFunc(triggers, func(t string) bool { return strings.HasPrefix(t, rtree+"update") }) {
+			return fmt.Errorf("feature table '%s' can't be writable, since the rtree triggers (%s*) are missing",
+				table.TableName, rtree)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package postgis
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PDOK/gokoala/config"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// assertIndexesExist asserts required indexes in the PostGIS database exists
+func assertIndexesExist(
+	configuredCollections config.GeoSpatialCollections,
+	featureTableByCollectionID map[string]*featureTable,
+	db *sqlx.DB, schema string, fidColumn string) error {
+
+	for collID, table := range featureTableByCollectionID {
+		if table == nil {
+			return errors.New("given table can't be nil")
+		}
+		for _, coll := range configuredCollections {
+			if coll.ID == collID && coll.Features != nil {
+				// assert feature id is indexed (usually the primary key), needed for pagination
+				if err := assertIndexExists(schema, table.TableName, db, fidColumn, true); err != nil {
+					return err
+				}
+
+				// assert spatial (GiST) index exists, needed for bbox queries
+				if err := assertIndexExists(schema, table.TableName, db, table.GeometryColumnName, false); err != nil {
+					return err
+				}
+
+				// assert temporal columns are indexed if configured
+				if coll.Metadata != nil && coll.Metadata.TemporalProperties != nil {
+					temporalBtreeColumns := strings.Join([]string{coll.Metadata.TemporalProperties.StartDate, coll.Metadata.TemporalProperties.EndDate}, ",")
+					if err := assertIndexExists(schema, table.TableName, db, temporalBtreeColumns, true); err != nil {
+						return err
+					}
+				}
+
+				// assert the column for each property filter is indexed.
+				for _, propertyFilter := range coll.Features.Filters.Properties {
+					if err := assertIndexExists(schema, table.TableName, db, propertyFilter.Name, false); err != nil {
+						return err
+					}
+					// text columns can be filtered by prefix, which requires an index in the "C" collation
+					if isTextType(table.ColumnsWithDateType[propertyFilter.Name]) {
+						if err := assertCCollatedIndexExists(schema, table.TableName, db, propertyFilter.Name); err != nil {
+							return err
+						}
+					}
+				}
+
+				// assert the external feature id is indexed (as first column), needed to lookup features by this id
+				if coll.Features.ExternalFid != "" {
+					if err := assertIndexExists(schema, table.TableName, db, coll.Features.ExternalFid, true); err != nil {
+						return err
+					}
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func assertIndexExists(schema string, tableName string, db *sqlx.DB, columns string, prefixMatch bool) error {
+	query := `
+select array_to_string(array_agg(a.attname order by k.position), ',') as indexed_columns
+from pg_index i
+     join pg_class t on t.oid = i.indrelid
+     join pg_namespace ns on ns.oid = t.relnamespace
+     cross join lateral unnest(i.indkey) with ordinality as k(attnum, position)
+     join pg_attribute a on a.attrelid = t.oid and a.attnum = k.attnum
+where ns.nspname = $1 and t.relname = $2
+group by i.indexrelid`
+
+	rows, err := db.Queryx(query, schema, tableName)
+	if err != nil {
+		return fmt.Errorf("failed to read indexes from table '%s'", tableName)
+	}
+	defer rows.Close()
+
+	exists := false
+	for rows.Next() {
+		var indexedColumns string
+		if err = rows.Scan(&indexedColumns); err != nil {
+			return fmt.Errorf("failed to read indexes from table '%s', error: %w", tableName, err)
+		}
+		if columns == indexedColumns {
+			exists = true // index on expected columns
+		} else if prefixMatch && strings.HasPrefix(indexedColumns, columns) {
+			exists = true // index with expected prefix columns
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to read indexes from table '%s', error: %w", tableName, err)
+	}
+	if !exists {
+		return fmt.Errorf("missing required index: no index exists on column(s) '%s' in table '%s'",
+			columns, tableName)
+	}
+	return nil
+}
+
+// assertCCollatedIndexExists asserts the given column has an index using the "C" collation (explicitly
+// or as database default). Property filters with a wildcard are translated to range comparisons in the "C"
+// collation, which can't use an index in another collation.
+func assertCCollatedIndexExists(schema string, tableName string, db *sqlx.DB, column string) error {
+	query := `
+select exists(
+    select 1
+    from pg_index i
+         join pg_class t on t.oid = i.indrelid
+         join pg_namespace ns on ns.oid = t.relnamespace
+         join pg_attribute a on a.attrelid = t.oid and a.attnum = i.indkey[0]
+         join pg_collation c on c.oid = i.indcollation[0]
+    where ns.nspname = $1 and t.relname = $2 and a.attname = $3
+      and (c.collname in ('C', 'POSIX') or (c.collname = 'default' and
+           (select datcollate from pg_database where datname = current_database()) in ('C', 'POSIX'))))`
+
+	var exists bool
+	if err := db.QueryRowx(query, schema, tableName, column).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to read index collation of column '%s' in table '%s'", column, tableName)
+	}
+	if !exists {
+		return fmt.Errorf("missing required index: no index with \"C\" collation exists on column '%s' in table "+
+			"'%s', needed for property filters with a wildcard. Create one using: create index on %s.%s (%s collate \"C\")",
+			column, tableName, quote(schema), quote(tableName), quote(column))
+	}
+	return nil
+}
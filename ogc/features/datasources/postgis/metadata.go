@@ -0,0 +1,196 @@
+package postgis
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PDOK/gokoala/config"
+	"github.com/PDOK/gokoala/ogc/features/datasources"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type featureTable struct {
+	Schema             string `db:"f_table_schema"`
+	TableName          string `db:"f_table_name"`
+	GeometryColumnName string `db:"f_geometry_column"`
+	GeometryType       string `db:"type"`
+	SRID               int64  `db:"srid"`
+
+	Columns             []string // in table order
+	ColumnsWithDateType map[string]string
+	fidColumn           string
+	externalFidColumn   string // optional, configured per collection
+}
+
+func (ft featureTable) ColumnsWithDataType() map[string]string {
+	return ft.ColumnsWithDateType
+}
+
+func (ft featureTable) IDColumn() string {
+	return ft.fidColumn
+}
+
+func (ft featureTable) GeometryColumn() datasources.GeometryColumn {
+	return datasources.GeometryColumn{Name: ft.GeometryColumnName, Type: ft.GeometryType, SRID: ft.SRID}
+}
+
+func (ft featureTable) LastModified() time.Time {
+	return time.Time{} // unknown, PostGIS doesn't keep track of changes to tables
+}
+
+// qualifiedName returns the schema-qualified (and quoted) name of the feature table
+func (ft featureTable) qualifiedName() string {
+	return quote(ft.Schema) + "." + quote(ft.TableName)
+}
+
+// selectClause returns the columns of the feature table matching the given property selection (all columns
+// when nothing is selected), where the geometry column is returned as WKB. The fid column and external fid
+// column (when configured) are always returned.
+func (ft featureTable) selectClause(fidColumn string, selection datasources.PropertySelection) string {
+	columns := make([]string, 0, len(ft.Columns))
+	for _, column := range ft.Columns {
+		switch {
+		case column == ft.GeometryColumnName:
+			if !selection.SkipGeometry {
+				columns = append(columns, fmt.Sprintf("st_asbinary(%[1]s) as %[1]s", quote(column)))
+			}
+		case column == fidColumn || column == ft.externalFidColumn ||
+			len(selection.Properties) == 0 || slices.Contains(selection.Properties, column):
+			columns = append(columns, quote(column))
+		}
+	}
+	return strings.Join(columns, ", ")
+}
+
+// Read metadata about PostgreSQL and PostGIS
+func readDriverMetadata(db *sqlx.DB) (string, error) {
+	type metadata struct {
+		Postgres string `db:"postgres"`
+		PostGIS  string `db:"postgis"`
+	}
+
+	var m metadata
+	err := db.QueryRowx(`
+select current_setting('server_version') as postgres,
+postgis_lib_version() as postgis`).StructScan(&m)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("postgres version: %s, postgis version: %s", m.Postgres, m.PostGIS), nil
+}
+
+// Read geometry_columns view. This view contains metadata about feature tables. The result is a mapping from
+// collection ID -> feature table metadata. We match each feature table to the collection ID by looking at the
+// table name. Also in case there's no exact match between 'collection ID' and table name we use
+// the explicitly configured table name.
+func readFeatureTables(collections config.GeoSpatialCollections, db *sqlx.DB, schema string, fidColumn string) (map[string]*featureTable, error) {
+	query := `
+select
+	gc.f_table_schema, gc.f_table_name, gc.f_geometry_column, gc.type, gc.srid
+from
+	geometry_columns gc
+where
+	gc.f_table_schema = $1`
+
+	rows, err := db.Queryx(query, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve geometry_columns using query: %v\n, error: %w", query, err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]*featureTable, 10)
+	for rows.Next() {
+		row := featureTable{
+			ColumnsWithDateType: make(map[string]string),
+			fidColumn:           fidColumn,
+		}
+		if err = rows.StructScan(&row); err != nil {
+			return nil, fmt.Errorf("failed to read geometry_columns record, error: %w", err)
+		}
+		if row.TableName == "" {
+			return nil, fmt.Errorf("feature table name is blank, error: %w", err)
+		}
+
+		for _, collection := range collections {
+			if row.TableName == collection.ID || hasMatchingTableName(collection, row) {
+				table := row // copy, since the external fid is configured per collection
+				if err = readFeatureTableInfo(db, &table); err != nil {
+					return nil, fmt.Errorf("failed to read feature table metadata, error: %w", err)
+				}
+				if collection.Features != nil {
+					table.externalFidColumn = collection.Features.ExternalFid
+				}
+				result[collection.ID] = &table
+			}
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no feature tables found in schema '%s', can't serve features", schema)
+	}
+	uniqueTables := make(map[string]struct{})
+	for _, table := range result {
+		uniqueTables[table.TableName] = struct{}{}
+	}
+	if len(uniqueTables) != len(result) {
+		log.Printf("Warning: found %d unique table names for %d collections, "+
+			"usually each collection is backed by its own unique table\n", len(uniqueTables), len(result))
+	}
+	return result, nil
+}
+
+func readFeatureTableInfo(db *sqlx.DB, table *featureTable) error {
+	if len(table.Columns) > 0 {
+		return nil // already read
+	}
+	rows, err := db.Queryx(`
+select column_name, data_type
+from information_schema.columns
+where table_schema = $1 and table_name = $2
+order by ordinal_position`, table.Schema, table.TableName)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var colName, colType string
+		err = rows.Scan(&colName, &colType)
+		if err != nil {
+			return err
+		}
+		if colName == table.GeometryColumnName {
+			// information_schema reports 'USER-DEFINED', use the actual geometry type instead (like GeoPackage does)
+			colType = table.GeometryType
+		}
+		table.Columns = append(table.Columns, colName)
+		table.ColumnsWithDateType[colName] = colType
+	}
+	if len(table.Columns) == 0 {
+		return errors.New("no columns found for table " + table.qualifiedName())
+	}
+	return rows.Err()
+}
+
+// isTextType returns true when the given data type (as reported by information_schema) is a character type
+func isTextType(dataType string) bool {
+	switch dataType {
+	case "text", "character varying", "character":
+		return true
+	default:
+		return false
+	}
+}
+
+func hasMatchingTableName(collection config.GeoSpatialCollection, row featureTable) bool {
+	return collection.Features != nil && collection.Features.TableName != nil &&
+		row.TableName == *collection.Features.TableName
+}
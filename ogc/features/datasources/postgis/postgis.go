@@ -2,45 +2,500 @@ package postgis
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 
+	"github.com/PDOK/gokoala/config"
+
+	"github.com/PDOK/gokoala/engine/util"
 	"github.com/PDOK/gokoala/ogc/features/datasources"
 	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/wkb"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/qustavo/sqlhooks/v2"
+)
+
+const (
+	pgxDriverName = "pgx_with_sqllog"
+
+	// GeoPackages use a custom SRID for WGS84 in lon/lat order (CRS84),
+	// in PostGIS this is just EPSG:4326 since coordinates are always stored in x/y order
+	wgs84SRIDGeoPackage = 100000
+	wgs84SRIDPostGIS    = 4326
+
+	// UTF-16 surrogate code points, not allowed in UTF-8
+	surrogateMin = 0xD800
+	surrogateMax = 0xDFFF
 )
 
-// PostGIS !!! Placeholder implementation, for future reference !!!
+var once sync.Once
+
+// Register pgx driver (wrapped with query logging) once
+func loadDriver() {
+	once.Do(func() {
+		sql.Register(pgxDriverName, sqlhooks.Wrap(stdlib.GetDefaultDriver(), datasources.NewSQLLogFromEnv()))
+		sqlx.BindDriver(pgxDriverName, sqlx.DOLLAR)
+	})
+}
+
 type PostGIS struct {
+	db *sqlx.DB
+
+	fidColumn                  string
+	schema                     string
+	featureTableByCollectionID map[string]*featureTable
+	queryTimeout               time.Duration
+}
+
+func NewPostGIS(collections config.GeoSpatialCollections, pgConfig config.PostGIS) *PostGIS {
+	loadDriver()
+
+	db, err := sqlx.Open(pgxDriverName, pgConfig.ConnectionString())
+	if err != nil {
+		log.Fatalf("failed to open PostGIS: %v", err)
+	}
+	db.SetMaxOpenConns(pgConfig.MaxOpenConnections)
+
+	pg := &PostGIS{
+		db:           db,
+		fidColumn:    pgConfig.Fid,
+		schema:       pgConfig.Schema,
+		queryTimeout: pgConfig.QueryTimeout.Duration,
+	}
+
+	metadata, err := readDriverMetadata(pg.db)
+	if err != nil {
+		log.Fatalf("failed to connect with PostGIS: %v", err)
+	}
+	log.Printf("connected to PostGIS database '%s' on %s, %s", pgConfig.DatabaseName, pgConfig.Host, metadata)
+
+	pg.featureTableByCollectionID, err = readFeatureTables(collections, pg.db, pg.schema, pg.fidColumn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = assertIndexesExist(collections, pg.featureTableByCollectionID, pg.db, pg.schema, pg.fidColumn); err != nil {
+		log.Fatal(err)
+	}
+	return pg
+}
+
+func (pg *PostGIS) Close() {
+	err := pg.db.Close()
+	if err != nil {
+		log.Printf("failed to close PostGIS: %v", err)
+	}
+}
+
+func (pg *PostGIS) GetFeatureIDs(ctx context.Context, collection string, criteria datasources.FeaturesCriteria) ([]int64, domain.Cursors, error) {
+	table, err := pg.getFeatureTable(collection)
+	if err != nil {
+		return nil, domain.Cursors{}, err
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, pg.queryTimeout) // https://go.dev/doc/database/cancel-operations
+	defer cancel()
+
+	query, queryArgs, err := pg.makeFeaturesQuery(table, true, criteria)
+	if err != nil {
+		return nil, domain.Cursors{}, err
+	}
+	rows, err := pg.db.NamedQueryContext(queryCtx, query, queryArgs)
+	if err != nil {
+		return nil, domain.Cursors{}, fmt.Errorf("failed to execute query '%s' error: %w", query, err)
+	}
+	defer rows.Close()
+
+	featureIDs, prevNext, err := domain.MapRowsToFeatureIDs(rows)
+	if err != nil {
+		return nil, domain.Cursors{}, err
+	}
+	if prevNext == nil {
+		return nil, domain.Cursors{}, nil
+	}
+	return featureIDs, domain.NewCursors(*prevNext, criteria.Cursor.FiltersChecksum), nil
+}
+
+func (pg *PostGIS) GetFeaturesByID(ctx context.Context, collection string, featureIDs []int64,
+	selection datasources.PropertySelection) (*domain.FeatureCollection, error) {
+	table, err := pg.getFeatureTable(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, pg.queryTimeout) // https://go.dev/doc/database/cancel-operations
+	defer cancel()
+
+	fids := map[string]any{"fids": featureIDs}
+	query, queryArgs, err := sqlx.Named(fmt.Sprintf("select %s from %s where %s in (:fids) order by %[3]s",
+		table.selectClause(pg.fidColumn, selection), table.qualifiedName(), quote(pg.fidColumn)), fids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make features query, error: %w", err)
+	}
+	query, queryArgs, err = sqlx.In(query, queryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make IN-clause, error: %w", err)
+	}
+
+	rows, err := pg.db.QueryxContext(queryCtx, pg.db.Rebind(query), queryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query '%s' error: %w", query, err)
+	}
+	defer rows.Close()
+
+	fc := domain.FeatureCollection{}
+	fc.Features, _, err = domain.MapRowsToFeatures(rows, pg.fidColumn, table.externalFidColumn, table.GeometryColumnName, readPostGISGeometry)
+	if err != nil {
+		return nil, err
+	}
+	fc.NumberReturned = len(fc.Features)
+	return &fc, nil
+}
+
+func (pg *PostGIS) GetFeatures(ctx context.Context, collection string, criteria datasources.FeaturesCriteria) (*domain.FeatureCollection, domain.Cursors, error) {
+	it, cursors, err := pg.StreamFeatures(ctx, collection, criteria)
+	if err != nil || it == nil {
+		return nil, cursors, err
+	}
+	defer it.Close()
+
+	fc := domain.FeatureCollection{}
+	if fc.Features, err = domain.ReadFeatures(it); err != nil {
+		return nil, domain.Cursors{}, err
+	}
+	fc.NumberReturned = len(fc.Features)
+	return &fc, cursors, nil
+}
+
+func (pg *PostGIS) StreamFeatures(ctx context.Context, collection string, criteria datasources.FeaturesCriteria) (domain.FeatureIterator, domain.Cursors, error) {
+	table, err := pg.getFeatureTable(collection)
+	if err != nil {
+		return nil, domain.Cursors{}, err
+	}
+
+	query, queryArgs, err := pg.makeFeaturesQuery(table, false, criteria)
+	if err != nil {
+		return nil, domain.Cursors{}, err
+	}
+
+	// the query context is canceled when the iterator is closed, since rows are read while streaming
+	queryCtx, cancel := context.WithTimeout(ctx, pg.queryTimeout) // https://go.dev/doc/database/cancel-operations
+	rows, err := pg.db.NamedQueryContext(queryCtx, query, queryArgs)
+	if err != nil {
+		cancel()
+		return nil, domain.Cursors{}, fmt.Errorf("failed to execute query '%s' error: %w", query, err)
+	}
+
+	it, prevNext, err := domain.NewRowsFeatureIterator(rows, pg.fidColumn, table.externalFidColumn, table.GeometryColumnName, readPostGISGeometry, cancel)
+	if err != nil || prevNext == nil {
+		it.Close()
+		return nil, domain.Cursors{}, err
+	}
+	return it, domain.NewCursors(*prevNext, criteria.Cursor.FiltersChecksum), nil
+}
+
+func (pg *PostGIS) CountFeatures(ctx context.Context, collection string, criteria datasources.FeaturesCriteria) (int, error) {
+	table, err := pg.getFeatureTable(collection)
+	if err != nil {
+		return 0, err
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, pg.queryTimeout) // https://go.dev/doc/database/cancel-operations
+	defer cancel()
+
+	query, queryArgs, err := pg.makeCountQuery(table, criteria)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := pg.db.NamedQueryContext(queryCtx, query, queryArgs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute count query '%s' error: %w", query, err)
+	}
+	defer rows.Close()
+
+	var count int
+	if rows.Next() {
+		err = rows.Scan(&count)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return count, rows.Err()
+}
+
+func (pg *PostGIS) GetFeature(ctx context.Context, collection string, featureID any,
+	selection datasources.PropertySelection) (*domain.Feature, error) {
+	table, err := pg.getFeatureTable(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, pg.queryTimeout) // https://go.dev/doc/database/cancel-operations
+	defer cancel()
+
+	idColumn := pg.fidColumn
+	if table.externalFidColumn != "" {
+		idColumn = table.externalFidColumn
+	}
+	query := fmt.Sprintf("select %s from %s where %s = :fid limit 1",
+		table.selectClause(pg.fidColumn, selection), table.qualifiedName(), quote(idColumn))
+	rows, err := pg.db.NamedQueryContext(queryCtx, query, map[string]any{"fid": featureID})
+	if err != nil {
+		return nil, fmt.Errorf("query '%s' failed: %w", query, err)
+	}
+	defer rows.Close()
+
+	features, _, err := domain.MapRowsToFeatures(rows, pg.fidColumn, table.externalFidColumn, table.GeometryColumnName, readPostGISGeometry)
+	if err != nil {
+		return nil, err
+	}
+	if len(features) != 1 {
+		return nil, nil
+	}
+	return features[0], nil
+}
+
+func (pg *PostGIS) GetFeatureTableMetadata(collection string) (datasources.FeatureTableMetadata, error) {
+	val, ok := pg.featureTableByCollectionID[collection]
+	if !ok {
+		return nil, fmt.Errorf("no metadata for %s", collection)
+	}
+	return val, nil
+}
+
+// SupportsPrefixFilter returns true for text columns only, since prefixes are matched by a range comparison
+// in the "C" collation, while PostgreSQL doesn't support collations for other types (e.g. integer or date).
+func (pg *PostGIS) SupportsPrefixFilter(collection string, property string) bool {
+	table, ok := pg.featureTableByCollectionID[collection]
+	return ok && isTextType(table.ColumnsWithDateType[property])
+}
+
+// Build specific features queries based on the given options.
+// Make sure to use SQL bind variables and return named params: https://jmoiron.github.io/sqlx/#namedParams
+func (pg *PostGIS) makeFeaturesQuery(table *featureTable, onlyFIDs bool, criteria datasources.FeaturesCriteria) (string, map[string]any, error) {
+	if criteria.Filter != nil {
+		return "", nil, errors.New("CQL filters are not supported by the PostGIS datasource")
+	}
+	if len(criteria.SortBy) > 0 {
+		return "", nil, errors.New("sortby is not supported by the PostGIS datasource")
+	}
+	selectClause := table.selectClause(pg.fidColumn, criteria.PropertySelection)
+	if onlyFIDs {
+		selectClause = quote(pg.fidColumn) + ", prevfid, nextfid"
+	} else {
+		selectClause += ", prevfid, nextfid"
+	}
+	if criteria.Bbox != nil {
+		query, namedParams := pg.makeBboxQuery(table, selectClause, criteria)
+		return query, namedParams, nil
+	}
+	query, namedParams := pg.makeDefaultQuery(table, selectClause, criteria)
+	return query, namedParams, nil
+}
+
+func (pg *PostGIS) makeDefaultQuery(table *featureTable, selectClause string, criteria datasources.FeaturesCriteria) (string, map[string]any) {
+	pfClause, pfNamedParams := propertyFiltersToSQL(criteria.PropertyFilters)
+	temporalClause, temporalNamedParams := temporalCriteriaToSQL(criteria.TemporalCriteria)
+
+	defaultQuery := fmt.Sprintf(`
+with
+    next as (select * from %[1]s where %[2]s >= :fid %[3]s %[4]s order by %[2]s asc limit :limit + 1),
+    prev as (select * from %[1]s where %[2]s < :fid %[3]s %[4]s order by %[2]s desc limit :limit),
+    nextprev as (select * from next union all select * from prev),
+    nextprevfeat as (select *, lag(%[2]s, :limit) over (order by %[2]s) as prevfid, lead(%[2]s, :limit) over (order by %[2]s) as nextfid from nextprev)
+select %[5]s from nextprevfeat where %[2]s >= :fid %[3]s %[4]s order by %[2]s asc limit :limit
+`, table.qualifiedName(), quote(pg.fidColumn), temporalClause, pfClause, selectClause) // don't add user input here, use named params for user input!
+
+	namedParams := map[string]any{
+		"fid":   criteria.Cursor.FID,
+		"limit": criteria.Limit,
+	}
+	maps.Copy(namedParams, pfNamedParams)
+	maps.Copy(namedParams, temporalNamedParams)
+	return defaultQuery, namedParams
+}
+
+func (pg *PostGIS) makeBboxQuery(table *featureTable, selectClause string, criteria datasources.FeaturesCriteria) (string, map[string]any) {
+	pfClause, pfNamedParams := propertyFiltersToSQL(criteria.PropertyFilters)
+	temporalClause, temporalNamedParams := temporalCriteriaToSQL(criteria.TemporalCriteria)
+	givenBbox, namedParams := bboxToSQL(table, criteria)
+
+	bboxQuery := fmt.Sprintf(`
+with
+    given_bbox as (select %[5]s as geom),
+    next as (select f.* from %[1]s f
+             where st_intersects(f.%[3]s, (select geom from given_bbox))
+               and f.%[2]s >= :fid %[6]s %[7]s
+             order by f.%[2]s asc
+             limit :limit + 1),
+    prev as (select f.* from %[1]s f
+             where st_intersects(f.%[3]s, (select geom from given_bbox))
+               and f.%[2]s < :fid %[6]s %[7]s
+             order by f.%[2]s desc
+             limit :limit),
+    nextprev as (select * from next union all select * from prev),
+    nextprevfeat as (select *, lag(%[2]s, :limit) over (order by %[2]s) as prevfid, lead(%[2]s, :limit) over (order by %[2]s) as nextfid from nextprev)
+select %[4]s from nextprevfeat where %[2]s >= :fid %[6]s %[7]s order by %[2]s asc limit :limit
+`, table.qualifiedName(), quote(pg.fidColumn), quote(table.GeometryColumnName), selectClause,
+		givenBbox, temporalClause, pfClause) // don't add user input here, use named params for user input!
+
+	namedParams["fid"] = criteria.Cursor.FID
+	namedParams["limit"] = criteria.Limit
+	maps.Copy(namedParams, pfNamedParams)
+	maps.Copy(namedParams, temporalNamedParams)
+	return bboxQuery, namedParams
+}
+
+// makeCountQuery counts the features matching the given criteria, regardless of pagination. Uses the
+// same filter clauses as makeDefaultQuery and makeBboxQuery, so the count matches the features returned.
+func (pg *PostGIS) makeCountQuery(table *featureTable, criteria datasources.FeaturesCriteria) (string, map[string]any, error) {
+	if criteria.Filter != nil {
+		return "", nil, errors.New("CQL filters are not supported by the PostGIS datasource")
+	}
+	pfClause, namedParams := propertyFiltersToSQL(criteria.PropertyFilters)
+	temporalClause, temporalNamedParams := temporalCriteriaToSQL(criteria.TemporalCriteria)
+	maps.Copy(namedParams, temporalNamedParams)
+
+	bboxClause := ""
+	if criteria.Bbox != nil {
+		givenBbox, bboxNamedParams := bboxToSQL(table, criteria)
+		bboxClause = fmt.Sprintf("and st_intersects(f.%s, %s)", quote(table.GeometryColumnName), givenBbox)
+		maps.Copy(namedParams, bboxNamedParams)
+	}
+
+	countQuery := fmt.Sprintf(`
+select count(*) from %[1]s f where true %[2]s %[3]s %[4]s
+`, table.qualifiedName(), bboxClause, temporalClause, pfClause) // don't add user input here, use named params for user input!
+
+	return countQuery, namedParams, nil
 }
 
-func NewPostGIS() *PostGIS {
-	return &PostGIS{}
+// bboxToSQL returns the SQL expression of the given bbox, and the corresponding named params.
+// The bbox is transformed to the SRID of the feature table in order to use the spatial (GiST) index.
+func bboxToSQL(table *featureTable, criteria datasources.FeaturesCriteria) (sql string, namedParams map[string]any) {
+	sql = "st_makeenvelope(:minx, :miny, :maxx, :maxy, :bboxSrid)"
+	if table.SRID > 0 {
+		sql = fmt.Sprintf("st_transform(%s, %d)", sql, table.SRID)
+	}
+	bboxSrid := criteria.InputSRID
+	if bboxSrid == wgs84SRIDGeoPackage {
+		bboxSrid = wgs84SRIDPostGIS
+	}
+	namedParams = map[string]any{
+		"maxx":     criteria.Bbox.MaxX(),
+		"minx":     criteria.Bbox.MinX(),
+		"maxy":     criteria.Bbox.MaxY(),
+		"miny":     criteria.Bbox.MinY(),
+		"bboxSrid": bboxSrid}
+	return sql, namedParams
 }
 
-func (PostGIS) Close() {
-	// noop
+func (pg *PostGIS) getFeatureTable(collection string) (*featureTable, error) {
+	table, ok := pg.featureTableByCollectionID[collection]
+	if !ok {
+		return nil, fmt.Errorf("can't query collection '%s' since it doesn't exist in "+
+			"PostGIS, available in PostGIS: %v", collection, util.Keys(pg.featureTableByCollectionID))
+	}
+	return table, nil
 }
 
-func (pg PostGIS) GetFeatureIDs(_ context.Context, _ string, _ datasources.FeaturesCriteria) ([]int64, domain.Cursors, error) {
-	log.Println("PostGIS support is not implemented yet, this just serves to demonstrate that we can support multiple types of datasources")
-	return []int64{}, domain.Cursors{}, nil
+func readPostGISGeometry(rawGeom []byte) (geom.Geometry, error) {
+	return wkb.DecodeBytes(rawGeom)
 }
 
-func (pg PostGIS) GetFeaturesByID(_ context.Context, _ string, _ []int64) (*domain.FeatureCollection, error) {
-	log.Println("PostGIS support is not implemented yet, this just serves to demonstrate that we can support multiple types of datasources")
-	return &domain.FeatureCollection{}, nil
+// propertyFiltersToSQL translates property filters to an equality comparison, an IN clause (multiple values)
+// and/or range comparisons (values ending with a wildcard), like the GeoPackage datasource. Range comparisons
+// use the "C" collation (code point order) so the upper bound is correct regardless of the database collation.
+// Prefixes are only allowed on text columns (see SupportsPrefixFilter), which are required to have an index
+// in the "C" collation (see assertIndexesExist) so these range comparisons use the index.
+func propertyFiltersToSQL(pf map[string]string) (sql string, namedParams map[string]any) {
+	namedParams = make(map[string]any)
+	position := 0
+	nextParam := func(value string) string {
+		position++
+		namedParam := fmt.Sprintf("pf%d", position)
+		namedParams[namedParam] = value
+		return namedParam
+	}
+	for k, v := range pf {
+		values, prefixes := datasources.SplitPropertyFilter(v)
+		conditions := make([]string, 0, len(prefixes)+1)
+		column := quote(k)
+		switch len(values) {
+		case 0:
+			// only prefixes
+		case 1:
+			conditions = append(conditions, fmt.Sprintf("%s = :%s", column, nextParam(values[0])))
+		default:
+			params := make([]string, 0, len(values))
+			for _, value := range values {
+				params = append(params, ":"+nextParam(value))
+			}
+			conditions = append(conditions, fmt.Sprintf("%s in (%s)", column, strings.Join(params, ", ")))
+		}
+		for _, prefix := range prefixes {
+			conditions = append(conditions, fmt.Sprintf("(%[1]s collate \"C\" >= :%[2]s and %[1]s collate \"C\" < :%[3]s)",
+				column, nextParam(prefix), nextParam(prefixUpperBound(prefix))))
+		}
+		if len(conditions) == 1 {
+			sql += " and " + conditions[0]
+		} else {
+			sql += fmt.Sprintf(" and (%s)", strings.Join(conditions, " or "))
+		}
+	}
+	return sql, namedParams
 }
 
-func (pg PostGIS) GetFeatures(_ context.Context, _ string, _ datasources.FeaturesCriteria) (*domain.FeatureCollection, domain.Cursors, error) {
-	log.Println("PostGIS support is not implemented yet, this just serves to demonstrate that we can support multiple types of datasources")
-	return nil, domain.Cursors{}, nil
+// prefixUpperBound returns the smallest string (in code point order) greater than all strings starting
+// with the given prefix, by incrementing the last code point of the prefix. In contrast to GeoPackage we
+// can't simply increment the last byte, since PostgreSQL rejects strings which aren't valid UTF-8.
+func prefixUpperBound(prefix string) string {
+	runes := []rune(prefix)
+	for i := len(runes) - 1; i >= 0; i-- {
+		switch {
+		case runes[i] == surrogateMin-1:
+			runes[i] = surrogateMax + 1 // skip surrogates, these aren't valid in UTF-8
+		case runes[i] < unicode.MaxRune:
+			runes[i]++
+		default:
+			continue
+		}
+		return string(runes[:i+1])
+	}
+	return prefix + string(unicode.MaxRune) // only reachable when the prefix consists of unicode.MaxRune's
 }
 
-func (pg PostGIS) GetFeature(_ context.Context, _ string, _ int64) (*domain.Feature, error) {
-	log.Println("PostGIS support is not implemented yet, this just serves to demonstrate that we can support multiple types of datasources")
-	return nil, nil
+func temporalCriteriaToSQL(temporalCriteria datasources.TemporalCriteria) (sql string, namedParams map[string]any) {
+	namedParams = make(map[string]any)
+	startDate := quote(temporalCriteria.StartDateProperty)
+	endDate := quote(temporalCriteria.EndDateProperty)
+	switch {
+	case !temporalCriteria.ReferenceDate.IsZero():
+		namedParams["referenceDate"] = temporalCriteria.ReferenceDate
+		sql = fmt.Sprintf(" and %[1]s <= :referenceDate and (%[2]s >= :referenceDate or %[2]s is null)", startDate, endDate)
+	case temporalCriteria.ReferenceInterval != nil:
+		// select features of which the validity overlaps with the (possibly open-ended) interval
+		if !temporalCriteria.ReferenceInterval.End.IsZero() {
+			namedParams["referenceEndDate"] = temporalCriteria.ReferenceInterval.End
+			sql += fmt.Sprintf(" and %[1]s <= :referenceEndDate", startDate)
+		}
+		if !temporalCriteria.ReferenceInterval.Start.IsZero() {
+			namedParams["referenceStartDate"] = temporalCriteria.ReferenceInterval.Start
+			sql += fmt.Sprintf(" and (%[1]s >= :referenceStartDate or %[1]s is null)", endDate)
+		}
+	}
+	return sql, namedParams
 }
 
-func (pg PostGIS) GetFeatureTableMetadata(_ string) (datasources.FeatureTableMetadata, error) {
-	log.Println("PostGIS support is not implemented yet, this just serves to demonstrate that we can support multiple types of datasources")
-	return nil, nil
+// quote identifier (table, column) in case it is a reserved keyword or contains uppercase characters
+func quote(identifier string) string {
+	return "\"" + strings.ReplaceAll(identifier, "\"", "\"\"") + "\""
 }
@@ -0,0 +1,224 @@
+package reproject
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-spatial/geom"
+)
+
+const (
+	// CRS84 (WGS84 with longitude/latitude axis order) as defined in GeoPackages
+	crs84SRID = 100000
+
+	// number of points per edge of a bounding box to reproject, since edges may curve when reprojected
+	extentDensification = 10
+
+	// web mercator is undefined at the poles, so clamp latitudes (at the usual "square world" latitude)
+	webMercatorMaxLat = 85.0511287798066
+)
+
+var (
+	grs80      = ellipsoid{a: 6378137, f: 1 / 298.257222101}
+	wgs84      = ellipsoid{a: 6378137, f: 1 / 298.257223563}
+	bessel1841 = ellipsoid{a: 6377397.155, f: 1 / 299.1528128}
+)
+
+// projection converts between geographic WGS84 coordinates (longitude/latitude in degrees)
+// and the coordinates of a specific CRS. Note: ETRS89 is treated as being equal to WGS84, the
+// difference between these two is less than a meter in Europe.
+type projection interface {
+	fromWGS84(lon, lat float64) (x, y float64)
+	toWGS84(x, y float64) (lon, lat float64)
+}
+
+// IsSupported returns true when features can be reprojected from/to the given SRID
+func IsSupported(srid int) bool {
+	_, err := newProjection(srid)
+	return err == nil
+}
+
+// IsGeographic returns true when the given SRID is a (supported) geographic CRS, with coordinates in degrees
+func IsGeographic(srid int) bool {
+	p, err := newProjection(srid)
+	if err != nil {
+		return false
+	}
+	_, ok := p.(geographic)
+	return ok
+}
+
+// TransformExtent returns the bounding box of the given extent (in x/y or lon/lat order) after
+// reprojection from the given SRID to the target SRID
+func TransformExtent(extent geom.Extent, fromSRID int, toSRID int) (geom.Extent, error) {
+	t, err := newTransformer(fromSRID, toSRID)
+	if err != nil {
+		return geom.Extent{}, err
+	}
+	result, err := t.extent(&extent)
+	if err != nil {
+		return geom.Extent{}, err
+	}
+	return *result, nil
+}
+
+// TransformGeometry returns a copy of the given geometry (in x/y or lon/lat order) after
+// reprojection from the given SRID to the target SRID
+func TransformGeometry(geometry geom.Geometry, fromSRID int, toSRID int) (geom.Geometry, error) {
+	t, err := newTransformer(fromSRID, toSRID)
+	if err != nil {
+		return nil, err
+	}
+	return t.geometry(geometry)
+}
+
+func newProjection(srid int) (projection, error) {
+	switch {
+	case srid == crs84SRID || srid == 4326 || srid == 4258:
+		return geographic{}, nil
+	case srid == 3857:
+		return webMercator{}, nil
+	case srid == 3035:
+		return newLambertAzimuthalEqualArea(grs80, 52, 10, 4321000, 3210000), nil
+	case srid == 28992:
+		return newRDNew(), nil
+	case srid >= 32601 && srid <= 32660: // WGS84 UTM north
+		return newUTM(wgs84, srid-32600, false), nil
+	case srid >= 32701 && srid <= 32760: // WGS84 UTM south
+		return newUTM(wgs84, srid-32700, true), nil
+	case srid >= 25828 && srid <= 25838: // ETRS89 UTM (north)
+		return newUTM(grs80, srid-25800, false), nil
+	}
+	return nil, fmt.Errorf("on-the-fly reprojection from/to SRID %d isn't supported", srid)
+}
+
+// transformer transforms coordinates between two projections (through WGS84)
+type transformer struct {
+	from projection
+	to   projection
+}
+
+func newTransformer(fromSRID int, toSRID int) (*transformer, error) {
+	from, err := newProjection(fromSRID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := newProjection(toSRID)
+	if err != nil {
+		return nil, err
+	}
+	return &transformer{from: from, to: to}, nil
+}
+
+func (t *transformer) transform(x, y float64) (float64, float64, error) {
+	lon, lat := t.from.toWGS84(x, y)
+	tx, ty := t.to.fromWGS84(lon, lat)
+	if math.IsNaN(tx) || math.IsNaN(ty) || math.IsInf(tx, 0) || math.IsInf(ty, 0) {
+		return 0, 0, fmt.Errorf("coordinate (%f, %f) can't be reprojected", x, y)
+	}
+	return tx, ty, nil
+}
+
+// geometry returns a copy of the given geometry with all coordinates transformed
+func (t *transformer) geometry(geometry geom.Geometry) (geom.Geometry, error) {
+	if collection, ok := geometry.(geom.Collection); ok {
+		result := make(geom.Collection, 0, len(collection))
+		for _, g := range collection {
+			transformed, err := t.geometry(g)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, transformed)
+		}
+		return result, nil
+	}
+	return geom.ApplyToPoints(geometry, func(coords ...float64) ([]float64, error) {
+		x, y, err := t.transform(coords[0], coords[1])
+		return []float64{x, y}, err
+	})
+}
+
+// extent returns the bounding box of the given extent after transformation. The edges of the extent
+// are densified, since straight edges may become curved in the other projection.
+func (t *transformer) extent(extent *geom.Extent) (*geom.Extent, error) {
+	width, height := extent.XSpan(), extent.YSpan()
+	points := make([][2]float64, 0, 4*(extentDensification+1))
+	for i := 0; i <= extentDensification; i++ {
+		step := float64(i) / extentDensification
+		for _, pt := range [][2]float64{
+			{extent.MinX() + step*width, extent.MinY()},
+			{extent.MinX() + step*width, extent.MaxY()},
+			{extent.MinX(), extent.MinY() + step*height},
+			{extent.MaxX(), extent.MinY() + step*height},
+		} {
+			x, y, err := t.transform(pt[0], pt[1])
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, [2]float64{x, y})
+		}
+	}
+	return geom.NewExtent(points...), nil
+}
+
+type ellipsoid struct {
+	a float64 // semi-major axis
+	f float64 // flattening
+}
+
+// eccentricity squared
+func (e ellipsoid) e2() float64 {
+	return e.f * (2 - e.f)
+}
+
+func (e ellipsoid) e() float64 {
+	return math.Sqrt(e.e2())
+}
+
+// toGeocentric converts geographic coordinates (in radians) to geocentric (earth-centered) coordinates
+func (e ellipsoid) toGeocentric(lon, lat float64) (x, y, z float64) {
+	nu := e.a / math.Sqrt(1-e.e2()*math.Pow(math.Sin(lat), 2))
+	return nu * math.Cos(lat) * math.Cos(lon), nu * math.Cos(lat) * math.Sin(lon), nu * (1 - e.e2()) * math.Sin(lat)
+}
+
+// fromGeocentric converts geocentric (earth-centered) coordinates to geographic coordinates (in radians)
+func (e ellipsoid) fromGeocentric(x, y, z float64) (lon, lat float64) {
+	p := math.Hypot(x, y)
+	lat = math.Atan2(z, p*(1-e.e2()))
+	for i := 0; i < 5; i++ {
+		nu := e.a / math.Sqrt(1-e.e2()*math.Pow(math.Sin(lat), 2))
+		lat = math.Atan2(z+e.e2()*nu*math.Sin(lat), p)
+	}
+	return math.Atan2(y, x), lat
+}
+
+// geographic longitude/latitude coordinates in WGS84 (or ETRS89)
+type geographic struct{}
+
+func (geographic) fromWGS84(lon, lat float64) (float64, float64) {
+	return lon, lat
+}
+
+func (geographic) toWGS84(x, y float64) (float64, float64) {
+	return x, y
+}
+
+// webMercator spherical (pseudo) mercator, EPSG:3857
+type webMercator struct{}
+
+func (webMercator) fromWGS84(lon, lat float64) (float64, float64) {
+	lat = math.Max(-webMercatorMaxLat, math.Min(webMercatorMaxLat, lat))
+	return wgs84.a * radians(lon), wgs84.a * math.Log(math.Tan(math.Pi/4+radians(lat)/2))
+}
+
+func (webMercator) toWGS84(x, y float64) (float64, float64) {
+	return degrees(x / wgs84.a), degrees(2*math.Atan(math.Exp(y/wgs84.a)) - math.Pi/2)
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
@@ -0,0 +1,131 @@
+package reproject
+
+import (
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjections(t *testing.T) {
+	tests := []struct {
+		name  string
+		srid  int
+		lon   float64
+		lat   float64
+		wantX float64
+		wantY float64
+		delta float64 // meters
+	}{
+		{
+			name: "RD New (Amersfoort)", srid: 28992, lon: 5.38720621, lat: 52.15517440,
+			wantX: 155000, wantY: 463000, delta: 1,
+		},
+		{
+			name: "RD New (address in reference dataset)", srid: 28992, lon: 4.874296788478395, lat: 53.08353047893742,
+			wantX: 120632.162, wantY: 566422.019, delta: 0.001,
+		},
+		{
+			name: "Web Mercator", srid: 3857, lon: 5, lat: 52,
+			wantX: 556597.454, wantY: 6800125.454, delta: 0.01,
+		},
+		{
+			name: "ETRS89-LAEA (EPSG example)", srid: 3035, lon: 5, lat: 50,
+			wantX: 3962799.45, wantY: 2999718.85, delta: 0.01,
+		},
+		{
+			name: "WGS84 UTM zone 31N (central meridian)", srid: 32631, lon: 3, lat: 0,
+			wantX: 500000, wantY: 0, delta: 0.001,
+		},
+		{
+			name: "WGS84 UTM zone 18N (Empire State Building)", srid: 32618, lon: -73.9857, lat: 40.7484,
+			wantX: 585628, wantY: 4511322, delta: 1,
+		},
+		{
+			name: "WGS84 UTM zone 18S", srid: 32718, lon: -75, lat: -10,
+			wantX: 500000, wantY: 8894587.51, delta: 0.01,
+		},
+		{
+			name: "CRS84", srid: crs84SRID, lon: 5, lat: 52,
+			wantX: 5, wantY: 52, delta: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newProjection(tt.srid)
+			require.NoError(t, err)
+
+			x, y := p.fromWGS84(tt.lon, tt.lat)
+			assert.InDelta(t, tt.wantX, x, tt.delta)
+			assert.InDelta(t, tt.wantY, y, tt.delta)
+
+			// round trip, should be (much) more accurate than the projection itself
+			lon, lat := p.toWGS84(x, y)
+			assert.InDelta(t, tt.lon, lon, 1e-8)
+			assert.InDelta(t, tt.lat, lat, 1e-8)
+		})
+	}
+}
+
+func TestObliqueStereographic(t *testing.T) {
+	// example from EPSG Guidance Note 7-2, without datum transformation
+	rd := newRDNew()
+	x, y := rd.stereographic.forward(radians(6), radians(53))
+	assert.InDelta(t, 196105.283, x, 0.001)
+	assert.InDelta(t, 557057.739, y, 0.001)
+}
+
+func TestIsSupported(t *testing.T) {
+	for _, srid := range []int{crs84SRID, 4326, 4258, 3857, 3035, 28992, 32631, 32760, 25831} {
+		assert.True(t, IsSupported(srid), srid)
+	}
+	for _, srid := range []int{0, 2154, 32661, 25839} {
+		assert.False(t, IsSupported(srid), srid)
+	}
+}
+
+func TestIsGeographic(t *testing.T) {
+	for _, srid := range []int{crs84SRID, 4326, 4258} {
+		assert.True(t, IsGeographic(srid), srid)
+	}
+	for _, srid := range []int{0, 3857, 28992, 25831} {
+		assert.False(t, IsGeographic(srid), srid)
+	}
+}
+
+func TestTransformer(t *testing.T) {
+	tr, err := newTransformer(crs84SRID, 28992)
+	require.NoError(t, err)
+
+	t.Run("geometry", func(t *testing.T) {
+		transformed, err := tr.geometry(geom.Collection{
+			geom.Point{5.38720621, 52.15517440},
+			geom.Polygon{{{5.38720621, 52.15517440}, {5.4, 52.15517440}, {5.4, 52.2}, {5.38720621, 52.15517440}}},
+		})
+		require.NoError(t, err)
+		point := transformed.(geom.Collection)[0].(geom.Point)
+		assert.InDelta(t, 155000, point.X(), 1)
+		assert.InDelta(t, 463000, point.Y(), 1)
+		assert.Len(t, transformed.(geom.Collection)[1].(geom.Polygon)[0], 4)
+	})
+
+	t.Run("extent", func(t *testing.T) {
+		extent, err := tr.extent(&geom.Extent{4.86, 52.35, 4.90, 52.38})
+		require.NoError(t, err)
+
+		corners := make([][2]float64, 0, 4)
+		for _, corner := range [][2]float64{{4.86, 52.35}, {4.90, 52.35}, {4.90, 52.38}, {4.86, 52.38}} {
+			x, y, err := tr.transform(corner[0], corner[1])
+			require.NoError(t, err)
+			corners = append(corners, [2]float64{x, y})
+		}
+		// RD New is almost aligned with WGS84 in Amsterdam, so the extent barely exceeds the corners
+		cornersExtent := geom.NewExtent(corners...)
+		assert.True(t, extent.Contains(cornersExtent))
+		assert.InDelta(t, cornersExtent.MinX(), extent.MinX(), 1)
+		assert.InDelta(t, cornersExtent.MinY(), extent.MinY(), 1)
+		assert.InDelta(t, cornersExtent.MaxX(), extent.MaxX(), 1)
+		assert.InDelta(t, cornersExtent.MaxY(), extent.MaxY(), 1)
+	})
+}
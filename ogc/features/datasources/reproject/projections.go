@@ -0,0 +1,281 @@
+package reproject
+
+import (
+	"math"
+)
+
+// Formulas in this file are based on the EPSG Guidance Note 7-2 (Coordinate Conversions and Transformations
+// including Formulas), see https://www.iogp.org/bookstore/product/coordinate-conversions-and-transformation-including-formulas/
+
+// transverseMercator Transverse Mercator (EPSG method 9807) using the JHS formulas (Krüger series),
+// which are accurate to a millimeter within a UTM zone. Latitude of origin is always the equator.
+type transverseMercator struct {
+	ell    ellipsoid
+	lon0   float64 // radians
+	k0     float64
+	fe, fn float64
+	b      float64
+	h      [4]float64 // forward series
+	hInv   [4]float64 // inverse series
+}
+
+func newUTM(ell ellipsoid, zone int, south bool) *transverseMercator {
+	fn := 0.0
+	if south {
+		fn = 10000000
+	}
+	return newTransverseMercator(ell, float64(zone)*6-183, 0.9996, 500000, fn)
+}
+
+func newTransverseMercator(ell ellipsoid, lon0 float64, k0 float64, fe float64, fn float64) *transverseMercator {
+	n := ell.f / (2 - ell.f)
+	n2, n3, n4 := n*n, n*n*n, n*n*n*n
+	return &transverseMercator{
+		ell:  ell,
+		lon0: radians(lon0),
+		k0:   k0,
+		fe:   fe,
+		fn:   fn,
+		b:    ell.a / (1 + n) * (1 + n2/4 + n4/64),
+		h: [4]float64{
+			n/2 - 2*n2/3 + 5*n3/16 + 41*n4/180,
+			13*n2/48 - 3*n3/5 + 557*n4/1440,
+			61*n3/240 - 103*n4/140,
+			49561 * n4 / 161280,
+		},
+		hInv: [4]float64{
+			n/2 - 2*n2/3 + 37*n3/96 - n4/360,
+			n2/48 + n3/15 - 437*n4/1440,
+			17*n3/480 - 37*n4/840,
+			4397 * n4 / 161280,
+		},
+	}
+}
+
+func (tm *transverseMercator) fromWGS84(lon, lat float64) (float64, float64) {
+	e := tm.ell.e()
+	phi := radians(lat)
+	q := math.Asinh(math.Tan(phi)) - e*math.Atanh(e*math.Sin(phi))
+	beta := math.Atan(math.Sinh(q))
+	eta0 := math.Atanh(math.Cos(beta) * math.Sin(radians(lon)-tm.lon0))
+	xi0 := math.Asin(math.Sin(beta) * math.Cosh(eta0))
+	xi, eta := xi0, eta0
+	for i, h := range tm.h {
+		k := 2 * float64(i+1)
+		xi += h * math.Sin(k*xi0) * math.Cosh(k*eta0)
+		eta += h * math.Cos(k*xi0) * math.Sinh(k*eta0)
+	}
+	return tm.fe + tm.k0*tm.b*eta, tm.fn + tm.k0*tm.b*xi
+}
+
+func (tm *transverseMercator) toWGS84(x, y float64) (float64, float64) {
+	e := tm.ell.e()
+	eta1 := (x - tm.fe) / (tm.b * tm.k0)
+	xi1 := (y - tm.fn) / (tm.b * tm.k0)
+	xi0, eta0 := xi1, eta1
+	for i, h := range tm.hInv {
+		k := 2 * float64(i+1)
+		xi0 -= h * math.Sin(k*xi1) * math.Cosh(k*eta1)
+		eta0 -= h * math.Cos(k*xi1) * math.Sinh(k*eta1)
+	}
+	beta := math.Asin(math.Sin(xi0) / math.Cosh(eta0))
+	q := math.Asinh(math.Tan(beta))
+	qi := q
+	for i := 0; i < 10; i++ {
+		qi = q + e*math.Atanh(e*math.Tanh(qi))
+	}
+	return degrees(tm.lon0 + math.Asin(math.Tanh(eta0)/math.Cos(beta))), degrees(math.Atan(math.Sinh(qi)))
+}
+
+// lambertAzimuthalEqualArea Lambert Azimuthal Equal Area (EPSG method 9820), e.g. ETRS89-LAEA
+type lambertAzimuthalEqualArea struct {
+	ell          ellipsoid
+	lon0         float64 // radians
+	fe, fn       float64
+	qp           float64
+	beta0        float64
+	rq           float64
+	d            float64
+	latCoeffs    [3]float64 // series to compute the latitude from the authalic latitude
+	sinB0, cosB0 float64
+}
+
+func newLambertAzimuthalEqualArea(ell ellipsoid, lat0 float64, lon0 float64, fe float64, fn float64) *lambertAzimuthalEqualArea {
+	e2 := ell.e2()
+	e4, e6 := e2*e2, e2*e2*e2
+	phi0 := radians(lat0)
+	laea := &lambertAzimuthalEqualArea{ell: ell, lon0: radians(lon0), fe: fe, fn: fn}
+	laea.qp = laea.q(math.Pi / 2)
+	laea.beta0 = math.Asin(laea.q(phi0) / laea.qp)
+	laea.sinB0, laea.cosB0 = math.Sin(laea.beta0), math.Cos(laea.beta0)
+	laea.rq = ell.a * math.Sqrt(laea.qp/2)
+	laea.d = ell.a * (math.Cos(phi0) / math.Sqrt(1-e2*math.Pow(math.Sin(phi0), 2))) / (laea.rq * laea.cosB0)
+	laea.latCoeffs = [3]float64{
+		e2/3 + 31*e4/180 + 517*e6/5040,
+		23*e4/360 + 251*e6/3780,
+		761 * e6 / 45360,
+	}
+	return laea
+}
+
+func (l *lambertAzimuthalEqualArea) q(phi float64) float64 {
+	e := l.ell.e()
+	sinPhi := math.Sin(phi)
+	return (1 - e*e) * (sinPhi/(1-e*e*sinPhi*sinPhi) - (1/(2*e))*math.Log((1-e*sinPhi)/(1+e*sinPhi)))
+}
+
+func (l *lambertAzimuthalEqualArea) fromWGS84(lon, lat float64) (float64, float64) {
+	beta := math.Asin(l.q(radians(lat)) / l.qp)
+	dLon := radians(lon) - l.lon0
+	b := l.rq * math.Sqrt(2/(1+l.sinB0*math.Sin(beta)+l.cosB0*math.Cos(beta)*math.Cos(dLon)))
+	x := l.fe + b*l.d*math.Cos(beta)*math.Sin(dLon)
+	y := l.fn + (b/l.d)*(l.cosB0*math.Sin(beta)-l.sinB0*math.Cos(beta)*math.Cos(dLon))
+	return x, y
+}
+
+func (l *lambertAzimuthalEqualArea) toWGS84(x, y float64) (float64, float64) {
+	dx, dy := x-l.fe, y-l.fn
+	rho := math.Hypot(dx/l.d, l.d*dy)
+	if rho == 0 {
+		return degrees(l.lon0), degrees(l.toLatitude(l.beta0))
+	}
+	c := 2 * math.Asin(rho/(2*l.rq))
+	beta := math.Asin(math.Cos(c)*l.sinB0 + (l.d*dy*math.Sin(c)*l.cosB0)/rho)
+	lon := l.lon0 + math.Atan2(dx*math.Sin(c), l.d*rho*l.cosB0*math.Cos(c)-l.d*l.d*dy*l.sinB0*math.Sin(c))
+	return degrees(lon), degrees(l.toLatitude(beta))
+}
+
+// toLatitude converts the authalic latitude to the geodetic latitude
+func (l *lambertAzimuthalEqualArea) toLatitude(beta float64) float64 {
+	return beta + l.latCoeffs[0]*math.Sin(2*beta) + l.latCoeffs[1]*math.Sin(4*beta) + l.latCoeffs[2]*math.Sin(6*beta)
+}
+
+// obliqueStereographic Oblique Stereographic (EPSG method 9809), e.g. the projection of RD New
+type obliqueStereographic struct {
+	ell          ellipsoid
+	lon0         float64 // radians
+	k0           float64
+	fe, fn       float64
+	r            float64
+	n            float64
+	c            float64
+	chi0         float64
+	sinX0, cosX0 float64
+}
+
+func newObliqueStereographic(ell ellipsoid, lat0 float64, lon0 float64, k0 float64, fe float64, fn float64) *obliqueStereographic {
+	e, e2 := ell.e(), ell.e2()
+	phi0 := radians(lat0)
+	sinPhi0 := math.Sin(phi0)
+	rho0 := ell.a * (1 - e2) / math.Pow(1-e2*sinPhi0*sinPhi0, 1.5)
+	nu0 := ell.a / math.Sqrt(1-e2*sinPhi0*sinPhi0)
+	n := math.Sqrt(1 + e2*math.Pow(math.Cos(phi0), 4)/(1-e2))
+	s1 := (1 + sinPhi0) / (1 - sinPhi0)
+	s2 := (1 - e*sinPhi0) / (1 + e*sinPhi0)
+	w1 := math.Pow(s1*math.Pow(s2, e), n)
+	sinChi00 := (w1 - 1) / (w1 + 1)
+	c := (n + sinPhi0) * (1 - sinChi00) / ((n - sinPhi0) * (1 + sinChi00))
+	w2 := c * w1
+	chi0 := math.Asin((w2 - 1) / (w2 + 1))
+	return &obliqueStereographic{
+		ell:   ell,
+		lon0:  radians(lon0),
+		k0:    k0,
+		fe:    fe,
+		fn:    fn,
+		r:     math.Sqrt(rho0 * nu0),
+		n:     n,
+		c:     c,
+		chi0:  chi0,
+		sinX0: math.Sin(chi0),
+		cosX0: math.Cos(chi0),
+	}
+}
+
+// forward projects geographic coordinates (in radians) on the ellipsoid of this projection
+func (os *obliqueStereographic) forward(lon, lat float64) (float64, float64) {
+	e := os.ell.e()
+	sinPhi := math.Sin(lat)
+	dLon := os.n * (lon - os.lon0)
+	sa := (1 + sinPhi) / (1 - sinPhi)
+	sb := (1 - e*sinPhi) / (1 + e*sinPhi)
+	w := os.c * math.Pow(sa*math.Pow(sb, e), os.n)
+	chi := math.Asin((w - 1) / (w + 1))
+	b := 1 + math.Sin(chi)*os.sinX0 + math.Cos(chi)*os.cosX0*math.Cos(dLon)
+	x := os.fe + 2*os.r*os.k0*math.Cos(chi)*math.Sin(dLon)/b
+	y := os.fn + 2*os.r*os.k0*(math.Sin(chi)*os.cosX0-math.Cos(chi)*os.sinX0*math.Cos(dLon))/b
+	return x, y
+}
+
+// inverse returns geographic coordinates (in radians) on the ellipsoid of this projection
+func (os *obliqueStereographic) inverse(x, y float64) (float64, float64) {
+	e := os.ell.e()
+	dx, dy := x-os.fe, y-os.fn
+	g := 2 * os.r * os.k0 * math.Tan(math.Pi/4-os.chi0/2)
+	h := 4*os.r*os.k0*math.Tan(os.chi0) + g
+	i := math.Atan(dx / (h + dy))
+	j := math.Atan(dx/(g-dy)) - i
+	chi := os.chi0 + 2*math.Atan((dy-dx*math.Tan(j/2))/(2*os.r*os.k0))
+	lon := (j+2*i)/os.n + os.lon0
+
+	psi := 0.5 * math.Log((1+math.Sin(chi))/(os.c*(1-math.Sin(chi)))) / os.n
+	lat := 2*math.Atan(math.Exp(psi)) - math.Pi/2
+	for k := 0; k < 10; k++ {
+		sinPhi := math.Sin(lat)
+		psiI := math.Log(math.Tan(lat/2+math.Pi/4) * math.Pow((1-e*sinPhi)/(1+e*sinPhi), e/2))
+		lat -= (psiI - psi) * math.Cos(lat) * (1 - e*e*sinPhi*sinPhi) / (1 - e*e)
+	}
+	return lon, lat
+}
+
+// helmert 7-parameter (position vector) transformation of geocentric coordinates to WGS84
+type helmert struct {
+	tx, ty, tz float64 // meters
+	rx, ry, rz float64 // radians
+	scale      float64 // 1 + ppm / 1e6
+}
+
+func newHelmert(tx, ty, tz, rxArcSec, ryArcSec, rzArcSec, ppm float64) helmert {
+	arcSec := math.Pi / (180 * 3600)
+	return helmert{tx, ty, tz, rxArcSec * arcSec, ryArcSec * arcSec, rzArcSec * arcSec, 1 + ppm/1e6}
+}
+
+func (h helmert) toWGS84(x, y, z float64) (float64, float64, float64) {
+	return h.tx + h.scale*(x-h.rz*y+h.ry*z),
+		h.ty + h.scale*(h.rz*x+y-h.rx*z),
+		h.tz + h.scale*(-h.ry*x+h.rx*y+z)
+}
+
+// fromWGS84 reverse transformation, using the transposed rotation matrix since rotations are small
+func (h helmert) fromWGS84(x, y, z float64) (float64, float64, float64) {
+	u, v, w := (x-h.tx)/h.scale, (y-h.ty)/h.scale, (z-h.tz)/h.scale
+	return u + h.rz*v - h.ry*w,
+		-h.rz*u + v + h.rx*w,
+		h.ry*u - h.rx*v + w
+}
+
+// rdNew Amersfoort / RD New (EPSG:28992), the Dutch national CRS. Uses a 7-parameter datum transformation
+// between Amersfoort and WGS84, which is accurate to about a meter (RDNAPTRANS™ isn't applied).
+type rdNew struct {
+	stereographic *obliqueStereographic
+	datum         helmert
+}
+
+func newRDNew() *rdNew {
+	return &rdNew{
+		stereographic: newObliqueStereographic(bessel1841, 52.15616055555555, 5.38763888888889, 0.9999079, 155000, 463000),
+		datum:         newHelmert(565.417, 50.3319, 465.552, -0.398957, 0.343988, -1.8774, 4.0725),
+	}
+}
+
+func (rd *rdNew) fromWGS84(lon, lat float64) (float64, float64) {
+	x, y, z := wgs84.toGeocentric(radians(lon), radians(lat))
+	lonB, latB := bessel1841.fromGeocentric(rd.datum.fromWGS84(x, y, z))
+	return rd.stereographic.forward(lonB, latB)
+}
+
+func (rd *rdNew) toWGS84(x, y float64) (float64, float64) {
+	lonB, latB := rd.stereographic.inverse(x, y)
+	lon, lat := wgs84.fromGeocentric(rd.datum.toWGS84(bessel1841.toGeocentric(lonB, latB)))
+	return degrees(lon), degrees(lat)
+}
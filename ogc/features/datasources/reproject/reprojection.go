@@ -0,0 +1,184 @@
+package reproject
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PDOK/gokoala/ogc/features/cql"
+	"github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+)
+
+// Reprojection serves the features of another (source) datasource in a different projection, by reprojecting
+// geometries on-the-fly. Spatial input (bbox and geometry literals in filters) is reprojected to the projection
+// of the source datasource. This avoids the need to maintain a separate datasource per projection.
+type Reprojection struct {
+	source     datasources.Datasource
+	sourceSRID int
+	targetSRID int
+	toTarget   *transformer
+}
+
+// NewReprojection serves the features of the given datasource (in the given source SRID) in the target SRID
+func NewReprojection(source datasources.Datasource, sourceSRID int, targetSRID int) (*Reprojection, error) {
+	toTarget, err := newTransformer(sourceSRID, targetSRID)
+	if err != nil {
+		return nil, err
+	}
+	return &Reprojection{
+		source:     source,
+		sourceSRID: sourceSRID,
+		targetSRID: targetSRID,
+		toTarget:   toTarget,
+	}, nil
+}
+
+// SupportsInputSRID returns true when spatial input (bbox/filter) in the given SRID can be handled
+// by this datasource, meaning it can be reprojected to the SRID of the source datasource.
+func (r *Reprojection) SupportsInputSRID(srid int) bool {
+	return IsSupported(srid)
+}
+
+func (r *Reprojection) GetFeatureIDs(ctx context.Context, collection string, criteria datasources.FeaturesCriteria) ([]int64, domain.Cursors, error) {
+	criteria, err := r.toSourceCriteria(criteria)
+	if err != nil {
+		return nil, domain.Cursors{}, err
+	}
+	return r.source.GetFeatureIDs(ctx, collection, criteria)
+}
+
+func (r *Reprojection) GetFeaturesByID(ctx context.Context, collection string, featureIDs []int64,
+	selection datasources.PropertySelection) (*domain.FeatureCollection, error) {
+
+	fc, err := r.source.GetFeaturesByID(ctx, collection, featureIDs, selection)
+	if err != nil || fc == nil {
+		return fc, err
+	}
+	return fc, r.reprojectFeatures(fc.Features...)
+}
+
+func (r *Reprojection) GetFeatures(ctx context.Context, collection string,
+	criteria datasources.FeaturesCriteria) (*domain.FeatureCollection, domain.Cursors, error) {
+
+	criteria, err := r.toSourceCriteria(criteria)
+	if err != nil {
+		return nil, domain.Cursors{}, err
+	}
+	fc, cursors, err := r.source.GetFeatures(ctx, collection, criteria)
+	if err != nil || fc == nil {
+		return fc, cursors, err
+	}
+	return fc, cursors, r.reprojectFeatures(fc.Features...)
+}
+
+func (r *Reprojection) StreamFeatures(ctx context.Context, collection string,
+	criteria datasources.FeaturesCriteria) (domain.FeatureIterator, domain.Cursors, error) {
+
+	criteria, err := r.toSourceCriteria(criteria)
+	if err != nil {
+		return nil, domain.Cursors{}, err
+	}
+	it, cursors, err := r.source.StreamFeatures(ctx, collection, criteria)
+	if err != nil || it == nil {
+		return it, cursors, err
+	}
+	return &reprojectingIterator{source: it, reprojection: r}, cursors, nil
+}
+
+func (r *Reprojection) CountFeatures(ctx context.Context, collection string, criteria datasources.FeaturesCriteria) (int, error) {
+	criteria, err := r.toSourceCriteria(criteria)
+	if err != nil {
+		return 0, err
+	}
+	return r.source.CountFeatures(ctx, collection, criteria)
+}
+
+func (r *Reprojection) GetFeature(ctx context.Context, collection string, featureID any,
+	selection datasources.PropertySelection) (*domain.Feature, error) {
+
+	feature, err := r.source.GetFeature(ctx, collection, featureID, selection)
+	if err != nil || feature == nil {
+		return feature, err
+	}
+	return feature, r.reprojectFeatures(feature)
+}
+
+func (r *Reprojection) GetFeatureTableMetadata(collection string) (datasources.FeatureTableMetadata, error) {
+	return r.source.GetFeatureTableMetadata(collection)
+}
+
+func (r *Reprojection) SupportsCQLFilter() bool {
+	filterer, ok := r.source.(datasources.CQLFilterer)
+	return ok && filterer.SupportsCQLFilter()
+}
+
+func (r *Reprojection) SupportsSortBy() bool {
+	sorter, ok := r.source.(datasources.Sorter)
+	return ok && sorter.SupportsSortBy()
+}
+
+func (r *Reprojection) SupportsPrefixFilter(collection string, property string) bool {
+	filterer, ok := r.source.(datasources.PrefixFilterer)
+	return !ok || filterer.SupportsPrefixFilter(collection, property)
+}
+
+func (r *Reprojection) Close() {
+	// noop: the source datasource is closed by its owner
+}
+
+// toSourceCriteria reprojects the bbox and geometry literals in the filter to the SRID of the source datasource
+func (r *Reprojection) toSourceCriteria(criteria datasources.FeaturesCriteria) (datasources.FeaturesCriteria, error) {
+	inputSRID := criteria.InputSRID
+	criteria.InputSRID = r.sourceSRID
+	criteria.OutputSRID = r.sourceSRID
+	if inputSRID == r.sourceSRID || (criteria.Bbox == nil && (criteria.Filter == nil || !cql.HasGeometryLiteral(criteria.Filter))) {
+		return criteria, nil
+	}
+	toSource, err := newTransformer(inputSRID, r.sourceSRID)
+	if err != nil {
+		return criteria, err
+	}
+	if criteria.Bbox != nil {
+		if criteria.Bbox, err = toSource.extent(criteria.Bbox); err != nil {
+			return criteria, fmt.Errorf("failed to reproject bbox, error: %w", err)
+		}
+	}
+	if criteria.Filter != nil {
+		if criteria.Filter, err = cql.MapGeometries(criteria.Filter, toSource.geometry); err != nil {
+			return criteria, fmt.Errorf("failed to reproject geometry in filter, error: %w", err)
+		}
+	}
+	return criteria, nil
+}
+
+// reprojectingIterator reprojects each Feature while it's read from the source iterator
+type reprojectingIterator struct {
+	source       domain.FeatureIterator
+	reprojection *Reprojection
+}
+
+func (it *reprojectingIterator) Next() (*domain.Feature, error) {
+	feature, err := it.source.Next()
+	if err != nil || feature == nil {
+		return feature, err
+	}
+	return feature, it.reprojection.reprojectFeatures(feature)
+}
+
+func (it *reprojectingIterator) Close() {
+	it.source.Close()
+}
+
+func (r *Reprojection) reprojectFeatures(features ...*domain.Feature) error {
+	for _, feature := range features {
+		if feature.Geometry == nil || feature.Geometry.Geometry == nil {
+			continue
+		}
+		reprojected, err := r.toTarget.geometry(feature.Geometry.Geometry)
+		if err != nil {
+			return fmt.Errorf("failed to reproject feature %v to SRID %d, error: %w", feature.ID, r.targetSRID, err)
+		}
+		feature.Geometry.Geometry = reprojected
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package reproject
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PDOK/gokoala/ogc/features/cql"
+	"github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rdSRID = 28992
+
+// fakeDatasource returns a single feature at Amersfoort (in WGS84) and records the given criteria
+type fakeDatasource struct {
+	datasources.Datasource
+	criteria datasources.FeaturesCriteria
+}
+
+func (f *fakeDatasource) GetFeatures(_ context.Context, _ string, criteria datasources.FeaturesCriteria) (*domain.FeatureCollection, domain.Cursors, error) {
+	f.criteria = criteria
+	return &domain.FeatureCollection{Features: []*domain.Feature{newFeature(), {ID: int64(2)}}}, domain.Cursors{}, nil
+}
+
+func (f *fakeDatasource) StreamFeatures(_ context.Context, _ string, criteria datasources.FeaturesCriteria) (domain.FeatureIterator, domain.Cursors, error) {
+	f.criteria = criteria
+	return domain.NewSliceFeatureIterator([]*domain.Feature{newFeature()}), domain.Cursors{}, nil
+}
+
+func (f *fakeDatasource) GetFeatureIDs(_ context.Context, _ string, criteria datasources.FeaturesCriteria) ([]int64, domain.Cursors, error) {
+	f.criteria = criteria
+	return []int64{1}, domain.Cursors{}, nil
+}
+
+func (f *fakeDatasource) GetFeature(_ context.Context, _ string, _ any, _ datasources.PropertySelection) (*domain.Feature, error) {
+	return newFeature(), nil
+}
+
+func newFeature() *domain.Feature {
+	return &domain.Feature{ID: int64(1), Geometry: &geojson.Geometry{Geometry: geom.Point{5.38720621, 52.15517440}}}
+}
+
+func TestReprojection_GetFeatures(t *testing.T) {
+	source := &fakeDatasource{}
+	reprojection, err := NewReprojection(source, crs84SRID, rdSRID)
+	require.NoError(t, err)
+
+	t.Run("reproject features and bbox", func(t *testing.T) {
+		fc, _, err := reprojection.GetFeatures(context.Background(), "foo", datasources.FeaturesCriteria{
+			InputSRID:  rdSRID,
+			OutputSRID: rdSRID,
+			Bbox:       &geom.Extent{154000, 462000, 156000, 464000},
+		})
+		require.NoError(t, err)
+		point := fc.Features[0].Geometry.Geometry.(geom.Point)
+		assert.InDelta(t, 155000, point.X(), 1)
+		assert.InDelta(t, 463000, point.Y(), 1)
+		assert.Nil(t, fc.Features[1].Geometry)
+
+		assert.Equal(t, crs84SRID, source.criteria.InputSRID)
+		assert.Equal(t, crs84SRID, source.criteria.OutputSRID)
+		assert.True(t, source.criteria.Bbox.Contains(geom.NewExtent([2]float64{5.38720621, 52.15517440})))
+		assert.InDelta(t, 5.37, source.criteria.Bbox.MinX(), 0.01)
+		assert.InDelta(t, 52.17, source.criteria.Bbox.MaxY(), 0.01)
+	})
+
+	t.Run("keep bbox in source projection", func(t *testing.T) {
+		bbox := &geom.Extent{5.3, 52.1, 5.4, 52.2}
+		_, _, err := reprojection.GetFeatures(context.Background(), "foo", datasources.FeaturesCriteria{
+			InputSRID:  crs84SRID,
+			OutputSRID: rdSRID,
+			Bbox:       bbox,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, bbox, source.criteria.Bbox)
+	})
+
+	t.Run("reproject filter", func(t *testing.T) {
+		filter, err := cql.ParseText("S_INTERSECTS(geom, POINT(155000 463000))")
+		require.NoError(t, err)
+		_, _, err = reprojection.GetFeatureIDs(context.Background(), "foo", datasources.FeaturesCriteria{
+			InputSRID: rdSRID,
+			Filter:    filter,
+		})
+		require.NoError(t, err)
+		point := source.criteria.Filter.(cql.SpatialPredicate).Right.(cql.Geometry).Geometry.(geom.Point)
+		assert.InDelta(t, 5.38720621, point.X(), 0.0001)
+		assert.InDelta(t, 52.15517440, point.Y(), 0.0001)
+	})
+
+	t.Run("unsupported input projection", func(t *testing.T) {
+		_, _, err := reprojection.GetFeatures(context.Background(), "foo", datasources.FeaturesCriteria{
+			InputSRID: 2154,
+			Bbox:      &geom.Extent{0, 0, 1, 1},
+		})
+		assert.EqualError(t, err, "on-the-fly reprojection from/to SRID 2154 isn't supported")
+	})
+}
+
+func TestReprojection_StreamFeatures(t *testing.T) {
+	source := &fakeDatasource{}
+	reprojection, err := NewReprojection(source, crs84SRID, rdSRID)
+	require.NoError(t, err)
+
+	it, _, err := reprojection.StreamFeatures(context.Background(), "foo", datasources.FeaturesCriteria{OutputSRID: rdSRID})
+	require.NoError(t, err)
+	defer it.Close()
+	features, err := domain.ReadFeatures(it)
+	require.NoError(t, err)
+	require.Len(t, features, 1)
+	point := features[0].Geometry.Geometry.(geom.Point)
+	assert.InDelta(t, 155000, point.X(), 1)
+	assert.InDelta(t, 463000, point.Y(), 1)
+	assert.Equal(t, crs84SRID, source.criteria.OutputSRID)
+}
+
+func TestReprojection_GetFeature(t *testing.T) {
+	reprojection, err := NewReprojection(&fakeDatasource{}, crs84SRID, 3857)
+	require.NoError(t, err)
+
+	feature, err := reprojection.GetFeature(context.Background(), "foo", int64(1), datasources.PropertySelection{})
+	require.NoError(t, err)
+	point := feature.Geometry.Geometry.(geom.Point)
+	assert.InDelta(t, 599701, point.X(), 1)
+	assert.InDelta(t, 6828232, point.Y(), 1)
+}
+
+func TestNewReprojection_Unsupported(t *testing.T) {
+	_, err := NewReprojection(&fakeDatasource{}, crs84SRID, 2154)
+	assert.EqualError(t, err, "on-the-fly reprojection from/to SRID 2154 isn't supported")
+}
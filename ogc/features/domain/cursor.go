@@ -3,6 +3,8 @@ package domain
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"log"
 	"math/big"
 	neturl "net/url"
@@ -28,31 +30,47 @@ type EncodedCursor string
 type DecodedCursor struct {
 	FiltersChecksum []byte
 	FID             int64
+
+	// values of the sort keys (sortby) of the feature identified by FID, nil when sorted by fid only
+	SortValues []any
 }
 
 // PrevNextFID previous and next feature id (fid) to encode in cursor.
 type PrevNextFID struct {
 	Prev int64
 	Next int64
+
+	// values of the sort keys (sortby) of the previous and next feature, nil when sorted by fid only
+	PrevSortValues []any
+	NextSortValues []any
 }
 
 // NewCursors create Cursors based on the prev/next feature ids from the datasource
 // and the provided filters (captured in a hash).
 func NewCursors(fid PrevNextFID, filtersChecksum []byte) Cursors {
 	return Cursors{
-		Prev: encodeCursor(fid.Prev, filtersChecksum),
-		Next: encodeCursor(fid.Next, filtersChecksum),
+		Prev: encodeCursor(fid.Prev, fid.PrevSortValues, filtersChecksum),
+		Next: encodeCursor(fid.Next, fid.NextSortValues, filtersChecksum),
 
 		HasPrev: fid.Prev > 0,
 		HasNext: fid.Next > 0,
 	}
 }
 
-func encodeCursor(fid int64, filtersChecksum []byte) EncodedCursor {
+func encodeCursor(fid int64, sortValues []any, filtersChecksum []byte) EncodedCursor {
 	fidAsBytes := big.NewInt(fid).Bytes()
 
-	// format of the cursor: <encoded fid><separator><encoded checksum>
-	return EncodedCursor(base64.RawURLEncoding.EncodeToString(fidAsBytes) + string(separator) + base64.RawURLEncoding.EncodeToString(filtersChecksum))
+	// format of the cursor: <encoded fid><separator><encoded checksum>[<separator><encoded sort values>]
+	cursor := base64.RawURLEncoding.EncodeToString(fidAsBytes) + string(separator) + base64.RawURLEncoding.EncodeToString(filtersChecksum)
+	if fid > 0 && len(sortValues) > 0 {
+		sortValuesAsJSON, err := json.Marshal(sortValues)
+		if err != nil {
+			log.Printf("failed to encode sort values %v in cursor, error: %v", sortValues, err)
+			return EncodedCursor(cursor)
+		}
+		cursor += string(separator) + base64.RawURLEncoding.EncodeToString(sortValuesAsJSON)
+	}
+	return EncodedCursor(cursor)
 }
 
 // Decode turns encoded cursor into DecodedCursor and verifies the
@@ -60,20 +78,20 @@ func encodeCursor(fid int64, filtersChecksum []byte) EncodedCursor {
 func (c EncodedCursor) Decode(filtersChecksum []byte) DecodedCursor {
 	value, err := neturl.QueryUnescape(string(c))
 	if err != nil || value == "" {
-		return DecodedCursor{filtersChecksum, 0}
+		return DecodedCursor{FiltersChecksum: filtersChecksum}
 	}
 
 	// split first, then decode
 	encoded := strings.Split(value, string(separator))
 	if len(encoded) < 2 {
 		log.Printf("cursor '%s' doesn't contain expected separator %c", value, separator)
-		return DecodedCursor{filtersChecksum, 0}
+		return DecodedCursor{FiltersChecksum: filtersChecksum}
 	}
 	decodedFid, fidErr := base64.RawURLEncoding.DecodeString(encoded[0])
 	decodedChecksum, checksumErr := base64.RawURLEncoding.DecodeString(encoded[1])
 	if fidErr != nil || checksumErr != nil {
 		log.Printf("decoding cursor value '%s' failed, defaulting to first page", value)
-		return DecodedCursor{filtersChecksum, 0}
+		return DecodedCursor{FiltersChecksum: filtersChecksum}
 	}
 
 	// feature id
@@ -86,10 +104,48 @@ func (c EncodedCursor) Decode(filtersChecksum []byte) DecodedCursor {
 	// checksum
 	if !bytes.Equal(decodedChecksum, filtersChecksum) {
 		log.Printf("filters in query params have changed during pagination, resetting to first page")
-		return DecodedCursor{filtersChecksum, 0}
+		return DecodedCursor{FiltersChecksum: filtersChecksum}
+	}
+
+	// sort values (optional)
+	var sortValues []any
+	if len(encoded) > 2 {
+		sortValues, err = decodeSortValues(encoded[2])
+		if err != nil {
+			log.Printf("decoding sort values in cursor value '%s' failed, defaulting to first page", value)
+			return DecodedCursor{FiltersChecksum: filtersChecksum}
+		}
 	}
 
-	return DecodedCursor{filtersChecksum, fid}
+	return DecodedCursor{FiltersChecksum: filtersChecksum, FID: fid, SortValues: sortValues}
+}
+
+func decodeSortValues(encoded string) ([]any, error) {
+	sortValuesAsJSON, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(sortValuesAsJSON))
+	decoder.UseNumber() // preserve (large) integers
+	var sortValues []any
+	if err = decoder.Decode(&sortValues); err != nil {
+		return nil, err
+	}
+	for i, v := range sortValues {
+		switch value := v.(type) {
+		case json.Number:
+			if asInt, err := value.Int64(); err == nil {
+				sortValues[i] = asInt
+			} else if sortValues[i], err = value.Float64(); err != nil {
+				return nil, err
+			}
+		case string, bool, nil:
+			// use as-is
+		default:
+			return nil, fmt.Errorf("unexpected sort value %v", v)
+		}
+	}
+	return sortValues, nil
 }
 
 func (c EncodedCursor) String() string {
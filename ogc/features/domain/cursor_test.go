@@ -64,6 +64,24 @@ func TestNewCursor(t *testing.T) {
 				HasNext: true,
 			},
 		},
+		{
+			name: "test middle page with sort values",
+			args: args{
+				features: []*Feature{{ID: 3}, {ID: 4}, {ID: 5}, {ID: 6}},
+				id: PrevNextFID{
+					Prev:           2,
+					Next:           7,
+					PrevSortValues: []any{"Damrak", int64(12)},
+					NextSortValues: []any{"Silodam", nil},
+				},
+			},
+			want: Cursors{
+				Prev:    "Ag||WyJEYW1yYWsiLDEyXQ",
+				Next:    "Bw||WyJTaWxvZGFtIixudWxsXQ",
+				HasPrev: true,
+				HasNext: true,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -87,7 +105,7 @@ func TestEncodedCursor_Decode(t *testing.T) {
 	}{
 		{
 			name: "should return cursor if no checksum is available in cursor, and no expected checksum provided",
-			c:    encodeCursor(123, []byte{}),
+			c:    encodeCursor(123, nil, []byte{}),
 			args: args{
 				filtersChecksum: []byte{},
 			},
@@ -98,7 +116,7 @@ func TestEncodedCursor_Decode(t *testing.T) {
 		},
 		{
 			name: "should not fail on checksum which contains separator",
-			c:    encodeCursor(123456, []byte{'a', separator, 'b'}),
+			c:    encodeCursor(123456, nil, []byte{'a', separator, 'b'}),
 			args: args{
 				filtersChecksum: []byte{'a', separator, 'b'},
 			},
@@ -109,7 +127,7 @@ func TestEncodedCursor_Decode(t *testing.T) {
 		},
 		{
 			name: "should not fail on checksum which contains only separator",
-			c:    encodeCursor(123456, []byte{separator}),
+			c:    encodeCursor(123456, nil, []byte{separator}),
 			args: args{
 				filtersChecksum: []byte{separator},
 			},
@@ -120,7 +138,7 @@ func TestEncodedCursor_Decode(t *testing.T) {
 		},
 		{
 			name: "should fail (return 0 fid) on non matching checksums",
-			c:    encodeCursor(123456, []byte("foobarbaz")),
+			c:    encodeCursor(123456, nil, []byte("foobarbaz")),
 			args: args{
 				filtersChecksum: []byte("bazbar"),
 			},
@@ -131,7 +149,7 @@ func TestEncodedCursor_Decode(t *testing.T) {
 		},
 		{
 			name: "should handle large feature id",
-			c:    encodeCursor(math.MaxInt64, []byte("foobar")),
+			c:    encodeCursor(math.MaxInt64, nil, []byte("foobar")),
 			args: args{
 				filtersChecksum: []byte("foobar"),
 			},
@@ -140,9 +158,32 @@ func TestEncodedCursor_Decode(t *testing.T) {
 				FiltersChecksum: []byte("foobar"),
 			},
 		},
+		{
+			name: "should return sort values",
+			c:    encodeCursor(123, []any{"Silodam", int64(math.MaxInt64), 1.5, true, nil}, []byte("foobar")),
+			args: args{
+				filtersChecksum: []byte("foobar"),
+			},
+			want: DecodedCursor{
+				FID:             123,
+				FiltersChecksum: []byte("foobar"),
+				SortValues:      []any{"Silodam", int64(math.MaxInt64), 1.5, true, nil},
+			},
+		},
+		{
+			name: "should fail (return 0 fid) on invalid sort values",
+			c:    EncodedCursor(string(encodeCursor(123, nil, []byte("foobar"))) + "|invalid"),
+			args: args{
+				filtersChecksum: []byte("foobar"),
+			},
+			want: DecodedCursor{
+				FID:             0,
+				FiltersChecksum: []byte("foobar"),
+			},
+		},
 		{
 			name: "should always return positive feature id",
-			c:    encodeCursor(math.MinInt64, []byte("foobar")),
+			c:    encodeCursor(math.MinInt64, nil, []byte("foobar")),
 			args: args{
 				filtersChecksum: []byte("foobar"),
 			},
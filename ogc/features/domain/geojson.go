@@ -19,7 +19,8 @@ type FeatureCollection struct {
 
 	Features []*Feature `json:"features"`
 
-	NumberReturned int `json:"numberReturned"`
+	NumberMatched  *int `json:"numberMatched,omitempty"` // only when enabled for the collection
+	NumberReturned int  `json:"numberReturned"`
 }
 
 // Feature is a GeoJSON Feature with extras such as links
@@ -27,9 +28,16 @@ type Feature struct {
 	geojson.Feature
 	Links []Link `json:"links,omitempty"`
 
-	// we overwrite ID since we want to make it a required attribute. We also expect feature ids to be
-	// auto-incrementing integers (which is the default in geopackages) since we use it for cursor-based pagination.
-	ID int64 `json:"id"`
+	// we overwrite ID since we want to make it a required attribute. This is either the internal feature id (int64)
+	// or - when configured for the collection - the external feature id (string).
+	ID any `json:"id"`
+
+	// FID the internal feature id. We expect feature ids to be auto-incrementing integers
+	// (which is the default in geopackages) since we use it for cursor-based pagination.
+	FID int64 `json:"-"`
+
+	// we overwrite Geometry since it's null when the geometry is omitted (e.g. skipGeometry=true or a NULL geometry)
+	Geometry *geojson.Geometry `json:"geometry"`
 }
 
 // Link according to RFC 8288, https://datatracker.ietf.org/doc/html/rfc8288
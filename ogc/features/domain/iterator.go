@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/geojson"
+	"github.com/jmoiron/sqlx"
+)
+
+// FeatureIterator iterates over Features while these are read from the datasource. This allows Features
+// to be streamed to the client, instead of holding all Features (of a page) in memory.
+type FeatureIterator interface {
+
+	// Next returns the next Feature, or nil when all Features have been read
+	Next() (*Feature, error)
+
+	// Close releases the underlying resources (e.g. SQL rows). Should always be called, also on error.
+	Close()
+}
+
+// RowsFeatureIterator datasource agnostic FeatureIterator over SQL rows/result set, see MapRowsToFeatures
+type RowsFeatureIterator struct {
+	rows              *sqlx.Rows
+	columns           []string
+	fidColumn         string
+	externalFidColumn string
+	geomColumn        string
+	geomMapper        func([]byte) (geom.Geometry, error)
+	onClose           func()
+
+	first *Feature // already read to determine the prev/next feature id
+}
+
+// NewRowsFeatureIterator creates a FeatureIterator over the given rows. The first row is read immediately,
+// since it contains the prev/next feature id required for pagination. This prev/next feature id is returned
+// as well, or nil when the result set is empty. The optional onClose func is called when the iterator is closed
+// (e.g. to cancel the query context).
+func NewRowsFeatureIterator(rows *sqlx.Rows, fidColumn string, externalFidColumn string, geomColumn string,
+	geomMapper func([]byte) (geom.Geometry, error), onClose func()) (*RowsFeatureIterator, *PrevNextFID, error) {
+
+	it := &RowsFeatureIterator{
+		rows:              rows,
+		fidColumn:         fidColumn,
+		externalFidColumn: externalFidColumn,
+		geomColumn:        geomColumn,
+		geomMapper:        geomMapper,
+		onClose:           onClose,
+	}
+	var err error
+	if it.columns, err = rows.Columns(); err != nil {
+		return it, nil, err
+	}
+	var prevNextID *PrevNextFID
+	it.first, prevNextID, err = it.read(true)
+	return it, prevNextID, err
+}
+
+// Next see FeatureIterator
+func (it *RowsFeatureIterator) Next() (*Feature, error) {
+	if it.first != nil {
+		feature := it.first
+		it.first = nil
+		return feature, nil
+	}
+	feature, _, err := it.read(false)
+	return feature, err
+}
+
+// Close see FeatureIterator
+func (it *RowsFeatureIterator) Close() {
+	_ = it.rows.Close()
+	if it.onClose != nil {
+		it.onClose()
+	}
+}
+
+func (it *RowsFeatureIterator) read(firstRow bool) (*Feature, *PrevNextFID, error) {
+	if !it.rows.Next() {
+		return nil, nil, it.rows.Err()
+	}
+	values, err := it.rows.SliceScan()
+	if err != nil {
+		return nil, nil, err
+	}
+	feature := &Feature{Feature: geojson.Feature{Properties: make(map[string]any)}}
+	prevNextID, err := mapColumnsToFeature(firstRow, feature, it.columns, values,
+		it.fidColumn, it.externalFidColumn, it.geomColumn, it.geomMapper)
+	if err != nil {
+		return nil, nil, err
+	}
+	return feature, prevNextID, nil
+}
+
+// sliceFeatureIterator FeatureIterator over Features already in memory
+type sliceFeatureIterator struct {
+	features []*Feature
+}
+
+// NewSliceFeatureIterator creates a FeatureIterator over the given Features (may be nil)
+func NewSliceFeatureIterator(features []*Feature) FeatureIterator {
+	return &sliceFeatureIterator{features: features}
+}
+
+func (it *sliceFeatureIterator) Next() (*Feature, error) {
+	if len(it.features) == 0 {
+		return nil, nil
+	}
+	feature := it.features[0]
+	it.features = it.features[1:]
+	return feature, nil
+}
+
+func (it *sliceFeatureIterator) Close() {
+	// noop
+}
+
+// ReadFeatures reads all (remaining) Features from the given iterator into memory. Does not close the iterator.
+func ReadFeatures(it FeatureIterator) ([]*Feature, error) {
+	result := make([]*Feature, 0)
+	for {
+		feature, err := it.Next()
+		if err != nil {
+			return result, err
+		}
+		if feature == nil {
+			return result, nil
+		}
+		result = append(result, feature)
+	}
+}
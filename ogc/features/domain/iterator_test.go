@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowsFeatureIterator(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(`create table features (fid integer, prevfid integer, nextfid integer, name text, geom blob);
+		insert into features values (1, null, 3, 'foo', x'01'), (2, null, null, 'bar', null)`)
+	require.NoError(t, err)
+
+	t.Run("iterate", func(t *testing.T) {
+		rows, err := db.Queryx("select * from features order by fid")
+		require.NoError(t, err)
+		closed := false
+		it, prevNext, err := NewRowsFeatureIterator(rows, "fid", "", "geom", func(_ []byte) (geom.Geometry, error) {
+			return geom.Point{1, 2}, nil
+		}, func() { closed = true })
+		require.NoError(t, err)
+		assert.Equal(t, &PrevNextFID{Prev: 0, Next: 3}, prevNext)
+
+		features, err := ReadFeatures(it)
+		require.NoError(t, err)
+		require.Len(t, features, 2)
+		assert.Equal(t, int64(1), features[0].ID)
+		assert.Equal(t, map[string]any{"name": "foo"}, features[0].Properties)
+		assert.Equal(t, geom.Point{1, 2}, features[0].Geometry.Geometry)
+		assert.Equal(t, int64(2), features[1].ID)
+		assert.Nil(t, features[1].Geometry)
+
+		it.Close()
+		assert.True(t, closed)
+	})
+
+	t.Run("empty result", func(t *testing.T) {
+		rows, err := db.Queryx("select * from features where fid > 10")
+		require.NoError(t, err)
+		it, prevNext, err := NewRowsFeatureIterator(rows, "fid", "", "geom", nil, nil)
+		require.NoError(t, err)
+		defer it.Close()
+		assert.Nil(t, prevNext)
+		feature, err := it.Next()
+		require.NoError(t, err)
+		assert.Nil(t, feature)
+	})
+}
+
+func TestSliceFeatureIterator(t *testing.T) {
+	features, err := ReadFeatures(NewSliceFeatureIterator([]*Feature{{ID: int64(1)}, {ID: int64(2)}}))
+	require.NoError(t, err)
+	assert.Len(t, features, 2)
+
+	features, err = ReadFeatures(NewSliceFeatureIterator(nil))
+	require.NoError(t, err)
+	assert.Empty(t, features)
+}
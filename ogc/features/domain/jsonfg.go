@@ -22,6 +22,7 @@ type JSONFGFeatureCollection struct {
 	Links          []Link                `json:"links,omitempty"`
 	ConformsTo     []string              `json:"conformsTo"`
 	Features       []*JSONFGFeature      `json:"features"`
+	NumberMatched  *int                  `json:"numberMatched,omitempty"` // only when enabled for the collection
 	NumberReturned int                   `json:"numberReturned"`
 }
 
@@ -36,7 +37,6 @@ type JSONFGFeature struct {
 	CoordRefSys string         `json:"coordRefSys,omitempty"`
 	Links       []Link         `json:"links,omitempty"`
 	ConformsTo  []string       `json:"conformsTo,omitempty"`
-	// We expect feature ids to be auto-incrementing integers (which is the default in geopackages)
-	// since we use it for cursor-based pagination.
-	ID int64 `json:"id"`
+	// Either the internal feature id (int64) or - when configured for the collection - the external feature id (string)
+	ID any `json:"id"`
 }
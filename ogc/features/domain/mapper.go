@@ -2,6 +2,7 @@ package domain
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-spatial/geom"
@@ -9,17 +10,29 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+const (
+	// PrevSortKeyColumnPrefix prefix of the columns holding the sort key values of the previous feature
+	PrevSortKeyColumnPrefix = "prevsortkey"
+	// NextSortKeyColumnPrefix prefix of the columns holding the sort key values of the next feature
+	NextSortKeyColumnPrefix = "nextsortkey"
+)
+
 // MapRowsToFeatureIDs datasource agnostic mapper from SQL rows set feature IDs, including prev/next feature ID
+// and (optionally) the prev/next sort key values
 func MapRowsToFeatureIDs(rows *sqlx.Rows) (featureIDs []int64, prevNextID *PrevNextFID, err error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
 	firstRow := true
 	for rows.Next() {
 		var values []any
 		if values, err = rows.SliceScan(); err != nil {
 			return nil, nil, err
 		}
-		if len(values) != 3 {
-			return nil, nil, fmt.Errorf("expected 3 columns containing the feature id, "+
-				"the previous feature id and the next feature id. Got: %v", values)
+		if len(values) < 3 || len(values) != len(columns) {
+			return nil, nil, fmt.Errorf("expected at least 3 columns containing the feature id, "+
+				"the previous feature id and the next feature id, optionally followed by sort keys. Got: %v", values)
 		}
 		featureID := values[0].(int64)
 		featureIDs = append(featureIDs, featureID)
@@ -33,57 +46,67 @@ func MapRowsToFeatureIDs(rows *sqlx.Rows) (featureIDs []int64, prevNextID *PrevN
 				next = values[2].(int64)
 			}
 			prevNextID = &PrevNextFID{Prev: prev, Next: next}
+			for i := 3; i < len(columns); i++ {
+				if !mapSortKeyColumn(prevNextID, columns[i], values[i]) {
+					return nil, nil, fmt.Errorf("expected only sort key columns after the feature id, "+
+						"the previous feature id and the next feature id. Got: %s", columns[i])
+				}
+			}
 			firstRow = false
 		}
 	}
 	return
 }
 
-// MapRowsToFeatures datasource agnostic mapper from SQL rows/result set to Features domain model
-func MapRowsToFeatures(rows *sqlx.Rows, fidColumn string, geomColumn string,
+// MapRowsToFeatures datasource agnostic mapper from SQL rows/result set to Features domain model.
+// The geometry column is optional, features without geometry have a nil (null) geometry. The external fid
+// column is optional as well, when given its value is used as the feature id instead of the internal fid.
+// Use NewRowsFeatureIterator instead to map rows to Features one at a time (e.g. for streaming).
+func MapRowsToFeatures(rows *sqlx.Rows, fidColumn string, externalFidColumn string, geomColumn string,
 	geomMapper func([]byte) (geom.Geometry, error)) ([]*Feature, *PrevNextFID, error) {
 
-	result := make([]*Feature, 0)
-	columns, err := rows.Columns()
+	it, prevNextID, err := NewRowsFeatureIterator(rows, fidColumn, externalFidColumn, geomColumn, geomMapper, nil)
 	if err != nil {
-		return result, nil, err
+		return make([]*Feature, 0), nil, err
 	}
-
-	firstRow := true
-	var prevNextID *PrevNextFID
-	for rows.Next() {
-		var values []any
-		if values, err = rows.SliceScan(); err != nil {
-			return result, nil, err
-		}
-
-		feature := &Feature{Feature: geojson.Feature{Properties: make(map[string]any)}}
-		np, err := mapColumnsToFeature(firstRow, feature, columns, values, fidColumn, geomColumn, geomMapper)
-		if err != nil {
-			return result, nil, err
-		} else if firstRow {
-			prevNextID = np
-			firstRow = false
-		}
-		result = append(result, feature)
+	result, err := ReadFeatures(it)
+	if err != nil {
+		return result, nil, err
 	}
 	return result, prevNextID, nil
 }
 
 //nolint:cyclop,funlen
 func mapColumnsToFeature(firstRow bool, feature *Feature, columns []string, values []any,
-	fidColumn string, geomColumn string, geomMapper func([]byte) (geom.Geometry, error)) (*PrevNextFID, error) {
+	fidColumn string, externalFidColumn string, geomColumn string,
+	geomMapper func([]byte) (geom.Geometry, error)) (*PrevNextFID, error) {
 
 	prevNextID := PrevNextFID{}
 	for i, columnName := range columns {
 		columnValue := values[i]
+		if isSortKeyColumn(columnName) {
+			// Only the first row in the result set contains the previous/next sort key values
+			if firstRow {
+				mapSortKeyColumn(&prevNextID, columnName, columnValue)
+			}
+			continue
+		}
 		if columnValue == nil {
 			continue
 		}
 
 		switch columnName {
 		case fidColumn:
-			feature.ID = columnValue.(int64)
+			feature.FID = columnValue.(int64)
+			if externalFidColumn == "" {
+				feature.ID = feature.FID
+			}
+
+		case externalFidColumn:
+			if asBytes, ok := columnValue.([]uint8); ok {
+				columnValue = string(asBytes)
+			}
+			feature.ID = fmt.Sprint(columnValue)
 
 		case geomColumn:
 			rawGeom, ok := columnValue.([]byte)
@@ -94,7 +117,7 @@ func mapColumnsToFeature(firstRow bool, feature *Feature, columns []string, valu
 			if err != nil {
 				return nil, fmt.Errorf("failed to map/decode geometry from datasource, error: %w", err)
 			}
-			feature.Geometry = geojson.Geometry{Geometry: mappedGeom}
+			feature.Geometry = &geojson.Geometry{Geometry: mappedGeom}
 
 		case "minx", "miny", "maxx", "maxy", "min_zoom", "max_zoom":
 			// Skip these columns used for bounding box and zoom filtering
@@ -136,3 +159,24 @@ func mapColumnsToFeature(firstRow bool, feature *Feature, columns []string, valu
 	}
 	return &prevNextID, nil
 }
+
+func isSortKeyColumn(columnName string) bool {
+	return strings.HasPrefix(columnName, PrevSortKeyColumnPrefix) || strings.HasPrefix(columnName, NextSortKeyColumnPrefix)
+}
+
+// mapSortKeyColumn adds the value of the given column to the prev or next sort values (in column order).
+// Returns false when the given column isn't a sort key column.
+func mapSortKeyColumn(prevNextID *PrevNextFID, columnName string, columnValue any) bool {
+	if asBytes, ok := columnValue.([]uint8); ok {
+		columnValue = string(asBytes)
+	}
+	switch {
+	case strings.HasPrefix(columnName, PrevSortKeyColumnPrefix):
+		prevNextID.PrevSortValues = append(prevNextID.PrevSortValues, columnValue)
+	case strings.HasPrefix(columnName, NextSortKeyColumnPrefix):
+		prevNextID.NextSortValues = append(prevNextID.NextSortValues, columnValue)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,194 @@
+package features
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/PDOK/gokoala/engine"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/PDOK/gokoala/ogc/features/flatgeobuf"
+	"github.com/go-spatial/geom"
+)
+
+// featuresAsFlatGeobuf serves features as FlatGeobuf (without spatial index). The columns in the FlatGeobuf
+// header are derived from the feature table metadata. Like CSV, links are exposed as Link headers.
+func (f *Features) featuresAsFlatGeobuf(w http.ResponseWriter, collectionID string, cursor domain.Cursors,
+	featuresURL featureCollectionURL, fc *domain.FeatureCollection, outputSRID SRID) {
+
+	setLinkHeaders(w, createLinkHeaderFeatureCollectionLinks(engine.FormatFlatGeobuf, engine.MediaTypeFlatGeobuf,
+		collectionID, cursor, featuresURL))
+	f.serveFlatGeobuf(w, collectionID, fc.Features, outputSRID)
+}
+
+// featureAsFlatGeobuf serves a single feature as FlatGeobuf, see featuresAsFlatGeobuf
+func (f *Features) featureAsFlatGeobuf(w http.ResponseWriter, collectionID string, feat *domain.Feature,
+	url featureURL, outputSRID SRID) {
+
+	setLinkHeaders(w, createLinkHeaderFeatureLinks(engine.FormatFlatGeobuf, engine.MediaTypeFlatGeobuf,
+		url, collectionID, feat.ID))
+	f.serveFlatGeobuf(w, collectionID, []*domain.Feature{feat}, outputSRID)
+}
+
+func (f *Features) serveFlatGeobuf(w http.ResponseWriter, collectionID string, features []*domain.Feature, outputSRID SRID) {
+	header, idColumn, err := f.createFlatGeobufHeader(collectionID, features, outputSRID)
+	if err != nil {
+		handleFlatGeobufEncodingFailure(err, w)
+		return
+	}
+	result := &bytes.Buffer{}
+	writer, err := flatgeobuf.NewWriter(result, header)
+	if err != nil {
+		handleFlatGeobufEncodingFailure(err, w)
+		return
+	}
+	for _, feature := range features {
+		properties := make(map[string]any, len(feature.Properties)+1)
+		for name, value := range feature.Properties {
+			properties[name] = value
+		}
+		properties[idColumn] = feature.ID
+
+		var geometry geom.Geometry
+		if feature.Geometry != nil {
+			geometry = feature.Geometry.Geometry
+		}
+		if err = writer.WriteFeature(geometry, properties); err != nil {
+			handleFlatGeobufEncodingFailure(fmt.Errorf("feature %v: %w", feature.ID, err), w)
+			return
+		}
+	}
+
+	w.Header().Set(engine.HeaderContentType, engine.MediaTypeFlatGeobuf)
+	if _, err = w.Write(result.Bytes()); err != nil {
+		log.Printf("failed to write FlatGeobuf response: %v", err)
+	}
+}
+
+// createFlatGeobufHeader creates the FlatGeobuf header based on the feature table metadata. The first column
+// holds the feature id, named after the (external) fid column. Returns the header and the name of this id column.
+func (f *Features) createFlatGeobufHeader(collectionID string, features []*domain.Feature,
+	outputSRID SRID) (flatgeobuf.Header, string, error) {
+
+	datasource := f.datasources[DatasourceKey{srid: outputSRID.GetOrDefault(), collectionID: collectionID}]
+	featTable, err := datasource.GetFeatureTableMetadata(collectionID)
+	if err != nil {
+		return flatgeobuf.Header{}, "", err
+	}
+
+	idColumn := flatgeobuf.Column{Name: featTable.IDColumn(), Type: flatgeobuf.ColumnTypeLong}
+	if externalFidColumn := f.engine.Config.OgcAPI.Features.ExternalFidForCollection(collectionID); externalFidColumn != "" {
+		idColumn = flatgeobuf.Column{Name: externalFidColumn, Type: flatgeobuf.ColumnTypeString}
+	}
+	geomColumn := featTable.GeometryColumn()
+	columnTypes := make(map[string]flatgeobuf.ColumnType)
+	for name, dataType := range featTable.ColumnsWithDataType() {
+		switch name {
+		case featTable.IDColumn(), idColumn.Name, geomColumn.Name, "minx", "miny", "maxx", "maxy", "min_zoom", "max_zoom":
+			continue
+		}
+		columnTypes[name] = toFlatGeobufColumnType(dataType)
+	}
+
+	geometryType := toFlatGeobufGeometryType(geomColumn.Type)
+	var envelope *geom.Extent
+	for _, feature := range features {
+		for name, value := range feature.Properties {
+			columnType, ok := columnTypes[name]
+			if !ok {
+				columnTypes[name] = flatgeobuf.ColumnTypeString // not in feature table metadata (shouldn't happen)
+			} else if columnType != flatgeobuf.ColumnTypeString && !flatgeobuf.Fits(columnType, value) {
+				// value doesn't match the declared type (e.g. mixed types in a SQLite column), fall
+				// back to strings for the whole column instead of failing the whole response
+				columnTypes[name] = flatgeobuf.ColumnTypeString
+			}
+		}
+		if feature.Geometry == nil || feature.Geometry.Geometry == nil {
+			continue
+		}
+		if flatgeobuf.GeometryTypeOf(feature.Geometry.Geometry) != geometryType {
+			geometryType = flatgeobuf.GeometryTypeUnknown // mixed geometry types
+		}
+		if envelope == nil {
+			envelope, err = geom.NewExtentFromGeometry(feature.Geometry.Geometry)
+		} else {
+			err = envelope.AddGeometry(feature.Geometry.Geometry)
+		}
+		if err != nil {
+			return flatgeobuf.Header{}, "", err
+		}
+	}
+
+	names := make([]string, 0, len(columnTypes))
+	for name := range columnTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	columns := []flatgeobuf.Column{idColumn}
+	for _, name := range names {
+		columns = append(columns, flatgeobuf.Column{Name: name, Type: columnTypes[name]})
+	}
+
+	epsgCode := outputSRID.GetOrDefault()
+	if epsgCode == wgs84SRID {
+		epsgCode = 4326 // same as CRS84, since FlatGeobuf coordinates are always in x/y (lon/lat) order
+	}
+	return flatgeobuf.Header{
+		Name:          collectionID,
+		Envelope:      envelope,
+		GeometryType:  geometryType,
+		Columns:       columns,
+		FeaturesCount: len(features),
+		EPSGCode:      epsgCode,
+	}, idColumn.Name, nil
+}
+
+// toFlatGeobufColumnType maps a datasource specific data type (GeoPackage/SQLite or PostgreSQL) to a FlatGeobuf
+// column type. A length or precision suffix like TEXT(10) or NUMERIC(10,2) is ignored.
+func toFlatGeobufColumnType(dataType string) flatgeobuf.ColumnType {
+	if i := strings.Index(dataType, "("); i > 0 {
+		dataType = dataType[:i]
+	}
+	switch strings.ToUpper(strings.TrimSpace(dataType)) {
+	case "INT", "INTEGER", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT", "INT2", "INT4", "INT8":
+		return flatgeobuf.ColumnTypeLong
+	case "REAL", "FLOAT", "DOUBLE", "DOUBLE PRECISION", "NUMERIC", "DECIMAL", "FLOAT4", "FLOAT8":
+		return flatgeobuf.ColumnTypeDouble
+	case "BOOLEAN":
+		return flatgeobuf.ColumnTypeBool
+	case "DATE", "DATETIME", "TIMESTAMP", "TIMESTAMP WITH TIME ZONE", "TIMESTAMP WITHOUT TIME ZONE":
+		return flatgeobuf.ColumnTypeDateTime
+	default:
+		return flatgeobuf.ColumnTypeString
+	}
+}
+
+// toFlatGeobufGeometryType maps a datasource specific geometry type to a FlatGeobuf geometry type
+func toFlatGeobufGeometryType(geometryType string) flatgeobuf.GeometryType {
+	switch strings.ToUpper(geometryType) {
+	case "POINT":
+		return flatgeobuf.GeometryTypePoint
+	case "LINESTRING":
+		return flatgeobuf.GeometryTypeLineString
+	case "POLYGON":
+		return flatgeobuf.GeometryTypePolygon
+	case "MULTIPOINT":
+		return flatgeobuf.GeometryTypeMultiPoint
+	case "MULTILINESTRING":
+		return flatgeobuf.GeometryTypeMultiLineString
+	case "MULTIPOLYGON":
+		return flatgeobuf.GeometryTypeMultiPolygon
+	case "GEOMETRYCOLLECTION":
+		return flatgeobuf.GeometryTypeGeometryCollection
+	default:
+		return flatgeobuf.GeometryTypeUnknown
+	}
+}
+
+func handleFlatGeobufEncodingFailure(err error, w http.ResponseWriter) {
+	log.Printf("FlatGeobuf encoding failed: %v", err)
+	engine.RenderProblem(engine.ProblemServerError, w, "Failed to write FlatGeobuf response")
+}
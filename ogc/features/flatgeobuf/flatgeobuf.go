@@ -0,0 +1,401 @@
+// Package flatgeobuf encodes features as FlatGeobuf, see https://flatgeobuf.org and
+// https://github.com/flatgeobuf/flatgeobuf/tree/master/src/fbs for the specification (schemas).
+package flatgeobuf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/go-spatial/geom"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// GeometryType FlatGeobuf geometry type
+type GeometryType uint8
+
+const (
+	GeometryTypeUnknown GeometryType = iota
+	GeometryTypePoint
+	GeometryTypeLineString
+	GeometryTypePolygon
+	GeometryTypeMultiPoint
+	GeometryTypeMultiLineString
+	GeometryTypeMultiPolygon
+	GeometryTypeGeometryCollection
+)
+
+// ColumnType FlatGeobuf (attribute) column type
+type ColumnType uint8
+
+// Note: only the column types used by the features endpoint are included
+const (
+	ColumnTypeBool     ColumnType = 2
+	ColumnTypeLong     ColumnType = 7
+	ColumnTypeDouble   ColumnType = 10
+	ColumnTypeString   ColumnType = 11
+	ColumnTypeDateTime ColumnType = 13
+)
+
+// number of fields (in use) of the tables in the FlatGeobuf schemas (header.fbs/feature.fbs)
+const (
+	headerFields   = 11
+	columnFields   = 2
+	crsFields      = 2
+	featureFields  = 2
+	geometryFields = 8
+)
+
+// magic bytes at the start of each FlatGeobuf file: "fgb", major version 3, "fgb", patch version 1
+var magicBytes = []byte{0x66, 0x67, 0x62, 0x03, 0x66, 0x67, 0x62, 0x01}
+
+// Column (attribute) of the features
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Header describes the features in a FlatGeobuf file
+type Header struct {
+	Name          string
+	Envelope      *geom.Extent // optional
+	GeometryType  GeometryType
+	Columns       []Column
+	FeaturesCount int
+	EPSGCode      int // optional
+}
+
+// Writer writes features as FlatGeobuf (without spatial index) to the underlying writer
+type Writer struct {
+	w       io.Writer
+	columns []Column
+	builder *flatbuffers.Builder // reused for each feature
+}
+
+// NewWriter writes the FlatGeobuf magic bytes and header, and returns a Writer for the features
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	if _, err := w.Write(magicBytes); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(encodeHeader(header)); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, columns: header.Columns, builder: flatbuffers.NewBuilder(1024)}, nil
+}
+
+// WriteFeature writes a single feature. Properties should match the columns in the header,
+// absent or nil properties are considered null.
+func (w *Writer) WriteFeature(geometry geom.Geometry, properties map[string]any) error {
+	b := w.builder
+	b.Reset()
+
+	// absent (zero) offsets are omitted by the builder
+	var encodedGeom, encodedProps flatbuffers.UOffsetT
+	if geometry != nil {
+		var err error
+		if encodedGeom, err = encodeGeometry(b, geometry); err != nil {
+			return err
+		}
+	}
+	props, err := w.encodeProperties(properties)
+	if err != nil {
+		return err
+	}
+	if len(props) > 0 {
+		encodedProps = b.CreateByteVector(props)
+	}
+	b.StartObject(featureFields)
+	b.PrependUOffsetTSlot(0, encodedGeom, 0)
+	b.PrependUOffsetTSlot(1, encodedProps, 0)
+	b.FinishSizePrefixed(b.EndObject())
+	_, err = w.w.Write(b.FinishedBytes())
+	return err
+}
+
+// GeometryTypeOf returns the FlatGeobuf geometry type of the given geometry
+func GeometryTypeOf(geometry geom.Geometry) GeometryType {
+	switch geometry.(type) {
+	case geom.Pointer:
+		return GeometryTypePoint
+	case geom.LineStringer:
+		return GeometryTypeLineString
+	case geom.Polygoner:
+		return GeometryTypePolygon
+	case geom.MultiPointer:
+		return GeometryTypeMultiPoint
+	case geom.MultiLineStringer:
+		return GeometryTypeMultiLineString
+	case geom.MultiPolygoner:
+		return GeometryTypeMultiPolygon
+	case geom.Collectioner:
+		return GeometryTypeGeometryCollection
+	default:
+		return GeometryTypeUnknown
+	}
+}
+
+func encodeHeader(header Header) []byte {
+	b := flatbuffers.NewBuilder(1024)
+	columns := make([]flatbuffers.UOffsetT, 0, len(header.Columns))
+	for _, column := range header.Columns {
+		name := b.CreateString(column.Name)
+		b.StartObject(columnFields)
+		b.PrependUOffsetTSlot(0, name, 0)
+		b.PrependByteSlot(1, byte(column.Type), 0)
+		columns = append(columns, b.EndObject())
+	}
+
+	// absent (zero) offsets are omitted by the builder
+	var encodedColumns, envelope, crs flatbuffers.UOffsetT
+	if len(columns) > 0 {
+		encodedColumns = createOffsetVector(b, columns)
+	}
+	if header.Envelope != nil {
+		envelope = createFloat64Vector(b, header.Envelope[:])
+	}
+	if header.EPSGCode > 0 {
+		org := b.CreateString("EPSG")
+		b.StartObject(crsFields)
+		b.PrependUOffsetTSlot(0, org, 0)
+		b.PrependInt32Slot(1, int32(header.EPSGCode), 0)
+		crs = b.EndObject()
+	}
+	name := b.CreateString(header.Name)
+
+	b.StartObject(headerFields)
+	b.PrependUOffsetTSlot(0, name, 0)
+	b.PrependUOffsetTSlot(1, envelope, 0)
+	b.PrependByteSlot(2, byte(header.GeometryType), 0)
+	b.PrependUOffsetTSlot(7, encodedColumns, 0)
+	b.PrependUint64Slot(8, uint64(header.FeaturesCount), 0)
+	b.PrependUint16Slot(9, 0, 16) // no spatial index (index node size 0, default is 16)
+	b.PrependUOffsetTSlot(10, crs, 0)
+	b.FinishSizePrefixed(b.EndObject())
+	return b.FinishedBytes()
+}
+
+func encodeGeometry(b *flatbuffers.Builder, geometry geom.Geometry) (flatbuffers.UOffsetT, error) {
+	var ends, xy, parts flatbuffers.UOffsetT
+	switch g := geometry.(type) {
+	case geom.Pointer:
+		point := g.XY()
+		xy = createFloat64Vector(b, point[:])
+	case geom.LineStringer:
+		xy = createFloat64Vector(b, flatten(g.Vertices()))
+	case geom.MultiPointer:
+		xy = createFloat64Vector(b, flatten(g.Points()))
+	case geom.Polygoner:
+		xy, ends = createParts(b, g.LinearRings())
+	case geom.MultiLineStringer:
+		xy, ends = createParts(b, g.LineStrings())
+	case geom.MultiPolygoner:
+		encodedParts := make([]flatbuffers.UOffsetT, 0, len(g.Polygons()))
+		for _, polygon := range g.Polygons() {
+			part, err := encodeGeometry(b, geom.Polygon(polygon))
+			if err != nil {
+				return 0, err
+			}
+			encodedParts = append(encodedParts, part)
+		}
+		parts = createOffsetVector(b, encodedParts)
+	case geom.Collectioner:
+		encodedParts := make([]flatbuffers.UOffsetT, 0, len(g.Geometries()))
+		for _, geometry := range g.Geometries() {
+			part, err := encodeGeometry(b, geometry)
+			if err != nil {
+				return 0, err
+			}
+			encodedParts = append(encodedParts, part)
+		}
+		parts = createOffsetVector(b, encodedParts)
+	default:
+		return 0, fmt.Errorf("unsupported geometry type %T", geometry)
+	}
+
+	b.StartObject(geometryFields)
+	b.PrependUOffsetTSlot(0, ends, 0)
+	b.PrependUOffsetTSlot(1, xy, 0)
+	b.PrependByteSlot(6, byte(GeometryTypeOf(geometry)), 0)
+	b.PrependUOffsetTSlot(7, parts, 0)
+	return b.EndObject(), nil
+}
+
+func flatten(points [][2]float64) []float64 {
+	result := make([]float64, 0, 2*len(points))
+	for _, point := range points {
+		result = append(result, point[0], point[1])
+	}
+	return result
+}
+
+// createParts flattens rings or linestrings, the ends are the (exclusive) end indexes of each part
+func createParts(b *flatbuffers.Builder, parts [][][2]float64) (xy flatbuffers.UOffsetT, ends flatbuffers.UOffsetT) {
+	points := make([][2]float64, 0)
+	partEnds := make([]uint32, 0, len(parts))
+	for _, part := range parts {
+		points = append(points, part...)
+		partEnds = append(partEnds, uint32(len(points)))
+	}
+	xy = createFloat64Vector(b, flatten(points))
+
+	// vectors are built back to front
+	b.StartVector(flatbuffers.SizeUint32, len(partEnds), flatbuffers.SizeUint32)
+	for i := len(partEnds) - 1; i >= 0; i-- {
+		b.PrependUint32(partEnds[i])
+	}
+	return xy, b.EndVector(len(partEnds))
+}
+
+func createFloat64Vector(b *flatbuffers.Builder, values []float64) flatbuffers.UOffsetT {
+	b.StartVector(flatbuffers.SizeFloat64, len(values), flatbuffers.SizeFloat64)
+	for i := len(values) - 1; i >= 0; i-- {
+		b.PrependFloat64(values[i])
+	}
+	return b.EndVector(len(values))
+}
+
+func createOffsetVector(b *flatbuffers.Builder, offsets []flatbuffers.UOffsetT) flatbuffers.UOffsetT {
+	b.StartVector(flatbuffers.SizeUOffsetT, len(offsets), flatbuffers.SizeUOffsetT)
+	for i := len(offsets) - 1; i >= 0; i-- {
+		b.PrependUOffsetT(offsets[i])
+	}
+	return b.EndVector(len(offsets))
+}
+
+// encodeProperties encodes properties in column order: the column index (uint16) followed by the value
+func (w *Writer) encodeProperties(properties map[string]any) ([]byte, error) {
+	var result []byte
+	for i, column := range w.columns {
+		value, ok := properties[column.Name]
+		if !ok || value == nil {
+			continue
+		}
+		result = binary.LittleEndian.AppendUint16(result, uint16(i))
+
+		var err error
+		if result, err = appendValue(result, column.Type, value); err != nil {
+			return nil, fmt.Errorf("failed to encode property %s, error: %w", column.Name, err)
+		}
+	}
+	return result, nil
+}
+
+// Fits reports whether the given (non-nil) value can be written to a column of the given type
+func Fits(columnType ColumnType, value any) bool {
+	_, err := appendValue(nil, columnType, value)
+	return err == nil
+}
+
+// appendValue appends the value in the binary representation of the column type. Values of another
+// (Go) type are converted when this is lossless, e.g. PostgreSQL numeric values are read as strings.
+//
+//nolint:cyclop
+func appendValue(buf []byte, columnType ColumnType, value any) ([]byte, error) {
+	switch columnType {
+	case ColumnTypeBool:
+		if v, ok := toBool(value); ok {
+			if v {
+				return append(buf, 1), nil
+			}
+			return append(buf, 0), nil
+		}
+	case ColumnTypeLong:
+		if v, ok := toInt64(value); ok {
+			return binary.LittleEndian.AppendUint64(buf, uint64(v)), nil
+		}
+	case ColumnTypeDouble:
+		if v, ok := toFloat64(value); ok {
+			return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v)), nil
+		}
+	case ColumnTypeDateTime:
+		switch v := value.(type) {
+		case time.Time:
+			return appendString(buf, v.Format(time.RFC3339Nano)), nil
+		case string:
+			return appendString(buf, v), nil
+		}
+	case ColumnTypeString:
+		switch v := value.(type) {
+		case string:
+			return appendString(buf, v), nil
+		case []byte:
+			return appendString(buf, string(v)), nil
+		case time.Time:
+			return appendString(buf, v.Format(time.RFC3339Nano)), nil
+		}
+		return appendString(buf, fmt.Sprint(value)), nil
+	}
+	return nil, fmt.Errorf("value %v of type %T doesn't match column type %d", value, value, columnType)
+}
+
+func toBool(value any) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	if i, ok := toInt64(value); ok {
+		return i != 0, true
+	}
+	return false, false
+}
+
+//nolint:cyclop
+func toInt64(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case uint:
+		return int64(v), uint64(v) <= math.MaxInt64
+	case float64:
+		return int64(v), v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64
+	case float32:
+		return toInt64(float64(v))
+	case string:
+		i, err := strconv.ParseInt(v, 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func toFloat64(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	if i, ok := toInt64(value); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+func appendString(buf []byte, value string) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(value)))
+	return append(buf, value...)
+}
@@ -0,0 +1,233 @@
+package flatgeobuf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-spatial/geom"
+	flatbuffers "github.com/google/flatbuffers/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	w, err := NewWriter(out, Header{
+		Name:          "foo",
+		Envelope:      &geom.Extent{0, 0, 6, 6},
+		GeometryType:  GeometryTypeUnknown,
+		Columns:       []Column{{Name: "fid", Type: ColumnTypeLong}, {Name: "naam", Type: ColumnTypeString}, {Name: "datum", Type: ColumnTypeDateTime}},
+		FeaturesCount: 3,
+		EPSGCode:      28992,
+	})
+	require.NoError(t, err)
+	require.NoError(t, w.WriteFeature(geom.Point{1.5, 2.5},
+		map[string]any{"fid": int64(1), "naam": "Silodam", "datum": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}))
+	require.NoError(t, w.WriteFeature(geom.MultiPolygon{
+		{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}, {{0.1, 0.1}, {0.2, 0.1}, {0.2, 0.2}, {0.1, 0.1}}},
+		{{{5, 5}, {6, 5}, {6, 6}, {5, 5}}},
+	}, map[string]any{"fid": int64(2), "naam": nil}))
+	require.NoError(t, w.WriteFeature(nil, nil))
+
+	data := out.Bytes()
+	assert.Equal(t, magicBytes, data[:8])
+
+	header, pos := refTable(data, 8)
+	assert.Equal(t, "foo", string(refBytes(header, 0)))
+	assert.Equal(t, []float64{0, 0, 6, 6}, refFloat64s(header, 1))
+	assert.Equal(t, uint8(GeometryTypeUnknown), header.GetUint8Slot(8, 0))
+	assert.Equal(t, uint64(3), header.GetUint64Slot(20, 0))
+	assert.Equal(t, uint16(0), header.GetUint16Slot(22, 16))
+	require.Equal(t, 3, refVectorLen(header, 7))
+	column := refElement(header, 7, 1)
+	assert.Equal(t, "naam", string(refBytes(column, 0)))
+	assert.Equal(t, uint8(ColumnTypeString), column.GetUint8Slot(6, 0))
+	crs := refChild(header, 10)
+	assert.Equal(t, "EPSG", string(refBytes(crs, 0)))
+	assert.Equal(t, int32(28992), crs.GetInt32Slot(6, 0))
+
+	// point with properties
+	feature, pos := refTable(data, pos)
+	geometry := refChild(feature, 0)
+	assert.Equal(t, uint8(GeometryTypePoint), geometry.GetUint8Slot(16, 0))
+	assert.Equal(t, []float64{1.5, 2.5}, refFloat64s(geometry, 1))
+	expectedProps := binary.LittleEndian.AppendUint16(nil, 0)
+	expectedProps = binary.LittleEndian.AppendUint64(expectedProps, 1)
+	expectedProps = binary.LittleEndian.AppendUint16(expectedProps, 1)
+	expectedProps = appendString(expectedProps, "Silodam")
+	expectedProps = binary.LittleEndian.AppendUint16(expectedProps, 2)
+	expectedProps = appendString(expectedProps, "2024-01-02T03:04:05Z")
+	assert.Equal(t, expectedProps, refBytes(feature, 1))
+
+	// multipolygon, null properties are omitted
+	feature, pos = refTable(data, pos)
+	geometry = refChild(feature, 0)
+	assert.Equal(t, uint8(GeometryTypeMultiPolygon), geometry.GetUint8Slot(16, 0))
+	require.Equal(t, 2, refVectorLen(geometry, 7))
+	part := refElement(geometry, 7, 0)
+	assert.Equal(t, uint8(GeometryTypePolygon), part.GetUint8Slot(16, 0))
+	assert.Equal(t, []uint32{4, 8}, refUint32s(part, 0))
+	assert.Equal(t, []float64{0, 0, 1, 0, 1, 1, 0, 0, 0.1, 0.1, 0.2, 0.1, 0.2, 0.2, 0.1, 0.1}, refFloat64s(part, 1))
+	assert.Equal(t, []float64{5, 5, 6, 5, 6, 6, 5, 5}, refFloat64s(refElement(geometry, 7, 1), 1))
+	assert.Equal(t, binary.LittleEndian.AppendUint64(binary.LittleEndian.AppendUint16(nil, 0), 2), refBytes(feature, 1))
+
+	// no geometry, no properties
+	feature, pos = refTable(data, pos)
+	assert.Zero(t, feature.Offset(4))
+	assert.Zero(t, feature.Offset(6))
+	assert.Equal(t, len(data), int(pos))
+}
+
+func TestWriter_PropertyTypeMismatch(t *testing.T) {
+	w, err := NewWriter(&bytes.Buffer{}, Header{Columns: []Column{{Name: "huisnummer", Type: ColumnTypeLong}}})
+	require.NoError(t, err)
+	err = w.WriteFeature(nil, map[string]any{"huisnummer": "1a"})
+	assert.EqualError(t, err, "failed to encode property huisnummer, error: value 1a of type string doesn't match column type 7")
+}
+
+func TestWriter_PropertyCoercion(t *testing.T) {
+	tests := []struct {
+		columnType ColumnType
+		value      any
+		expected   []byte
+	}{
+		{ColumnTypeLong, int32(42), binary.LittleEndian.AppendUint64(nil, 42)},
+		{ColumnTypeLong, float64(42), binary.LittleEndian.AppendUint64(nil, 42)},
+		{ColumnTypeLong, "42", binary.LittleEndian.AppendUint64(nil, 42)},
+		{ColumnTypeDouble, "12.5", binary.LittleEndian.AppendUint64(nil, math.Float64bits(12.5))},
+		{ColumnTypeDouble, int64(12), binary.LittleEndian.AppendUint64(nil, math.Float64bits(12))},
+		{ColumnTypeDouble, float32(0.5), binary.LittleEndian.AppendUint64(nil, math.Float64bits(0.5))},
+		{ColumnTypeBool, "true", []byte{1}},
+		{ColumnTypeBool, int64(0), []byte{0}},
+		{ColumnTypeString, int64(1104), appendString(nil, "1104")},
+		{ColumnTypeString, []byte("abc"), appendString(nil, "abc")},
+	}
+	for _, tt := range tests {
+		actual, err := appendValue(nil, tt.columnType, tt.value)
+		require.NoError(t, err, "%v (%T)", tt.value, tt.value)
+		assert.Equal(t, tt.expected, actual, "%v (%T)", tt.value, tt.value)
+		assert.True(t, Fits(tt.columnType, tt.value))
+	}
+
+	assert.False(t, Fits(ColumnTypeLong, 1.5))
+	assert.False(t, Fits(ColumnTypeLong, "1a"))
+	assert.False(t, Fits(ColumnTypeDouble, "1,5"))
+	assert.False(t, Fits(ColumnTypeDateTime, int64(1)))
+}
+
+// TestWriter_Fixture compares the output with a fixture and reads the fixture with the reference FlatBuffers
+// runtime, using accessors equivalent to the code generated from the FlatGeobuf schemas (header.fbs/feature.fbs).
+func TestWriter_Fixture(t *testing.T) {
+	out := &bytes.Buffer{}
+	w, err := NewWriter(out, Header{
+		Name:         "adressen",
+		Envelope:     &geom.Extent{4.8, 52.3, 4.9, 52.4},
+		GeometryType: GeometryTypePoint,
+		Columns: []Column{
+			{Name: "fid", Type: ColumnTypeLong},
+			{Name: "huisnummer", Type: ColumnTypeLong},
+			{Name: "oppervlakte", Type: ColumnTypeDouble},
+			{Name: "straatnaam", Type: ColumnTypeString},
+		},
+		FeaturesCount: 2,
+		EPSGCode:      4326,
+	})
+	require.NoError(t, err)
+	require.NoError(t, w.WriteFeature(geom.Point{4.8, 52.3},
+		map[string]any{"fid": int64(1), "huisnummer": int64(1104), "oppervlakte": "70.5", "straatnaam": "Silodam"}))
+	require.NoError(t, w.WriteFeature(geom.Point{4.9, 52.4},
+		map[string]any{"fid": int64(2), "straatnaam": "Prinsengracht"}))
+
+	fixture, err := os.ReadFile("testdata/adressen.fgb")
+	require.NoError(t, err)
+	assert.Equal(t, fixture, out.Bytes())
+
+	assert.Equal(t, []byte("fgb\x03fgb"), fixture[:7])
+	header, pos := refTable(fixture, 8)
+	assert.Equal(t, "adressen", string(refBytes(header, 0)))
+	assert.Equal(t, []float64{4.8, 52.3, 4.9, 52.4}, refFloat64s(header, 1))
+	assert.Equal(t, uint8(GeometryTypePoint), header.GetUint8Slot(8, 0))
+	assert.Equal(t, uint64(2), header.GetUint64Slot(20, 0))
+	assert.Equal(t, uint16(0), header.GetUint16Slot(22, 16))
+	crs := refChild(header, 10)
+	assert.Equal(t, int32(4326), crs.GetInt32Slot(6, 0))
+
+	var columns []string
+	for i := 0; i < refVectorLen(header, 7); i++ {
+		column := refElement(header, 7, i)
+		columns = append(columns, string(refBytes(column, 0)))
+		assert.True(t, column.GetBoolSlot(18, true), "nullable")
+	}
+	assert.Equal(t, []string{"fid", "huisnummer", "oppervlakte", "straatnaam"}, columns)
+
+	feature, pos := refTable(fixture, pos)
+	geometry := refChild(feature, 0)
+	assert.Equal(t, uint8(GeometryTypePoint), geometry.GetUint8Slot(16, 0))
+	assert.Equal(t, []float64{4.8, 52.3}, refFloat64s(geometry, 1))
+	props := refBytes(feature, 1)
+	assert.Equal(t, uint16(0), binary.LittleEndian.Uint16(props))
+	assert.Equal(t, uint64(1), binary.LittleEndian.Uint64(props[2:]))
+	assert.Equal(t, uint16(1), binary.LittleEndian.Uint16(props[10:]))
+	assert.Equal(t, uint64(1104), binary.LittleEndian.Uint64(props[12:]))
+	assert.Equal(t, uint16(2), binary.LittleEndian.Uint16(props[20:]))
+	assert.Equal(t, 70.5, math.Float64frombits(binary.LittleEndian.Uint64(props[22:])))
+	assert.Equal(t, uint16(3), binary.LittleEndian.Uint16(props[30:]))
+	assert.Equal(t, uint32(7), binary.LittleEndian.Uint32(props[32:]))
+	assert.Equal(t, "Silodam", string(props[36:]))
+
+	feature, pos = refTable(fixture, pos)
+	assert.Equal(t, []float64{4.9, 52.4}, refFloat64s(refChild(feature, 0), 1))
+	assert.Len(t, refBytes(feature, 1), 2+8+2+4+len("Prinsengracht"))
+	assert.Equal(t, len(fixture), int(pos))
+}
+
+// refTable reads the size prefixed root table at the given position using the reference FlatBuffers runtime
+func refTable(data []byte, pos flatbuffers.UOffsetT) (*flatbuffers.Table, flatbuffers.UOffsetT) {
+	size := flatbuffers.UOffsetT(flatbuffers.GetSizePrefix(data, pos))
+	buf := data[pos : pos+flatbuffers.SizeUint32+size]
+	root := flatbuffers.GetUOffsetT(buf[flatbuffers.SizeUint32:]) + flatbuffers.SizeUint32
+	return &flatbuffers.Table{Bytes: buf, Pos: root}, pos + flatbuffers.SizeUint32 + size
+}
+
+func refBytes(parent *flatbuffers.Table, id int) []byte {
+	return parent.ByteVector(parent.Pos + flatbuffers.UOffsetT(parent.Offset(flatbuffers.VOffsetT(4+2*id))))
+}
+
+func refVectorLen(parent *flatbuffers.Table, id int) int {
+	return parent.VectorLen(flatbuffers.UOffsetT(parent.Offset(flatbuffers.VOffsetT(4 + 2*id))))
+}
+
+func refChild(parent *flatbuffers.Table, id int) *flatbuffers.Table {
+	offset := flatbuffers.UOffsetT(parent.Offset(flatbuffers.VOffsetT(4 + 2*id)))
+	return &flatbuffers.Table{Bytes: parent.Bytes, Pos: parent.Indirect(parent.Pos + offset)}
+}
+
+func refElement(parent *flatbuffers.Table, id int, i int) *flatbuffers.Table {
+	offset := flatbuffers.UOffsetT(parent.Offset(flatbuffers.VOffsetT(4 + 2*id)))
+	element := parent.Vector(offset) + flatbuffers.UOffsetT(i)*flatbuffers.SizeUOffsetT
+	return &flatbuffers.Table{Bytes: parent.Bytes, Pos: parent.Indirect(element)}
+}
+
+func refFloat64s(parent *flatbuffers.Table, id int) []float64 {
+	offset := flatbuffers.UOffsetT(parent.Offset(flatbuffers.VOffsetT(4 + 2*id)))
+	start := parent.Vector(offset)
+	result := make([]float64, 0, parent.VectorLen(offset))
+	for i := 0; i < parent.VectorLen(offset); i++ {
+		result = append(result, parent.GetFloat64(start+flatbuffers.UOffsetT(i)*flatbuffers.SizeFloat64))
+	}
+	return result
+}
+
+func refUint32s(parent *flatbuffers.Table, id int) []uint32 {
+	offset := flatbuffers.UOffsetT(parent.Offset(flatbuffers.VOffsetT(4 + 2*id)))
+	start := parent.Vector(offset)
+	result := make([]uint32, 0, parent.VectorLen(offset))
+	for i := 0; i < parent.VectorLen(offset); i++ {
+		result = append(result, parent.GetUint32(start+flatbuffers.UOffsetT(i)*flatbuffers.SizeUint32))
+	}
+	return result
+}
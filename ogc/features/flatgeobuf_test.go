@@ -0,0 +1,114 @@
+package features
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PDOK/gokoala/engine"
+	ds "github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/PDOK/gokoala/ogc/features/flatgeobuf"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubFeatureTable struct{}
+
+func (stubFeatureTable) ColumnsWithDataType() map[string]string {
+	return map[string]string{"feature_id": "INTEGER", "geom": "POINT", "straatnaam": "TEXT",
+		"huisnummer": "INTEGER", "datum": "DATE", "oppervlakte": "NUMERIC", "minx": "REAL"}
+}
+
+func (stubFeatureTable) IDColumn() string {
+	return "feature_id"
+}
+
+func (stubFeatureTable) GeometryColumn() ds.GeometryColumn {
+	return ds.GeometryColumn{Name: "geom", Type: "POINT", SRID: 28992}
+}
+
+func (stubFeatureTable) LastModified() time.Time {
+	return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+}
+
+type stubFeatureTableDatasource struct{ ds.Datasource }
+
+func (stubFeatureTableDatasource) GetFeatureTableMetadata(_ string) (ds.FeatureTableMetadata, error) {
+	return stubFeatureTable{}, nil
+}
+
+func TestCreateFlatGeobufHeader(t *testing.T) {
+	newEngine, err := engine.NewEngine("ogc/features/testdata/config_features_bag.yaml", "", false, true)
+	require.NoError(t, err)
+	f := &Features{engine: newEngine, datasources: map[DatasourceKey]ds.Datasource{
+		{srid: wgs84SRID, collectionID: "foo"}: stubFeatureTableDatasource{},
+		{srid: 28992, collectionID: "foo"}:     stubFeatureTableDatasource{},
+	}}
+	features := []*domain.Feature{
+		{Geometry: &geojson.Geometry{Geometry: geom.Point{1, 2}}},
+		{Geometry: &geojson.Geometry{Geometry: geom.Point{3, 4}}},
+		{},
+	}
+
+	t.Run("single geometry type", func(t *testing.T) {
+		header, idColumn, err := f.createFlatGeobufHeader("foo", features, SRID(0))
+		require.NoError(t, err)
+		assert.Equal(t, "feature_id", idColumn)
+		assert.Equal(t, flatgeobuf.Header{
+			Name:         "foo",
+			Envelope:     &geom.Extent{1, 2, 3, 4},
+			GeometryType: flatgeobuf.GeometryTypePoint,
+			Columns: []flatgeobuf.Column{
+				{Name: "feature_id", Type: flatgeobuf.ColumnTypeLong},
+				{Name: "datum", Type: flatgeobuf.ColumnTypeDateTime},
+				{Name: "huisnummer", Type: flatgeobuf.ColumnTypeLong},
+				{Name: "oppervlakte", Type: flatgeobuf.ColumnTypeDouble},
+				{Name: "straatnaam", Type: flatgeobuf.ColumnTypeString},
+			},
+			FeaturesCount: 3,
+			EPSGCode:      4326,
+		}, header)
+	})
+
+	t.Run("mixed geometry types", func(t *testing.T) {
+		mixed := append([]*domain.Feature{{Geometry: &geojson.Geometry{Geometry: geom.LineString{{0, 0}, {1, 1}}}}}, features...)
+		header, _, err := f.createFlatGeobufHeader("foo", mixed, SRID(28992))
+		require.NoError(t, err)
+		assert.Equal(t, flatgeobuf.GeometryTypeUnknown, header.GeometryType)
+		assert.Equal(t, &geom.Extent{0, 0, 3, 4}, header.Envelope)
+		assert.Equal(t, 28992, header.EPSGCode)
+	})
+	t.Run("values not matching the column type", func(t *testing.T) {
+		mismatch := []*domain.Feature{
+			{Feature: geojson.Feature{Properties: map[string]any{"huisnummer": int64(1104), "oppervlakte": "70.5"}}},
+			{Feature: geojson.Feature{Properties: map[string]any{"huisnummer": "1104a"}}}, // e.g. mixed types in SQLite
+		}
+		header, _, err := f.createFlatGeobufHeader("foo", mismatch, SRID(0))
+		require.NoError(t, err)
+		assert.Contains(t, header.Columns, flatgeobuf.Column{Name: "huisnummer", Type: flatgeobuf.ColumnTypeString})
+		assert.Contains(t, header.Columns, flatgeobuf.Column{Name: "oppervlakte", Type: flatgeobuf.ColumnTypeDouble})
+	})
+}
+
+func TestToFlatGeobufColumnType(t *testing.T) {
+	tests := map[string]flatgeobuf.ColumnType{
+		"INT":                      flatgeobuf.ColumnTypeLong,
+		"TINYINT":                  flatgeobuf.ColumnTypeLong,
+		"MEDIUMINT":                flatgeobuf.ColumnTypeLong,
+		"bigint":                   flatgeobuf.ColumnTypeLong,
+		"FLOAT":                    flatgeobuf.ColumnTypeDouble,
+		"DOUBLE":                   flatgeobuf.ColumnTypeDouble,
+		"double precision":         flatgeobuf.ColumnTypeDouble,
+		"numeric":                  flatgeobuf.ColumnTypeDouble,
+		"NUMERIC(10,2)":            flatgeobuf.ColumnTypeDouble,
+		"boolean":                  flatgeobuf.ColumnTypeBool,
+		"timestamp with time zone": flatgeobuf.ColumnTypeDateTime,
+		"TEXT(10)":                 flatgeobuf.ColumnTypeString,
+		"character varying":        flatgeobuf.ColumnTypeString,
+	}
+	for dataType, expected := range tests {
+		assert.Equal(t, expected, toFlatGeobufColumnType(dataType), dataType)
+	}
+}
@@ -0,0 +1,200 @@
+package features
+
+import (
+	"math"
+
+	"github.com/PDOK/gokoala/config"
+	"github.com/PDOK/gokoala/ogc/features/datasources/reproject"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom"
+)
+
+const (
+	// coordinate precision is configured for projected CRSs (in metres), geographic CRSs (in degrees)
+	// get additional decimals for roughly the same precision since one degree is about 100 km.
+	geographicExtraDecimals = 5
+
+	// minimum number of points of a closed ring (triangle + closing point)
+	minRingSize = 4
+)
+
+// geometryOptions controls the processing of geometries before these are encoded in the response
+type geometryOptions struct {
+	// tolerance in units of the output CRS to simplify geometries with, 0 means no simplification
+	maxAllowableOffset float64
+
+	// number of decimals to round coordinates to, nil means no rounding
+	decimals *int
+}
+
+func newGeometryOptions(cfg *config.OgcAPIFeatures, collectionID string, outputSRID SRID,
+	maxAllowableOffset float64) geometryOptions {
+
+	options := geometryOptions{maxAllowableOffset: maxAllowableOffset}
+	if precision := cfg.CoordinatePrecisionForCollection(collectionID); precision != nil {
+		decimals := *precision
+		if reproject.IsGeographic(outputSRID.GetOrDefault()) {
+			decimals += geographicExtraDecimals
+		}
+		options.decimals = &decimals
+	}
+	return options
+}
+
+func (o geometryOptions) isEmpty() bool {
+	return o.maxAllowableOffset <= 0 && o.decimals == nil
+}
+
+// apply simplifies and/or rounds the geometry of the given feature, in-place
+func (o geometryOptions) apply(feature *domain.Feature) {
+	if o.isEmpty() || feature.Geometry == nil || feature.Geometry.Geometry == nil {
+		return
+	}
+	geometry := feature.Geometry.Geometry
+	if o.maxAllowableOffset > 0 {
+		// only simplify linestrings and rings, (multi)points are unrelated points which can't be simplified
+		geometry = mapGeometry(geometry, func(points [][2]float64) [][2]float64 {
+			return douglasPeucker(points, o.maxAllowableOffset)
+		}, keepPoints)
+	}
+	if o.decimals != nil {
+		factor := math.Pow10(*o.decimals)
+		round := func(points [][2]float64) [][2]float64 {
+			rounded := make([][2]float64, 0, len(points))
+			for _, point := range points {
+				rounded = append(rounded, [2]float64{math.Round(point[0]*factor) / factor, math.Round(point[1]*factor) / factor})
+			}
+			return rounded
+		}
+		geometry = mapGeometry(geometry, round, round)
+	}
+	feature.Geometry.Geometry = geometry
+}
+
+// wrap returns an iterator which applies these options to each feature, or the given iterator when there's nothing to apply
+func (o geometryOptions) wrap(features domain.FeatureIterator) domain.FeatureIterator {
+	if o.isEmpty() {
+		return features
+	}
+	return &geometryProcessingIterator{FeatureIterator: features, options: o}
+}
+
+// geometryProcessingIterator applies geometryOptions to features while these are read
+type geometryProcessingIterator struct {
+	domain.FeatureIterator
+	options geometryOptions
+}
+
+func (it *geometryProcessingIterator) Next() (*domain.Feature, error) {
+	feature, err := it.FeatureIterator.Next()
+	if err != nil || feature == nil {
+		return feature, err
+	}
+	it.options.apply(feature)
+	return feature, nil
+}
+
+// mapGeometry returns a (2D) copy of the given geometry with fn applied to each sequence of connected points
+// (linestring or ring) and pointsFn applied to the points of a (multi)point. Rings reduced to less than 4 points
+// are considered collapsed: collapsed holes are removed while polygons with a collapsed exterior are retained
+// as-is, so features never lose their geometry.
+func mapGeometry(geometry geom.Geometry, fn func([][2]float64) [][2]float64,
+	pointsFn func([][2]float64) [][2]float64) geom.Geometry {
+
+	switch v := geometry.(type) {
+	case geom.Pointer:
+		return geom.Point(pointsFn([][2]float64{v.XY()})[0])
+	case geom.LineStringer:
+		return geom.LineString(fn(v.Vertices()))
+	case geom.Polygoner:
+		return geom.Polygon(mapPolygon(v.LinearRings(), fn))
+	case geom.MultiPointer:
+		return geom.MultiPoint(pointsFn(v.Points()))
+	case geom.MultiLineStringer:
+		lineStrings := make([][][2]float64, 0, len(v.LineStrings()))
+		for _, lineString := range v.LineStrings() {
+			lineStrings = append(lineStrings, fn(lineString))
+		}
+		return geom.MultiLineString(lineStrings)
+	case geom.MultiPolygoner:
+		polygons := make([][][][2]float64, 0, len(v.Polygons()))
+		for _, polygon := range v.Polygons() {
+			polygons = append(polygons, mapPolygon(polygon, fn))
+		}
+		return geom.MultiPolygon(polygons)
+	case geom.Collectioner:
+		geometries := make([]geom.Geometry, 0, len(v.Geometries()))
+		for _, member := range v.Geometries() {
+			geometries = append(geometries, mapGeometry(member, fn, pointsFn))
+		}
+		return geom.Collection(geometries)
+	}
+	return geometry
+}
+
+// keepPoints returns the given points unchanged
+func keepPoints(points [][2]float64) [][2]float64 {
+	return points
+}
+
+func mapPolygon(rings [][][2]float64, fn func([][2]float64) [][2]float64) [][][2]float64 {
+	result := make([][][2]float64, 0, len(rings))
+	for i, ring := range rings {
+		mapped := fn(ring)
+		if len(mapped) < minRingSize && len(ring) >= minRingSize {
+			if i == 0 {
+				return rings // exterior collapsed
+			}
+			continue // hole collapsed
+		}
+		result = append(result, mapped)
+	}
+	return result
+}
+
+// douglasPeucker simplifies the given points using the Douglas-Peucker algorithm, removing points within
+// the given tolerance of the simplified line. The first and last point are always retained, so rings stay closed.
+func douglasPeucker(points [][2]float64, tolerance float64) [][2]float64 {
+	if len(points) <= 2 {
+		return points
+	}
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+
+	// iterative instead of recursive to support long linestrings
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		maxDistance, index := 0.0, 0
+		for i := first + 1; i < last; i++ {
+			if distance := distanceToSegment(points[i], points[first], points[last]); distance > maxDistance {
+				maxDistance, index = distance, i
+			}
+		}
+		if maxDistance > tolerance {
+			keep[index] = true
+			stack = append(stack, [2]int{first, index}, [2]int{index, last})
+		}
+	}
+
+	result := make([][2]float64, 0, len(points))
+	for i, point := range points {
+		if keep[i] {
+			result = append(result, point)
+		}
+	}
+	return result
+}
+
+// distanceToSegment returns the distance between point p and the line segment from a to b
+func distanceToSegment(p, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	if dx == 0 && dy == 0 {
+		return math.Hypot(p[0]-a[0], p[1]-a[1]) // segment is a single point, e.g. start/end of a ring
+	}
+	t := ((p[0]-a[0])*dx + (p[1]-a[1])*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(p[0]-(a[0]+t*dx), p[1]-(a[1]+t*dy))
+}
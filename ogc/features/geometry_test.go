@@ -0,0 +1,161 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/PDOK/gokoala/config"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDouglasPeucker(t *testing.T) {
+	tests := []struct {
+		name      string
+		points    [][2]float64
+		tolerance float64
+		want      [][2]float64
+	}{
+		{
+			name:      "remove points within tolerance",
+			points:    [][2]float64{{0, 0}, {1, 0.1}, {2, -0.1}, {3, 5}, {4, 6}, {5, 7}},
+			tolerance: 0.5,
+			want:      [][2]float64{{0, 0}, {2, -0.1}, {3, 5}, {5, 7}},
+		},
+		{
+			name:      "keep all points when tolerance is small",
+			points:    [][2]float64{{0, 0}, {1, 0.1}, {2, 0}},
+			tolerance: 0.01,
+			want:      [][2]float64{{0, 0}, {1, 0.1}, {2, 0}},
+		},
+		{
+			name:      "closed ring stays closed",
+			points:    [][2]float64{{0, 0}, {10, 0}, {10, 0.1}, {10, 10}, {0, 10}, {0, 0}},
+			tolerance: 1,
+			want:      [][2]float64{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		},
+		{
+			name:      "two points",
+			points:    [][2]float64{{0, 0}, {1, 1}},
+			tolerance: 10,
+			want:      [][2]float64{{0, 0}, {1, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, douglasPeucker(tt.points, tt.tolerance))
+		})
+	}
+}
+
+func TestGeometryOptions_apply(t *testing.T) {
+	two := 2
+	tests := []struct {
+		name     string
+		options  geometryOptions
+		geometry geom.Geometry
+		want     geom.Geometry
+	}{
+		{
+			name:     "round point",
+			options:  geometryOptions{decimals: &two},
+			geometry: geom.Point{4.123456, 52.987654},
+			want:     geom.Point{4.12, 52.99},
+		},
+		{
+			name:     "simplify and round linestring",
+			options:  geometryOptions{maxAllowableOffset: 0.5, decimals: &two},
+			geometry: geom.LineString{{0, 0}, {1, 0.1}, {2.005, 0.001}},
+			want:     geom.LineString{{0, 0}, {2.01, 0}},
+		},
+		{
+			name:    "drop collapsed hole",
+			options: geometryOptions{maxAllowableOffset: 1},
+			geometry: geom.Polygon{
+				{{0, 0}, {100, 0}, {100, 100}, {0, 100}, {0, 0}},
+				{{10, 10}, {10.1, 10}, {10.1, 10.1}, {10, 10}},
+			},
+			want: geom.Polygon{
+				{{0, 0}, {100, 0}, {100, 100}, {0, 100}, {0, 0}},
+			},
+		},
+		{
+			name:     "keep polygon with collapsed exterior as-is",
+			options:  geometryOptions{maxAllowableOffset: 1},
+			geometry: geom.Polygon{{{0, 0}, {0.1, 0}, {0.1, 0.1}, {0, 0}}},
+			want:     geom.Polygon{{{0, 0}, {0.1, 0}, {0.1, 0.1}, {0, 0}}},
+		},
+		{
+			name:     "round multipolygon",
+			options:  geometryOptions{decimals: &two},
+			geometry: geom.MultiPolygon{{{{0.001, 0}, {1.004, 0}, {1, 1.006}, {0.001, 0}}}},
+			want:     geom.MultiPolygon{{{{0, 0}, {1, 0}, {1, 1.01}, {0, 0}}}},
+		},
+		{
+			name:     "don't simplify multipoint",
+			options:  geometryOptions{maxAllowableOffset: 0.5, decimals: &two},
+			geometry: geom.MultiPoint{{0, 0}, {1, 0.1}, {2.005, 0.001}},
+			want:     geom.MultiPoint{{0, 0}, {1, 0.1}, {2.01, 0}},
+		},
+		{
+			name:     "round collection",
+			options:  geometryOptions{decimals: &two},
+			geometry: geom.Collection{geom.Point{1.001, 2.009}, geom.MultiPoint{{3.333, 4.444}}},
+			want:     geom.Collection{geom.Point{1, 2.01}, geom.MultiPoint{{3.33, 4.44}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feature := &domain.Feature{Geometry: &geojson.Geometry{Geometry: tt.geometry}}
+			tt.options.apply(feature)
+			assert.Equal(t, tt.want, feature.Geometry.Geometry)
+		})
+	}
+
+	t.Run("feature without geometry", func(t *testing.T) {
+		feature := &domain.Feature{}
+		geometryOptions{decimals: &two}.apply(feature)
+		assert.Nil(t, feature.Geometry)
+	})
+}
+
+func TestNewGeometryOptions(t *testing.T) {
+	precision := 3
+	cfg := &config.OgcAPIFeatures{
+		Collections: config.GeoSpatialCollections{
+			{ID: "foo", Features: &config.CollectionEntryFeatures{CoordinatePrecision: &precision}},
+			{ID: "bar", Features: &config.CollectionEntryFeatures{}},
+		},
+	}
+
+	options := newGeometryOptions(cfg, "foo", SRID(28992), 0)
+	require.NotNil(t, options.decimals)
+	assert.Equal(t, 3, *options.decimals)
+
+	options = newGeometryOptions(cfg, "foo", SRID(undefinedSRID), 0)
+	require.NotNil(t, options.decimals)
+	assert.Equal(t, 3+geographicExtraDecimals, *options.decimals)
+
+	options = newGeometryOptions(cfg, "bar", SRID(28992), 0)
+	assert.True(t, options.isEmpty())
+
+	options = newGeometryOptions(cfg, "bar", SRID(28992), 2.5)
+	assert.False(t, options.isEmpty())
+}
+
+func TestGeometryOptions_wrap(t *testing.T) {
+	features := domain.NewSliceFeatureIterator([]*domain.Feature{
+		{ID: int64(1), Geometry: &geojson.Geometry{Geometry: geom.Point{1.26, 2.24}}},
+		{ID: int64(2)},
+	})
+	assert.Same(t, features, geometryOptions{}.wrap(features))
+
+	zero := 0
+	result, err := domain.ReadFeatures(geometryOptions{decimals: &zero}.wrap(features))
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, geom.Point{1, 2}, result[0].Geometry.Geometry)
+	assert.Nil(t, result[1].Geometry)
+}
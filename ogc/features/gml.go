@@ -0,0 +1,304 @@
+package features
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PDOK/gokoala/engine"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom"
+)
+
+const (
+	gmlSF0Profile   = "http://www.opengis.net/def/profile/ogc/2.0/gml-sf0"
+	mediaTypeGMLSF0 = engine.MediaTypeGML + ";profile=" + gmlSF0Profile
+
+	gmlNamespace  = "http://www.opengis.net/gml/3.2"
+	sfNamespace   = "http://www.opengis.net/ogcapi-features-1/1.0/sf"
+	sfSchema      = "http://schemas.opengis.net/ogcapi/features/part1/1.0/xml/core-sf.xsd"
+	atomNamespace = "http://www.w3.org/2005/Atom"
+	xsiNamespace  = "http://www.w3.org/2001/XMLSchema-instance"
+	appPrefix     = "app:"
+	gmlDateLayout = "2006-01-02"
+	gmlDimension  = 2 // only 2D geometries are supported
+)
+
+// featuresAsGML serves features as GML according to the GML simple features profile (level 0), as
+// described in OGC API Features part 1. The application schema of each collection is served at /schema?f=xsd.
+func (f *Features) featuresAsGML(w http.ResponseWriter, collectionID string, cursor domain.Cursors,
+	featuresURL featureCollectionURL, fc *domain.FeatureCollection, crs ContentCrs) {
+
+	properties, ok := f.schemas[collectionID]
+	if !ok {
+		handleGMLEncodingFailure(fmt.Errorf("no schema available for collection %s", collectionID), w)
+		return
+	}
+	result := &bytes.Buffer{}
+	g := newGMLWriter(result)
+	root := xml.StartElement{Name: xml.Name{Local: "sf:FeatureCollection"}}
+	root.Attr = f.gmlNamespaces(collectionID, sfNamespace+" "+sfSchema)
+	if fc.NumberMatched != nil {
+		root.Attr = append(root.Attr, gmlAttr("numberMatched", strconv.Itoa(*fc.NumberMatched)))
+	}
+	root.Attr = append(root.Attr,
+		gmlAttr("numberReturned", strconv.Itoa(len(fc.Features))),
+		gmlAttr("timeStamp", time.Now().UTC().Format(time.RFC3339)))
+	g.start(root)
+	links := createLinkHeaderFeatureCollectionLinks(engine.FormatGML, mediaTypeGMLSF0, collectionID, cursor, featuresURL)
+	for _, link := range links {
+		g.start(gmlElement("atom:link", gmlAttr("href", link.Href), gmlAttr("rel", link.Rel),
+			gmlAttr("type", link.Type), gmlAttr("title", link.Title)))
+		g.end("atom:link")
+	}
+	for _, feature := range fc.Features {
+		g.start(gmlElement("sf:featureMember"))
+		g.feature(collectionID, properties, feature, crs, nil)
+		g.end("sf:featureMember")
+	}
+	g.end(root.Name.Local)
+	serveGML(w, g, result)
+}
+
+// featureAsGML serves a single feature as GML, see featuresAsGML. The feature itself is the root element,
+// so links are exposed as Link headers.
+func (f *Features) featureAsGML(w http.ResponseWriter, collectionID string, feat *domain.Feature,
+	url featureURL, crs ContentCrs) {
+
+	properties, ok := f.schemas[collectionID]
+	if !ok {
+		handleGMLEncodingFailure(fmt.Errorf("no schema available for collection %s", collectionID), w)
+		return
+	}
+	setLinkHeaders(w, createLinkHeaderFeatureLinks(engine.FormatGML, mediaTypeGMLSF0, url, collectionID, feat.ID))
+	result := &bytes.Buffer{}
+	g := newGMLWriter(result)
+	g.feature(collectionID, properties, feat, crs, f.gmlNamespaces(collectionID, ""))
+	serveGML(w, g, result)
+}
+
+func serveGML(w http.ResponseWriter, g *gmlWriter, result *bytes.Buffer) {
+	if err := g.flush(); err != nil {
+		handleGMLEncodingFailure(err, w)
+		return
+	}
+	w.Header().Set(engine.HeaderContentType, mediaTypeGMLSF0)
+	if _, err := w.Write(result.Bytes()); err != nil {
+		log.Printf("failed to write GML response: %v", err)
+	}
+}
+
+// gmlNamespaces returns the namespace declarations and schema locations for the root element,
+// the given schema location (namespace and location) is added to that of the application schema.
+func (f *Features) gmlNamespaces(collectionID string, schemaLocation string) []xml.Attr {
+	appNamespace := fmt.Sprintf("%s/collections/%s", f.engine.Config.BaseURL.String(), collectionID)
+	schemaLocations := appNamespace + " " + appNamespace + "/schema?f=" + engine.FormatXSD
+	if schemaLocation != "" {
+		schemaLocations = schemaLocation + " " + schemaLocations
+	}
+	return []xml.Attr{
+		gmlAttr("xmlns:sf", sfNamespace),
+		gmlAttr("xmlns:gml", gmlNamespace),
+		gmlAttr("xmlns:atom", atomNamespace),
+		gmlAttr("xmlns:xsi", xsiNamespace),
+		gmlAttr("xmlns:app", appNamespace),
+		gmlAttr("xsi:schemaLocation", schemaLocations),
+	}
+}
+
+// gmlWriter writes GML using prefixed element names. To keep the encoding code readable
+// the first error is retained (like bufio.Writer) and returned on flush.
+type gmlWriter struct {
+	enc *xml.Encoder
+	err error
+}
+
+func newGMLWriter(buf *bytes.Buffer) *gmlWriter {
+	buf.WriteString(xml.Header)
+	return &gmlWriter{enc: xml.NewEncoder(buf)}
+}
+
+func (g *gmlWriter) start(element xml.StartElement) {
+	if g.err == nil {
+		g.err = g.enc.EncodeToken(element)
+	}
+}
+
+func (g *gmlWriter) end(name string) {
+	if g.err == nil {
+		g.err = g.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
+	}
+}
+
+func (g *gmlWriter) text(name string, value string) {
+	g.start(gmlElement(name))
+	if g.err == nil {
+		g.err = g.enc.EncodeToken(xml.CharData(value))
+	}
+	g.end(name)
+}
+
+func (g *gmlWriter) flush() error {
+	if g.err != nil {
+		return g.err
+	}
+	return g.enc.Flush()
+}
+
+// feature writes a single feature, with its properties in the order of the application schema.
+// Namespace declarations are only needed when the feature is the root element.
+func (g *gmlWriter) feature(collectionID string, properties []SchemaProperty, feature *domain.Feature,
+	crs ContentCrs, namespaces []xml.Attr) {
+
+	featureGMLID := fmt.Sprintf("%s.%v", collectionID, feature.ID)
+	element := gmlElement(appPrefix+collectionID, gmlAttr("gml:id", featureGMLID))
+	element.Attr = append(element.Attr, namespaces...)
+	g.start(element)
+	for _, property := range properties {
+		switch property.Role {
+		case roleID:
+			continue // already encoded as gml:id
+		case rolePrimaryGeometry:
+			if feature.Geometry != nil && feature.Geometry.Geometry != nil {
+				g.start(gmlElement(appPrefix + property.Name))
+				g.geometry(feature.Geometry.Geometry, featureGMLID+".geom", string(crs))
+				g.end(appPrefix + property.Name)
+			}
+		default:
+			value, ok := feature.Properties[property.Name]
+			if !ok || value == nil {
+				continue
+			}
+			encoded, err := toGMLValue(value, property)
+			if err != nil {
+				g.err = fmt.Errorf("failed to encode property %s of feature %v, error: %w", property.Name, feature.ID, err)
+				return
+			}
+			g.text(appPrefix+property.Name, encoded)
+		}
+	}
+	g.end(element.Name.Local)
+}
+
+// geometry writes the given geometry as GML, each geometry (also those in multi-geometries) requires a gml:id
+func (g *gmlWriter) geometry(geometry geom.Geometry, id string, srsName string) {
+	attrs := []xml.Attr{gmlAttr("gml:id", id)}
+	if srsName != "" {
+		attrs = append(attrs, gmlAttr("srsName", srsName))
+	}
+	switch v := geometry.(type) {
+	case geom.Pointer:
+		g.start(gmlElement("gml:Point", attrs...))
+		g.text("gml:pos", gmlPosList([][2]float64{v.XY()}))
+		g.end("gml:Point")
+	case geom.LineStringer:
+		g.start(gmlElement("gml:LineString", attrs...))
+		g.text("gml:posList", gmlPosList(v.Vertices()))
+		g.end("gml:LineString")
+	case geom.Polygoner:
+		g.start(gmlElement("gml:Polygon", attrs...))
+		for i, ring := range v.LinearRings() {
+			boundary := "gml:interior"
+			if i == 0 {
+				boundary = "gml:exterior"
+			}
+			g.start(gmlElement(boundary))
+			g.start(gmlElement("gml:LinearRing"))
+			g.text("gml:posList", gmlPosList(closeRing(ring)))
+			g.end("gml:LinearRing")
+			g.end(boundary)
+		}
+		g.end("gml:Polygon")
+	case geom.MultiPointer:
+		members := make([]geom.Geometry, 0, len(v.Points()))
+		for _, point := range v.Points() {
+			members = append(members, geom.Point(point))
+		}
+		g.multiGeometry("gml:MultiPoint", "gml:pointMember", attrs, id, members)
+	case geom.MultiLineStringer:
+		members := make([]geom.Geometry, 0, len(v.LineStrings()))
+		for _, lineString := range v.LineStrings() {
+			members = append(members, geom.LineString(lineString))
+		}
+		g.multiGeometry("gml:MultiCurve", "gml:curveMember", attrs, id, members)
+	case geom.MultiPolygoner:
+		members := make([]geom.Geometry, 0, len(v.Polygons()))
+		for _, polygon := range v.Polygons() {
+			members = append(members, geom.Polygon(polygon))
+		}
+		g.multiGeometry("gml:MultiSurface", "gml:surfaceMember", attrs, id, members)
+	case geom.Collectioner:
+		g.multiGeometry("gml:MultiGeometry", "gml:geometryMember", attrs, id, v.Geometries())
+	default:
+		if g.err == nil {
+			g.err = fmt.Errorf("unsupported geometry type %T", geometry)
+		}
+	}
+}
+
+func (g *gmlWriter) multiGeometry(name string, memberName string, attrs []xml.Attr, id string, members []geom.Geometry) {
+	g.start(gmlElement(name, attrs...))
+	for i, member := range members {
+		g.start(gmlElement(memberName))
+		g.geometry(member, fmt.Sprintf("%s.%d", id, i+1), "")
+		g.end(memberName)
+	}
+	g.end(name)
+}
+
+func gmlElement(name string, attrs ...xml.Attr) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs}
+}
+
+func gmlAttr(name string, value string) xml.Attr {
+	return xml.Attr{Name: xml.Name{Local: name}, Value: value}
+}
+
+func gmlPosList(points [][2]float64) string {
+	coords := make([]string, 0, gmlDimension*len(points))
+	for _, point := range points {
+		coords = append(coords, strconv.FormatFloat(point[0], 'f', -1, 64), strconv.FormatFloat(point[1], 'f', -1, 64))
+	}
+	return strings.Join(coords, " ")
+}
+
+// closeRing makes sure the first and last point of the ring are equal, as required by GML
+func closeRing(ring [][2]float64) [][2]float64 {
+	if len(ring) > 0 && ring[0] != ring[len(ring)-1] {
+		return append(ring[:len(ring):len(ring)], ring[0])
+	}
+	return ring
+}
+
+func toGMLValue(value any, property SchemaProperty) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		if property.Format == "date" {
+			return v.Format(gmlDateLayout), nil
+		}
+		return v.Format(time.RFC3339Nano), nil
+	case map[string]any, []any:
+		// GML SF-0 only allows simple properties, so nested values are encoded as JSON
+		result, err := stdjson.Marshal(v)
+		return string(result), err
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func handleGMLEncodingFailure(err error, w http.ResponseWriter) {
+	log.Printf("GML encoding failed: %v", err)
+	engine.RenderProblem(engine.ProblemServerError, w, "Failed to write GML response")
+}
@@ -0,0 +1,111 @@
+package features
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/PDOK/gokoala/engine"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var gmlTestSchemas = map[string][]SchemaProperty{
+	"foo": {
+		{Name: "datum", DataType: "string", Format: "date"},
+		{Name: "feature_id", DataType: "integer", Role: roleID},
+		{Name: "geom", Format: "geometry-multipolygon", Role: rolePrimaryGeometry},
+		{Name: "huisnummer", DataType: "integer"},
+		{Name: "straatnaam", DataType: "string"},
+	},
+}
+
+func TestFeaturesAsGML(t *testing.T) {
+	newEngine, err := engine.NewEngine("ogc/features/testdata/config_features_bag.yaml", "", false, true)
+	require.NoError(t, err)
+	f := &Features{engine: newEngine, schemas: gmlTestSchemas}
+	baseURL, _ := url.Parse("http://localhost:8080")
+	featuresURL := featureCollectionURL{baseURL: *baseURL, params: url.Values{"limit": []string{"2"}}}
+	fc := &domain.FeatureCollection{
+		Features: []*domain.Feature{
+			{
+				ID: int64(1),
+				Feature: geojson.Feature{Properties: map[string]any{
+					"straatnaam": "Silodam & Damrak",
+					"huisnummer": int64(1),
+					"datum":      time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+					"onbekend":   "not in schema",
+				}},
+				Geometry: &geojson.Geometry{Geometry: geom.MultiPolygon{
+					{{{0, 0}, {1, 0}, {1, 1}}},
+				}},
+			},
+			{
+				ID:      int64(2),
+				Feature: geojson.Feature{Properties: map[string]any{"straatnaam": nil}},
+			},
+		},
+	}
+
+	w := httptest.NewRecorder()
+	f.featuresAsGML(w, "foo", domain.Cursors{HasNext: true, Next: "abc"}, featuresURL, fc, wgs84CrsURI)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, mediaTypeGMLSF0, w.Header().Get(engine.HeaderContentType))
+	body := regexp.MustCompile(`timeStamp="[^"]+"`).ReplaceAllString(w.Body.String(), `timeStamp="..."`)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<sf:FeatureCollection xmlns:sf="http://www.opengis.net/ogcapi-features-1/1.0/sf" xmlns:gml="http://www.opengis.net/gml/3.2" `+
+		`xmlns:atom="http://www.w3.org/2005/Atom" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" `+
+		`xmlns:app="http://localhost:8080/collections/foo" `+
+		`xsi:schemaLocation="http://www.opengis.net/ogcapi-features-1/1.0/sf http://schemas.opengis.net/ogcapi/features/part1/1.0/xml/core-sf.xsd `+
+		`http://localhost:8080/collections/foo http://localhost:8080/collections/foo/schema?f=xsd" numberReturned="2" timeStamp="...">`+
+		`<atom:link href="http://localhost:8080/collections/foo/items?f=gml&amp;limit=2" rel="self" type="`+mediaTypeGMLSF0+`" title="This document as GML"></atom:link>`+
+		`<atom:link href="http://localhost:8080/collections/foo/items?f=json&amp;limit=2" rel="alternate" type="application/geo+json" title="This document as GeoJSON"></atom:link>`+
+		`<atom:link href="http://localhost:8080/collections/foo/items?cursor=abc&amp;f=gml&amp;limit=2" rel="next" type="`+mediaTypeGMLSF0+`" title="Next page"></atom:link>`+
+		`<sf:featureMember><app:foo gml:id="foo.1"><app:datum>2024-01-02</app:datum>`+
+		`<app:geom><gml:MultiSurface gml:id="foo.1.geom" srsName="http://www.opengis.net/def/crs/OGC/1.3/CRS84">`+
+		`<gml:surfaceMember><gml:Polygon gml:id="foo.1.geom.1"><gml:exterior><gml:LinearRing>`+
+		`<gml:posList>0 0 1 0 1 1 0 0</gml:posList>`+
+		`</gml:LinearRing></gml:exterior></gml:Polygon></gml:surfaceMember></gml:MultiSurface></app:geom>`+
+		`<app:huisnummer>1</app:huisnummer><app:straatnaam>Silodam &amp; Damrak</app:straatnaam></app:foo></sf:featureMember>`+
+		`<sf:featureMember><app:foo gml:id="foo.2"></app:foo></sf:featureMember>`+
+		`</sf:FeatureCollection>`, body)
+}
+
+func TestFeatureAsGML(t *testing.T) {
+	newEngine, err := engine.NewEngine("ogc/features/testdata/config_features_bag.yaml", "", false, true)
+	require.NoError(t, err)
+	f := &Features{engine: newEngine, schemas: gmlTestSchemas}
+	baseURL, _ := url.Parse("http://localhost:8080")
+	feat := &domain.Feature{
+		ID:       "a3f1",
+		Feature:  geojson.Feature{Properties: map[string]any{"huisnummer": int64(10)}},
+		Geometry: &geojson.Geometry{Geometry: geom.Point{4.89, 52.38}},
+	}
+
+	w := httptest.NewRecorder()
+	f.featureAsGML(w, "foo", feat, featureURL{baseURL: *baseURL, params: url.Values{}}, "http://www.opengis.net/def/crs/EPSG/0/28992")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<app:foo gml:id="foo.a3f1" xmlns:sf=`)
+	assert.Contains(t, w.Body.String(), `xsi:schemaLocation="http://localhost:8080/collections/foo http://localhost:8080/collections/foo/schema?f=xsd">`+
+		`<app:geom><gml:Point gml:id="foo.a3f1.geom" srsName="http://www.opengis.net/def/crs/EPSG/0/28992"><gml:pos>4.89 52.38</gml:pos></gml:Point></app:geom>`+
+		`<app:huisnummer>10</app:huisnummer></app:foo>`)
+	assert.Len(t, w.Header().Values(engine.HeaderLink), 3)
+}
+
+func TestSchemaProperty_XSDType(t *testing.T) {
+	assert.Equal(t, "xs:integer", SchemaProperty{DataType: "integer"}.XSDType())
+	assert.Equal(t, "xs:double", SchemaProperty{DataType: "number"}.XSDType())
+	assert.Equal(t, "xs:date", SchemaProperty{DataType: "string", Format: "date"}.XSDType())
+	assert.Equal(t, "xs:dateTime", SchemaProperty{DataType: "string", Format: "date-time"}.XSDType())
+	assert.Equal(t, "xs:string", SchemaProperty{DataType: "string"}.XSDType())
+	assert.Equal(t, "gml:CurvePropertyType", SchemaProperty{Format: "geometry-linestring"}.XSDType())
+	assert.Equal(t, "gml:GeometryPropertyType", SchemaProperty{Format: "geometry-any"}.XSDType())
+}
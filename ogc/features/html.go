@@ -1,8 +1,8 @@
 package features
 
 import (
+	"fmt"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/PDOK/gokoala/config"
@@ -51,6 +51,7 @@ type featureCollectionPage struct {
 	Limit           int
 	ReferenceDate   *time.Time
 	PropertyFilters map[string]string
+	NumberOfPages   int // only when numberMatched is available
 }
 
 // featurePage enriched Feature for HTML representation.
@@ -58,7 +59,7 @@ type featurePage struct {
 	domain.Feature
 
 	CollectionID string
-	FeatureID    int64
+	FeatureID    any
 	Metadata     *config.GeoSpatialCollectionMetadata
 }
 
@@ -84,6 +85,11 @@ func (hf *htmlFeatures) features(w http.ResponseWriter, r *http.Request, collect
 		referenceDate = nil
 	}
 
+	numberOfPages := 0
+	if fc.NumberMatched != nil && limit > 0 {
+		numberOfPages = (*fc.NumberMatched + limit - 1) / limit
+	}
+
 	pageContent := &featureCollectionPage{
 		*fc,
 		collectionID,
@@ -94,6 +100,7 @@ func (hf *htmlFeatures) features(w http.ResponseWriter, r *http.Request, collect
 		limit,
 		referenceDate,
 		propertyFilters,
+		numberOfPages,
 	}
 
 	lang := hf.engine.CN.NegotiateLanguage(w, r)
@@ -114,8 +121,8 @@ func (hf *htmlFeatures) feature(w http.ResponseWriter, r *http.Request, collecti
 			Path: collectionsCrumb + collectionID + "/items",
 		},
 		{
-			Name: strconv.FormatInt(feat.ID, 10),
-			Path: collectionsCrumb + collectionID + "/items/" + strconv.FormatInt(feat.ID, 10),
+			Name: fmt.Sprint(feat.ID),
+			Path: collectionsCrumb + collectionID + "/items/" + fmt.Sprint(feat.ID),
 		},
 	}...)
 
@@ -1,10 +1,12 @@
 package features
 
 import (
+	"bufio"
 	"bytes"
 	stdjson "encoding/json"
 	"io"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
 	"strconv"
@@ -21,39 +23,52 @@ var (
 )
 
 type jsonFeatures struct {
-	engine           *engine.Engine
-	validateResponse bool
+	engine                      *engine.Engine
+	validateResponse            bool
+	validateResponsesPercentage int
+}
+
+// jsonMember a member (name/value pair) of a JSON object
+type jsonMember struct {
+	name  string
+	value any
 }
 
 func newJSONFeatures(e *engine.Engine) *jsonFeatures {
 	if *e.Config.OgcAPI.Features.ValidateResponses {
-		log.Println("JSON response validation is enabled (by default). When serving large feature collections " +
-			"set 'validateResponses' to 'false' to improve performance")
+		log.Printf("JSON response validation is enabled (by default) for %d%% of the responses. Validated responses "+
+			"are buffered in memory instead of streamed, when serving large feature collections keep "+
+			"'validateResponsesPercentage' low or set 'validateResponses' to 'false' to improve performance",
+			e.Config.OgcAPI.Features.ValidateResponsesPercentage)
 	}
 	return &jsonFeatures{
-		engine:           e,
-		validateResponse: *e.Config.OgcAPI.Features.ValidateResponses,
+		engine:                      e,
+		validateResponse:            *e.Config.OgcAPI.Features.ValidateResponses,
+		validateResponsesPercentage: e.Config.OgcAPI.Features.ValidateResponsesPercentage,
 	}
 }
 
 func (jf *jsonFeatures) featuresAsGeoJSON(w http.ResponseWriter, r *http.Request, collectionID string,
-	cursor domain.Cursors, featuresURL featureCollectionURL, fc *domain.FeatureCollection) {
-
-	fc.Timestamp = now().Format(time.RFC3339)
-	fc.Links = jf.createFeatureCollectionLinks(engine.FormatGeoJSON, collectionID, cursor, featuresURL)
+	cursor domain.Cursors, featuresURL featureCollectionURL, features domain.FeatureIterator, numberMatched *int) {
 
-	if jf.validateResponse {
-		jf.serveAndValidateJSON(&fc, engine.MediaTypeGeoJSON, r, w)
-	} else {
-		serveJSON(&fc, engine.MediaTypeGeoJSON, w)
+	// same members as domain.FeatureCollection
+	members := []jsonMember{
+		{"timeStamp", now().Format(time.RFC3339)},
+		{"links", jf.createFeatureCollectionLinks(engine.FormatGeoJSON, collectionID, cursor, featuresURL)},
+	}
+	if numberMatched != nil {
+		members = append(members, jsonMember{"numberMatched", *numberMatched})
 	}
+	jf.serveFeatureCollection(members, features, func(f *domain.Feature) any {
+		return f
+	}, engine.MediaTypeGeoJSON, r, w)
 }
 
 func (jf *jsonFeatures) featureAsGeoJSON(w http.ResponseWriter, r *http.Request, collectionID string,
 	feat *domain.Feature, url featureURL) {
 
 	feat.Links = jf.createFeatureLinks(engine.FormatGeoJSON, url, collectionID, feat.ID)
-	if jf.validateResponse {
+	if jf.sampleValidation() {
 		jf.serveAndValidateJSON(&feat, engine.MediaTypeGeoJSON, r, w)
 	} else {
 		serveJSON(&feat, engine.MediaTypeGeoJSON, w)
@@ -61,33 +76,28 @@ func (jf *jsonFeatures) featureAsGeoJSON(w http.ResponseWriter, r *http.Request,
 }
 
 func (jf *jsonFeatures) featuresAsJSONFG(w http.ResponseWriter, r *http.Request, collectionID string,
-	cursor domain.Cursors, featuresURL featureCollectionURL, fc *domain.FeatureCollection, crs ContentCrs) {
+	cursor domain.Cursors, featuresURL featureCollectionURL, features domain.FeatureIterator, numberMatched *int,
+	crs ContentCrs) {
 
-	fgFC := domain.JSONFGFeatureCollection{}
-	fgFC.ConformsTo = []string{domain.ConformanceJSONFGCore}
-	fgFC.CoordRefSys = string(crs)
-	if len(fc.Features) == 0 {
-		fgFC.Features = make([]*domain.JSONFGFeature, 0)
-	} else {
-		for _, f := range fc.Features {
-			fgF := domain.JSONFGFeature{
-				ID:         f.ID,
-				Links:      f.Links,
-				Properties: f.Properties,
-			}
-			setGeom(crs, &fgF, f)
-			fgFC.Features = append(fgFC.Features, &fgF)
-		}
+	// same members as domain.JSONFGFeatureCollection
+	members := []jsonMember{
+		{"timeStamp", now().Format(time.RFC3339)},
+		{"coordRefSys", string(crs)},
+		{"links", jf.createFeatureCollectionLinks(engine.FormatJSONFG, collectionID, cursor, featuresURL)},
+		{"conformsTo", []string{domain.ConformanceJSONFGCore}},
 	}
-	fgFC.NumberReturned = fc.NumberReturned
-	fgFC.Timestamp = now().Format(time.RFC3339)
-	fgFC.Links = jf.createFeatureCollectionLinks(engine.FormatJSONFG, collectionID, cursor, featuresURL)
-
-	if jf.validateResponse {
-		jf.serveAndValidateJSON(&fgFC, engine.MediaTypeJSONFG, r, w)
-	} else {
-		serveJSON(&fgFC, engine.MediaTypeJSONFG, w)
+	if numberMatched != nil {
+		members = append(members, jsonMember{"numberMatched", *numberMatched})
 	}
+	jf.serveFeatureCollection(members, features, func(f *domain.Feature) any {
+		fgF := domain.JSONFGFeature{
+			ID:         f.ID,
+			Links:      f.Links,
+			Properties: f.Properties,
+		}
+		setGeom(crs, &fgF, f)
+		return &fgF
+	}, engine.MediaTypeJSONFG, r, w)
 }
 
 func (jf *jsonFeatures) featureAsJSONFG(w http.ResponseWriter, r *http.Request, collectionID string,
@@ -103,7 +113,7 @@ func (jf *jsonFeatures) featureAsJSONFG(w http.ResponseWriter, r *http.Request,
 	setGeom(crs, &fgF, f)
 	fgF.Links = jf.createFeatureLinks(engine.FormatJSONFG, url, collectionID, fgF.ID)
 
-	if jf.validateResponse {
+	if jf.sampleValidation() {
 		jf.serveAndValidateJSON(&fgF, engine.MediaTypeJSONFG, r, w)
 	} else {
 		serveJSON(&fgF, engine.MediaTypeJSONFG, w)
@@ -191,7 +201,7 @@ func (jf *jsonFeatures) createFeatureCollectionLinks(currentFormat string, colle
 }
 
 func (jf *jsonFeatures) createFeatureLinks(currentFormat string, url featureURL,
-	collectionID string, featureID int64) []domain.Link {
+	collectionID string, featureID any) []domain.Link {
 
 	links := make([]domain.Link, 0)
 	switch currentFormat {
@@ -237,6 +247,95 @@ func (jf *jsonFeatures) createFeatureLinks(currentFormat string, url featureURL,
 	return links
 }
 
+// sampleValidation decides whether the current response should be validated against the OpenAPI spec,
+// based on the configured percentage of responses to validate.
+func (jf *jsonFeatures) sampleValidation() bool {
+	if !jf.validateResponse {
+		return false
+	}
+	return jf.validateResponsesPercentage >= 100 ||
+		rand.Intn(100) < jf.validateResponsesPercentage //nolint:gosec // no need for a cryptographically secure random number
+}
+
+// serveFeatureCollection streams the given features as a (GeoJSON or JSON-FG) feature collection to the client.
+// Unless the response is sampled for validation, then the feature collection is marshalled in-memory first in order
+// to validate it against the OpenAPI spec.
+func (jf *jsonFeatures) serveFeatureCollection(members []jsonMember, features domain.FeatureIterator,
+	toJSON func(*domain.Feature) any, contentType string, r *http.Request, w http.ResponseWriter) {
+
+	if jf.sampleValidation() {
+		json := &bytes.Buffer{}
+		if err := writeFeatureCollection(json, members, features, toJSON); err != nil {
+			handleJSONEncodingFailure(err, w)
+			return
+		}
+		jf.engine.ServeResponse(w, r, false /* performed earlier */, true, contentType, json.Bytes())
+		return
+	}
+
+	w.Header().Set(engine.HeaderContentType, contentType)
+	counter := &countingWriter{w: w}
+	if err := writeFeatureCollection(counter, members, features, toJSON); err != nil {
+		if counter.written == 0 {
+			// nothing reached the client yet (output is buffered), so we can still return a problem
+			handleJSONEncodingFailure(err, w)
+			return
+		}
+		// the response is partially written at this point, so we can't return a problem anymore
+		log.Printf("failed to stream JSON response: %v", err)
+	}
+}
+
+// countingWriter keeps track of the number of bytes written to the underlying writer
+type countingWriter struct {
+	w       io.Writer
+	written int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.written += n
+	return n, err
+}
+
+// writeFeatureCollection writes a feature collection while the features are read from the given iterator,
+// so the features are never held in memory all at once. The given members are written before the features
+// and numberReturned is written last, since it's only known after all features are written.
+func writeFeatureCollection(w io.Writer, members []jsonMember, features domain.FeatureIterator,
+	toJSON func(*domain.Feature) any) error {
+
+	// bufio.Writer retains the first write error, which is returned on Flush
+	buf := bufio.NewWriter(w)
+	encoder := getEncoder(buf)
+	_, _ = buf.WriteString(`{"type":"FeatureCollection"`)
+	for _, member := range members {
+		_, _ = buf.WriteString(`,"` + member.name + `":`)
+		if err := encoder.Encode(member.value); err != nil {
+			return err
+		}
+	}
+	_, _ = buf.WriteString(`,"features":[`)
+	numberReturned := 0
+	for {
+		feature, err := features.Next()
+		if err != nil {
+			return err
+		}
+		if feature == nil {
+			break
+		}
+		if numberReturned > 0 {
+			_ = buf.WriteByte(',')
+		}
+		if err = encoder.Encode(toJSON(feature)); err != nil {
+			return err
+		}
+		numberReturned++
+	}
+	_, _ = buf.WriteString(`],"numberReturned":` + strconv.Itoa(numberReturned) + "}\n")
+	return buf.Flush()
+}
+
 // serveAndValidateJSON serves JSON after performing OpenAPI response validation.
 // Note: this requires reading first marshalling to the result to JSON in-memory.
 func (jf *jsonFeatures) serveAndValidateJSON(input any, contentType string, r *http.Request, w http.ResponseWriter) {
@@ -283,6 +382,9 @@ func handleJSONEncodingFailure(err error, w http.ResponseWriter) {
 }
 
 func setGeom(crs ContentCrs, jsonfgFeature *domain.JSONFGFeature, feature *domain.Feature) {
+	if feature.Geometry == nil {
+		return // both geometry and place are null
+	}
 	if crs.IsWGS84() {
 		jsonfgFeature.Geometry = feature.Geometry
 	} else {
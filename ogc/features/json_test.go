@@ -0,0 +1,121 @@
+package features
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PDOK/gokoala/engine"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingFeatureIterator struct{}
+
+func (failingFeatureIterator) Next() (*domain.Feature, error) {
+	return nil, errors.New("rows failed")
+}
+
+func (failingFeatureIterator) Close() {}
+
+func TestWriteFeatureCollection(t *testing.T) {
+	members := []jsonMember{
+		{"timeStamp", "2000-01-01T00:00:00Z"},
+		{"links", []domain.Link{{Rel: "self", Href: "http://localhost:8080/collections/foo/items?f=json"}}},
+	}
+	toJSON := func(f *domain.Feature) any { return f }
+
+	t.Run("features", func(t *testing.T) {
+		features := domain.NewSliceFeatureIterator([]*domain.Feature{
+			{
+				ID:       int64(1),
+				Feature:  geojson.Feature{Properties: map[string]any{"straatnaam": "Silodam"}},
+				Geometry: &geojson.Geometry{Geometry: geom.Point{4.89, 52.38}},
+			},
+			{
+				ID:      int64(2),
+				Feature: geojson.Feature{Properties: map[string]any{"straatnaam": "Damrak"}},
+			},
+		})
+		json := &bytes.Buffer{}
+		require.NoError(t, writeFeatureCollection(json, members, features, toJSON))
+		assert.JSONEq(t, `{
+		  "type": "FeatureCollection",
+		  "timeStamp": "2000-01-01T00:00:00Z",
+		  "links": [{"rel": "self", "href": "http://localhost:8080/collections/foo/items?f=json"}],
+		  "features": [
+			{"type": "Feature", "id": 1, "properties": {"straatnaam": "Silodam"}, "geometry": {"type": "Point", "coordinates": [4.89, 52.38]}},
+			{"type": "Feature", "id": 2, "properties": {"straatnaam": "Damrak"}, "geometry": null}
+		  ],
+		  "numberReturned": 2
+		}`, json.String())
+	})
+
+	t.Run("with numberMatched", func(t *testing.T) {
+		json := &bytes.Buffer{}
+		withNumberMatched := []jsonMember{members[0], members[1], {"numberMatched", 120}}
+		require.NoError(t, writeFeatureCollection(json, withNumberMatched, domain.NewSliceFeatureIterator(nil), toJSON))
+		assert.Contains(t, json.String(), `"numberMatched":120`)
+	})
+
+	t.Run("no features", func(t *testing.T) {
+		json := &bytes.Buffer{}
+		require.NoError(t, writeFeatureCollection(json, members, domain.NewSliceFeatureIterator(nil), toJSON))
+		assert.Contains(t, json.String(), `"features":[],"numberReturned":0}`)
+	})
+
+	t.Run("failing iterator", func(t *testing.T) {
+		json := &bytes.Buffer{}
+		require.Error(t, writeFeatureCollection(json, members, failingFeatureIterator{}, toJSON))
+	})
+}
+
+func TestServeFeatureCollection(t *testing.T) {
+	newEngine, err := engine.NewEngine("ogc/features/testdata/config_features_bag.yaml", "", false, true)
+	require.NoError(t, err)
+	members := []jsonMember{{"timeStamp", "2000-01-01T00:00:00Z"}}
+	toJSON := func(f *domain.Feature) any { return f }
+
+	t.Run("streamed without validation", func(t *testing.T) {
+		jf := &jsonFeatures{engine: newEngine, validateResponse: false, validateResponsesPercentage: 100}
+		assert.False(t, jf.sampleValidation())
+
+		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/collections/foo/items", nil)
+		w := httptest.NewRecorder()
+		jf.serveFeatureCollection(members, domain.NewSliceFeatureIterator([]*domain.Feature{{ID: int64(1)}}),
+			toJSON, engine.MediaTypeGeoJSON, r, w)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, engine.MediaTypeGeoJSON, w.Header().Get(engine.HeaderContentType))
+		assert.JSONEq(t, `{"type": "FeatureCollection", "timeStamp": "2000-01-01T00:00:00Z",
+			"features": [{"type": "Feature", "id": 1, "properties": null, "geometry": null}], "numberReturned": 1}`, w.Body.String())
+	})
+
+	t.Run("problem when streamed response fails before anything is written", func(t *testing.T) {
+		jf := &jsonFeatures{engine: newEngine, validateResponse: false, validateResponsesPercentage: 100}
+		assert.False(t, jf.sampleValidation())
+
+		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/collections/foo/items", nil)
+		w := httptest.NewRecorder()
+		jf.serveFeatureCollection(members, failingFeatureIterator{}, toJSON, engine.MediaTypeGeoJSON, r, w)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NotEqual(t, engine.MediaTypeGeoJSON, w.Header().Get(engine.HeaderContentType))
+	})
+
+	t.Run("problem when validated response fails to encode", func(t *testing.T) {
+		jf := &jsonFeatures{engine: newEngine, validateResponse: true, validateResponsesPercentage: 100}
+		assert.True(t, jf.sampleValidation())
+
+		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/collections/foo/items", nil)
+		w := httptest.NewRecorder()
+		jf.serveFeatureCollection(members, failingFeatureIterator{}, toJSON, engine.MediaTypeGeoJSON, r, w)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
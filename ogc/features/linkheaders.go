@@ -0,0 +1,81 @@
+package features
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/PDOK/gokoala/engine"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+)
+
+// createLinkHeaderFeatureCollectionLinks creates links to this document, other formats and the next/prev page
+// for output formats without a links member (e.g. CSV). These links are served as Link headers.
+func createLinkHeaderFeatureCollectionLinks(currentFormat string, currentMediaType string, collectionID string,
+	cursor domain.Cursors, featuresURL featureCollectionURL) []domain.Link {
+
+	links := []domain.Link{
+		{
+			Rel:   "self",
+			Title: "This document as " + engine.OutputFormatFeatures[currentFormat],
+			Type:  currentMediaType,
+			Href:  featuresURL.toSelfURL(collectionID, currentFormat),
+		},
+		{
+			Rel:   "alternate",
+			Title: "This document as GeoJSON",
+			Type:  engine.MediaTypeGeoJSON,
+			Href:  featuresURL.toSelfURL(collectionID, engine.FormatJSON),
+		},
+	}
+	if cursor.HasNext {
+		links = append(links, domain.Link{
+			Rel:   "next",
+			Title: "Next page",
+			Type:  currentMediaType,
+			Href:  featuresURL.toPrevNextURL(collectionID, cursor.Next, currentFormat),
+		})
+	}
+	if cursor.HasPrev {
+		links = append(links, domain.Link{
+			Rel:   "prev",
+			Title: "Previous page",
+			Type:  currentMediaType,
+			Href:  featuresURL.toPrevNextURL(collectionID, cursor.Prev, currentFormat),
+		})
+	}
+	return links
+}
+
+// createLinkHeaderFeatureLinks same as createLinkHeaderFeatureCollectionLinks, but for a single feature
+func createLinkHeaderFeatureLinks(currentFormat string, currentMediaType string, url featureURL,
+	collectionID string, featureID any) []domain.Link {
+
+	return []domain.Link{
+		{
+			Rel:   "self",
+			Title: "This document as " + engine.OutputFormatFeatures[currentFormat],
+			Type:  currentMediaType,
+			Href:  url.toSelfURL(collectionID, featureID, currentFormat),
+		},
+		{
+			Rel:   "alternate",
+			Title: "This document as GeoJSON",
+			Type:  engine.MediaTypeGeoJSON,
+			Href:  url.toSelfURL(collectionID, featureID, engine.FormatJSON),
+		},
+		{
+			Rel:   "collection",
+			Title: "The collection to which this feature belongs",
+			Type:  engine.MediaTypeJSON,
+			Href:  url.toCollectionURL(collectionID, engine.FormatJSON),
+		},
+	}
+}
+
+// setLinkHeaders sets the given links as Link headers, according to RFC 8288
+func setLinkHeaders(w http.ResponseWriter, links []domain.Link) {
+	for _, link := range links {
+		w.Header().Add(engine.HeaderLink, fmt.Sprintf(`<%s>; rel="%s"; type="%s"; title="%s"`,
+			link.Href, link.Rel, link.Type, link.Title))
+	}
+}
@@ -11,9 +11,12 @@ import (
 
 	"github.com/PDOK/gokoala/engine"
 	"github.com/PDOK/gokoala/ogc/common/geospatial"
+	"github.com/PDOK/gokoala/ogc/features/cql"
 	ds "github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/datasources/geojson"
 	"github.com/PDOK/gokoala/ogc/features/datasources/geopackage"
 	"github.com/PDOK/gokoala/ogc/features/datasources/postgis"
+	"github.com/PDOK/gokoala/ogc/features/datasources/reproject"
 	"github.com/PDOK/gokoala/ogc/features/domain"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-spatial/geom"
@@ -29,8 +32,7 @@ const (
 )
 
 var (
-	collections            map[string]*config.GeoSpatialCollectionMetadata
-	emptyFeatureCollection = &domain.FeatureCollection{Features: make([]*domain.Feature, 0)}
+	collections map[string]*config.GeoSpatialCollectionMetadata
 )
 
 type DatasourceKey struct {
@@ -46,6 +48,11 @@ type DatasourceConfig struct {
 type Features struct {
 	engine      *engine.Engine
 	datasources map[DatasourceKey]ds.Datasource
+	schemas     map[string][]SchemaProperty
+
+	numberMatched *numberMatchedCache
+	// called when features of a collection are created, changed or deleted
+	changeListeners []func(collectionID string)
 
 	html *htmlFeatures
 	json *jsonFeatures
@@ -54,21 +61,57 @@ type Features struct {
 func NewFeatures(e *engine.Engine) *Features {
 	collections = cacheCollectionsMetadata(e)
 	datasources := createDatasources(e)
+	validateWritableCollections(e, datasources)
 
 	rebuildOpenAPIForFeatures(e, datasources)
+	renderQueryables(e, datasources)
+	schemas := renderSchemas(e, datasources)
 
 	f := &Features{
-		engine:      e,
-		datasources: datasources,
-		html:        newHTMLFeatures(e),
-		json:        newJSONFeatures(e),
+		engine:        e,
+		datasources:   datasources,
+		schemas:       schemas,
+		numberMatched: newNumberMatchedCache(),
+		html:          newHTMLFeatures(e),
+		json:          newJSONFeatures(e),
 	}
 
 	e.Router.Get(geospatial.CollectionsPath+"/{collectionId}/items", f.Features())
 	e.Router.Get(geospatial.CollectionsPath+"/{collectionId}/items/{featureId}", f.Feature())
+	e.Router.Get(geospatial.CollectionsPath+"/{collectionId}/queryables", f.Queryables())
+	e.Router.Get(geospatial.CollectionsPath+"/{collectionId}/schema", f.Schema())
+
+	if e.Config.OgcAPI.Features.HasWritableCollections() {
+		e.Router.Post(geospatial.CollectionsPath+"/{collectionId}/items", f.CreateFeature())
+		e.Router.Put(geospatial.CollectionsPath+"/{collectionId}/items/{featureId}", f.ReplaceFeature())
+		e.Router.Patch(geospatial.CollectionsPath+"/{collectionId}/items/{featureId}", f.UpdateFeature())
+		e.Router.Delete(geospatial.CollectionsPath+"/{collectionId}/items/{featureId}", f.DeleteFeature())
+	}
 	return f
 }
 
+// Datasource returns the datasource serving the features of the given collection in the given projection (EPSG
+// code), or nil when the features of this collection aren't available in this projection. Used by other building
+// blocks which are based on features, like vector tiles generated on-the-fly.
+func (f *Features) Datasource(collectionID string, srid int) ds.Datasource {
+	return f.datasources[DatasourceKey{srid: srid, collectionID: collectionID}]
+}
+
+// OnFeaturesChanged registers a listener which is called with the collection id when features of a (writable)
+// collection are created, changed or deleted. Used by other building blocks to invalidate derived data, like
+// cached vector tiles generated on-the-fly. Should only be called on startup, before serving requests.
+func (f *Features) OnFeaturesChanged(listener func(collectionID string)) {
+	f.changeListeners = append(f.changeListeners, listener)
+}
+
+// featuresChanged invalidates everything derived from the features of the given collection
+func (f *Features) featuresChanged(collectionID string) {
+	f.numberMatched.invalidate()
+	for _, listener := range f.changeListeners {
+		listener(collectionID)
+	}
+}
+
 // Features serve a FeatureCollection with the given collectionId
 //
 //nolint:cyclop
@@ -87,15 +130,17 @@ func (f *Features) Features() http.HandlerFunc {
 			return
 		}
 		url := featureCollectionURL{*cfg.BaseURL.URL, r.URL.Query(), cfg.OgcAPI.Features.Limit,
-			cfg.OgcAPI.Features.PropertyFiltersForCollection(collectionID), false}
+			cfg.OgcAPI.Features.PropertyFiltersForCollection(collectionID),
+			cfg.OgcAPI.Features.SortablesForCollection(collectionID), false}
 		if collection := collections[collectionID]; collection != nil && collection.TemporalProperties != nil {
 			url.supportsDatetime = true
 		}
-		encodedCursor, limit, inputSRID, outputSRID, contentCrs, bbox, referenceDate, propertyFilters, err := url.parse()
+		params, err := url.parse()
 		var temporalCriteria ds.TemporalCriteria
 		if collection := collections[collectionID]; collection != nil && collection.TemporalProperties != nil {
 			temporalCriteria = ds.TemporalCriteria{
-				ReferenceDate:     referenceDate,
+				ReferenceDate:     params.referenceDate,
+				ReferenceInterval: params.referenceInterval,
 				StartDateProperty: collection.TemporalProperties.StartDate,
 				EndDateProperty:   collection.TemporalProperties.EndDate}
 		}
@@ -103,23 +148,60 @@ func (f *Features) Features() http.HandlerFunc {
 			engine.RenderProblem(engine.ProblemBadRequest, w, err.Error())
 			return
 		}
-		w.Header().Add(engine.HeaderContentCrs, contentCrs.ToLink())
+		if err = f.validateFilter(collectionID, params.filter); err != nil {
+			engine.RenderProblem(engine.ProblemBadRequest, w, err.Error())
+			return
+		}
+		if err = f.validatePropertyFilters(collectionID, params.propertyFilters); err != nil {
+			engine.RenderProblem(engine.ProblemBadRequest, w, err.Error())
+			return
+		}
+		if err = f.validateSortBy(collectionID, params.sortBy); err != nil {
+			engine.RenderProblem(engine.ProblemBadRequest, w, err.Error())
+			return
+		}
+		if err = f.validateSelectedProperties(collectionID, params.selection); err != nil {
+			engine.RenderProblem(engine.ProblemBadRequest, w, err.Error())
+			return
+		}
+		w.Header().Add(engine.HeaderContentCrs, params.contentCrs.ToLink())
+		if f.notModified(w, r, collectionID) {
+			return
+		}
+
+		criteria := ds.FeaturesCriteria{
+			Cursor:            params.encodedCursor.Decode(url.checksum()),
+			Limit:             params.limit,
+			InputSRID:         params.inputSRID.GetOrDefault(),
+			OutputSRID:        params.outputSRID.GetOrDefault(),
+			Bbox:              params.bbox,
+			TemporalCriteria:  temporalCriteria,
+			PropertyFilters:   params.propertyFilters,
+			Filter:            params.filter,
+			SortBy:            params.sortBy,
+			PropertySelection: params.selection,
+		}
+		fastPath := querySingleDatasource(params.inputSRID, params.outputSRID, params.bbox, params.filter) ||
+			f.reprojectsInput(collectionID, params.inputSRID, params.outputSRID)
+		filterSRID := params.inputSRID
+		if fastPath {
+			filterSRID = params.outputSRID
+		}
+		// the datasource used to select the features
+		filterDatasource := f.datasources[DatasourceKey{srid: filterSRID.GetOrDefault(), collectionID: collectionID}]
+
+		// count before reading the features: a streamed feature collection holds a database connection (open cursor)
+		// until the response is written, which would starve the count on datasources with a small connection pool.
+		var numberMatched *int
+		if cfg.OgcAPI.Features.NumberMatchedForCollection(collectionID) {
+			numberMatched = f.numberMatched.get(r.Context(), filterDatasource, collectionID, url.filtersKey(), criteria)
+		}
 
 		var newCursor domain.Cursors
-		var fc *domain.FeatureCollection
-		if querySingleDatasource(inputSRID, outputSRID, bbox) {
-			// fast path
-			datasource := f.datasources[DatasourceKey{srid: outputSRID.GetOrDefault(), collectionID: collectionID}]
-			fc, newCursor, err = datasource.GetFeatures(r.Context(), collectionID, ds.FeaturesCriteria{
-				Cursor:           encodedCursor.Decode(url.checksum()),
-				Limit:            limit,
-				InputSRID:        inputSRID.GetOrDefault(),
-				OutputSRID:       outputSRID.GetOrDefault(),
-				Bbox:             bbox,
-				TemporalCriteria: temporalCriteria,
-				PropertyFilters:  propertyFilters,
-				// Add filter, filter-lang
-			})
+		var features domain.FeatureIterator
+		if fastPath {
+			// fast path, features are streamed while read from the datasource
+			features, newCursor, err = filterDatasource.StreamFeatures(r.Context(), collectionID, criteria)
 			if err != nil {
 				handleFeatureCollectionError(w, collectionID, err)
 				return
@@ -127,38 +209,53 @@ func (f *Features) Features() http.HandlerFunc {
 		} else {
 			// slower path: get feature ids by input CRS (step 1), then the actual features in output CRS (step 2)
 			var fids []int64
-			datasource := f.datasources[DatasourceKey{srid: inputSRID.GetOrDefault(), collectionID: collectionID}]
-			fids, newCursor, err = datasource.GetFeatureIDs(r.Context(), collectionID, ds.FeaturesCriteria{
-				Cursor:           encodedCursor.Decode(url.checksum()),
-				Limit:            limit,
-				InputSRID:        inputSRID.GetOrDefault(),
-				OutputSRID:       outputSRID.GetOrDefault(),
-				Bbox:             bbox,
-				TemporalCriteria: temporalCriteria,
-				PropertyFilters:  propertyFilters,
-				// Add filter, filter-lang
-			})
+			fids, newCursor, err = filterDatasource.GetFeatureIDs(r.Context(), collectionID, criteria)
+			var fc *domain.FeatureCollection
 			if err == nil && fids != nil {
-				datasource = f.datasources[DatasourceKey{srid: outputSRID.GetOrDefault(), collectionID: collectionID}]
-				fc, err = datasource.GetFeaturesByID(r.Context(), collectionID, fids)
+				datasource := f.datasources[DatasourceKey{srid: params.outputSRID.GetOrDefault(), collectionID: collectionID}]
+				fc, err = datasource.GetFeaturesByID(r.Context(), collectionID, fids, params.selection)
 			}
 			if err != nil {
 				handleFeatureCollectionError(w, collectionID, err)
 				return
 			}
+			if fc != nil {
+				features = domain.NewSliceFeatureIterator(fc.Features)
+			}
 		}
-		if fc == nil {
-			fc = emptyFeatureCollection
+		if features == nil {
+			features = domain.NewSliceFeatureIterator(nil)
 		}
+		features = newGeometryOptions(cfg.OgcAPI.Features, collectionID, params.outputSRID, params.maxAllowableOffset).wrap(features)
+		defer features.Close()
 
 		format := f.engine.CN.NegotiateFormat(r)
 		switch format {
-		case engine.FormatHTML:
-			f.html.features(w, r, collectionID, newCursor, url, limit, &referenceDate, propertyFilters, fc)
 		case engine.FormatGeoJSON, engine.FormatJSON:
-			f.json.featuresAsGeoJSON(w, r, collectionID, newCursor, url, fc)
+			f.json.featuresAsGeoJSON(w, r, collectionID, newCursor, url, features, numberMatched)
+			return
 		case engine.FormatJSONFG:
-			f.json.featuresAsJSONFG(w, r, collectionID, newCursor, url, fc, contentCrs)
+			f.json.featuresAsJSONFG(w, r, collectionID, newCursor, url, features, numberMatched, params.contentCrs)
+			return
+		}
+
+		// other formats require all features (of the current page) in memory
+		fc := &domain.FeatureCollection{NumberMatched: numberMatched}
+		if fc.Features, err = domain.ReadFeatures(features); err != nil {
+			handleFeatureCollectionError(w, collectionID, err)
+			return
+		}
+		fc.NumberReturned = len(fc.Features)
+
+		switch format {
+		case engine.FormatHTML:
+			f.html.features(w, r, collectionID, newCursor, url, params.limit, &params.referenceDate, params.propertyFilters, fc)
+		case engine.FormatCSV:
+			featuresAsCSV(w, collectionID, newCursor, url, fc)
+		case engine.FormatFlatGeobuf:
+			f.featuresAsFlatGeobuf(w, collectionID, newCursor, url, fc, params.outputSRID)
+		case engine.FormatGML:
+			f.featuresAsGML(w, collectionID, newCursor, url, fc, params.contentCrs)
 		default:
 			engine.RenderProblem(engine.ProblemNotAcceptable, w, fmt.Sprintf("format '%s' is not supported", format))
 			return
@@ -179,34 +276,40 @@ func (f *Features) Feature() http.HandlerFunc {
 			handleCollectionNotFound(w, collectionID)
 			return
 		}
-		featureID, err := strconv.Atoi(chi.URLParam(r, "featureId"))
+		featureID, err := f.parseFeatureID(collectionID, r)
 		if err != nil {
-			engine.RenderProblem(engine.ProblemBadRequest, w, "feature ID must be a number")
+			engine.RenderProblem(engine.ProblemBadRequest, w, err.Error())
 			return
 		}
 		url := featureURL{*f.engine.Config.BaseURL.URL, r.URL.Query()}
-		outputSRID, contentCrs, err := url.parse()
+		outputSRID, contentCrs, selection, err := url.parse()
 		if err != nil {
 			engine.RenderProblem(engine.ProblemBadRequest, w, err.Error())
 			return
 		}
+		if err = f.validateSelectedProperties(collectionID, selection); err != nil {
+			engine.RenderProblem(engine.ProblemBadRequest, w, err.Error())
+			return
+		}
 		w.Header().Add(engine.HeaderContentCrs, contentCrs.ToLink())
+		if f.notModified(w, r, collectionID) {
+			return
+		}
 
 		datasource := f.datasources[DatasourceKey{srid: outputSRID.GetOrDefault(), collectionID: collectionID}]
-		feat, err := datasource.GetFeature(r.Context(), collectionID, int64(featureID))
+		feat, err := datasource.GetFeature(r.Context(), collectionID, featureID, selection)
 		if err != nil {
 			// log error, but sent generic message to client to prevent possible information leakage from datasource
-			msg := fmt.Sprintf("failed to retrieve feature %d in collection %s", featureID, collectionID)
+			msg := fmt.Sprintf("failed to retrieve feature %v in collection %s", featureID, collectionID)
 			log.Printf("%s, error: %v\n", msg, err)
 			engine.RenderProblem(engine.ProblemServerError, w, msg)
 			return
 		}
 		if feat == nil {
-			msg := fmt.Sprintf("the requested feature with id: %d does not exist in collection '%s'", featureID, collectionID)
-			log.Println(msg)
-			engine.RenderProblem(engine.ProblemNotFound, w, msg)
+			handleFeatureNotFound(w, collectionID, featureID)
 			return
 		}
+		newGeometryOptions(f.engine.Config.OgcAPI.Features, collectionID, outputSRID, 0).apply(feat)
 
 		format := f.engine.CN.NegotiateFormat(r)
 		switch format {
@@ -216,6 +319,12 @@ func (f *Features) Feature() http.HandlerFunc {
 			f.json.featureAsGeoJSON(w, r, collectionID, feat, url)
 		case engine.FormatJSONFG:
 			f.json.featureAsJSONFG(w, r, collectionID, feat, url, contentCrs)
+		case engine.FormatCSV:
+			featureAsCSV(w, collectionID, feat, url)
+		case engine.FormatFlatGeobuf:
+			f.featureAsFlatGeobuf(w, collectionID, feat, url, outputSRID)
+		case engine.FormatGML:
+			f.featureAsGML(w, collectionID, feat, url, contentCrs)
 		default:
 			engine.RenderProblem(engine.ProblemNotAcceptable, w, fmt.Sprintf("format '%s' is not supported", format))
 			return
@@ -251,6 +360,7 @@ func createDatasources(e *engine.Engine) map[DatasourceKey]ds.Datasource {
 		}
 		created[k] = newDatasource(e, cfg.collections, cfg.ds)
 	}
+	configureReprojections(e, created)
 	return created
 }
 
@@ -304,12 +414,48 @@ func configureCollectionDatasources(e *engine.Engine, result map[DatasourceKey]*
 	}
 }
 
+// configureReprojections serves the features of the WGS84 datasource of each collection in other projections
+// by reprojecting on-the-fly. But only when there's no additional datasource already configured for the projection.
+func configureReprojections(e *engine.Engine, datasources map[DatasourceKey]ds.Datasource) {
+	cfg := e.Config.OgcAPI.Features
+	for _, coll := range cfg.Collections {
+		source, ok := datasources[DatasourceKey{srid: wgs84SRID, collectionID: coll.ID}]
+		if !ok {
+			continue
+		}
+		var reprojections []string
+		if coll.Features != nil && coll.Features.Datasources != nil {
+			reprojections = append(reprojections, coll.Features.Datasources.Reprojections...)
+		}
+		if cfg.Datasources != nil {
+			reprojections = append(reprojections, cfg.Datasources.Reprojections...)
+		}
+		for _, srs := range reprojections {
+			srid, err := epsgToSrid(srs)
+			if err != nil {
+				log.Fatal(err)
+			}
+			key := DatasourceKey{srid: srid, collectionID: coll.ID}
+			if _, exists := datasources[key]; exists {
+				continue
+			}
+			reprojection, err := reproject.NewReprojection(source, wgs84SRID, srid)
+			if err != nil {
+				log.Fatalf("failed to configure reprojection of collection %s, error: %v", coll.ID, err)
+			}
+			datasources[key] = reprojection
+		}
+	}
+}
+
 func newDatasource(e *engine.Engine, coll config.GeoSpatialCollections, dsConfig config.Datasource) ds.Datasource {
 	var datasource ds.Datasource
 	if dsConfig.GeoPackage != nil {
 		datasource = geopackage.NewGeoPackage(coll, *dsConfig.GeoPackage)
 	} else if dsConfig.PostGIS != nil {
-		datasource = postgis.NewPostGIS()
+		datasource = postgis.NewPostGIS(coll, *dsConfig.PostGIS)
+	} else if dsConfig.GeoJSON != nil {
+		datasource = geojson.NewGeoJSON(coll, *dsConfig.GeoJSON)
 	}
 	e.RegisterShutdownHook(datasource.Close)
 	return datasource
@@ -341,9 +487,111 @@ func handleFeatureCollectionError(w http.ResponseWriter, collectionID string, er
 	engine.RenderProblem(engine.ProblemServerError, w, msg)
 }
 
-func querySingleDatasource(input SRID, output SRID, bbox *geom.Extent) bool {
-	return bbox == nil ||
+// notModified answers a conditional request for features of the given collection with 304 Not Modified when
+// these haven't changed since, based on the last change of the feature table or the start of the server (since
+// a deployment may change the representation, e.g. templates or config), whichever is later. Returns true when
+// 304 is sent.
+func (f *Features) notModified(w http.ResponseWriter, r *http.Request, collectionID string) bool {
+	datasource := f.datasources[DatasourceKey{srid: wgs84SRID, collectionID: collectionID}]
+	metadata, err := datasource.GetFeatureTableMetadata(collectionID)
+	if err != nil {
+		return false
+	}
+	lastModified := metadata.LastModified()
+	if !lastModified.IsZero() && lastModified.Before(f.engine.Started) {
+		lastModified = f.engine.Started
+	}
+	return engine.NotModified(w, r, "", lastModified)
+}
+
+// validate that the datasource supports CQL filters and all properties used in the given filter exist
+func (f *Features) validateFilter(collectionID string, filter cql.Expression) error {
+	if filter == nil {
+		return nil
+	}
+	datasource := f.datasources[DatasourceKey{srid: wgs84SRID, collectionID: collectionID}]
+	if filterer, ok := datasource.(ds.CQLFilterer); !ok || !filterer.SupportsCQLFilter() {
+		return fmt.Errorf("filter parameter is not supported for collection '%s'", collectionID)
+	}
+	columns, err := f.featureTableColumns(collectionID)
+	if err != nil {
+		return err
+	}
+	for _, property := range cql.Properties(filter) {
+		if _, ok := columns[property]; !ok {
+			return fmt.Errorf("property '%s' used in filter doesn't exist in collection '%s'", property, collectionID)
+		}
+	}
+	return nil
+}
+
+// validate that the datasource supports prefix matching (wildcard) on the properties filtered by prefix
+func (f *Features) validatePropertyFilters(collectionID string, propertyFilters map[string]string) error {
+	datasource := f.datasources[DatasourceKey{srid: wgs84SRID, collectionID: collectionID}]
+	filterer, ok := datasource.(ds.PrefixFilterer)
+	if !ok {
+		return nil
+	}
+	for property, value := range propertyFilters {
+		if _, prefixes := ds.SplitPropertyFilter(value); len(prefixes) > 0 && !filterer.SupportsPrefixFilter(collectionID, property) {
+			return fmt.Errorf("wildcard is not supported in property filter '%s' of collection '%s', "+
+				"only for text properties", property, collectionID)
+		}
+	}
+	return nil
+}
+
+// validate that the datasource supports sorting, the sort properties are already validated against the sortables
+func (f *Features) validateSortBy(collectionID string, sortBy []ds.SortKey) error {
+	if len(sortBy) == 0 {
+		return nil
+	}
+	datasource := f.datasources[DatasourceKey{srid: wgs84SRID, collectionID: collectionID}]
+	if sorter, ok := datasource.(ds.Sorter); !ok || !sorter.SupportsSortBy() {
+		return fmt.Errorf("sortby parameter is not supported for collection '%s'", collectionID)
+	}
+	return nil
+}
+
+// validate that all properties in the given selection exist in the datasource
+func (f *Features) validateSelectedProperties(collectionID string, selection ds.PropertySelection) error {
+	if len(selection.Properties) == 0 {
+		return nil
+	}
+	columns, err := f.featureTableColumns(collectionID)
+	if err != nil {
+		return err
+	}
+	for _, property := range selection.Properties {
+		if _, ok := columns[property]; !ok {
+			return fmt.Errorf("property '%s' in properties param doesn't exist in collection '%s'", property, collectionID)
+		}
+	}
+	return nil
+}
+
+func (f *Features) featureTableColumns(collectionID string) (map[string]string, error) {
+	datasource := f.datasources[DatasourceKey{srid: wgs84SRID, collectionID: collectionID}]
+	metadata, err := datasource.GetFeatureTableMetadata(collectionID)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.ColumnsWithDataType(), nil
+}
+
+func querySingleDatasource(input SRID, output SRID, bbox *geom.Extent, filter cql.Expression) bool {
+	// geometries in bbox or filter are in the input crs (bbox-crs/filter-crs), this may differ from the output crs
+	hasSpatialInput := bbox != nil || (filter != nil && cql.HasGeometryLiteral(filter))
+	return !hasSpatialInput ||
 		int(input) == int(output) ||
 		(int(input) == undefinedSRID && int(output) == wgs84SRID) ||
 		(int(input) == wgs84SRID && int(output) == undefinedSRID)
 }
+
+// reprojectsInput returns true when the datasource for the output crs reprojects on-the-fly and is able to
+// reproject spatial input (bbox/filter) in the input crs as well, so there's no need for a second datasource
+func (f *Features) reprojectsInput(collectionID string, input SRID, output SRID) bool {
+	datasource := f.datasources[DatasourceKey{srid: output.GetOrDefault(), collectionID: collectionID}]
+	reprojection, ok := datasource.(*reproject.Reprojection)
+	return ok && reprojection.SupportsInputSRID(input.GetOrDefault())
+}
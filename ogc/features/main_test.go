@@ -2,6 +2,7 @@ package features
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"net"
 	"net/http"
@@ -14,6 +15,8 @@ import (
 	"time"
 
 	"github.com/PDOK/gokoala/engine"
+	ds "github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/datasources/reproject"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 )
@@ -113,6 +116,32 @@ func TestFeatures_CollectionContent(t *testing.T) {
 				statusCode: http.StatusBadRequest,
 			},
 		},
+		{
+			name: "Request with sortby on property that isn't sortable",
+			fields: fields{
+				configFile:   "ogc/features/testdata/config_features_bag.yaml",
+				url:          "http://localhost:8080/collections/:collectionId/items?sortby=-postcode",
+				collectionID: "foo",
+				format:       "json",
+			},
+			want: want{
+				body:       "",
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "Request with properties param containing a property that doesn't exist",
+			fields: fields{
+				configFile:   "ogc/features/testdata/config_features_bag.yaml",
+				url:          "http://localhost:8080/collections/:collectionId/items?properties=straatnaam,doesnotexist",
+				collectionID: "foo",
+				format:       "json",
+			},
+			want: want{
+				body:       "",
+				statusCode: http.StatusBadRequest,
+			},
+		},
 		{
 			name: "Request with invalid limit",
 			fields: fields{
@@ -670,6 +699,190 @@ func TestFeatures_Feature(t *testing.T) {
 	}
 }
 
+func TestFeatures_Queryables(t *testing.T) {
+	type fields struct {
+		configFile   string
+		url          string
+		collectionID string
+		format       string
+	}
+	type want struct {
+		body       string
+		statusCode int
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		want   want
+	}{
+		{
+			name: "Request queryables as JSON",
+			fields: fields{
+				configFile:   "ogc/features/testdata/config_features_bag.yaml",
+				url:          "http://localhost:8080/collections/foo/queryables",
+				collectionID: "foo",
+				format:       "json",
+			},
+			want: want{
+				body:       "ogc/features/testdata/expected_queryables_foo.json",
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name: "Request queryables as HTML",
+			fields: fields{
+				configFile:   "ogc/features/testdata/config_features_bag.yaml",
+				url:          "http://localhost:8080/collections/foo/queryables",
+				collectionID: "foo",
+				format:       "html",
+			},
+			want: want{
+				body:       "ogc/features/testdata/expected_queryables_foo_snippet.html",
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name: "Request queryables of non existing collection",
+			fields: fields{
+				configFile:   "ogc/features/testdata/config_features_bag.yaml",
+				url:          "http://localhost:8080/collections/nonexisting/queryables",
+				collectionID: "nonexisting",
+				format:       "json",
+			},
+			want: want{
+				body:       "",
+				statusCode: http.StatusNotFound,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := createRequest(tt.fields.url, tt.fields.collectionID, "", tt.fields.format)
+			if err != nil {
+				log.Fatal(err)
+			}
+			rr, ts := createMockServer()
+			defer ts.Close()
+
+			newEngine, err := engine.NewEngine(tt.fields.configFile, "", false, true)
+			assert.NoError(t, err)
+			features := NewFeatures(newEngine)
+			handler := features.Queryables()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want.statusCode, rr.Code)
+			if tt.want.body != "" {
+				expectedBody, err := os.ReadFile(tt.want.body)
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				printActual(rr)
+				switch {
+				case tt.fields.format == "json":
+					assert.JSONEq(t, string(expectedBody), rr.Body.String())
+				case tt.fields.format == "html":
+					assert.Contains(t, normalize(rr.Body.String()), normalize(string(expectedBody)))
+				default:
+					log.Fatalf("implement support to test format: %s", tt.fields.format)
+				}
+			}
+		})
+	}
+}
+
+func TestFeatures_Schema(t *testing.T) {
+	type fields struct {
+		configFile   string
+		url          string
+		collectionID string
+		format       string
+	}
+	type want struct {
+		body       string
+		statusCode int
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		want   want
+	}{
+		{
+			name: "Request schema as JSON",
+			fields: fields{
+				configFile:   "ogc/features/testdata/config_features_bag.yaml",
+				url:          "http://localhost:8080/collections/foo/schema",
+				collectionID: "foo",
+				format:       "json",
+			},
+			want: want{
+				body:       "ogc/features/testdata/expected_schema_foo.json",
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name: "Request schema as HTML",
+			fields: fields{
+				configFile:   "ogc/features/testdata/config_features_bag.yaml",
+				url:          "http://localhost:8080/collections/foo/schema",
+				collectionID: "foo",
+				format:       "html",
+			},
+			want: want{
+				body:       "ogc/features/testdata/expected_schema_foo_snippet.html",
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name: "Request schema of non existing collection",
+			fields: fields{
+				configFile:   "ogc/features/testdata/config_features_bag.yaml",
+				url:          "http://localhost:8080/collections/nonexisting/schema",
+				collectionID: "nonexisting",
+				format:       "json",
+			},
+			want: want{
+				body:       "",
+				statusCode: http.StatusNotFound,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := createRequest(tt.fields.url, tt.fields.collectionID, "", tt.fields.format)
+			if err != nil {
+				log.Fatal(err)
+			}
+			rr, ts := createMockServer()
+			defer ts.Close()
+
+			newEngine, err := engine.NewEngine(tt.fields.configFile, "", false, true)
+			assert.NoError(t, err)
+			features := NewFeatures(newEngine)
+			handler := features.Schema()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want.statusCode, rr.Code)
+			if tt.want.body != "" {
+				expectedBody, err := os.ReadFile(tt.want.body)
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				printActual(rr)
+				switch {
+				case tt.fields.format == "json":
+					assert.JSONEq(t, string(expectedBody), rr.Body.String())
+				case tt.fields.format == "html":
+					assert.Contains(t, normalize(rr.Body.String()), normalize(string(expectedBody)))
+				default:
+					log.Fatalf("implement support to test format: %s", tt.fields.format)
+				}
+			}
+		})
+	}
+}
+
 func createMockServer() (*httptest.ResponseRecorder, *httptest.Server) {
 	rr := httptest.NewRecorder()
 	l, err := net.Listen("tcp", "localhost:9095")
@@ -711,3 +924,161 @@ func printActual(rr *httptest.ResponseRecorder) {
 	log.Print(rr.Body.String()) // to ease debugging & updating expected results
 	log.Print("\n=========\n")
 }
+
+func TestConfigureReprojections(t *testing.T) {
+	newEngine, err := engine.NewEngine("ogc/features/testdata/config_features_bag_reprojected.yaml", "", false, true)
+	assert.NoError(t, err)
+
+	// use stub datasources, only the WGS84 and EPSG:3035 datasources are configured upfront
+	type stubDatasource struct{ ds.Datasource }
+	wgs84, etrs89 := &stubDatasource{}, &stubDatasource{}
+	datasources := map[DatasourceKey]ds.Datasource{
+		{srid: wgs84SRID, collectionID: "foo"}: wgs84,
+		{srid: 3035, collectionID: "foo"}:      etrs89,
+		{srid: wgs84SRID, collectionID: "bar"}: wgs84,
+	}
+	configureReprojections(newEngine, datasources)
+
+	assert.Len(t, datasources, 7)
+	assert.Same(t, etrs89, datasources[DatasourceKey{srid: 3035, collectionID: "foo"}], "additional datasource takes precedence")
+	assert.IsType(t, &reproject.Reprojection{}, datasources[DatasourceKey{srid: 28992, collectionID: "foo"}])
+	assert.IsType(t, &reproject.Reprojection{}, datasources[DatasourceKey{srid: 28992, collectionID: "bar"}])
+	assert.IsType(t, &reproject.Reprojection{}, datasources[DatasourceKey{srid: 3035, collectionID: "bar"}])
+	assert.IsType(t, &reproject.Reprojection{}, datasources[DatasourceKey{srid: 3857, collectionID: "bar"}])
+	assert.NotContains(t, datasources, DatasourceKey{srid: 3857, collectionID: "foo"})
+
+	f := &Features{engine: newEngine, datasources: datasources}
+	assert.True(t, f.reprojectsInput("bar", SRID(28992), SRID(3857)))
+	assert.True(t, f.reprojectsInput("foo", SRID(3857), SRID(28992)))
+	assert.False(t, f.reprojectsInput("foo", SRID(28992), SRID(3035)), "not reprojected on-the-fly")
+	assert.False(t, f.reprojectsInput("foo", SRID(2154), SRID(28992)), "unsupported input crs")
+}
+
+func TestFeatures_notModified(t *testing.T) {
+	newEngine, err := engine.NewEngine("ogc/features/testdata/config_features_bag.yaml", "", false, true)
+	assert.NoError(t, err)
+	newEngine.Started = time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	f := &Features{engine: newEngine, datasources: map[DatasourceKey]ds.Datasource{
+		{srid: wgs84SRID, collectionID: "foo"}: stubFeatureTableDatasource{},
+	}}
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/collections/foo/items", nil)
+	w := httptest.NewRecorder()
+	assert.False(t, f.notModified(w, r, "foo"))
+	assert.Equal(t, "Wed, 01 May 2024 12:00:00 GMT", w.Header().Get(engine.HeaderLastModified))
+
+	r.Header.Set(engine.HeaderIfModifiedSince, "Wed, 01 May 2024 12:00:00 GMT")
+	w = httptest.NewRecorder()
+	assert.True(t, f.notModified(w, r, "foo"))
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// deployed after the last change of the features, e.g. with different templates
+	newEngine.Started = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	w = httptest.NewRecorder()
+	assert.False(t, f.notModified(w, r, "foo"))
+	assert.Equal(t, "Sat, 01 Jun 2024 12:00:00 GMT", w.Header().Get(engine.HeaderLastModified))
+}
+
+// stubPrefixFilterer supports prefix matching on text properties only, like PostGIS
+type stubPrefixFilterer struct{ ds.Datasource }
+
+func (stubPrefixFilterer) SupportsPrefixFilter(_ string, property string) bool {
+	return property == "straatnaam"
+}
+
+func TestFeatures_validatePropertyFilters(t *testing.T) {
+	f := &Features{datasources: map[DatasourceKey]ds.Datasource{
+		{srid: wgs84SRID, collectionID: "foo"}: stubPrefixFilterer{},
+		{srid: wgs84SRID, collectionID: "bar"}: stubFeatureTableDatasource{},
+	}}
+
+	assert.NoError(t, f.validatePropertyFilters("foo", map[string]string{"straatnaam": "Silo*", "huisnummer": "12"}))
+	assert.ErrorContains(t, f.validatePropertyFilters("foo", map[string]string{"huisnummer": "12,13*"}),
+		"wildcard is not supported in property filter 'huisnummer'")
+	assert.NoError(t, f.validatePropertyFilters("bar", map[string]string{"huisnummer": "12*"}), "supported by default")
+}
+
+func TestFeatures_GeoJSONDatasource(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		wantStatusCode int
+		wantIDs        []float64
+		wantNext       bool
+	}{
+		{
+			name:           "first page",
+			url:            "http://localhost:8080/collections/addresses/items?limit=3",
+			wantStatusCode: http.StatusOK,
+			wantIDs:        []float64{1, 2, 3},
+			wantNext:       true,
+		},
+		{
+			name:           "bbox",
+			url:            "http://localhost:8080/collections/addresses/items?bbox=4.890,52.385,4.8936,52.388",
+			wantStatusCode: http.StatusOK,
+			wantIDs:        []float64{1, 2, 3},
+		},
+		{
+			name:           "property filter",
+			url:            "http://localhost:8080/collections/addresses/items?straatnaam=Damrak",
+			wantStatusCode: http.StatusOK,
+			wantIDs:        []float64{5, 6},
+		},
+		{
+			name:           "temporal filter",
+			url:            "http://localhost:8080/collections/addresses/items?datetime=2011-01-01T00:00:00Z",
+			wantStatusCode: http.StatusOK,
+			wantIDs:        []float64{1, 5, 6},
+		},
+		{
+			name:           "CQL filter not supported",
+			url:            "http://localhost:8080/collections/addresses/items?filter=straatnaam%3D%27Damrak%27",
+			wantStatusCode: http.StatusBadRequest,
+			wantIDs:        []float64{},
+		},
+		{
+			name:           "sortby not supported",
+			url:            "http://localhost:8080/collections/addresses/items?sortby=straatnaam",
+			wantStatusCode: http.StatusBadRequest,
+			wantIDs:        []float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := createRequest(tt.url, "addresses", "", "json")
+			if err != nil {
+				log.Fatal(err)
+			}
+			rr, ts := createMockServer()
+			defer ts.Close()
+
+			newEngine, err := engine.NewEngine("ogc/features/testdata/config_features_geojson.yaml", "", false, true)
+			assert.NoError(t, err)
+			features := NewFeatures(newEngine)
+			handler := features.Features()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantStatusCode, rr.Code)
+			var fc struct {
+				Features []struct {
+					ID float64 `json:"id"`
+				} `json:"features"`
+				Links []struct {
+					Rel string `json:"rel"`
+				} `json:"links"`
+			}
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &fc))
+			ids := make([]float64, 0, len(fc.Features))
+			for _, feature := range fc.Features {
+				ids = append(ids, feature.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+			hasNext := false
+			for _, link := range fc.Links {
+				hasNext = hasNext || link.Rel == "next"
+			}
+			assert.Equal(t, tt.wantNext, hasNext)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package features
+
+import (
+	"context"
+	"log"
+	"time"
+
+	ds "github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+const (
+	numberMatchedCacheSize = 1000
+	numberMatchedCacheTTL  = 10 * time.Minute
+
+	// counting features can be slow for large collections, since numberMatched
+	// is optional we rather omit it than delay the response for too long.
+	numberMatchedTimeout = 5 * time.Second
+)
+
+// numberMatchedCache caches the number of features matching the filters of a request (numberMatched), by
+// collection and filters key. This way the (expensive) count is only performed once when paging through
+// a feature collection, since the filters key is the same for each page.
+type numberMatchedCache struct {
+	counts *expirable.LRU[string, int]
+}
+
+func newNumberMatchedCache() *numberMatchedCache {
+	return &numberMatchedCache{
+		counts: expirable.NewLRU[string, int](numberMatchedCacheSize, nil, numberMatchedCacheTTL),
+	}
+}
+
+// get returns the number of features matching the given criteria, from cache when available. Returns nil when
+// the number of features couldn't be determined (e.g. on timeout), in which case numberMatched should be omitted.
+func (c *numberMatchedCache) get(ctx context.Context, datasource ds.Datasource, collectionID string,
+	filtersKey string, criteria ds.FeaturesCriteria) *int {
+
+	key := collectionID + "?" + filtersKey
+	if count, ok := c.counts.Get(key); ok {
+		return &count
+	}
+
+	countCtx, cancel := context.WithTimeout(ctx, numberMatchedTimeout)
+	defer cancel()
+
+	count, err := datasource.CountFeatures(countCtx, collectionID, criteria)
+	if err != nil {
+		log.Printf("failed to count features in collection %s, omitting numberMatched. Error: %v", collectionID, err)
+		return nil
+	}
+	c.counts.Add(key, count)
+	return &count
+}
+
+// invalidate removes all cached counts, to be called when features are created, changed or deleted
+func (c *numberMatchedCache) invalidate() {
+	c.counts.Purge()
+}
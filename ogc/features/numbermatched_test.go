@@ -0,0 +1,130 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/PDOK/gokoala/engine"
+	ds "github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingDatasource struct {
+	ds.Datasource
+	count int
+	err   error
+	calls int
+}
+
+func (c *countingDatasource) CountFeatures(_ context.Context, _ string, _ ds.FeaturesCriteria) (int, error) {
+	c.calls++
+	return c.count, c.err
+}
+
+// singleConnectionDatasource mimics a datasource with a connection pool of size 1: the connection
+// is in use while streamed features are read, so counting features meanwhile fails.
+type singleConnectionDatasource struct {
+	ds.Datasource
+	inUse bool
+}
+
+type releasingIterator struct {
+	domain.FeatureIterator
+	release func()
+}
+
+func (r releasingIterator) Close() {
+	r.FeatureIterator.Close()
+	r.release()
+}
+
+func (s *singleConnectionDatasource) StreamFeatures(ctx context.Context, collection string,
+	criteria ds.FeaturesCriteria) (domain.FeatureIterator, domain.Cursors, error) {
+
+	features, cursors, err := s.Datasource.StreamFeatures(ctx, collection, criteria)
+	if err != nil {
+		return nil, cursors, err
+	}
+	s.inUse = true
+	return releasingIterator{features, func() { s.inUse = false }}, cursors, nil
+}
+
+func (s *singleConnectionDatasource) CountFeatures(ctx context.Context, collection string, criteria ds.FeaturesCriteria) (int, error) {
+	if s.inUse {
+		return 0, context.DeadlineExceeded
+	}
+	return s.Datasource.CountFeatures(ctx, collection, criteria)
+}
+
+func TestFeatures_NumberMatchedWithStreamedFeatures(t *testing.T) {
+	newEngine, err := engine.NewEngine("ogc/features/testdata/config_features_geojson.yaml", "", false, true)
+	require.NoError(t, err)
+	newEngine.Config.OgcAPI.Features.Collections[0].Features.NumberMatched = true
+	features := NewFeatures(newEngine)
+	for key, datasource := range features.datasources {
+		features.datasources[key] = &singleConnectionDatasource{Datasource: datasource}
+	}
+
+	req, err := createRequest("http://localhost:8080/collections/addresses/items?limit=2", "addresses", "", "json")
+	require.NoError(t, err)
+	rr, ts := createMockServer()
+	defer ts.Close()
+	features.Features().ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var fc struct {
+		NumberMatched  *int `json:"numberMatched"`
+		NumberReturned int  `json:"numberReturned"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &fc))
+	require.NotNil(t, fc.NumberMatched, "numberMatched should be counted before features are streamed")
+	assert.Greater(t, *fc.NumberMatched, fc.NumberReturned)
+}
+
+func TestFeatures_OnFeaturesChanged(t *testing.T) {
+	f := &Features{numberMatched: newNumberMatchedCache()}
+	f.numberMatched.counts.Add("foo?", 42)
+	var changed []string
+	f.OnFeaturesChanged(func(collectionID string) { changed = append(changed, collectionID) })
+
+	f.featuresChanged("foo")
+	assert.Equal(t, []string{"foo"}, changed)
+	assert.Equal(t, 0, f.numberMatched.counts.Len())
+}
+
+func TestNumberMatchedCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("count is cached per collection and filters key", func(t *testing.T) {
+		cache := newNumberMatchedCache()
+		datasource := &countingDatasource{count: 42}
+
+		numberMatched := cache.get(ctx, datasource, "foo", "a=1&b=23", ds.FeaturesCriteria{})
+		require.NotNil(t, numberMatched)
+		assert.Equal(t, 42, *numberMatched)
+		cache.get(ctx, datasource, "foo", "a=1&b=23", ds.FeaturesCriteria{})
+		assert.Equal(t, 1, datasource.calls)
+
+		cache.get(ctx, datasource, "foo", "a=12&b=3", ds.FeaturesCriteria{})
+		cache.get(ctx, datasource, "bar", "a=1&b=23", ds.FeaturesCriteria{})
+		assert.Equal(t, 3, datasource.calls)
+
+		cache.invalidate()
+		cache.get(ctx, datasource, "foo", "a=1&b=23", ds.FeaturesCriteria{})
+		assert.Equal(t, 4, datasource.calls)
+	})
+
+	t.Run("failed count is omitted and not cached", func(t *testing.T) {
+		cache := newNumberMatchedCache()
+		datasource := &countingDatasource{err: errors.New("context deadline exceeded")}
+
+		assert.Nil(t, cache.get(ctx, datasource, "foo", "", ds.FeaturesCriteria{}))
+		assert.Nil(t, cache.get(ctx, datasource, "foo", "", ds.FeaturesCriteria{}))
+		assert.Equal(t, 2, datasource.calls)
+	})
+}
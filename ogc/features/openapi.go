@@ -73,12 +73,14 @@ func createPropertyFiltersByCollection(config *config.OgcAPIFeatures,
 // translate database data types to OpenAPI data types
 func datasourceToOpenAPI(dataType string) string {
 	switch strings.ToUpper(dataType) {
-	case "INTEGER":
+	case "INTEGER", "SMALLINT", "BIGINT":
 		dataType = "integer"
-	case "REAL", "NUMERIC":
+	case "REAL", "NUMERIC", "DOUBLE PRECISION":
 		dataType = "number"
-	case "TEXT", "VARCHAR":
+	case "TEXT", "VARCHAR", "CHARACTER VARYING":
 		dataType = "string"
+	case "BOOLEAN":
+		dataType = "boolean"
 	default:
 		dataType = "string"
 	}
@@ -0,0 +1,130 @@
+package features
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/PDOK/gokoala/config"
+	"github.com/PDOK/gokoala/engine"
+	ds "github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/go-chi/chi/v5"
+)
+
+// Queryable a property of a feature collection that can be used in filters,
+// see https://docs.ogc.org/DRAFTS/19-079r1.html#queryables
+type Queryable struct {
+	Name        string
+	Description string
+	DataType    string // JSON Schema type, empty for geometries
+	Format      string // JSON Schema format (e.g. date, geometry-point), optional
+}
+
+// queryablesPage queryables of a single collection for JSON/HTML representation.
+type queryablesPage struct {
+	CollectionID string
+	Metadata     *config.GeoSpatialCollectionMetadata
+	Queryables   []Queryable
+}
+
+// Queryables serve the queryables (as JSON Schema) of the given collectionId
+func (f *Features) Queryables() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		collectionID := chi.URLParam(r, "collectionId")
+
+		key := engine.NewTemplateKeyWithNameAndLanguage(templatesDir+"queryables.go."+f.engine.CN.NegotiateFormat(r),
+			collectionID, f.engine.CN.NegotiateLanguage(w, r))
+		f.engine.ServePage(w, r, key)
+	}
+}
+
+// renderQueryables pre-renders the queryables of each collection, since these are static
+func renderQueryables(e *engine.Engine, datasources map[DatasourceKey]ds.Datasource) {
+	queryablesByCollection := createQueryablesByCollection(e.Config.OgcAPI.Features, datasources)
+	for _, coll := range e.Config.OgcAPI.Features.Collections {
+		queryables, ok := queryablesByCollection[coll.ID]
+		if !ok {
+			continue
+		}
+		breadcrumbs := collectionsBreadcrumb
+		breadcrumbs = append(breadcrumbs, []engine.Breadcrumb{
+			{
+				Name: getCollectionTitle(coll.ID, coll.Metadata),
+				Path: collectionsCrumb + coll.ID,
+			},
+			{
+				Name: "Queryables",
+				Path: collectionsCrumb + coll.ID + "/queryables",
+			},
+		}...)
+		page := &queryablesPage{
+			CollectionID: coll.ID,
+			Metadata:     coll.Metadata,
+			Queryables:   queryables,
+		}
+		e.RenderTemplatesWithParams(page,
+			nil,
+			engine.NewTemplateKeyWithName(templatesDir+"queryables.go.json", coll.ID))
+		e.RenderTemplatesWithParams(page,
+			breadcrumbs,
+			engine.NewTemplateKeyWithName(templatesDir+"queryables.go.html", coll.ID))
+	}
+}
+
+// createQueryablesByCollection all columns in the feature table are queryable (using CQL), property
+// filters configured for the collection are also queryable and provide a description.
+func createQueryablesByCollection(config *config.OgcAPIFeatures,
+	datasources map[DatasourceKey]ds.Datasource) map[string][]Queryable {
+
+	result := make(map[string][]Queryable)
+	for k, datasource := range datasources {
+		if k.srid != wgs84SRID {
+			continue // all projections share the same columns, so WGS84 suffices
+		}
+		featTable, err := datasource.GetFeatureTableMetadata(k.collectionID)
+		if err != nil {
+			continue
+		}
+		descriptions := make(map[string]string)
+		for _, pf := range config.PropertyFiltersForCollection(k.collectionID) {
+			descriptions[pf.Name] = pf.Description
+		}
+		featTableColumns := featTable.ColumnsWithDataType()
+		queryables := make([]Queryable, 0, len(featTableColumns))
+		for name, dataType := range featTableColumns {
+			switch name {
+			case "minx", "miny", "maxx", "maxy", "min_zoom", "max_zoom":
+				// Skip these columns used for bounding box and zoom filtering, like the feature mapper does
+				continue
+			}
+			queryables = append(queryables, newQueryable(name, descriptions[name], dataType))
+		}
+		sort.Slice(queryables, func(i, j int) bool {
+			return queryables[i].Name < queryables[j].Name
+		})
+		result[k.collectionID] = queryables
+	}
+	return result
+}
+
+func newQueryable(name string, description string, dataType string) Queryable {
+	jsonType, format := toJSONSchemaTypeAndFormat(dataType)
+	return Queryable{Name: name, Description: description, DataType: jsonType, Format: format}
+}
+
+// toJSONSchemaTypeAndFormat maps a datasource specific data type to a JSON Schema type and format.
+// Geometries have no JSON Schema type, only a format (e.g. geometry-point).
+func toJSONSchemaTypeAndFormat(dataType string) (jsonType string, format string) {
+	switch strings.ToUpper(dataType) {
+	case "POINT", "LINESTRING", "POLYGON", "MULTIPOINT", "MULTILINESTRING", "MULTIPOLYGON", "GEOMETRYCOLLECTION":
+		return "", "geometry-" + strings.ToLower(dataType)
+	case "GEOMETRY":
+		return "", "geometry-any"
+	case "DATE":
+		return datasourceToOpenAPI(dataType), "date"
+	case "DATETIME", "TIMESTAMP", "TIMESTAMP WITH TIME ZONE", "TIMESTAMP WITHOUT TIME ZONE":
+		return datasourceToOpenAPI(dataType), "date-time"
+	default:
+		return datasourceToOpenAPI(dataType), ""
+	}
+}
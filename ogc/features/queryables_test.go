@@ -0,0 +1,60 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/PDOK/gokoala/config"
+	ds "github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/datasources/geopackage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateQueryablesByCollection(t *testing.T) {
+	eng, err := config.NewConfig("ogc/features/testdata/config_features_bag.yaml")
+	assert.NoError(t, err)
+	oaf := eng.OgcAPI.Features
+
+	datasources := map[DatasourceKey]ds.Datasource{
+		DatasourceKey{srid: wgs84SRID, collectionID: "foo"}: geopackage.NewGeoPackage(oaf.Collections, *oaf.Datasources.DefaultWGS84.GeoPackage),
+	}
+	result := createQueryablesByCollection(oaf, datasources)
+
+	assert.Len(t, result, 1)
+	assert.Equal(t, []Queryable{
+		{Name: "datum_doc", DataType: "string"},
+		{Name: "datum_eind", DataType: "string"},
+		{Name: "datum_strt", DataType: "string"},
+		{Name: "document", DataType: "string"},
+		{Name: "feature_id", DataType: "integer"},
+		{Name: "geom", Format: "geometry-point"},
+		{Name: "huisletter", DataType: "string"},
+		{Name: "huisnummer", DataType: "integer"},
+		{Name: "nummer_id", DataType: "string"},
+		{Name: "postcode", Description: "Filter features by this property", DataType: "string"},
+		{Name: "rdf_seealso", DataType: "string"},
+		{Name: "status", DataType: "string"},
+		{Name: "straatnaam", Description: "Filter features by this property", DataType: "string"},
+		{Name: "toevoeging", DataType: "string"},
+		{Name: "type", DataType: "string"},
+		{Name: "woonplaats", DataType: "string"},
+	}, result["foo"])
+}
+
+func TestNewQueryable(t *testing.T) {
+	tests := []struct {
+		dataType string
+		want     Queryable
+	}{
+		{dataType: "INTEGER", want: Queryable{Name: "foo", DataType: "integer"}},
+		{dataType: "character varying", want: Queryable{Name: "foo", DataType: "string"}},
+		{dataType: "DATE", want: Queryable{Name: "foo", DataType: "string", Format: "date"}},
+		{dataType: "timestamp with time zone", want: Queryable{Name: "foo", DataType: "string", Format: "date-time"}},
+		{dataType: "MULTIPOLYGON", want: Queryable{Name: "foo", Format: "geometry-multipolygon"}},
+		{dataType: "GEOMETRY", want: Queryable{Name: "foo", Format: "geometry-any"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dataType, func(t *testing.T) {
+			assert.Equal(t, tt.want, newQueryable("foo", "", tt.dataType))
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package features
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/PDOK/gokoala/config"
+	"github.com/PDOK/gokoala/engine"
+	ds "github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/go-chi/chi/v5"
+)
+
+// OGC roles of feature properties (x-ogc-role), as defined in OGC API Features part 5 (schemas)
+const (
+	roleID                   = "id"
+	rolePrimaryGeometry      = "primary-geometry"
+	rolePrimaryIntervalStart = "primary-interval-start"
+	rolePrimaryIntervalEnd   = "primary-interval-end"
+)
+
+// SchemaProperty a property of the features in a feature collection, as described in the feature schema
+type SchemaProperty struct {
+	Name        string
+	Description string
+	DataType    string // JSON Schema type, empty for geometries
+	Format      string // JSON Schema format (e.g. date, geometry-point), optional
+	Role        string // OGC role (e.g. id, primary-geometry), optional
+}
+
+// XSDType returns the XML Schema (or GML property) type of this property, as used in the GML application schema.
+// Only the types allowed by the GML simple features profile (level 0) are used.
+func (p SchemaProperty) XSDType() string {
+	switch p.Format {
+	case "geometry-point":
+		return "gml:PointPropertyType"
+	case "geometry-linestring":
+		return "gml:CurvePropertyType"
+	case "geometry-polygon":
+		return "gml:SurfacePropertyType"
+	case "geometry-multipoint":
+		return "gml:MultiPointPropertyType"
+	case "geometry-multilinestring":
+		return "gml:MultiCurvePropertyType"
+	case "geometry-multipolygon":
+		return "gml:MultiSurfacePropertyType"
+	case "geometry-geometrycollection":
+		return "gml:MultiGeometryPropertyType"
+	case "geometry-any":
+		return "gml:GeometryPropertyType"
+	case "date":
+		return "xs:date"
+	case "date-time":
+		return "xs:dateTime"
+	}
+	switch p.DataType {
+	case "integer":
+		return "xs:integer"
+	case "number":
+		return "xs:double"
+	case "boolean":
+		return "xs:boolean"
+	default:
+		return "xs:string"
+	}
+}
+
+// schemaPage feature schema of a single collection for JSON/HTML representation.
+type schemaPage struct {
+	CollectionID string
+	Metadata     *config.GeoSpatialCollectionMetadata
+	Properties   []SchemaProperty
+}
+
+// Schema serve the schema (as JSON Schema or GML application schema) of the features in the given collectionId
+func (f *Features) Schema() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		collectionID := chi.URLParam(r, "collectionId")
+
+		key := engine.NewTemplateKeyWithNameAndLanguage(templatesDir+"schema.go."+f.engine.CN.NegotiateFormat(r),
+			collectionID, f.engine.CN.NegotiateLanguage(w, r))
+		f.engine.ServePage(w, r, key)
+	}
+}
+
+// renderSchemas pre-renders the feature schema of each collection, since these are static.
+// Returns the schemas by collection, since these are also used to encode features as GML.
+func renderSchemas(e *engine.Engine, datasources map[DatasourceKey]ds.Datasource) map[string][]SchemaProperty {
+	schemasByCollection := createSchemasByCollection(e.Config.OgcAPI.Features, datasources)
+	for _, coll := range e.Config.OgcAPI.Features.Collections {
+		properties, ok := schemasByCollection[coll.ID]
+		if !ok {
+			continue
+		}
+		breadcrumbs := collectionsBreadcrumb
+		breadcrumbs = append(breadcrumbs, []engine.Breadcrumb{
+			{
+				Name: getCollectionTitle(coll.ID, coll.Metadata),
+				Path: collectionsCrumb + coll.ID,
+			},
+			{
+				Name: "Schema",
+				Path: collectionsCrumb + coll.ID + "/schema",
+			},
+		}...)
+		page := &schemaPage{
+			CollectionID: coll.ID,
+			Metadata:     coll.Metadata,
+			Properties:   properties,
+		}
+		e.RenderTemplatesWithParams(page,
+			nil,
+			engine.NewTemplateKeyWithName(templatesDir+"schema.go.json", coll.ID))
+		e.RenderTemplatesWithParams(page,
+			breadcrumbs,
+			engine.NewTemplateKeyWithName(templatesDir+"schema.go.html", coll.ID))
+		e.RenderTemplatesWithParams(page,
+			nil,
+			engine.NewTemplateKeyWithName(templatesDir+"schema.go.xsd", coll.ID))
+	}
+	return schemasByCollection
+}
+
+// createSchemasByCollection describes the properties of the features in each collection based on
+// the columns in the feature table. The feature id (the external fid when configured), geometry and temporal
+// properties receive an OGC role.
+func createSchemasByCollection(config *config.OgcAPIFeatures,
+	datasources map[DatasourceKey]ds.Datasource) map[string][]SchemaProperty {
+
+	result := make(map[string][]SchemaProperty)
+	for k, datasource := range datasources {
+		if k.srid != wgs84SRID {
+			continue // all projections share the same columns, so WGS84 suffices
+		}
+		featTable, err := datasource.GetFeatureTableMetadata(k.collectionID)
+		if err != nil {
+			continue
+		}
+		geomColumn := featTable.GeometryColumn()
+		idColumn := featTable.IDColumn()
+		externalFidColumn := config.ExternalFidForCollection(k.collectionID)
+		if externalFidColumn != "" {
+			idColumn = externalFidColumn
+		}
+		roles := map[string]string{
+			idColumn:        roleID,
+			geomColumn.Name: rolePrimaryGeometry,
+		}
+		for _, coll := range config.Collections {
+			if coll.ID == k.collectionID && coll.Metadata != nil && coll.Metadata.TemporalProperties != nil {
+				roles[coll.Metadata.TemporalProperties.StartDate] = rolePrimaryIntervalStart
+				roles[coll.Metadata.TemporalProperties.EndDate] = rolePrimaryIntervalEnd
+			}
+		}
+
+		featTableColumns := featTable.ColumnsWithDataType()
+		properties := make([]SchemaProperty, 0, len(featTableColumns))
+		for name, dataType := range featTableColumns {
+			if externalFidColumn != "" && name == featTable.IDColumn() {
+				continue // the internal fid isn't exposed when an external fid is used
+			}
+			switch name {
+			case "minx", "miny", "maxx", "maxy", "min_zoom", "max_zoom":
+				// Skip these columns used for bounding box and zoom filtering, like the feature mapper does
+				continue
+			}
+			property := SchemaProperty{Name: name, Role: roles[name]}
+			if name == geomColumn.Name {
+				_, property.Format = toJSONSchemaTypeAndFormat(geomColumn.Type)
+				property.Description = fmt.Sprintf("%s geometry, stored in EPSG:%d", geomColumn.Type, geomColumn.SRID)
+			} else {
+				property.DataType, property.Format = toJSONSchemaTypeAndFormat(dataType)
+			}
+			properties = append(properties, property)
+		}
+		sort.Slice(properties, func(i, j int) bool {
+			return properties[i].Name < properties[j].Name
+		})
+		result[k.collectionID] = properties
+	}
+	return result
+}
@@ -0,0 +1,28 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/PDOK/gokoala/config"
+	ds "github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/datasources/geopackage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateSchemasByCollection(t *testing.T) {
+	eng, err := config.NewConfig("ogc/features/testdata/config_features_bag.yaml")
+	assert.NoError(t, err)
+	oaf := eng.OgcAPI.Features
+
+	datasources := map[DatasourceKey]ds.Datasource{
+		DatasourceKey{srid: wgs84SRID, collectionID: "foo"}: geopackage.NewGeoPackage(oaf.Collections, *oaf.Datasources.DefaultWGS84.GeoPackage),
+	}
+	result := createSchemasByCollection(oaf, datasources)
+
+	assert.Len(t, result, 1)
+	assert.Contains(t, result["foo"], SchemaProperty{Name: "feature_id", DataType: "integer", Role: "id"})
+	assert.Contains(t, result["foo"], SchemaProperty{Name: "geom", Format: "geometry-point", Role: "primary-geometry",
+		Description: "POINT geometry, stored in EPSG:28992"})
+	assert.Contains(t, result["foo"], SchemaProperty{Name: "straatnaam", DataType: "string"})
+	assert.NotContains(t, result["foo"], SchemaProperty{Name: "minx", DataType: "number"})
+}
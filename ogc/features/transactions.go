@@ -0,0 +1,208 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/PDOK/gokoala/engine"
+	ds "github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/datasources/reproject"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateFeature creates a new feature in the given collectionId (OAF Part 4)
+func (f *Features) CreateFeature() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		collectionID, writer, ok := f.writableCollection(w, r)
+		if !ok {
+			return
+		}
+		feature, ok := f.readFeature(w, r)
+		if !ok {
+			return
+		}
+		if f.engine.Config.OgcAPI.Features.ExternalFidForCollection(collectionID) != "" && feature.ID == nil {
+			engine.RenderProblem(engine.ProblemBadRequest, w, "feature id is required, since collection "+
+				collectionID+" uses external feature ids")
+			return
+		}
+
+		featureID, err := writer.CreateFeature(r.Context(), collectionID, feature)
+		if err != nil {
+			handleFeatureWriteError(w, "create", collectionID, err)
+			return
+		}
+		f.featuresChanged(collectionID)
+		w.Header().Set("Location", f.engine.Config.BaseURL.JoinPath(
+			"collections", collectionID, "items", fmt.Sprint(featureID)).String())
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// ReplaceFeature replaces an existing feature in the given collectionId (OAF Part 4)
+func (f *Features) ReplaceFeature() http.HandlerFunc {
+	return f.modifyFeature("replace", ds.DatasourceWriter.ReplaceFeature)
+}
+
+// UpdateFeature updates the given properties and/or geometry of an existing feature in the given collectionId (OAF Part 4)
+func (f *Features) UpdateFeature() http.HandlerFunc {
+	return f.modifyFeature("update", ds.DatasourceWriter.UpdateFeature)
+}
+
+// DeleteFeature deletes an existing feature in the given collectionId (OAF Part 4)
+func (f *Features) DeleteFeature() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		collectionID, writer, ok := f.writableCollection(w, r)
+		if !ok {
+			return
+		}
+		featureID, err := f.parseFeatureID(collectionID, r)
+		if err != nil {
+			engine.RenderProblem(engine.ProblemBadRequest, w, err.Error())
+			return
+		}
+
+		found, err := writer.DeleteFeature(r.Context(), collectionID, featureID)
+		if err != nil {
+			handleFeatureWriteError(w, "delete", collectionID, err)
+			return
+		}
+		if !found {
+			handleFeatureNotFound(w, collectionID, featureID)
+			return
+		}
+		f.featuresChanged(collectionID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *Features) modifyFeature(operation string,
+	modify func(ds.DatasourceWriter, context.Context, string, any, *domain.Feature) (bool, error)) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		collectionID, writer, ok := f.writableCollection(w, r)
+		if !ok {
+			return
+		}
+		featureID, err := f.parseFeatureID(collectionID, r)
+		if err != nil {
+			engine.RenderProblem(engine.ProblemBadRequest, w, err.Error())
+			return
+		}
+		feature, ok := f.readFeature(w, r)
+		if !ok {
+			return
+		}
+
+		found, err := modify(writer, r.Context(), collectionID, featureID, feature)
+		if err != nil {
+			handleFeatureWriteError(w, operation, collectionID, err)
+			return
+		}
+		if !found {
+			handleFeatureNotFound(w, collectionID, featureID)
+			return
+		}
+		f.featuresChanged(collectionID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// writableCollection returns the collection and the datasource to write features to, renders
+// a problem when the collection doesn't exist or isn't writable.
+func (f *Features) writableCollection(w http.ResponseWriter, r *http.Request) (string, ds.DatasourceWriter, bool) {
+	if err := f.engine.OpenAPI.ValidateRequest(r); err != nil {
+		engine.RenderProblem(engine.ProblemBadRequest, w, err.Error())
+		return "", nil, false
+	}
+	collectionID := chi.URLParam(r, "collectionId")
+	if _, ok := collections[collectionID]; !ok {
+		handleCollectionNotFound(w, collectionID)
+		return "", nil, false
+	}
+	writer, ok := f.datasources[DatasourceKey{srid: wgs84SRID, collectionID: collectionID}].(ds.DatasourceWriter)
+	if !ok || !f.engine.Config.OgcAPI.Features.IsWritableCollection(collectionID) {
+		engine.RenderProblem(engine.ProblemMethodNotAllowed, w, fmt.Sprintf("collection %s isn't writable", collectionID))
+		return "", nil, false
+	}
+	return collectionID, writer, true
+}
+
+// readFeature reads the GeoJSON feature in the request body, renders a problem when the body isn't a valid
+// GeoJSON feature or exceeds the max size. The properties of the feature are validated by the datasource.
+func (f *Features) readFeature(w http.ResponseWriter, r *http.Request) (*domain.Feature, bool) {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, f.engine.Config.OgcAPI.Features.MaxRequestBodySize))
+	decoder.UseNumber() // don't lose precision of (large) integers by decoding these as float64
+	var feature domain.Feature
+	if err := decoder.Decode(&feature); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			engine.RenderProblem(engine.ProblemPayloadTooLarge, w,
+				fmt.Sprintf("request body exceeds the maximum size of %d bytes", maxBytesErr.Limit))
+			return nil, false
+		}
+		engine.RenderProblem(engine.ProblemBadRequest, w, "request body doesn't contain a valid GeoJSON feature")
+		return nil, false
+	}
+	return &feature, true
+}
+
+// parseFeatureID returns the external feature id (when configured) or the internal (numeric) feature id
+func (f *Features) parseFeatureID(collectionID string, r *http.Request) (any, error) {
+	featureID := chi.URLParam(r, "featureId")
+	if f.engine.Config.OgcAPI.Features.ExternalFidForCollection(collectionID) != "" {
+		return featureID, nil
+	}
+	fid, err := strconv.Atoi(featureID)
+	if err != nil {
+		return nil, errors.New("feature ID must be a number")
+	}
+	return int64(fid), nil
+}
+
+// validateWritableCollections asserts writable collections are backed by a single datasource which supports
+// writes, since features are only written in WGS84 and datasources reprojected ahead of time won't be kept in sync
+func validateWritableCollections(e *engine.Engine, datasources map[DatasourceKey]ds.Datasource) {
+	for k, datasource := range datasources {
+		if !e.Config.OgcAPI.Features.IsWritableCollection(k.collectionID) {
+			continue
+		}
+		if _, ok := datasource.(*reproject.Reprojection); ok {
+			continue // reprojected on-the-fly, so always in sync with the WGS84 datasource
+		}
+		if k.srid != wgs84SRID {
+			log.Fatalf("writable collection %s can't be served in other projections than WGS84, "+
+				"except when reprojected on-the-fly", k.collectionID)
+		}
+		if _, ok := datasource.(ds.DatasourceWriter); !ok {
+			log.Fatalf("writable collection %s is backed by a datasource which doesn't support writes", k.collectionID)
+		}
+	}
+}
+
+func handleFeatureNotFound(w http.ResponseWriter, collectionID string, featureID any) {
+	msg := fmt.Sprintf("the requested feature with id: %v does not exist in collection '%s'", featureID, collectionID)
+	log.Println(msg)
+	engine.RenderProblem(engine.ProblemNotFound, w, msg)
+}
+
+// log error, but send generic message to client to prevent possible information leakage from datasource.
+// Errors caused by the given feature result in a client error, with the (datasource neutral) cause as message.
+func handleFeatureWriteError(w http.ResponseWriter, operation string, collectionID string, err error) {
+	msg := fmt.Sprintf("failed to %s feature in collection %s", operation, collectionID)
+	log.Printf("%s, error: %v\n", msg, err)
+	switch {
+	case errors.Is(err, ds.ErrInvalidFeature):
+		engine.RenderProblem(engine.ProblemBadRequest, w, fmt.Sprintf("%s: %v", msg, ds.ErrInvalidFeature))
+	case errors.Is(err, ds.ErrFeatureConflict):
+		engine.RenderProblem(engine.ProblemConflict, w, fmt.Sprintf("%s: %v", msg, ds.ErrFeatureConflict))
+	default:
+		engine.RenderProblem(engine.ProblemServerError, w, msg)
+	}
+}
@@ -0,0 +1,201 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PDOK/gokoala/engine"
+	ds "github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatures_Transactions(t *testing.T) {
+	configFile := newWritableConfig(t)
+	newEngine, err := engine.NewEngine(configFile, "", false, true)
+	require.NoError(t, err)
+	features := NewFeatures(newEngine)
+
+	newFeature := `{"type": "Feature", "geometry": {"type": "Point", "coordinates": [5.3872, 52.1551]}, "properties": {"straatnaam": "Nieuwstraat", "huisnummer": 12}}`
+
+	t.Run("create feature", func(t *testing.T) {
+		rr := serveWriteRequest(t, features.CreateFeature(), http.MethodPost, "foo", "", newFeature)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.True(t, strings.HasPrefix(rr.Header().Get("Location"), "http://localhost:8080/collections/foo/items/"))
+
+		featureID := rr.Header().Get("Location")[strings.LastIndex(rr.Header().Get("Location"), "/")+1:]
+		body := serveFeature(t, features, featureID)
+		assert.Contains(t, body, `"straatnaam":"Nieuwstraat"`)
+
+		rr = serveWriteRequest(t, features.UpdateFeature(), http.MethodPatch, "foo", featureID,
+			`{"type": "Feature", "properties": {"postcode": "1234AB"}}`)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		body = serveFeature(t, features, featureID)
+		assert.Contains(t, body, `"straatnaam":"Nieuwstraat"`)
+		assert.Contains(t, body, `"postcode":"1234AB"`)
+
+		rr = serveWriteRequest(t, features.ReplaceFeature(), http.MethodPut, "foo", featureID,
+			`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [5.40, 52.16]}, "properties": {"straatnaam": "Vervangstraat"}}`)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		body = serveFeature(t, features, featureID)
+		assert.Contains(t, body, `"straatnaam":"Vervangstraat"`)
+		assert.NotContains(t, body, `"postcode"`)
+
+		rr = serveWriteRequest(t, features.DeleteFeature(), http.MethodDelete, "foo", featureID, "")
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		rr = serveWriteRequest(t, features.DeleteFeature(), http.MethodDelete, "foo", featureID, "")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("replace non existing feature", func(t *testing.T) {
+		rr := serveWriteRequest(t, features.ReplaceFeature(), http.MethodPut, "foo", "999999999", newFeature)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("create feature with unknown property", func(t *testing.T) {
+		rr := serveWriteRequest(t, features.CreateFeature(), http.MethodPost, "foo", "",
+			`{"type": "Feature", "geometry": null, "properties": {"nonexisting": "foo"}}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("create feature with fid property", func(t *testing.T) {
+		rr := serveWriteRequest(t, features.CreateFeature(), http.MethodPost, "foo", "",
+			`{"type": "Feature", "geometry": null, "properties": {"feature_id": 1}}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("create feature with geometry which can't be reprojected", func(t *testing.T) {
+		rr := serveWriteRequest(t, features.CreateFeature(), http.MethodPost, "foo", "",
+			`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [120000, 480000]}, "properties": {}}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("create feature with large integer property", func(t *testing.T) {
+		rr := serveWriteRequest(t, features.CreateFeature(), http.MethodPost, "foo", "",
+			`{"type": "Feature", "geometry": null, "properties": {"huisnummer": 9007199254740993}}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		featureID := rr.Header().Get("Location")[strings.LastIndex(rr.Header().Get("Location"), "/")+1:]
+		assert.Contains(t, serveFeature(t, features, featureID), `"huisnummer":9007199254740993`)
+	})
+
+	t.Run("create feature with nested property value", func(t *testing.T) {
+		rr := serveWriteRequest(t, features.CreateFeature(), http.MethodPost, "foo", "",
+			`{"type": "Feature", "geometry": null, "properties": {"straatnaam": {"foo": "bar"}}}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("create feature in collection which isn't writable", func(t *testing.T) {
+		rr := serveWriteRequest(t, features.CreateFeature(), http.MethodPost, "bar", "", newFeature)
+		assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	})
+}
+
+func TestFeatures_readFeature(t *testing.T) {
+	newEngine, err := engine.NewEngine("ogc/features/testdata/config_features_geojson.yaml", "", false, true)
+	require.NoError(t, err)
+	newEngine.Config.OgcAPI.Features.MaxRequestBodySize = 128
+	f := &Features{engine: newEngine}
+
+	readFeature := func(body string) (*domain.Feature, *httptest.ResponseRecorder) {
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/collections/foo/items", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		feature, ok := f.readFeature(w, r)
+		assert.Equal(t, ok, feature != nil)
+		return feature, w
+	}
+
+	feature, _ := readFeature(`{"type": "Feature", "geometry": null, "properties": {"huisnummer": 9007199254740993}}`)
+	require.NotNil(t, feature)
+	assert.Equal(t, json.Number("9007199254740993"), feature.Properties["huisnummer"], "no loss of precision")
+
+	_, w := readFeature(`{"type": "Feature", "geometry": null, "properties": {"straatnaam": "` +
+		strings.Repeat("a", 128) + `"}}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	_, w = readFeature(`{"type": "Feature"`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandleFeatureWriteError(t *testing.T) {
+	tests := []struct {
+		err      error
+		wantCode int
+	}{
+		{fmt.Errorf("%w: property 'foo' doesn't exist", ds.ErrInvalidFeature), http.StatusBadRequest},
+		{fmt.Errorf("failed to insert feature, error: %w", fmt.Errorf("%w: UNIQUE constraint failed", ds.ErrFeatureConflict)), http.StatusConflict},
+		{errors.New("database is locked"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handleFeatureWriteError(rr, "create", "foo", tt.err)
+		assert.Equal(t, tt.wantCode, rr.Code, tt.err.Error())
+		assert.NotContains(t, rr.Body.String(), "constraint")
+	}
+}
+
+// newWritableConfig returns a config referencing a copy of the test GeoPackage, since it will be modified
+func newWritableConfig(t *testing.T) string {
+	t.Helper()
+	gpkg := "./ogc/features/datasources/geopackage/testdata/bag.gpkg"
+	dir := t.TempDir()
+
+	src, err := os.Open(gpkg)
+	require.NoError(t, err)
+	defer src.Close()
+	dst, err := os.Create(filepath.Join(dir, "bag.gpkg"))
+	require.NoError(t, err)
+	defer dst.Close()
+	_, err = io.Copy(dst, src)
+	require.NoError(t, err)
+
+	cfg, err := os.ReadFile("ogc/features/testdata/config_features_bag_writable.yaml")
+	require.NoError(t, err)
+	configFile := filepath.Join(dir, "config.yaml")
+	err = os.WriteFile(configFile, []byte(strings.ReplaceAll(string(cfg), gpkg, dst.Name())), 0o600)
+	require.NoError(t, err)
+	return configFile
+}
+
+func serveWriteRequest(t *testing.T, handler http.HandlerFunc, method string, collectionID string,
+	featureID string, body string) *httptest.ResponseRecorder {
+
+	t.Helper()
+	url := "http://localhost:8080/collections/" + collectionID + "/items"
+	if featureID != "" {
+		url += "/" + featureID
+	}
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	if body != "" {
+		req.Header.Set(engine.HeaderContentType, engine.MediaTypeGeoJSON)
+	}
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("collectionId", collectionID)
+	rctx.URLParams.Add("featureId", featureID)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func serveFeature(t *testing.T, features *Features, featureID string) string {
+	t.Helper()
+	req, err := createRequest("http://localhost:8080/collections/foo/items/"+featureID, "foo", featureID, "json")
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	features.Feature().ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	return rr.Body.String()
+}
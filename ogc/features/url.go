@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"hash/fnv"
+	"math"
 	"net/url"
 	"slices"
 	"sort"
@@ -15,26 +16,43 @@ import (
 	"github.com/PDOK/gokoala/config"
 
 	"github.com/PDOK/gokoala/engine"
+	"github.com/PDOK/gokoala/ogc/features/cql"
+	ds "github.com/PDOK/gokoala/ogc/features/datasources"
 	"github.com/PDOK/gokoala/ogc/features/domain"
 	"github.com/go-spatial/geom"
 )
 
 const (
-	cursorParam    = "cursor"
-	limitParam     = "limit"
-	crsParam       = "crs"
-	dateTimeParam  = "datetime"
-	bboxParam      = "bbox"
-	bboxCrsParam   = "bbox-crs"
-	filterParam    = "filter"
-	filterCrsParam = "filter-crs"
+	cursorParam     = "cursor"
+	limitParam      = "limit"
+	crsParam        = "crs"
+	dateTimeParam   = "datetime"
+	bboxParam       = "bbox"
+	bboxCrsParam    = "bbox-crs"
+	filterParam     = "filter"
+	filterLangParam = "filter-lang"
+	filterCrsParam  = "filter-crs"
+	sortByParam     = "sortby"
+
+	propertiesParam   = "properties"
+	skipGeometryParam = "skipGeometry"
+
+	maxAllowableOffsetParam = "max-allowable-offset"
+
+	cql2TextLang = "cql2-text"
+	cql2JSONLang = "cql2-json"
+
+	openIntervalBoundary = ".."
 
 	propertyFilterMaxLength = 512
-	propertyFilterWildcard  = "*"
 )
 
 var (
 	checksumExcludedParams = []string{engine.FormatParam, cursorParam} // don't include these in checksum
+
+	// query params which don't affect the set of matching features, so don't include these in the filters key
+	filtersKeyExcludedParams = []string{engine.FormatParam, cursorParam, limitParam, crsParam, sortByParam,
+		propertiesParam, skipGeometryParam, maxAllowableOffsetParam}
 )
 
 // SRID Spatial Reference System Identifier: a unique value to unambiguously identify a spatial coordinate system.
@@ -67,29 +85,52 @@ type featureCollectionURL struct {
 	params                    url.Values
 	limit                     config.Limit
 	configuredPropertyFilters []config.PropertyFilter
+	configuredSortables       []string
 	supportsDatetime          bool
 }
 
-// parse the given URL to values required to delivery a set of Features
-func (fc featureCollectionURL) parse() (encodedCursor domain.EncodedCursor, limit int, inputSRID SRID, outputSRID SRID,
-	contentCrs ContentCrs, bbox *geom.Extent, referenceDate time.Time, propertyFilters map[string]string, err error) {
+// featureCollectionParams values parsed from a featureCollectionURL, required to deliver a set of Features
+type featureCollectionParams struct {
+	encodedCursor      domain.EncodedCursor
+	limit              int
+	inputSRID          SRID // of the bbox and/or filter
+	outputSRID         SRID
+	contentCrs         ContentCrs
+	bbox               *geom.Extent
+	referenceDate      time.Time
+	referenceInterval  *ds.Interval
+	propertyFilters    map[string]string
+	filter             cql.Expression
+	sortBy             []ds.SortKey
+	selection          ds.PropertySelection
+	maxAllowableOffset float64
+}
 
-	err = fc.validateNoUnknownParams()
-	if err != nil {
-		return
+// parse the given URL to values required to delivery a set of Features
+func (fc featureCollectionURL) parse() (featureCollectionParams, error) {
+	var p featureCollectionParams
+	if err := fc.validateNoUnknownParams(); err != nil {
+		return p, err
 	}
-	encodedCursor = domain.EncodedCursor(fc.params.Get(cursorParam))
-	limit, limitErr := parseLimit(fc.params, fc.limit)
-	outputSRID, outputSRIDErr := parseCrsToSRID(fc.params, crsParam)
-	contentCrs = parseCrsToContentCrs(fc.params)
-	propertyFilters, pfErr := parsePropertyFilters(fc.configuredPropertyFilters, fc.params)
-	bbox, bboxSRID, bboxErr := parseBbox(fc.params)
-	referenceDate, dateTimeErr := parseDateTime(fc.params, fc.supportsDatetime)
-	_, filterSRID, filterErr := parseFilter(fc.params)
-	inputSRID, inputSRIDErr := consolidateSRIDs(bboxSRID, filterSRID)
-
-	err = errors.Join(limitErr, outputSRIDErr, bboxErr, pfErr, dateTimeErr, filterErr, inputSRIDErr)
-	return
+	var limitErr, outputSRIDErr, pfErr, bboxErr, dateTimeErr, filterErr, inputSRIDErr, sortByErr, selectionErr,
+		maxAllowableOffsetErr error
+	var bboxSRID, filterSRID SRID
+
+	p.encodedCursor = domain.EncodedCursor(fc.params.Get(cursorParam))
+	p.limit, limitErr = parseLimit(fc.params, fc.limit)
+	p.outputSRID, outputSRIDErr = parseCrsToSRID(fc.params, crsParam)
+	p.contentCrs = parseCrsToContentCrs(fc.params)
+	p.propertyFilters, pfErr = parsePropertyFilters(fc.configuredPropertyFilters, fc.params)
+	p.bbox, bboxSRID, bboxErr = parseBbox(fc.params)
+	p.referenceDate, p.referenceInterval, dateTimeErr = parseDateTime(fc.params, fc.supportsDatetime)
+	p.filter, filterSRID, filterErr = parseFilter(fc.params)
+	p.inputSRID, inputSRIDErr = consolidateSRIDs(bboxSRID, filterSRID)
+	p.sortBy, sortByErr = parseSortBy(fc.params, fc.configuredSortables)
+	p.selection, selectionErr = parsePropertySelection(fc.params)
+	p.maxAllowableOffset, maxAllowableOffsetErr = parseMaxAllowableOffset(fc.params)
+
+	return p, errors.Join(limitErr, outputSRIDErr, bboxErr, pfErr, dateTimeErr, filterErr, inputSRIDErr, sortByErr,
+		selectionErr, maxAllowableOffsetErr)
 }
 
 // Calculate checksum over the query parameters that have a "filtering effect" on
@@ -128,6 +169,22 @@ OUTER:
 	return []byte{}
 }
 
+// filtersKey returns a canonical representation (sorted and encoded key=value pairs) of the query
+// parameters which affect the set of matching features, such as bbox, property filters, CQL filters, etc.
+// In contrast to the checksum this is unambiguous, to be used as a cache key for e.g. numberMatched.
+func (fc featureCollectionURL) filtersKey() string {
+	filterParams := url.Values{}
+	for k, values := range fc.params {
+		if slices.Contains(filtersKeyExcludedParams, k) {
+			continue
+		}
+		values = slices.Clone(values)
+		slices.Sort(values)
+		filterParams[k] = values
+	}
+	return filterParams.Encode() // sorted by key
+}
+
 func (fc featureCollectionURL) toSelfURL(collectionID string, format string) string {
 	copyParams := clone(fc.params)
 	copyParams.Set(engine.FormatParam, format)
@@ -158,7 +215,12 @@ func (fc featureCollectionURL) validateNoUnknownParams() error {
 	copyParams.Del(bboxParam)
 	copyParams.Del(bboxCrsParam)
 	copyParams.Del(filterParam)
+	copyParams.Del(filterLangParam)
 	copyParams.Del(filterCrsParam)
+	copyParams.Del(sortByParam)
+	copyParams.Del(propertiesParam)
+	copyParams.Del(skipGeometryParam)
+	copyParams.Del(maxAllowableOffsetParam)
 	for _, pf := range fc.configuredPropertyFilters {
 		copyParams.Del(pf.Name)
 	}
@@ -175,22 +237,25 @@ type featureURL struct {
 }
 
 // parse the given URL to values required to delivery a specific Feature
-func (f featureURL) parse() (srid SRID, contentCrs ContentCrs, err error) {
+func (f featureURL) parse() (srid SRID, contentCrs ContentCrs, selection ds.PropertySelection, err error) {
 	err = f.validateNoUnknownParams()
 	if err != nil {
 		return
 	}
 
-	srid, err = parseCrsToSRID(f.params, crsParam)
+	srid, sridErr := parseCrsToSRID(f.params, crsParam)
 	contentCrs = parseCrsToContentCrs(f.params)
+	selection, selectionErr := parsePropertySelection(f.params)
+
+	err = errors.Join(sridErr, selectionErr)
 	return
 }
 
-func (f featureURL) toSelfURL(collectionID string, featureID int64, format string) string {
+func (f featureURL) toSelfURL(collectionID string, featureID any, format string) string {
 	newParams := url.Values{}
 	newParams.Set(engine.FormatParam, format)
 
-	result := f.baseURL.JoinPath("collections", collectionID, "items", strconv.FormatInt(featureID, 10))
+	result := f.baseURL.JoinPath("collections", collectionID, "items", fmt.Sprint(featureID))
 	result.RawQuery = newParams.Encode()
 	return result.String()
 }
@@ -209,6 +274,8 @@ func (f featureURL) validateNoUnknownParams() error {
 	copyParams := clone(f.params)
 	copyParams.Del(engine.FormatParam)
 	copyParams.Del(crsParam)
+	copyParams.Del(propertiesParam)
+	copyParams.Del(skipGeometryParam)
 	if len(copyParams) > 0 {
 		return fmt.Errorf("unknown query parameter(s) found: %v", copyParams.Encode())
 	}
@@ -228,8 +295,10 @@ func consolidateSRIDs(bboxSRID SRID, filterSRID SRID) (inputSRID SRID, err error
 		return 0, errors.New("bbox-crs and filter-crs need to be equal. " +
 			"Can't use more than one CRS as input, but input and output CRS may differ")
 	}
-	if bboxSRID != undefinedSRID || filterSRID != undefinedSRID {
+	if bboxSRID != undefinedSRID {
 		inputSRID = bboxSRID // or filterCrs, both the same
+	} else if filterSRID != undefinedSRID {
+		inputSRID = filterSRID
 	}
 	return inputSRID, err
 }
@@ -253,6 +322,21 @@ func parseLimit(params url.Values, limitCfg config.Limit) (int, error) {
 	return limit, err
 }
 
+// parseMaxAllowableOffset parses the tolerance (in units of the output CRS) to simplify geometries with
+func parseMaxAllowableOffset(params url.Values) (float64, error) {
+	if params.Get(maxAllowableOffsetParam) == "" {
+		return 0, nil
+	}
+	offset, err := strconv.ParseFloat(params.Get(maxAllowableOffsetParam), 64)
+	if err != nil || math.IsNaN(offset) || math.IsInf(offset, 0) {
+		return 0, errors.New("max-allowable-offset must be numeric")
+	}
+	if offset < 0 {
+		return 0, errors.New("max-allowable-offset can't be negative")
+	}
+	return offset, nil
+}
+
 func parseBbox(params url.Values) (*geom.Extent, SRID, error) {
 	bboxSRID, err := parseCrsToSRID(params, bboxCrsParam)
 	if err != nil {
@@ -322,11 +406,8 @@ func parsePropertyFilters(configuredPropertyFilters []config.PropertyFilter, par
 				return nil, fmt.Errorf("property filter %s is too large, "+
 					"value is limited to %d characters", cpf.Name, propertyFilterMaxLength)
 			}
-			if strings.Contains(pf, propertyFilterWildcard) {
-				// if/when we choose to support wildcards in the future, make sure wildcards are
-				// only allowed at the END (suffix) of the filter
-				return nil, fmt.Errorf("property filter %s contains a wildcard (%s), "+
-					"wildcard filtering is not allowed", cpf.Name, propertyFilterWildcard)
+			if err := validatePropertyFilter(cpf.Name, pf); err != nil {
+				return nil, err
 			}
 			propertyFilters[cpf.Name] = pf
 		}
@@ -334,27 +415,155 @@ func parsePropertyFilters(configuredPropertyFilters []config.PropertyFilter, par
 	return propertyFilters, nil
 }
 
+// A property filter may contain multiple comma-separated values (IN). Each value may end with a
+// wildcard to match on prefix. Wildcards are only allowed at the END (suffix) of a value, since
+// only prefix matching can make use of the index on the property.
+func validatePropertyFilter(name string, pf string) error {
+	for _, value := range strings.Split(pf, ds.PropertyFilterSeparator) {
+		prefix, _ := strings.CutSuffix(value, ds.PropertyFilterWildcard)
+		if prefix == "" {
+			return fmt.Errorf("property filter %s contains an empty value or a wildcard (%s) "+
+				"without a preceding value", name, ds.PropertyFilterWildcard)
+		}
+		if strings.Contains(prefix, ds.PropertyFilterWildcard) {
+			return fmt.Errorf("property filter %s contains a wildcard (%s) which isn't at the end "+
+				"of a value, only suffix wildcards are allowed", name, ds.PropertyFilterWildcard)
+		}
+	}
+	return nil
+}
+
 // Support filtering on datetime: https://docs.ogc.org/is/17-069r4/17-069r4.html#_parameter_datetime
-func parseDateTime(params url.Values, datetimeSupported bool) (time.Time, error) {
+// The datetime is either a date-time/date (the reference date) or an interval, where the
+// interval start or end may be open-ended ('..' or empty).
+func parseDateTime(params url.Values, datetimeSupported bool) (time.Time, *ds.Interval, error) {
 	datetime := params.Get(dateTimeParam)
-	if datetime != "" {
-		if !datetimeSupported {
-			return time.Time{}, errors.New("datetime param is currently not supported for this collection")
+	if datetime == "" {
+		return time.Time{}, nil, nil
+	}
+	if !datetimeSupported {
+		return time.Time{}, nil, errors.New("datetime param is currently not supported for this collection")
+	}
+	if !strings.Contains(datetime, "/") {
+		referenceDate, err := parseDateTimeValue(datetime, false)
+		return referenceDate, nil, err
+	}
+	boundaries := strings.Split(datetime, "/")
+	if len(boundaries) != 2 {
+		return time.Time{}, nil, fmt.Errorf("datetime param '%s' isn't a valid interval, expected start/end", datetime)
+	}
+	var interval ds.Interval
+	var err error
+	if boundaries[0] != "" && boundaries[0] != openIntervalBoundary {
+		if interval.Start, err = parseDateTimeValue(boundaries[0], false); err != nil {
+			return time.Time{}, nil, err
 		}
-		if strings.Contains(datetime, "/") {
-			return time.Time{}, fmt.Errorf("datetime param '%s' represents an interval, intervals are currently not supported", datetime)
+	}
+	if boundaries[1] != "" && boundaries[1] != openIntervalBoundary {
+		if interval.End, err = parseDateTimeValue(boundaries[1], true); err != nil {
+			return time.Time{}, nil, err
 		}
-		return time.Parse(time.RFC3339, datetime)
 	}
-	return time.Time{}, nil
+	if interval.Start.IsZero() && interval.End.IsZero() {
+		return time.Time{}, nil, fmt.Errorf("datetime param '%s' isn't a valid interval, start and end can't both be open", datetime)
+	}
+	if !interval.Start.IsZero() && !interval.End.IsZero() && interval.End.Before(interval.Start) {
+		return time.Time{}, nil, fmt.Errorf("datetime param '%s' isn't a valid interval, end is before start", datetime)
+	}
+	return time.Time{}, &interval, nil
 }
 
-func parseFilter(params url.Values) (filter string, filterSRID SRID, err error) {
-	filter = params.Get(filterParam)
-	filterSRID, _ = parseCrsToSRID(params, filterCrsParam)
+// parseDateTimeValue parses an RFC 3339 date-time or date. A date as interval end is
+// inclusive, so it represents the end of that day.
+func parseDateTimeValue(value string, isIntervalEnd bool) (time.Time, error) {
+	if dateTime, err := time.Parse(time.RFC3339, value); err == nil {
+		return dateTime, nil
+	}
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("datetime param contains '%s' which isn't a valid "+
+			"RFC 3339 date-time (e.g. 2018-02-12T23:20:50Z) or date (e.g. 2018-02-12)", value)
+	}
+	if isIntervalEnd {
+		return date.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	return date, nil
+}
 
-	if filter != "" {
-		return filter, filterSRID, errors.New("CQL filter param is currently not supported")
+// Support CQL2 filtering: https://docs.ogc.org/DRAFTS/19-079r1.html#filter-param
+func parseFilter(params url.Values) (filter cql.Expression, filterSRID SRID, err error) {
+	filterSRID, err = parseCrsToSRID(params, filterCrsParam)
+	if err != nil {
+		return nil, undefinedSRID, err
+	}
+	filterLang := params.Get(filterLangParam)
+	if filterLang != "" && filterLang != cql2TextLang && filterLang != cql2JSONLang {
+		return nil, filterSRID, fmt.Errorf("%s '%s' is not supported, supported are: %s, %s",
+			filterLangParam, filterLang, cql2TextLang, cql2JSONLang)
+	}
+	if params.Get(filterParam) == "" {
+		return nil, filterSRID, nil
+	}
+	if filterLang == cql2JSONLang {
+		filter, err = cql.ParseJSON(params.Get(filterParam))
+	} else {
+		filter, err = cql.ParseText(params.Get(filterParam))
+	}
+	return filter, filterSRID, err
+}
+
+// Support sorting using the sortby param as defined in OGC API Records: https://docs.ogc.org/DRAFTS/20-004.html
+// Each property is prefixed by an optional + (ascending, the default) or - (descending).
+func parseSortBy(params url.Values, configuredSortables []string) ([]ds.SortKey, error) {
+	sortBy := params.Get(sortByParam)
+	if sortBy == "" {
+		return nil, nil
+	}
+	if len(configuredSortables) == 0 {
+		return nil, errors.New("sortby param is currently not supported for this collection")
+	}
+	sortKeys := make([]ds.SortKey, 0, len(configuredSortables))
+	for _, value := range strings.Split(sortBy, ",") {
+		// note: an unencoded + in the query string decodes to a space, hence the trim
+		value = strings.TrimSpace(value)
+		sortKey := ds.SortKey{Property: strings.TrimPrefix(value, "+")}
+		if strings.HasPrefix(value, "-") {
+			sortKey = ds.SortKey{Property: strings.TrimPrefix(value, "-"), Descending: true}
+		}
+		if !slices.Contains(configuredSortables, sortKey.Property) {
+			return nil, fmt.Errorf("sortby param contains property '%s' which can't be used for sorting, "+
+				"valid properties are: %s", sortKey.Property, strings.Join(configuredSortables, ", "))
+		}
+		if slices.ContainsFunc(sortKeys, func(k ds.SortKey) bool { return k.Property == sortKey.Property }) {
+			return nil, fmt.Errorf("sortby param contains property '%s' more than once", sortKey.Property)
+		}
+		sortKeys = append(sortKeys, sortKey)
+	}
+	return sortKeys, nil
+}
+
+// Support selecting properties and omitting the geometry using the properties and skipGeometry
+// params, as proposed in the (draft) property selection extension of OGC API Features.
+func parsePropertySelection(params url.Values) (ds.PropertySelection, error) {
+	var selection ds.PropertySelection
+	if params.Has(propertiesParam) {
+		for _, property := range strings.Split(params.Get(propertiesParam), ",") {
+			property = strings.TrimSpace(property)
+			if property == "" {
+				return selection, errors.New("properties param contains an empty property name")
+			}
+			if slices.Contains(selection.Properties, property) {
+				return selection, fmt.Errorf("properties param contains property '%s' more than once", property)
+			}
+			selection.Properties = append(selection.Properties, property)
+		}
+	}
+	if params.Get(skipGeometryParam) != "" {
+		skipGeometry, err := strconv.ParseBool(params.Get(skipGeometryParam))
+		if err != nil {
+			return selection, errors.New("skipGeometry param must be either true or false")
+		}
+		selection.SkipGeometry = skipGeometry
 	}
-	return filter, filterSRID, nil
+	return selection, nil
 }
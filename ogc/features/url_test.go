@@ -8,9 +8,12 @@ import (
 
 	"github.com/PDOK/gokoala/config"
 
+	"github.com/PDOK/gokoala/ogc/features/cql"
+	ds "github.com/PDOK/gokoala/ogc/features/datasources"
 	"github.com/PDOK/gokoala/ogc/features/domain"
 	"github.com/go-spatial/geom"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_featureCollectionURL_parseParams(t *testing.T) {
@@ -30,7 +33,11 @@ func Test_featureCollectionURL_parseParams(t *testing.T) {
 		wantBbox          *geom.Extent
 		wantInputCrs      int
 		wantRefDate       *time.Time
+		wantRefInterval   *ds.Interval
 		wantPropFilters   map[string]string
+		wantFilter        cql.Expression
+		wantSortBy        []ds.SortKey
+		wantSelection     ds.PropertySelection
 		wantErr           assert.ErrorAssertionFunc
 	}{
 		{
@@ -221,11 +228,48 @@ func Test_featureCollectionURL_parseParams(t *testing.T) {
 			},
 		},
 		{
-			name: "Fail on wildcard property filter",
+			name: "Parse wildcard and multi-value property filters",
 			fields: fields{
 				baseURL: *host,
 				params: url.Values{
 					"foo": []string{"baz*"},
+					"bar": []string{"bazz,qux*"},
+				},
+				limit: config.Limit{
+					Default: 10,
+					Max:     20,
+				},
+			},
+			wantLimit:       10,
+			wantOutputCrs:   100000,
+			wantInputCrs:    100000,
+			wantRefDate:     nil,
+			wantPropFilters: map[string]string{"foo": "baz*", "bar": "bazz,qux*"},
+			wantErr:         success(),
+		},
+		{
+			name: "Fail on wildcard property filter not at the end",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"foo": []string{"b*az"},
+				},
+				limit: config.Limit{
+					Default: 10,
+					Max:     20,
+				},
+			},
+			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
+				assert.Equalf(t, "property filter foo contains a wildcard (*) which isn't at the end of a value, only suffix wildcards are allowed", err.Error(), "parse()")
+				return false
+			},
+		},
+		{
+			name: "Fail on wildcard-only property filter",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"foo": []string{"baz,*"},
 				},
 				limit: config.Limit{
 					Default: 10,
@@ -233,7 +277,7 @@ func Test_featureCollectionURL_parseParams(t *testing.T) {
 				},
 			},
 			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
-				assert.Equalf(t, "property filter foo contains a wildcard (*), wildcard filtering is not allowed", err.Error(), "parse()")
+				assert.Equalf(t, "property filter foo contains an empty value or a wildcard (*) without a preceding value", err.Error(), "parse()")
 				return false
 			},
 		},
@@ -328,11 +372,107 @@ func Test_featureCollectionURL_parseParams(t *testing.T) {
 			},
 		},
 		{
-			name: "Fail on unimplemented datetime interval",
+			name: "Parse datetime interval",
 			fields: fields{
 				baseURL: *host,
 				params: url.Values{
-					"datetime": []string{"2023-11-10T23:00:00Z/2023-11-15T23:00:00Z"},
+					"datetime": []string{"2023-11-10T23:00:00Z/2023-11-15"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+				dtSupport: true,
+			},
+			wantLimit:     1,
+			wantOutputCrs: 100000,
+			wantInputCrs:  100000,
+			wantRefInterval: &ds.Interval{
+				Start: time.Date(2023, 11, 10, 23, 0, 0, 0, time.UTC),
+				End:   time.Date(2023, 11, 15, 23, 59, 59, 999999999, time.UTC), // end of day, since end is inclusive
+			},
+			wantErr: success(),
+		},
+		{
+			name: "Parse open-ended datetime interval",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"datetime": []string{"../2023-11-15T23:00:00Z"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+				dtSupport: true,
+			},
+			wantLimit:       1,
+			wantOutputCrs:   100000,
+			wantInputCrs:    100000,
+			wantRefInterval: &ds.Interval{End: time.Date(2023, 11, 15, 23, 0, 0, 0, time.UTC)},
+			wantErr:         success(),
+		},
+		{
+			name: "Parse date-only datetime",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"datetime": []string{"2023-11-15"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+				dtSupport: true,
+			},
+			wantLimit:     1,
+			wantOutputCrs: 100000,
+			wantInputCrs:  100000,
+			wantRefDate:   ptrTo(time.Date(2023, 11, 15, 0, 0, 0, 0, time.UTC)),
+			wantErr:       success(),
+		},
+		{
+			name: "Fail on datetime interval open at both ends",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"datetime": []string{"../"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+				dtSupport: true,
+			},
+			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
+				assert.Equalf(t, "datetime param '../' isn't a valid interval, start and end can't both be open", err.Error(), "parse()")
+				return false
+			},
+		},
+		{
+			name: "Fail on datetime interval with end before start",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"datetime": []string{"2023-11-15/2023-11-10"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+				dtSupport: true,
+			},
+			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
+				assert.Equalf(t, "datetime param '2023-11-15/2023-11-10' isn't a valid interval, end is before start", err.Error(), "parse()")
+				return false
+			},
+		},
+		{
+			name: "Fail on invalid datetime",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"datetime": []string{"2023-11-10T23:00:00Z/yesterday"},
 				},
 				limit: config.Limit{
 					Default: 1,
@@ -341,7 +481,7 @@ func Test_featureCollectionURL_parseParams(t *testing.T) {
 				dtSupport: true,
 			},
 			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
-				assert.Equalf(t, "datetime param '2023-11-10T23:00:00Z/2023-11-15T23:00:00Z' represents an interval, intervals are currently not supported", err.Error(), "parse()")
+				assert.Equalf(t, "datetime param contains 'yesterday' which isn't a valid RFC 3339 date-time (e.g. 2018-02-12T23:20:50Z) or date (e.g. 2018-02-12)", err.Error(), "parse()")
 				return false
 			},
 		},
@@ -364,7 +504,30 @@ func Test_featureCollectionURL_parseParams(t *testing.T) {
 			},
 		},
 		{
-			name: "Fail on unimplemented filter",
+			name: "Parse CQL2-Text filter with filter-crs",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"filter":      []string{"straatnaam = 'Silodam' and S_INTERSECTS(geom, POINT(120000 487000))"},
+					"filter-lang": []string{"cql2-text"},
+					"filter-crs":  []string{"http://www.opengis.net/def/crs/EPSG/0/28992"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+			},
+			wantLimit:     1,
+			wantOutputCrs: 100000,
+			wantInputCrs:  28992,
+			wantFilter: cql.And{Children: []cql.Expression{
+				cql.Comparison{Operator: cql.Equal, Left: cql.Property{Name: "straatnaam"}, Right: cql.String{Value: "Silodam"}},
+				cql.SpatialPredicate{Operator: cql.SIntersects, Left: cql.Property{Name: "geom"}, Right: cql.Geometry{Geometry: geom.Point{120000, 487000}}},
+			}},
+			wantErr: success(),
+		},
+		{
+			name: "Fail on invalid filter",
 			fields: fields{
 				baseURL: *host,
 				params: url.Values{
@@ -376,7 +539,185 @@ func Test_featureCollectionURL_parseParams(t *testing.T) {
 				},
 			},
 			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
-				assert.Equalf(t, "CQL filter param is currently not supported", err.Error(), "parse()")
+				assert.Equalf(t, "invalid CQL2-Text filter: expected comparison operator or predicate but got 'CQL' at position 5", err.Error(), "parse()")
+				return false
+			},
+		},
+		{
+			name: "Parse CQL2-JSON filter",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"filter":      []string{`{"op": "in", "args": [{"property": "straatnaam"}, ["Silodam", "Damrak"]]}`},
+					"filter-lang": []string{"cql2-json"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+			},
+			wantLimit:     1,
+			wantOutputCrs: 100000,
+			wantInputCrs:  100000,
+			wantFilter: cql.In{Value: cql.Property{Name: "straatnaam"},
+				List: []cql.Operand{cql.String{Value: "Silodam"}, cql.String{Value: "Damrak"}}},
+			wantErr: success(),
+		},
+		{
+			name: "Fail on unsupported operator in CQL2-JSON filter",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"filter":      []string{`{"op": "a_overlaps", "args": [{"property": "tags"}, ["a", "b"]]}`},
+					"filter-lang": []string{"cql2-json"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+			},
+			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
+				assert.Equalf(t, "invalid CQL2-JSON filter: operator 'a_overlaps' is not supported", err.Error(), "parse()")
+				return false
+			},
+		},
+		{
+			name: "Fail on unsupported filter-lang",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"filter":      []string{"foo = 'bar'"},
+					"filter-lang": []string{"ecql"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+			},
+			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
+				assert.Equalf(t, "filter-lang 'ecql' is not supported, supported are: cql2-text, cql2-json", err.Error(), "parse()")
+				return false
+			},
+		},
+		{
+			name: "Parse sortby",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"sortby": []string{"-foo, baz"}, // unencoded + decodes to space
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+			},
+			wantLimit:     1,
+			wantOutputCrs: 100000,
+			wantInputCrs:  100000,
+			wantSortBy:    []ds.SortKey{{Property: "foo", Descending: true}, {Property: "baz"}},
+			wantErr:       success(),
+		},
+		{
+			name: "Fail on sortby with property that isn't sortable",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"sortby": []string{"+foo,-bar"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+			},
+			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
+				assert.Equalf(t, "sortby param contains property 'bar' which can't be used for sorting, valid properties are: foo, baz", err.Error(), "parse()")
+				return false
+			},
+		},
+		{
+			name: "Fail on sortby with duplicate property",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"sortby": []string{"foo,-foo"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+			},
+			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
+				assert.Equalf(t, "sortby param contains property 'foo' more than once", err.Error(), "parse()")
+				return false
+			},
+		},
+		{
+			name: "Parse properties and skipGeometry",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"properties":   []string{"foo, bar"},
+					"skipGeometry": []string{"true"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+			},
+			wantLimit:     1,
+			wantOutputCrs: 100000,
+			wantInputCrs:  100000,
+			wantSelection: ds.PropertySelection{Properties: []string{"foo", "bar"}, SkipGeometry: true},
+			wantErr:       success(),
+		},
+		{
+			name: "Fail on properties with empty property",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"properties": []string{"foo,,bar"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+			},
+			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
+				assert.Equalf(t, "properties param contains an empty property name", err.Error(), "parse()")
+				return false
+			},
+		},
+		{
+			name: "Fail on properties with duplicate property",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"properties": []string{"foo,foo"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+			},
+			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
+				assert.Equalf(t, "properties param contains property 'foo' more than once", err.Error(), "parse()")
+				return false
+			},
+		},
+		{
+			name: "Fail on invalid skipGeometry",
+			fields: fields{
+				baseURL: *host,
+				params: url.Values{
+					"skipGeometry": []string{"yes"},
+				},
+				limit: config.Limit{
+					Default: 1,
+					Max:     2,
+				},
+			},
+			wantErr: func(t assert.TestingT, err error, _ ...any) bool {
+				assert.Equalf(t, "skipGeometry param must be either true or false", err.Error(), "parse()")
 				return false
 			},
 		},
@@ -414,24 +755,113 @@ func Test_featureCollectionURL_parseParams(t *testing.T) {
 						Description: "even more awesome bar property to filter on",
 					},
 				},
-				supportsDatetime: tt.fields.dtSupport,
+				configuredSortables: []string{"foo", "baz"},
+				supportsDatetime:    tt.fields.dtSupport,
 			}
-			gotEncodedCursor, gotLimit, gotInputCrs, gotOutputCrs, _, gotBbox, _, gotPF, err := fc.parse()
+			got, err := fc.parse()
 			if !tt.wantErr(t, err, "parse()") {
 				return
 			}
-			assert.Equalf(t, tt.wantEncodedCursor, gotEncodedCursor, "parse()")
-			assert.Equalf(t, tt.wantLimit, gotLimit, "parse()")
-			assert.Equalf(t, tt.wantOutputCrs, gotOutputCrs.GetOrDefault(), "parse()")
-			assert.Equalf(t, tt.wantBbox, gotBbox, "parse()")
-			assert.Equalf(t, tt.wantInputCrs, gotInputCrs.GetOrDefault(), "parse()")
+			assert.Equalf(t, tt.wantEncodedCursor, got.encodedCursor, "parse()")
+			assert.Equalf(t, tt.wantLimit, got.limit, "parse()")
+			assert.Equalf(t, tt.wantOutputCrs, got.outputSRID.GetOrDefault(), "parse()")
+			assert.Equalf(t, tt.wantBbox, got.bbox, "parse()")
+			assert.Equalf(t, tt.wantInputCrs, got.inputSRID.GetOrDefault(), "parse()")
+			if tt.wantRefDate != nil {
+				assert.Equalf(t, *tt.wantRefDate, got.referenceDate, "parse()")
+			}
+			assert.Equalf(t, tt.wantRefInterval, got.referenceInterval, "parse()")
 			if tt.wantPropFilters != nil {
-				assert.Equalf(t, tt.wantPropFilters, gotPF, "parse()")
+				assert.Equalf(t, tt.wantPropFilters, got.propertyFilters, "parse()")
 			}
+			assert.Equalf(t, tt.wantFilter, got.filter, "parse()")
+			assert.Equalf(t, tt.wantSortBy, got.sortBy, "parse()")
+			assert.Equalf(t, tt.wantSelection, got.selection, "parse()")
 		})
 	}
 }
 
+func Test_featureCollectionURL_filtersKey(t *testing.T) {
+	filtersKey := func(query string) string {
+		params, err := url.ParseQuery(query)
+		require.NoError(t, err)
+		return featureCollectionURL{params: params}.filtersKey()
+	}
+
+	// checksums of these are equal, since values are concatenated without keys or separators
+	assert.NotEqual(t, filtersKey("postcode=1104"), filtersKey("huisnummer=1104"))
+	assert.NotEqual(t, filtersKey("a=1&b=23"), filtersKey("a=12&b=3"))
+
+	assert.Equal(t, "a=1&b=23", filtersKey("b=23&a=1"))
+	assert.Equal(t, filtersKey("bbox=1,2,3,4"), filtersKey("bbox=1,2,3,4&limit=5&f=json&cursor=abc&crs=foo&sortby=bar"))
+	assert.Empty(t, filtersKey("limit=5&f=json"))
+}
+
+func Test_parseMaxAllowableOffset(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr string
+	}{
+		{value: "", want: 0},
+		{value: "2.5", want: 2.5},
+		{value: "-1", wantErr: "max-allowable-offset can't be negative"},
+		{value: "abc", wantErr: "max-allowable-offset must be numeric"},
+		{value: "NaN", wantErr: "max-allowable-offset must be numeric"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseMaxAllowableOffset(url.Values{maxAllowableOffsetParam: []string{tt.value}})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 0)
+		})
+	}
+}
+
+func Test_featureURL_parse(t *testing.T) {
+	host, _ := url.Parse("http://ogc.example")
+	tests := []struct {
+		name          string
+		params        url.Values
+		wantSelection ds.PropertySelection
+		wantErr       string
+	}{
+		{
+			name:   "Parse no params",
+			params: url.Values{},
+		},
+		{
+			name:          "Parse properties and skipGeometry",
+			params:        url.Values{"properties": []string{"foo"}, "skipGeometry": []string{"true"}},
+			wantSelection: ds.PropertySelection{Properties: []string{"foo"}, SkipGeometry: true},
+		},
+		{
+			name:    "Fail on unknown param",
+			params:  url.Values{"limit": []string{"10"}},
+			wantErr: "unknown query parameter(s) found: limit=10",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, gotSelection, err := featureURL{*host, tt.params}.parse()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSelection, gotSelection)
+		})
+	}
+}
+
+func ptrTo[T any](v T) *T {
+	return &v
+}
+
 func success() func(t assert.TestingT, err error, i ...any) bool {
 	return func(_ assert.TestingT, _ error, _ ...any) bool {
 		return true
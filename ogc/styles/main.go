@@ -46,12 +46,11 @@ func NewStyles(e *engine.Engine) *Styles {
 		engine.NewTemplateKey(templatesDir+"styles.go.json"),
 		engine.NewTemplateKey(templatesDir+"styles.go.html"))
 
-	projections := map[string]string{"EPSG:28992": "NetherlandsRDNewQuad", "EPSG:3035": "EuropeanETRS89_LAEAQuad", "EPSG:3857": "WebMercatorQuad"}
-	defaultProjection = strings.ToLower(projections[e.Config.OgcAPI.Tiles.SupportedSrs[0].Srs])
+	defaultProjection = strings.ToLower(e.Config.OgcAPI.Tiles.SupportedSrs[0].TileMatrixSetID())
 
 	for _, style := range e.Config.OgcAPI.Styles.SupportedStyles {
 		for _, supportedSrs := range e.Config.OgcAPI.Tiles.SupportedSrs {
-			projection := projections[supportedSrs.Srs]
+			projection := supportedSrs.TileMatrixSetID()
 			zoomLevelRange := supportedSrs.ZoomLevelRange
 			styleInstanceID := style.ID + projectionDelimiter + strings.ToLower(projection)
 			// Render metadata templates
@@ -0,0 +1,17 @@
+package backends
+
+import (
+	"context"
+)
+
+// Backend holds pre-generated tiles of a single tile matrix set, e.g. a tile archive on local disk.
+type Backend interface {
+
+	// GetTile returns the tile at the given tile matrix (zoom level), row and column. Rows are counted from
+	// the top (OGC tile matrix / XYZ scheme). Returns nil when the archive holds no (or an empty) tile at the
+	// given position. Tile data is returned as stored, so may be compressed (e.g. gzip).
+	GetTile(ctx context.Context, tileMatrix, tileRow, tileCol int) ([]byte, error)
+
+	// Close closes the backend gracefully
+	Close()
+}
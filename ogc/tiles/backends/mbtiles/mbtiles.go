@@ -0,0 +1,64 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/PDOK/gokoala/config"
+	"github.com/jmoiron/sqlx"
+
+	_ "github.com/mattn/go-sqlite3" // import for side effect (= sqlite3 driver) only
+)
+
+// MBTiles archive, a SQLite database holding tiles. See https://github.com/mapbox/mbtiles-spec.
+type MBTiles struct {
+	db            *sqlx.DB
+	matrixHeights map[int]int // number of rows by tile matrix (zoom level)
+}
+
+// NewMBTiles opens the given archive, holding tiles of a tile matrix set with the given matrix
+// heights (number of rows) by tile matrix (zoom level), which are needed to flip rows.
+func NewMBTiles(archive config.TilesArchiveFile, matrixHeights map[int]int) *MBTiles {
+	// immutable: the archive isn't changed while being served, so SQLite can skip locking
+	db, err := sqlx.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro&immutable=1", archive.File))
+	if err != nil {
+		log.Fatalf("failed to open MBTiles: %v", err)
+	}
+	if err = db.Ping(); err != nil {
+		log.Fatalf("failed to open MBTiles %s: %v", archive.File, err)
+	}
+	log.Printf("opened local MBTiles: %s", archive.File)
+	return &MBTiles{db, matrixHeights}
+}
+
+func (m *MBTiles) GetTile(ctx context.Context, tileMatrix, tileRow, tileCol int) ([]byte, error) {
+	// MBTiles uses the TMS scheme, with rows counted from the bottom
+	matrixHeight, ok := m.matrixHeights[tileMatrix]
+	if !ok {
+		return nil, nil
+	}
+	tmsRow := matrixHeight - 1 - tileRow
+
+	var tile []byte
+	err := m.db.QueryRowxContext(ctx, "select tile_data from tiles where zoom_level = ? and tile_column = ? and tile_row = ?",
+		tileMatrix, tileCol, tmsRow).Scan(&tile)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read tile from MBTiles: %w", err)
+	}
+	if len(tile) == 0 {
+		return nil, nil
+	}
+	return tile, nil
+}
+
+func (m *MBTiles) Close() {
+	err := m.db.Close()
+	if err != nil {
+		log.Printf("failed to close MBTiles: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package mbtiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/PDOK/gokoala/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMBTiles_GetTile(t *testing.T) {
+	m := NewMBTiles(config.TilesArchiveFile{File: "testdata/tiles.mbtiles"}, map[int]int{0: 1, 1: 2, 2: 4})
+	defer m.Close()
+
+	tests := []struct {
+		name       string
+		tileMatrix int
+		tileRow    int
+		tileCol    int
+		want       string
+		wantGzip   bool
+	}{
+		{name: "gzip compressed tile", tileMatrix: 0, tileRow: 0, tileCol: 0, want: "tile 0/0/0", wantGzip: true},
+		{name: "rows are counted from the top", tileMatrix: 1, tileRow: 0, tileCol: 1, want: "tile 1/0/1", wantGzip: true},
+		{name: "uncompressed tile", tileMatrix: 2, tileRow: 2, tileCol: 3, want: "tile 2/2/3"},
+		{name: "empty tile", tileMatrix: 2, tileRow: 0, tileCol: 0},
+		{name: "missing tile", tileMatrix: 1, tileRow: 1, tileCol: 1},
+		{name: "unknown tile matrix", tileMatrix: 3, tileRow: 0, tileCol: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tile, err := m.GetTile(context.Background(), tt.tileMatrix, tt.tileRow, tt.tileCol)
+			require.NoError(t, err)
+			if tt.want == "" {
+				assert.Nil(t, tile)
+				return
+			}
+			if tt.wantGzip {
+				reader, err := gzip.NewReader(bytes.NewReader(tile))
+				require.NoError(t, err)
+				tile, err = io.ReadAll(reader)
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, string(tile))
+		})
+	}
+}
+
+func TestMBTiles_GetTile_NonQuadtree(t *testing.T) {
+	// tile matrix sets (e.g. national grids) don't necessarily double the number of rows per tile matrix
+	m := NewMBTiles(config.TilesArchiveFile{File: "testdata/tiles.mbtiles"}, map[int]int{1: 3})
+	defer m.Close()
+
+	tile, err := m.GetTile(context.Background(), 1, 1, 1)
+	require.NoError(t, err)
+	reader, err := gzip.NewReader(bytes.NewReader(tile))
+	require.NoError(t, err)
+	tile, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "tile 1/0/1", string(tile)) // stored at TMS row 1, which is row 1 (3-1-1) counted from the top
+}
@@ -0,0 +1,162 @@
+package pmtiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Only version 3 of the PMTiles spec is supported,
+// see https://github.com/protomaps/PMTiles/blob/main/spec/v3/spec.md.
+const (
+	headerLength = 127
+	magic        = "PMTiles"
+	version      = 3
+)
+
+type compression uint8
+
+const (
+	compressionUnknown compression = 0
+	compressionNone    compression = 1
+	compressionGzip    compression = 2
+)
+
+type header struct {
+	rootDirectoryOffset   uint64
+	rootDirectoryLength   uint64
+	leafDirectoriesOffset uint64
+	tileDataOffset        uint64
+	internalCompression   compression
+	tileCompression       compression
+	minZoom               uint8
+	maxZoom               uint8
+}
+
+func readHeader(b []byte) (header, error) {
+	if len(b) < headerLength || string(b[0:7]) != magic {
+		return header{}, errors.New("not a PMTiles archive")
+	}
+	if b[7] != version {
+		return header{}, fmt.Errorf("unsupported PMTiles version %d, only version %d is supported", b[7], version)
+	}
+	h := header{
+		rootDirectoryOffset:   binary.LittleEndian.Uint64(b[8:16]),
+		rootDirectoryLength:   binary.LittleEndian.Uint64(b[16:24]),
+		leafDirectoriesOffset: binary.LittleEndian.Uint64(b[40:48]),
+		tileDataOffset:        binary.LittleEndian.Uint64(b[56:64]),
+		internalCompression:   compression(b[97]),
+		tileCompression:       compression(b[98]),
+		minZoom:               b[100],
+		maxZoom:               b[101],
+	}
+	if h.internalCompression != compressionNone && h.internalCompression != compressionGzip {
+		return header{}, fmt.Errorf("unsupported PMTiles directory compression %d, only none or gzip is supported", h.internalCompression)
+	}
+	if h.tileCompression != compressionUnknown && h.tileCompression != compressionNone && h.tileCompression != compressionGzip {
+		return header{}, fmt.Errorf("unsupported PMTiles tile compression %d, only none or gzip is supported", h.tileCompression)
+	}
+	return h, nil
+}
+
+// entry in a directory, pointing to a run of identical tiles or (when run length is 0) to a leaf directory
+type entry struct {
+	tileID    uint64
+	offset    uint64
+	length    uint32
+	runLength uint32
+}
+
+// readEntries decodes a (decompressed) directory: the number of entries followed by
+// columns of tile ids (delta encoded), run lengths, lengths and offsets. All varints.
+func readEntries(b []byte) ([]entry, error) {
+	r := bytes.NewReader(b)
+	numEntries, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if numEntries > uint64(len(b)) { // every entry takes at least a byte per column
+		return nil, errors.New("invalid PMTiles directory")
+	}
+	entries := make([]entry, numEntries)
+
+	var tileID uint64
+	for i := range entries {
+		delta, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		tileID += delta
+		entries[i].tileID = tileID
+	}
+	for i := range entries {
+		runLength, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		entries[i].runLength = uint32(runLength)
+	}
+	for i := range entries {
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		entries[i].length = uint32(length)
+	}
+	for i := range entries {
+		offset, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if offset == 0 && i > 0 {
+			// tile data directly follows the previous entry
+			entries[i].offset = entries[i-1].offset + uint64(entries[i-1].length)
+		} else {
+			entries[i].offset = offset - 1
+		}
+	}
+	return entries, nil
+}
+
+// zxyToTileID returns the id of the given tile: tiles are numbered by zoom level and
+// within a zoom level along a Hilbert curve, to cluster nearby tiles in the archive.
+func zxyToTileID(z uint8, x, y uint32) uint64 {
+	var id uint64
+	for i := uint8(0); i < z; i++ {
+		id += uint64(1) << (2 * i) // number of tiles in all lower zoom levels
+	}
+	n := uint32(1) << z
+	for s := n / 2; s > 0; s /= 2 {
+		var rx, ry uint32
+		if x&s > 0 {
+			rx = 1
+		}
+		if y&s > 0 {
+			ry = 1
+		}
+		id += uint64(s) * uint64(s) * uint64((3*rx)^ry)
+		// rotate quadrant
+		if ry == 0 {
+			if rx == 1 {
+				x, y = n-1-x, n-1-y
+			}
+			x, y = y, x
+		}
+	}
+	return id
+}
+
+func decompress(b []byte, c compression) ([]byte, error) {
+	if c != compressionGzip {
+		return b, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,138 @@
+package pmtiles
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/PDOK/gokoala/config"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+const (
+	// leaf directories may be nested, but the spec (and common writers) never go beyond this depth
+	maxDirectoryDepth = 4
+
+	// number of leaf directories kept in memory (a leaf directory holds up to thousands of entries)
+	leafDirectoryCacheSize = 64
+)
+
+// PMTiles archive, a single file holding tiles which is read using range reads. Only the
+// header and root directory are kept in memory. See https://github.com/protomaps/PMTiles.
+type PMTiles struct {
+	file   io.ReaderAt
+	closer io.Closer
+	header header
+	root   []entry
+	leaves *lru.Cache[uint64, []entry] // by offset
+}
+
+func NewPMTiles(archive config.TilesArchiveFile) *PMTiles {
+	file, err := os.Open(archive.File)
+	if err != nil {
+		log.Fatalf("failed to open PMTiles: %v", err)
+	}
+	p, err := newPMTiles(file)
+	if err != nil {
+		log.Fatalf("failed to open PMTiles %s: %v", archive.File, err)
+	}
+	p.closer = file
+	log.Printf("opened local PMTiles: %s (zoom levels %d-%d)", archive.File, p.header.minZoom, p.header.maxZoom)
+	return p
+}
+
+func newPMTiles(file io.ReaderAt) (*PMTiles, error) {
+	headerBytes := make([]byte, headerLength)
+	if _, err := file.ReadAt(headerBytes, 0); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	h, err := readHeader(headerBytes)
+	if err != nil {
+		return nil, err
+	}
+	leaves, err := lru.New[uint64, []entry](leafDirectoryCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	p := &PMTiles{file: file, header: h, leaves: leaves}
+	if p.root, err = p.readDirectory(h.rootDirectoryOffset, h.rootDirectoryLength); err != nil {
+		return nil, fmt.Errorf("failed to read root directory: %w", err)
+	}
+	return p, nil
+}
+
+func (p *PMTiles) GetTile(_ context.Context, tileMatrix, tileRow, tileCol int) ([]byte, error) {
+	if tileMatrix < int(p.header.minZoom) || tileMatrix > int(p.header.maxZoom) {
+		return nil, nil
+	}
+	tileID := zxyToTileID(uint8(tileMatrix), uint32(tileCol), uint32(tileRow))
+
+	directory := p.root
+	for depth := 0; depth < maxDirectoryDepth; depth++ {
+		e, ok := findEntry(directory, tileID)
+		if !ok {
+			return nil, nil
+		}
+		if e.runLength > 0 {
+			if e.length == 0 {
+				return nil, nil
+			}
+			tile := make([]byte, e.length)
+			if _, err := p.file.ReadAt(tile, int64(p.header.tileDataOffset+e.offset)); err != nil {
+				return nil, fmt.Errorf("failed to read tile from PMTiles: %w", err)
+			}
+			return tile, nil
+		}
+		// entry points to a leaf directory
+		offset := p.header.leafDirectoriesOffset + e.offset
+		leaf, ok := p.leaves.Get(offset)
+		if !ok {
+			var err error
+			if leaf, err = p.readDirectory(offset, uint64(e.length)); err != nil {
+				return nil, fmt.Errorf("failed to read leaf directory from PMTiles: %w", err)
+			}
+			p.leaves.Add(offset, leaf)
+		}
+		directory = leaf
+	}
+	return nil, fmt.Errorf("failed to read tile from PMTiles, directories nested beyond max depth %d", maxDirectoryDepth)
+}
+
+func (p *PMTiles) Close() {
+	if p.closer == nil {
+		return
+	}
+	if err := p.closer.Close(); err != nil {
+		log.Printf("failed to close PMTiles: %v", err)
+	}
+}
+
+func (p *PMTiles) readDirectory(offset uint64, length uint64) ([]entry, error) {
+	b := make([]byte, length)
+	if _, err := p.file.ReadAt(b, int64(offset)); err != nil {
+		return nil, err
+	}
+	b, err := decompress(b, p.header.internalCompression)
+	if err != nil {
+		return nil, err
+	}
+	return readEntries(b)
+}
+
+// findEntry returns the entry in the (sorted) directory which contains the given tile id, either
+// as tile (run) or as leaf directory
+func findEntry(directory []entry, tileID uint64) (entry, bool) {
+	// index of the last entry with a tile id <= the given tile id
+	i := sort.Search(len(directory), func(i int) bool { return directory[i].tileID > tileID }) - 1
+	if i < 0 {
+		return entry{}, false
+	}
+	e := directory[i]
+	if e.runLength == 0 || tileID < e.tileID+uint64(e.runLength) {
+		return e, true
+	}
+	return entry{}, false
+}
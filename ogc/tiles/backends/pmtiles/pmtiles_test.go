@@ -0,0 +1,65 @@
+package pmtiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/PDOK/gokoala/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPMTiles_GetTile(t *testing.T) {
+	p := NewPMTiles(config.TilesArchiveFile{File: "testdata/tiles.pmtiles"})
+	defer p.Close()
+
+	tests := []struct {
+		name       string
+		tileMatrix int
+		tileRow    int
+		tileCol    int
+		want       string
+	}{
+		{name: "tile in root directory", tileMatrix: 0, tileRow: 0, tileCol: 0, want: "tile 0/0/0"},
+		{name: "row and column", tileMatrix: 1, tileRow: 0, tileCol: 1, want: "tile 1/0/1"},
+		{name: "tile in leaf directory", tileMatrix: 2, tileRow: 2, tileCol: 3, want: "tile 2/2/3"},
+		{name: "first tile of run", tileMatrix: 2, tileRow: 0, tileCol: 0, want: "same tile"},
+		{name: "second tile of run", tileMatrix: 2, tileRow: 0, tileCol: 1, want: "same tile"},
+		{name: "missing tile", tileMatrix: 1, tileRow: 1, tileCol: 1},
+		{name: "missing tile in leaf directory", tileMatrix: 2, tileRow: 3, tileCol: 3},
+		{name: "zoom level beyond archive", tileMatrix: 3, tileRow: 0, tileCol: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tile, err := p.GetTile(context.Background(), tt.tileMatrix, tt.tileRow, tt.tileCol)
+			require.NoError(t, err)
+			if tt.want == "" {
+				assert.Nil(t, tile)
+				return
+			}
+			reader, err := gzip.NewReader(bytes.NewReader(tile))
+			require.NoError(t, err)
+			content, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(content))
+		})
+	}
+}
+
+func TestNewPMTiles_Invalid(t *testing.T) {
+	_, err := newPMTiles(bytes.NewReader([]byte("not a pmtiles archive at all")))
+	assert.Error(t, err)
+}
+
+func TestZxyToTileID(t *testing.T) {
+	assert.Equal(t, uint64(0), zxyToTileID(0, 0, 0))
+	assert.Equal(t, uint64(1), zxyToTileID(1, 0, 0))
+	assert.Equal(t, uint64(2), zxyToTileID(1, 0, 1))
+	assert.Equal(t, uint64(3), zxyToTileID(1, 1, 1))
+	assert.Equal(t, uint64(4), zxyToTileID(1, 1, 0))
+	assert.Equal(t, uint64(5), zxyToTileID(2, 0, 0))
+	assert.Equal(t, uint64(19078479), zxyToTileID(12, 3423, 1763))
+}
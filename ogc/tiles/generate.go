@@ -0,0 +1,172 @@
+package tiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/PDOK/gokoala/config"
+	"github.com/PDOK/gokoala/engine"
+	"github.com/PDOK/gokoala/ogc/features"
+	ds "github.com/PDOK/gokoala/ogc/features/datasources"
+	"github.com/PDOK/gokoala/ogc/features/domain"
+	"github.com/PDOK/gokoala/ogc/tiles/mvt"
+	"github.com/go-spatial/geom"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+// features may change (e.g. writable collections), so generated tiles expire
+const tileCacheTTL = 10 * time.Minute
+
+var errTileNotFound = errors.New("tile not found")
+
+// tileGenerator generates vector tiles on-the-fly from the features of collections
+type tileGenerator struct {
+	features       *features.Features
+	tileMatrixSets map[string]*tileMatrixSet
+	collections    map[string]*config.OnTheFlyTiles
+	caches         map[string]*expirable.LRU[string, []byte] // by collection
+}
+
+func newTileGenerator(e *engine.Engine, f *features.Features, tileMatrixSets map[string]*tileMatrixSet) *tileGenerator {
+	g := &tileGenerator{
+		features:       f,
+		tileMatrixSets: tileMatrixSets,
+		collections:    make(map[string]*config.OnTheFlyTiles),
+		caches:         make(map[string]*expirable.LRU[string, []byte]),
+	}
+	for _, coll := range e.Config.OgcAPI.Tiles.Collections {
+		if coll.Tiles == nil || coll.Tiles.OnTheFly == nil {
+			continue
+		}
+		if f == nil || !e.Config.OgcAPI.Features.Collections.ContainsID(coll.ID) {
+			log.Fatalf("collection %s is configured to generate tiles on-the-fly, "+
+				"but isn't served as features (OGC API Features), check config", coll.ID)
+		}
+		g.collections[coll.ID] = coll.Tiles.OnTheFly
+		g.caches[coll.ID] = expirable.NewLRU[string, []byte](coll.Tiles.OnTheFly.CacheSize, nil, tileCacheTTL)
+	}
+	if len(g.collections) > 0 {
+		f.OnFeaturesChanged(g.purge)
+	}
+	return g
+}
+
+// purge removes all cached tiles of the given collection, to be called when its features have changed
+func (g *tileGenerator) purge(collectionID string) {
+	if cache, ok := g.caches[collectionID]; ok {
+		cache.Purge()
+	}
+}
+
+// generate returns the given tile of the given collection as a Mapbox Vector Tile, which is empty (nil) when
+// there are no features in the tile. Returns errTileNotFound when the tile isn't available.
+func (g *tileGenerator) generate(ctx context.Context, collectionID string, tileMatrixSetID string, zoom, row, col int) ([]byte, error) {
+	cfg, ok := g.collections[collectionID]
+	if !ok {
+		return nil, errTileNotFound
+	}
+	tms, ok := g.tileMatrixSets[tileMatrixSetID]
+	if !ok {
+		return nil, errTileNotFound
+	}
+	bounds, ok := tms.tileBounds(zoom, row, col)
+	if !ok {
+		return nil, errTileNotFound
+	}
+	datasource := g.features.Datasource(collectionID, tms.srid())
+	if datasource == nil {
+		return nil, errTileNotFound
+	}
+
+	key := fmt.Sprintf("%s/%d/%d/%d", tileMatrixSetID, zoom, row, col)
+	if tile, ok := g.caches[collectionID].Get(key); ok {
+		return tile, nil
+	}
+	tile, truncated, err := g.generateTile(ctx, datasource, collectionID, cfg, tms.srid(), bounds)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		// incomplete tiles aren't cached, so features omitted from them are never served from cache
+		log.Printf("Warning: tile %s of collection %s contains more than %d features (maxFeatures), "+
+			"the remaining features are omitted. Consider a higher minimum zoom level for this collection",
+			key, collectionID, cfg.MaxFeatures)
+		return tile, nil
+	}
+	g.caches[collectionID].Add(key, tile)
+	return tile, nil
+}
+
+// generateTile returns the tile with the features in the given bounds, and whether the tile is truncated
+// since there are more features in the bounds than the configured max.
+func (g *tileGenerator) generateTile(ctx context.Context, datasource ds.Datasource, collectionID string,
+	cfg *config.OnTheFlyTiles, srid int, bounds geom.Extent) ([]byte, bool, error) {
+
+	// include features in the buffer around the tile
+	buffer := float64(cfg.Buffer) * (bounds.MaxX() - bounds.MinX()) / float64(cfg.Extent)
+	bbox := geom.Extent{bounds.MinX() - buffer, bounds.MinY() - buffer, bounds.MaxX() + buffer, bounds.MaxY() + buffer}
+
+	it, _, err := datasource.StreamFeatures(ctx, collectionID, ds.FeaturesCriteria{
+		Cursor:     domain.DecodedCursor{FID: 0},
+		Limit:      cfg.MaxFeatures + 1, // to detect truncation
+		InputSRID:  srid,
+		OutputSRID: srid,
+		Bbox:       &bbox,
+	})
+	if err != nil || it == nil {
+		return nil, false, err
+	}
+	defer it.Close()
+
+	layer := mvt.Layer{Name: collectionID, Extent: uint32(cfg.Extent)}
+	truncated := false
+	for read := 0; ; read++ {
+		feature, err := it.Next()
+		if err != nil {
+			return nil, false, err
+		}
+		if feature == nil {
+			break
+		}
+		if read == cfg.MaxFeatures {
+			truncated = true
+			break
+		}
+		if feature.Geometry == nil {
+			continue
+		}
+		geometry := mvt.Prepare(feature.Geometry.Geometry, bounds, layer.Extent, uint32(cfg.Buffer))
+		if geometry == nil {
+			continue
+		}
+		layer.Features = append(layer.Features, mvt.Feature{
+			ID:         featureID(feature),
+			Properties: feature.Properties,
+			Geometry:   geometry,
+		})
+	}
+	if len(layer.Features) == 0 {
+		return nil, truncated, nil
+	}
+	return mvt.Encode(layer), truncated, nil
+}
+
+// featureID returns the numeric id of the given feature, MVT doesn't support other (e.g. external) ids
+func featureID(feature *domain.Feature) uint64 {
+	if feature.FID > 0 {
+		return uint64(feature.FID)
+	}
+	return 0
+}
+
+// parseTile returns the zoom level, row and column of a tile, or false when these aren't valid numbers
+func parseTile(tileMatrix, tileRow, tileCol string) (int, int, int, bool) {
+	zoom, zoomErr := strconv.Atoi(tileMatrix)
+	row, rowErr := strconv.Atoi(tileRow)
+	col, colErr := strconv.Atoi(tileCol)
+	return zoom, row, col, zoomErr == nil && rowErr == nil && colErr == nil
+}
@@ -0,0 +1,60 @@
+package tiles
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PDOK/gokoala/engine"
+	"github.com/PDOK/gokoala/ogc/features"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTileGenerator_Generate(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxFeatures int
+		wantCached  bool
+	}{
+		{
+			name:        "complete tile is cached",
+			maxFeatures: 10000,
+			wantCached:  true,
+		},
+		{
+			name:        "truncated tile isn't cached",
+			maxFeatures: 1,
+			wantCached:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newEngine, err := engine.NewEngine("ogc/tiles/testdata/config_tiles_onthefly.yaml", "", false, true)
+			require.NoError(t, err)
+			newEngine.Config.OgcAPI.Tiles.Collections[0].Tiles.OnTheFly.MaxFeatures = tt.maxFeatures
+			tiles := NewTiles(newEngine, features.NewFeatures(newEngine))
+			generator := tiles.generator
+
+			tile, err := generator.generate(context.Background(), "addresses", "WebMercatorQuad", 14, 5383, 8414)
+			require.NoError(t, err)
+			assert.NotEmpty(t, tile)
+			assert.Equal(t, tt.wantCached, generator.caches["addresses"].Len() == 1)
+		})
+	}
+}
+
+func TestTileGenerator_Purge(t *testing.T) {
+	newEngine, err := engine.NewEngine("ogc/tiles/testdata/config_tiles_onthefly.yaml", "", false, true)
+	require.NoError(t, err)
+	tiles := NewTiles(newEngine, features.NewFeatures(newEngine))
+	generator := tiles.generator
+
+	_, err = generator.generate(context.Background(), "addresses", "WebMercatorQuad", 14, 5383, 8414)
+	require.NoError(t, err)
+	require.Equal(t, 1, generator.caches["addresses"].Len())
+
+	generator.purge("other")
+	assert.Equal(t, 1, generator.caches["addresses"].Len())
+	generator.purge("addresses")
+	assert.Equal(t, 0, generator.caches["addresses"].Len())
+}
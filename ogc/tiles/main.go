@@ -1,13 +1,25 @@
 package tiles
 
 import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/PDOK/gokoala/config"
 	"github.com/PDOK/gokoala/engine"
 	"github.com/PDOK/gokoala/ogc/common/geospatial"
+	"github.com/PDOK/gokoala/ogc/features"
+	"github.com/PDOK/gokoala/ogc/tiles/backends"
+	"github.com/PDOK/gokoala/ogc/tiles/backends/mbtiles"
+	"github.com/PDOK/gokoala/ogc/tiles/backends/pmtiles"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -17,14 +29,42 @@ const (
 	tilesLocalPath          = "tiles/"
 	tileMatrixSetsPath      = "/tileMatrixSets"
 	tileMatrixSetsLocalPath = "tileMatrixSets/"
-	defaultTilesTmpl        = "{tms}/{z}/{x}/{y}." + engine.FormatMVTAlternative
+	tileMatrixSetTmpl       = "tileMatrixSet.go."
+	tilesetTmpl             = "tileset.go."
+	defaultTilesTmpl        = "{tms}/{z}/{x}/{y}."
+)
+
+var (
+	tileMediaTypes = map[string]string{
+		engine.FormatMVT:  engine.MediaTypeMVT,
+		engine.FormatPNG:  engine.MediaTypePNG,
+		engine.FormatJPEG: engine.MediaTypeJPEG,
+		engine.FormatWebP: engine.MediaTypeWebP,
+	}
+	tileExtensions = map[string]string{
+		engine.FormatMVT:  engine.FormatMVTAlternative,
+		engine.FormatPNG:  engine.FormatPNG,
+		engine.FormatJPEG: engine.FormatJPEGAlternative,
+		engine.FormatWebP: engine.FormatWebP,
+	}
 )
 
 type Tiles struct {
-	engine *engine.Engine
+	engine         *engine.Engine
+	formats        []string                        // offered tile formats, the first one is the default
+	tileMatrixSets map[string]*tileMatrixSet       // by tile matrix set id
+	backends       map[archiveKey]backends.Backend // local archives
+	generator      *tileGenerator
+}
+
+type archiveKey struct {
+	tileMatrixSet string
+	tilesType     config.TilesType
 }
 
-func NewTiles(e *engine.Engine) *Tiles {
+// NewTiles sets up OGC API Tiles. Features are optional and only
+// required to generate tiles on-the-fly (when configured).
+func NewTiles(e *engine.Engine, f *features.Features) *Tiles {
 	tilesBreadcrumbs := []engine.Breadcrumb{
 		{
 			Name: "Tiles",
@@ -42,21 +82,34 @@ func NewTiles(e *engine.Engine) *Tiles {
 		tilesBreadcrumbs,
 		engine.NewTemplateKey(templatesDir+"tiles.go.json"),
 		engine.NewTemplateKey(templatesDir+"tiles.go.html"))
-	e.RenderTemplates(tileMatrixSetsPath,
+
+	tileMatrixSets, err := newTileMatrixSets(e.Config.OgcAPI.Tiles)
+	if err != nil {
+		log.Fatalf("failed to load tile matrix sets: %v", err)
+	}
+	e.RenderTemplatesWithParams(tileMatrixSets,
 		tileMatrixSetsBreadcrumbs,
 		engine.NewTemplateKey(templatesDir+"tileMatrixSets.go.json"),
 		engine.NewTemplateKey(templatesDir+"tileMatrixSets.go.html"))
 
-	renderTemplatesForSrs(e, "EuropeanETRS89_LAEAQuad", tilesBreadcrumbs, tileMatrixSetsBreadcrumbs)
-	renderTemplatesForSrs(e, "NetherlandsRDNewQuad", tilesBreadcrumbs, tileMatrixSetsBreadcrumbs)
-	renderTemplatesForSrs(e, "WebMercatorQuad", tilesBreadcrumbs, tileMatrixSetsBreadcrumbs)
+	tileMatrixSetsByID := make(map[string]*tileMatrixSet)
+	for _, tms := range tileMatrixSets {
+		renderTemplatesForTileMatrixSet(e, tms, tilesBreadcrumbs, tileMatrixSetsBreadcrumbs)
+		tileMatrixSetsByID[tms.ID] = tms
+	}
 
-	_, err := url.ParseRequestURI(e.Config.OgcAPI.Tiles.TileServer.String())
-	if err != nil {
-		log.Fatalf("invalid tileserver url provided: %v", err)
+	if e.Config.OgcAPI.Tiles.HasTileServer() {
+		_, err := url.ParseRequestURI(e.Config.OgcAPI.Tiles.TileServer.String())
+		if err != nil {
+			log.Fatalf("invalid tileserver url provided: %v", err)
+		}
 	}
 	tiles := &Tiles{
-		engine: e,
+		engine:         e,
+		formats:        tileFormats(e.Config.OgcAPI.Tiles),
+		tileMatrixSets: tileMatrixSetsByID,
+		backends:       newBackends(e, tileMatrixSetsByID),
+		generator:      newTileGenerator(e, f, tileMatrixSetsByID),
 	}
 
 	e.Router.Get(tileMatrixSetsPath, tiles.TileMatrixSets())
@@ -65,40 +118,74 @@ func NewTiles(e *engine.Engine) *Tiles {
 	e.Router.Get(tilesPath+"/{tileMatrixSetId}", tiles.Tileset())
 	e.Router.Head(tilesPath+"/{tileMatrixSetId}/{tileMatrix}/{tileRow}/{tileCol}", tiles.Tile())
 	e.Router.Get(tilesPath+"/{tileMatrixSetId}/{tileMatrix}/{tileRow}/{tileCol}", tiles.Tile())
-	e.Router.Get(geospatial.CollectionsPath+"/{collectionId}/tiles", tiles.TilesCollection())
+	if len(tiles.generator.collections) > 0 {
+		collectionTilePath := geospatial.CollectionsPath + "/{collectionId}/tiles/{tileMatrixSetId}/{tileMatrix}/{tileRow}/{tileCol}"
+		e.Router.Head(collectionTilePath, tiles.TilesCollection())
+		e.Router.Get(collectionTilePath, tiles.TilesCollection())
+	}
 
 	return tiles
 }
 
-func renderTemplatesForSrs(e *engine.Engine, srs string, tilesBreadcrumbs []engine.Breadcrumb, tileMatrixSetsBreadcrumbs []engine.Breadcrumb) {
-	tilesSrsBreadcrumbs := tilesBreadcrumbs
-	tilesSrsBreadcrumbs = append(tilesSrsBreadcrumbs, []engine.Breadcrumb{
+// tileFormats returns the offered tile formats: MVT for vector tiles and/or the configured raster format
+func tileFormats(cfg *config.OgcAPITiles) []string {
+	var formats []string
+	if cfg.HasType(config.TilesTypeVector) {
+		formats = append(formats, engine.FormatMVT)
+	}
+	if cfg.HasType(config.TilesTypeRaster) {
+		formats = append(formats, string(cfg.RasterFormat))
+	}
+	return formats
+}
+
+func newBackends(e *engine.Engine, tileMatrixSets map[string]*tileMatrixSet) map[archiveKey]backends.Backend {
+	result := make(map[archiveKey]backends.Backend)
+	for _, archive := range e.Config.OgcAPI.Tiles.Archives {
+		var backend backends.Backend
+		if archive.MBTiles != nil {
+			tms, ok := tileMatrixSets[archive.TileMatrixSet]
+			if !ok {
+				log.Fatalf("unknown tile matrix set %s for MBTiles archive %s", archive.TileMatrixSet, archive.MBTiles.File)
+			}
+			backend = mbtiles.NewMBTiles(*archive.MBTiles, tms.matrixHeights())
+		} else if archive.PMTiles != nil {
+			backend = pmtiles.NewPMTiles(*archive.PMTiles)
+		}
+		e.RegisterShutdownHook(backend.Close)
+		result[archiveKey{archive.TileMatrixSet, archive.Type}] = backend
+	}
+	return result
+}
+
+func renderTemplatesForTileMatrixSet(e *engine.Engine, tms *tileMatrixSet,
+	tilesBreadcrumbs []engine.Breadcrumb, tileMatrixSetsBreadcrumbs []engine.Breadcrumb) {
+
+	tilesetBreadcrumbs := tilesBreadcrumbs
+	tilesetBreadcrumbs = append(tilesetBreadcrumbs, []engine.Breadcrumb{
 		{
-			Name: srs,
-			Path: tilesLocalPath + srs,
+			Name: tms.ID,
+			Path: tilesLocalPath + tms.ID,
 		},
 	}...)
-	tileMatrixSetsSrsBreadcrumbs := tileMatrixSetsBreadcrumbs
-	tileMatrixSetsSrsBreadcrumbs = append(tileMatrixSetsSrsBreadcrumbs, []engine.Breadcrumb{
+	tileMatrixSetBreadcrumbs := tileMatrixSetsBreadcrumbs
+	tileMatrixSetBreadcrumbs = append(tileMatrixSetBreadcrumbs, []engine.Breadcrumb{
 		{
-			Name: srs,
-			Path: tileMatrixSetsLocalPath + srs,
+			Name: tms.ID,
+			Path: tileMatrixSetsLocalPath + tms.ID,
 		},
 	}...)
 
-	e.RenderTemplates(tileMatrixSetsPath+"/"+srs,
-		tileMatrixSetsSrsBreadcrumbs,
-		engine.NewTemplateKey(templatesDir+tileMatrixSetsLocalPath+srs+".go.json"),
-		engine.NewTemplateKey(templatesDir+tileMatrixSetsLocalPath+srs+".go.html"))
-
-	e.RenderTemplates(tilesPath+"/"+srs,
-		tilesSrsBreadcrumbs,
-		engine.NewTemplateKey(templatesDir+tilesLocalPath+srs+".go.json"),
-		engine.NewTemplateKey(templatesDir+tilesLocalPath+srs+".go.html"))
+	e.RenderTemplatesWithParams(tms,
+		tileMatrixSetBreadcrumbs,
+		engine.NewTemplateKeyWithName(templatesDir+tileMatrixSetsLocalPath+tileMatrixSetTmpl+engine.FormatJSON, tms.ID),
+		engine.NewTemplateKeyWithName(templatesDir+tileMatrixSetsLocalPath+tileMatrixSetTmpl+engine.FormatHTML, tms.ID))
 
-	e.RenderTemplates(tilesPath+"/"+srs,
-		tilesSrsBreadcrumbs,
-		engine.NewTemplateKey(templatesDir+tilesLocalPath+srs+".go.tilejson"))
+	e.RenderTemplatesWithParams(tms,
+		tilesetBreadcrumbs,
+		engine.NewTemplateKeyWithName(templatesDir+tilesLocalPath+tilesetTmpl+engine.FormatJSON, tms.ID),
+		engine.NewTemplateKeyWithName(templatesDir+tilesLocalPath+tilesetTmpl+engine.FormatHTML, tms.ID),
+		engine.NewTemplateKeyWithName(templatesDir+tilesLocalPath+tilesetTmpl+engine.FormatTileJSON, tms.ID))
 }
 
 func (t *Tiles) TileMatrixSets() http.HandlerFunc {
@@ -111,7 +198,7 @@ func (t *Tiles) TileMatrixSets() http.HandlerFunc {
 func (t *Tiles) TileMatrixSet() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tileMatrixSetID := chi.URLParam(r, "tileMatrixSetId")
-		key := engine.NewTemplateKeyWithLanguage(templatesDir+tileMatrixSetsLocalPath+tileMatrixSetID+".go."+t.engine.CN.NegotiateFormat(r), t.engine.CN.NegotiateLanguage(w, r))
+		key := engine.NewTemplateKeyWithNameAndLanguage(templatesDir+tileMatrixSetsLocalPath+tileMatrixSetTmpl+t.engine.CN.NegotiateFormat(r), tileMatrixSetID, t.engine.CN.NegotiateLanguage(w, r))
 		t.engine.ServePage(w, r, key)
 	}
 }
@@ -126,12 +213,13 @@ func (t *Tiles) TilesetsList() http.HandlerFunc {
 func (t *Tiles) Tileset() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tileMatrixSetID := chi.URLParam(r, "tileMatrixSetId")
-		key := engine.NewTemplateKeyWithLanguage(templatesDir+tilesLocalPath+tileMatrixSetID+".go."+t.engine.CN.NegotiateFormat(r), t.engine.CN.NegotiateLanguage(w, r))
+		key := engine.NewTemplateKeyWithNameAndLanguage(templatesDir+tilesLocalPath+tilesetTmpl+t.engine.CN.NegotiateFormat(r), tileMatrixSetID, t.engine.CN.NegotiateLanguage(w, r))
 		t.engine.ServePage(w, r, key)
 	}
 }
 
-// Tile reverse proxy to Azure Blob, assumes blob bucket/container is public
+// Tile serves a tile from a local archive (MBTiles or PMTiles) when configured for the tile matrix set. Otherwise,
+// acts as reverse proxy to the tileserver (e.g. Azure Blob), assumes blob bucket/container is public
 func (t *Tiles) Tile() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tileMatrixSetID := chi.URLParam(r, "tileMatrixSetId")
@@ -141,21 +229,50 @@ func (t *Tiles) Tile() http.HandlerFunc {
 
 		// We support content negotiation using Accept header and ?f= param, but also
 		// using the .pbf extension. This is for backwards compatibility.
+		// When no format is specified, default to the first offered format.
+		format := engine.FormatMVT
 		if !strings.HasSuffix(tileCol, ".pbf") {
-			// if no format is specified, default to mvt
-			if format := strings.Replace(t.engine.CN.NegotiateFormat(r), engine.FormatJSON, engine.FormatMVT, 1); format != engine.FormatMVT && format != engine.FormatMVTAlternative {
-				engine.RenderProblem(engine.ProblemBadRequest, w, "Specify tile format. Currently only Mapbox Vector Tiles (?f=mvt) tiles are supported")
-				return
-			}
+			format = t.engine.CN.NegotiateFormatOf(r, t.formats)
 		} else {
 			tileCol = tileCol[:len(tileCol)-4] // remove .pbf extension
 		}
+		if !slices.Contains(t.formats, format) {
+			engine.RenderProblem(engine.ProblemBadRequest, w, "Specify tile format. Supported formats are: "+strings.Join(t.formats, ", "))
+			return
+		}
+		tilesType := config.TilesTypeRaster
+		if format == engine.FormatMVT {
+			tilesType = config.TilesTypeVector
+		}
+
+		zoom, row, col, ok := parseTile(tileMatrix, tileRow, tileCol)
+		if !ok {
+			engine.RenderProblem(engine.ProblemBadRequest, w, "tile matrix, row and column should be numbers")
+			return
+		}
+		// OGC spec: tiles outside the tile matrix set limits (zoom level range and extent) result in 404
+		if tms, ok := t.tileMatrixSets[tileMatrixSetID]; !ok || !tms.hasTile(zoom, row, col) {
+			engine.RenderProblem(engine.ProblemNotFound, w, fmt.Sprintf(
+				"tile %s/%s/%s/%s is outside the tile matrix set limits", tileMatrixSetID, tileMatrix, tileRow, tileCol))
+			return
+		}
+
+		if backend, ok := t.backends[archiveKey{tileMatrixSetID, tilesType}]; ok {
+			t.serveFromBackend(w, r, backend, tileMediaTypes[format], tileMatrixSetID, zoom, row, col)
+			return
+		}
+		if !t.engine.Config.OgcAPI.Tiles.HasTileServer() {
+			engine.RenderProblem(engine.ProblemNotFound, w)
+			return
+		}
 
 		// ogc spec is (default) z/row/col but tileserver is z/col/row (z/x/y)
 		replacer := strings.NewReplacer("{tms}", tileMatrixSetID, "{z}", tileMatrix, "{x}", tileCol, "{y}", tileRow)
-		tilesTmpl := defaultTilesTmpl
-		if t.engine.Config.OgcAPI.Tiles.URITemplateTiles != nil {
+		tilesTmpl := defaultTilesTmpl + tileExtensions[format]
+		if tilesType == config.TilesTypeVector && t.engine.Config.OgcAPI.Tiles.URITemplateTiles != nil {
 			tilesTmpl = *t.engine.Config.OgcAPI.Tiles.URITemplateTiles
+		} else if tilesType == config.TilesTypeRaster && t.engine.Config.OgcAPI.Tiles.URITemplateRasterTiles != nil {
+			tilesTmpl = *t.engine.Config.OgcAPI.Tiles.URITemplateRasterTiles
 		}
 		path, _ := url.JoinPath("/", replacer.Replace(tilesTmpl))
 
@@ -165,15 +282,97 @@ func (t *Tiles) Tile() http.HandlerFunc {
 			engine.RenderProblem(engine.ProblemServerError, w)
 			return
 		}
-		t.engine.ReverseProxy(w, r, target, true, engine.MediaTypeMVT)
+		t.engine.ReverseProxy(w, r, target, true, tileMediaTypes[format])
+	}
+}
+
+func (t *Tiles) serveFromBackend(w http.ResponseWriter, r *http.Request, backend backends.Backend,
+	mediaType, tileMatrixSetID string, zoom, row, col int) {
+
+	tile, err := backend.GetTile(r.Context(), zoom, row, col)
+	if err != nil {
+		log.Printf("failed to read tile %s/%d/%d/%d from archive: %v", tileMatrixSetID, zoom, row, col, err)
+		engine.RenderProblem(engine.ProblemServerError, w)
+		return
 	}
+	writeTile(w, r, tile, mediaType)
 }
 
-func (t *Tiles) TilesCollection(_ ...any) http.HandlerFunc {
-	return func(_ http.ResponseWriter, r *http.Request) {
+// TilesCollection serves vector tiles of a collection, generated on-the-fly from its features
+func (t *Tiles) TilesCollection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		collectionID := chi.URLParam(r, "collectionId")
+		tileMatrixSetID := chi.URLParam(r, "tileMatrixSetId")
+		tileCol := chi.URLParam(r, "tileCol")
+
+		// same content negotiation as for pre-generated tiles (see Tile()), but generated tiles are always vector tiles
+		if !strings.HasSuffix(tileCol, ".pbf") {
+			if format := t.engine.CN.NegotiateFormatOf(r, []string{engine.FormatMVT}); format != engine.FormatMVT {
+				engine.RenderProblem(engine.ProblemBadRequest, w, "Specify tile format. Supported formats are: "+engine.FormatMVT)
+				return
+			}
+		} else {
+			tileCol = tileCol[:len(tileCol)-4] // remove .pbf extension
+		}
+		zoom, row, col, ok := parseTile(chi.URLParam(r, "tileMatrix"), chi.URLParam(r, "tileRow"), tileCol)
+		if !ok {
+			engine.RenderProblem(engine.ProblemBadRequest, w, "tile matrix, row and column should be numbers")
+			return
+		}
 
-		// TODO: not implemented, since we don't (yet) support tile collections
-		log.Printf("TODO: return tiles for collection %s", collectionID)
+		tile, err := t.generator.generate(r.Context(), collectionID, tileMatrixSetID, zoom, row, col)
+		if err != nil {
+			if errors.Is(err, errTileNotFound) {
+				engine.RenderProblem(engine.ProblemNotFound, w)
+				return
+			}
+			log.Printf("failed to generate tile %s/%d/%d/%d for collection %s: %v",
+				tileMatrixSetID, zoom, row, col, collectionID, err)
+			engine.RenderProblem(engine.ProblemServerError, w)
+			return
+		}
+		writeTile(w, r, tile, engine.MediaTypeMVT)
+	}
+}
+
+// writeTile writes the given tile with the given media type, or 204 No Content when the tile is empty (nil). Tiles which are
+// stored gzip compressed are sent as-is to clients accepting gzip, and decompressed for other clients.
+func writeTile(w http.ResponseWriter, r *http.Request, tile []byte, mediaType string) {
+	if tile == nil {
+		// OGC spec: an empty tile within the tile matrix set (limits) results in 204 or 200, same as when proxying
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set(engine.HeaderContentType, mediaType)
+	if isGzip(tile) {
+		w.Header().Add(engine.HeaderVary, engine.HeaderAcceptEncoding)
+		if strings.Contains(r.Header.Get(engine.HeaderAcceptEncoding), engine.FormatGzip) {
+			w.Header().Set(engine.HeaderContentEncoding, engine.FormatGzip)
+		} else {
+			decompressed, err := gunzip(tile)
+			if err != nil {
+				log.Printf("failed to decompress tile: %v", err)
+				engine.RenderProblem(engine.ProblemServerError, w)
+				return
+			}
+			tile = decompressed
+		}
+	}
+	if engine.NotModified(w, r, engine.NewETag(tile), time.Time{}) {
+		return
+	}
+	engine.SafeWrite(w.Write, tile)
+}
+
+func isGzip(b []byte) bool {
+	return len(b) > 2 && b[0] == 0x1f && b[1] == 0x8b
+}
+
+func gunzip(b []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
 	}
+	defer reader.Close()
+	return io.ReadAll(reader)
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/PDOK/gokoala/config"
 
 	"github.com/PDOK/gokoala/engine"
+	"github.com/PDOK/gokoala/ogc/features"
 	"golang.org/x/text/language"
 
 	"github.com/go-chi/chi/v5"
@@ -106,7 +107,7 @@ func TestNewTiles(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			tiles := NewTiles(test.args.e)
+			tiles := NewTiles(test.args.e, nil)
 			assert.NotEmpty(t, tiles.engine.Templates.RenderedTemplates)
 		})
 	}
@@ -122,8 +123,9 @@ func TestTiles_Tile(t *testing.T) {
 		tileCol         string
 	}
 	type want struct {
-		body       string
-		statusCode int
+		body        string
+		statusCode  int
+		contentType string
 	}
 	tests := []struct {
 		name   string
@@ -216,7 +218,7 @@ func TestTiles_Tile(t *testing.T) {
 				tileCol:         "15",
 			},
 			want: want{
-				body:       "Specify tile format. Currently only Mapbox Vector Tiles (?f=mvt) tiles are supported",
+				body:       "Specify tile format. Supported formats are: mvt",
 				statusCode: http.StatusBadRequest,
 			},
 		},
@@ -225,16 +227,185 @@ func TestTiles_Tile(t *testing.T) {
 			fields: fields{
 				configFile:      "ogc/tiles/testdata/config_minimal_tiles_2.yaml",
 				url:             "http://localhost:8080/tiles/:tileMatrixSetId/:tileMatrix/:tileRow/:tileCol?f=mvt",
-				tileMatrixSetID: "NetherlandsRDNewQuad",
+				tileMatrixSetID: "EuropeanETRS89_LAEAQuad",
 				tileMatrix:      "5",
 				tileRow:         "10",
 				tileCol:         "15",
 			},
 			want: want{
-				body:       "/foo/NetherlandsRDNewQuad/5/10/15",
+				body:       "/foo/EuropeanETRS89_LAEAQuad/5/10/15",
 				statusCode: http.StatusOK,
 			},
 		},
+		{
+			name: "zoom level outside zoom level range",
+			fields: fields{
+				configFile:      "ogc/tiles/testdata/config_minimal_tiles.yaml",
+				url:             "http://localhost:8080/tiles/:tileMatrixSetId/:tileMatrix/:tileRow/:tileCol?f=mvt",
+				tileMatrixSetID: "NetherlandsRDNewQuad",
+				tileMatrix:      "13",
+				tileRow:         "0",
+				tileCol:         "0",
+			},
+			want: want{
+				body:       "tile NetherlandsRDNewQuad/13/0/0 is outside the tile matrix set limits",
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name: "tile outside tile matrix",
+			fields: fields{
+				configFile:      "ogc/tiles/testdata/config_minimal_tiles.yaml",
+				url:             "http://localhost:8080/tiles/:tileMatrixSetId/:tileMatrix/:tileRow/:tileCol?f=mvt",
+				tileMatrixSetID: "NetherlandsRDNewQuad",
+				tileMatrix:      "1",
+				tileRow:         "2",
+				tileCol:         "0",
+			},
+			want: want{
+				body:       "tile NetherlandsRDNewQuad/1/2/0 is outside the tile matrix set limits",
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name: "tile matrix set not offered",
+			fields: fields{
+				configFile:      "ogc/tiles/testdata/config_minimal_tiles.yaml",
+				url:             "http://localhost:8080/tiles/:tileMatrixSetId/:tileMatrix/:tileRow/:tileCol?f=mvt",
+				tileMatrixSetID: "EuropeanETRS89_LAEAQuad",
+				tileMatrix:      "0",
+				tileRow:         "0",
+				tileCol:         "0",
+			},
+			want: want{
+				body:       "outside the tile matrix set limits",
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name: "non-numeric tile",
+			fields: fields{
+				configFile:      "ogc/tiles/testdata/config_minimal_tiles.yaml",
+				url:             "http://localhost:8080/tiles/:tileMatrixSetId/:tileMatrix/:tileRow/:tileCol?f=mvt",
+				tileMatrixSetID: "NetherlandsRDNewQuad",
+				tileMatrix:      "5",
+				tileRow:         "foo",
+				tileCol:         "0",
+			},
+			want: want{
+				body:       "tile matrix, row and column should be numbers",
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "tile within dataset extent",
+			fields: fields{
+				configFile:      "ogc/tiles/testdata/config_tiles_extent.yaml",
+				url:             "http://localhost:8080/tiles/:tileMatrixSetId/:tileMatrix/:tileRow/:tileCol?f=mvt",
+				tileMatrixSetID: "WebMercatorQuad",
+				tileMatrix:      "5",
+				tileRow:         "10",
+				tileCol:         "16",
+			},
+			want: want{
+				body:       "/WebMercatorQuad/5/16/10.pbf",
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name: "tile outside dataset extent",
+			fields: fields{
+				configFile:      "ogc/tiles/testdata/config_tiles_extent.yaml",
+				url:             "http://localhost:8080/tiles/:tileMatrixSetId/:tileMatrix/:tileRow/:tileCol?f=mvt",
+				tileMatrixSetID: "WebMercatorQuad",
+				tileMatrix:      "5",
+				tileRow:         "10",
+				tileCol:         "17",
+			},
+			want: want{
+				body:       "tile WebMercatorQuad/5/10/17 is outside the tile matrix set limits",
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name: "raster WebMercatorQuad/5/10/15?f=jpeg",
+			fields: fields{
+				configFile:      "ogc/tiles/testdata/config_tiles_raster.yaml",
+				url:             "http://localhost:8080/tiles/:tileMatrixSetId/:tileMatrix/:tileRow/:tileCol?f=jpeg",
+				tileMatrixSetID: "WebMercatorQuad",
+				tileMatrix:      "5",
+				tileRow:         "10",
+				tileCol:         "15",
+			},
+			want: want{
+				body:        "/WebMercatorQuad/5/15/10.jpg",
+				statusCode:  http.StatusOK,
+				contentType: engine.MediaTypeJPEG,
+			},
+		},
+		{
+			name: "raster WebMercatorQuad/5/10/15?f=jpg",
+			fields: fields{
+				configFile:      "ogc/tiles/testdata/config_tiles_raster.yaml",
+				url:             "http://localhost:8080/tiles/:tileMatrixSetId/:tileMatrix/:tileRow/:tileCol?f=jpg",
+				tileMatrixSetID: "WebMercatorQuad",
+				tileMatrix:      "5",
+				tileRow:         "10",
+				tileCol:         "15",
+			},
+			want: want{
+				body:        "/WebMercatorQuad/5/15/10.jpg",
+				statusCode:  http.StatusOK,
+				contentType: engine.MediaTypeJPEG,
+			},
+		},
+		{
+			name: "vector tile by default when both vector and raster tiles are offered",
+			fields: fields{
+				configFile:      "ogc/tiles/testdata/config_tiles_raster.yaml",
+				url:             "http://localhost:8080/tiles/:tileMatrixSetId/:tileMatrix/:tileRow/:tileCol",
+				tileMatrixSetID: "WebMercatorQuad",
+				tileMatrix:      "5",
+				tileRow:         "10",
+				tileCol:         "15",
+			},
+			want: want{
+				body:        "/WebMercatorQuad/5/15/10.pbf",
+				statusCode:  http.StatusOK,
+				contentType: engine.MediaTypeMVT,
+			},
+		},
+		{
+			name: "raster tile from local archive",
+			fields: fields{
+				configFile:      "ogc/tiles/testdata/config_tiles_raster.yaml",
+				url:             "http://localhost:8080/tiles/:tileMatrixSetId/:tileMatrix/:tileRow/:tileCol?f=jpeg",
+				tileMatrixSetID: "NetherlandsRDNewQuad",
+				tileMatrix:      "1",
+				tileRow:         "0",
+				tileCol:         "1",
+			},
+			want: want{
+				body:        "tile 1/0/1",
+				statusCode:  http.StatusOK,
+				contentType: engine.MediaTypeJPEG,
+			},
+		},
+		{
+			name: "raster format not offered",
+			fields: fields{
+				configFile:      "ogc/tiles/testdata/config_tiles_raster.yaml",
+				url:             "http://localhost:8080/tiles/:tileMatrixSetId/:tileMatrix/:tileRow/:tileCol?f=png",
+				tileMatrixSetID: "WebMercatorQuad",
+				tileMatrix:      "5",
+				tileRow:         "10",
+				tileCol:         "15",
+			},
+			want: want{
+				body:       "Specify tile format. Supported formats are: mvt, jpeg",
+				statusCode: http.StatusBadRequest,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -247,12 +418,15 @@ func TestTiles_Tile(t *testing.T) {
 
 			newEngine, err := engine.NewEngine(tt.fields.configFile, "", false, true)
 			assert.NoError(t, err)
-			tiles := NewTiles(newEngine)
+			tiles := NewTiles(newEngine, nil)
 			handler := tiles.Tile()
 			handler.ServeHTTP(rr, req)
 
 			assert.Equal(t, tt.want.statusCode, rr.Code)
 			assert.Contains(t, rr.Body.String(), tt.want.body)
+			if tt.want.contentType != "" {
+				assert.Equal(t, tt.want.contentType, rr.Header().Get(engine.HeaderContentType))
+			}
 		})
 	}
 }
@@ -305,7 +479,7 @@ func TestTile_TilesetsList(t *testing.T) {
 
 			newEngine, err := engine.NewEngine(tt.fields.configFile, "", false, true)
 			assert.NoError(t, err)
-			tiles := NewTiles(newEngine)
+			tiles := NewTiles(newEngine, nil)
 			handler := tiles.TilesetsList()
 			handler.ServeHTTP(rr, req)
 
@@ -366,6 +540,18 @@ func TestTile_Tileset(t *testing.T) {
 				statusCode:   http.StatusOK,
 			},
 		},
+		{
+			name: "Custom UTM31Grid",
+			fields: fields{
+				configFile:      "ogc/tiles/testdata/config_tiles_custom_tms.yaml",
+				url:             "http://localhost:8080/tiles/UTM31Grid?f=json",
+				tileMatrixSetID: "UTM31Grid",
+			},
+			want: want{
+				bodyContains: "\"maxTileRow\": 79",
+				statusCode:   http.StatusOK,
+			},
+		},
 		{
 			name: "Invalid",
 			fields: fields{
@@ -390,7 +576,7 @@ func TestTile_Tileset(t *testing.T) {
 
 			newEngine, err := engine.NewEngine(tt.fields.configFile, "", false, true)
 			assert.NoError(t, err)
-			tiles := NewTiles(newEngine)
+			tiles := NewTiles(newEngine, nil)
 			handler := tiles.Tileset()
 			handler.ServeHTTP(rr, req)
 
@@ -451,6 +637,18 @@ func TestTile_TilematrixSet(t *testing.T) {
 				statusCode:   http.StatusOK,
 			},
 		},
+		{
+			name: "Custom UTM31Grid",
+			fields: fields{
+				configFile:      "ogc/tiles/testdata/config_tiles_custom_tms.yaml",
+				url:             "http://localhost:8080/tileMatrixSets/UTM31Grid?f=json",
+				tileMatrixSetID: "UTM31Grid",
+			},
+			want: want{
+				bodyContains: "\"crs\": \"http://www.opengis.net/def/crs/EPSG/0/32631\"",
+				statusCode:   http.StatusOK,
+			},
+		},
 		{
 			name: "Invalid",
 			fields: fields{
@@ -475,7 +673,7 @@ func TestTile_TilematrixSet(t *testing.T) {
 
 			newEngine, err := engine.NewEngine(tt.fields.configFile, "", false, true)
 			assert.NoError(t, err)
-			tiles := NewTiles(newEngine)
+			tiles := NewTiles(newEngine, nil)
 			handler := tiles.TileMatrixSet()
 			handler.ServeHTTP(rr, req)
 
@@ -544,7 +742,7 @@ func TestTile_TilematrixSets(t *testing.T) {
 
 			newEngine, err := engine.NewEngine(tt.fields.configFile, "", false, true)
 			assert.NoError(t, err)
-			tiles := NewTiles(newEngine)
+			tiles := NewTiles(newEngine, nil)
 			handler := tiles.TileMatrixSets()
 			handler.ServeHTTP(rr, req)
 
@@ -614,3 +812,199 @@ func createTilematrixSetsRequest(url string) (*http.Request, error) {
 	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
 	return req, err
 }
+
+func TestTiles_TilesCollection(t *testing.T) {
+	tests := []struct {
+		name            string
+		tileMatrixSetID string
+		tileMatrix      string
+		tileRow         string
+		tileCol         string
+		wantStatusCode  int
+	}{
+		{
+			name:            "tile with features (addresses in Amsterdam)",
+			tileMatrixSetID: "WebMercatorQuad",
+			tileMatrix:      "14",
+			tileRow:         "5383",
+			tileCol:         "8414",
+			wantStatusCode:  http.StatusOK,
+		},
+		{
+			name:            "tile with features and .pbf extension",
+			tileMatrixSetID: "WebMercatorQuad",
+			tileMatrix:      "14",
+			tileRow:         "5383",
+			tileCol:         "8414.pbf",
+			wantStatusCode:  http.StatusOK,
+		},
+		{
+			name:            "empty tile",
+			tileMatrixSetID: "WebMercatorQuad",
+			tileMatrix:      "14",
+			tileRow:         "5000",
+			tileCol:         "8414",
+			wantStatusCode:  http.StatusNoContent,
+		},
+		{
+			name:            "zoom level not supported",
+			tileMatrixSetID: "WebMercatorQuad",
+			tileMatrix:      "17",
+			tileRow:         "5383",
+			tileCol:         "8414",
+			wantStatusCode:  http.StatusNotFound,
+		},
+		{
+			name:            "tile outside tile matrix",
+			tileMatrixSetID: "WebMercatorQuad",
+			tileMatrix:      "1",
+			tileRow:         "2",
+			tileCol:         "0",
+			wantStatusCode:  http.StatusNotFound,
+		},
+		{
+			name:            "tile matrix set not supported",
+			tileMatrixSetID: "EuropeanETRS89_LAEAQuad",
+			tileMatrix:      "5",
+			tileRow:         "1",
+			tileCol:         "1",
+			wantStatusCode:  http.StatusNotFound,
+		},
+		{
+			name:            "invalid tile",
+			tileMatrixSetID: "WebMercatorQuad",
+			tileMatrix:      "14",
+			tileRow:         "foo",
+			tileCol:         "8414",
+			wantStatusCode:  http.StatusBadRequest,
+		},
+	}
+	newEngine, err := engine.NewEngine("ogc/tiles/testdata/config_tiles_onthefly.yaml", "", false, true)
+	assert.NoError(t, err)
+	tiles := NewTiles(newEngine, features.NewFeatures(newEngine))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := createTileRequest("http://localhost:8080/collections/addresses/tiles", tt.tileMatrixSetID, tt.tileMatrix, tt.tileRow, tt.tileCol)
+			if err != nil {
+				log.Fatal(err)
+			}
+			chi.RouteContext(req.Context()).URLParams.Add("collectionId", "addresses")
+			req.Header.Set("Accept", engine.MediaTypeMVT)
+			rr := httptest.NewRecorder()
+
+			handler := tiles.TilesCollection()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantStatusCode, rr.Code)
+			if tt.wantStatusCode == http.StatusOK {
+				assert.Equal(t, engine.MediaTypeMVT, rr.Header().Get(engine.HeaderContentType))
+				assert.NotEmpty(t, rr.Header().Get(engine.HeaderETag))
+				assert.NotEmpty(t, rr.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestTiles_Tile_Archives(t *testing.T) {
+	tests := []struct {
+		name            string
+		tileMatrixSetID string
+		tileMatrix      string
+		tileRow         string
+		tileCol         string
+		acceptGzip      bool
+		wantStatusCode  int
+		wantBody        string
+		wantEncoding    string
+	}{
+		{
+			name:            "tile from MBTiles",
+			tileMatrixSetID: "NetherlandsRDNewQuad",
+			tileMatrix:      "1",
+			tileRow:         "0",
+			tileCol:         "1",
+			wantStatusCode:  http.StatusOK,
+			wantBody:        "tile 1/0/1",
+		},
+		{
+			name:            "gzip compressed tile from MBTiles",
+			tileMatrixSetID: "NetherlandsRDNewQuad",
+			tileMatrix:      "0",
+			tileRow:         "0",
+			tileCol:         "0.pbf",
+			acceptGzip:      true,
+			wantStatusCode:  http.StatusOK,
+			wantEncoding:    "gzip",
+		},
+		{
+			name:            "uncompressed tile from MBTiles",
+			tileMatrixSetID: "NetherlandsRDNewQuad",
+			tileMatrix:      "2",
+			tileRow:         "2",
+			tileCol:         "3",
+			acceptGzip:      true,
+			wantStatusCode:  http.StatusOK,
+			wantBody:        "tile 2/2/3",
+		},
+		{
+			name:            "empty tile from MBTiles",
+			tileMatrixSetID: "NetherlandsRDNewQuad",
+			tileMatrix:      "2",
+			tileRow:         "0",
+			tileCol:         "0",
+			wantStatusCode:  http.StatusNoContent,
+		},
+		{
+			name:            "tile from PMTiles",
+			tileMatrixSetID: "WebMercatorQuad",
+			tileMatrix:      "2",
+			tileRow:         "0",
+			tileCol:         "1",
+			wantStatusCode:  http.StatusOK,
+			wantBody:        "same tile",
+		},
+		{
+			name:            "missing tile from PMTiles",
+			tileMatrixSetID: "WebMercatorQuad",
+			tileMatrix:      "1",
+			tileRow:         "1",
+			tileCol:         "1",
+			wantStatusCode:  http.StatusNoContent,
+		},
+		{
+			name:            "no archive and no tileserver",
+			tileMatrixSetID: "EuropeanETRS89_LAEAQuad",
+			tileMatrix:      "1",
+			tileRow:         "1",
+			tileCol:         "1",
+			wantStatusCode:  http.StatusNotFound,
+		},
+	}
+	newEngine, err := engine.NewEngine("ogc/tiles/testdata/config_tiles_archives.yaml", "", false, true)
+	assert.NoError(t, err)
+	tiles := NewTiles(newEngine, nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := createTileRequest("http://localhost:8080/tiles", tt.tileMatrixSetID, tt.tileMatrix, tt.tileRow, tt.tileCol)
+			if err != nil {
+				log.Fatal(err)
+			}
+			req.Header.Set("Accept", engine.MediaTypeMVT)
+			if tt.acceptGzip {
+				req.Header.Set("Accept-Encoding", "gzip, deflate")
+			}
+			rr := httptest.NewRecorder()
+
+			handler := tiles.Tile()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantStatusCode, rr.Code)
+			assert.Equal(t, tt.wantEncoding, rr.Header().Get(engine.HeaderContentEncoding))
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+// Package mvt encodes Mapbox Vector Tiles, see https://github.com/mapbox/vector-tile-spec/tree/master/2.1
+// for the specification and https://github.com/mapbox/vector-tile-spec/blob/master/2.1/vector_tile.proto
+// for the protocol buffers schema.
+package mvt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/go-spatial/geom"
+)
+
+const (
+	version = 2
+
+	// protocol buffers wire types
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+
+	// geometry commands
+	cmdMoveTo    = 1
+	cmdLineTo    = 2
+	cmdClosePath = 7
+)
+
+// geometryType MVT geometry type
+type geometryType uint64
+
+const (
+	geometryTypePoint      geometryType = 1
+	geometryTypeLineString geometryType = 2
+	geometryTypePolygon    geometryType = 3
+)
+
+// Layer in a vector tile
+type Layer struct {
+	// Name of the layer, unique within a tile
+	Name string
+
+	// Size of the tile in tile coordinates
+	Extent uint32
+
+	Features []Feature
+}
+
+// Feature in a layer
+type Feature struct {
+	// ID of the feature, omitted when 0
+	ID uint64
+
+	// Properties of the feature, nil values are omitted
+	Properties map[string]any
+
+	// Geometry in (integer) tile coordinates, see Prepare. Members of a geometry
+	// collection are encoded as separate features, since MVT doesn't support collections.
+	Geometry geom.Geometry
+}
+
+// Encode encodes the given layers as a vector tile
+func Encode(layers ...Layer) []byte {
+	var tile []byte
+	for _, layer := range layers {
+		tile = appendBytes(tile, 3, encodeLayer(layer))
+	}
+	return tile
+}
+
+func encodeLayer(layer Layer) []byte {
+	var features []byte
+	keys := newIndex[string]()
+	values := newIndex[any]()
+	for _, feature := range layer.Features {
+		tags := encodeTags(feature.Properties, keys, values)
+		for _, geometry := range flatten(feature.Geometry) {
+			geomType, commands := encodeGeometry(geometry)
+			if len(commands) == 0 {
+				continue
+			}
+			var f []byte
+			if feature.ID > 0 {
+				f = appendVarint(f, 1, feature.ID)
+			}
+			if len(tags) > 0 {
+				f = appendPacked(f, 2, tags)
+			}
+			f = appendVarint(f, 3, uint64(geomType))
+			f = appendPacked(f, 4, commands)
+			features = appendBytes(features, 2, f)
+		}
+	}
+
+	result := appendBytes(nil, 1, []byte(layer.Name))
+	result = append(result, features...)
+	for _, key := range keys.items {
+		result = appendBytes(result, 3, []byte(key))
+	}
+	for _, value := range values.items {
+		result = appendBytes(result, 4, encodeValue(value))
+	}
+	result = appendVarint(result, 5, uint64(layer.Extent))
+	return appendVarint(result, 15, version)
+}
+
+// encodeTags returns pairs of indexes in the keys and values of the layer
+func encodeTags(properties map[string]any, keys *index[string], values *index[any]) []uint32 {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	slices.Sort(names) // for a deterministic encoding
+
+	tags := make([]uint32, 0, 2*len(names))
+	for _, name := range names {
+		value := normalizeValue(properties[name])
+		if value == nil {
+			continue
+		}
+		tags = append(tags, keys.add(name), values.add(value))
+	}
+	return tags
+}
+
+// normalizeValue converts the given property value to one of the types supported by MVT
+func normalizeValue(value any) any {
+	switch v := value.(type) {
+	case nil, string, bool, float32, float64, int64, uint64:
+		return v
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint32:
+		return uint64(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		asJSON, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		return string(asJSON)
+	}
+}
+
+func encodeValue(value any) []byte {
+	switch v := value.(type) {
+	case string:
+		return appendBytes(nil, 1, []byte(v))
+	case float32:
+		return binary.LittleEndian.AppendUint32(appendKey(nil, 2, wireFixed32), math.Float32bits(v))
+	case float64:
+		return binary.LittleEndian.AppendUint64(appendKey(nil, 3, wireFixed64), math.Float64bits(v))
+	case int64:
+		if v < 0 {
+			return appendVarint(nil, 6, zigzag(v))
+		}
+		return appendVarint(nil, 5, uint64(v))
+	case uint64:
+		return appendVarint(nil, 5, v)
+	case bool:
+		if v {
+			return appendVarint(nil, 7, 1)
+		}
+		return appendVarint(nil, 7, 0)
+	}
+	return nil
+}
+
+// flatten returns the members of a geometry collection (recursively) or the geometry itself
+func flatten(geometry geom.Geometry) []geom.Geometry {
+	collection, ok := geometry.(geom.Collectioner)
+	if !ok {
+		if geometry == nil {
+			return nil
+		}
+		return []geom.Geometry{geometry}
+	}
+	var result []geom.Geometry
+	for _, member := range collection.Geometries() {
+		result = append(result, flatten(member)...)
+	}
+	return result
+}
+
+// encodeGeometry encodes the given geometry as a sequence of commands and (delta encoded) parameters
+func encodeGeometry(geometry geom.Geometry) (geometryType, []uint32) {
+	e := &geometryEncoder{}
+	switch g := geometry.(type) {
+	case geom.Pointer:
+		e.points([][2]float64{g.XY()})
+		return geometryTypePoint, e.commands
+	case geom.LineStringer: // before MultiPointer, since linestrings implement both
+		e.lineString(g.Vertices())
+		return geometryTypeLineString, e.commands
+	case geom.MultiPointer:
+		e.points(g.Points())
+		return geometryTypePoint, e.commands
+	case geom.MultiLineStringer:
+		for _, line := range g.LineStrings() {
+			e.lineString(line)
+		}
+		return geometryTypeLineString, e.commands
+	case geom.Polygoner:
+		e.polygon(g.LinearRings())
+		return geometryTypePolygon, e.commands
+	case geom.MultiPolygoner:
+		for _, polygon := range g.Polygons() {
+			e.polygon(polygon)
+		}
+		return geometryTypePolygon, e.commands
+	}
+	return 0, nil
+}
+
+type geometryEncoder struct {
+	commands []uint32
+	x, y     int64 // cursor
+}
+
+func (e *geometryEncoder) points(points [][2]float64) {
+	if len(points) == 0 {
+		return
+	}
+	e.command(cmdMoveTo, len(points))
+	for _, point := range points {
+		e.moveCursor(point)
+	}
+}
+
+func (e *geometryEncoder) lineString(line [][2]float64) {
+	if len(line) < 2 {
+		return
+	}
+	e.command(cmdMoveTo, 1)
+	e.moveCursor(line[0])
+	e.command(cmdLineTo, len(line)-1)
+	for _, point := range line[1:] {
+		e.moveCursor(point)
+	}
+}
+
+func (e *geometryEncoder) polygon(rings [][][2]float64) {
+	for i, ring := range rings {
+		ring = openRing(ring)
+		if len(ring) < 3 {
+			if i == 0 {
+				return // no exterior ring, skip polygon
+			}
+			continue
+		}
+		// exterior rings should have a positive area in tile coordinates (clockwise, since the y-axis points
+		// down) and interior rings a negative area (counterclockwise).
+		if exterior := i == 0; exterior != (area(ring) > 0) {
+			ring = slices.Clone(ring)
+			slices.Reverse(ring)
+		}
+		e.lineString(ring)
+		e.command(cmdClosePath, 1)
+	}
+}
+
+func (e *geometryEncoder) command(id uint32, count int) {
+	e.commands = append(e.commands, (id&0x7)|(uint32(count)<<3))
+}
+
+func (e *geometryEncoder) moveCursor(point [2]float64) {
+	x, y := int64(math.Round(point[0])), int64(math.Round(point[1]))
+	e.commands = append(e.commands, uint32(zigzag(x-e.x)), uint32(zigzag(y-e.y)))
+	e.x, e.y = x, y
+}
+
+// openRing returns the given ring without the closing point (which equals the first point), if any
+func openRing(ring [][2]float64) [][2]float64 {
+	if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+		return ring[:len(ring)-1]
+	}
+	return ring
+}
+
+// area of the given ring using the surveyor's formula, positive when clockwise in tile coordinates
+func area(ring [][2]float64) float64 {
+	sum := 0.0
+	for i := range ring {
+		a, b := ring[i], ring[(i+1)%len(ring)]
+		sum += a[0]*b[1] - b[0]*a[1]
+	}
+	return sum / 2
+}
+
+// index keeps track of the unique keys or values in a layer
+type index[T comparable] struct {
+	positions map[T]uint32
+	items     []T
+}
+
+func newIndex[T comparable]() *index[T] {
+	return &index[T]{positions: make(map[T]uint32)}
+}
+
+func (i *index[T]) add(item T) uint32 {
+	position, ok := i.positions[item]
+	if !ok {
+		position = uint32(len(i.items))
+		i.positions[item] = position
+		i.items = append(i.items, item)
+	}
+	return position
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func appendKey(b []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+func appendVarint(b []byte, field int, v uint64) []byte {
+	return binary.AppendUvarint(appendKey(b, field, wireVarint), v)
+}
+
+func appendBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(appendKey(b, field, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendPacked(b []byte, field int, values []uint32) []byte {
+	var packed []byte
+	for _, v := range values {
+		packed = binary.AppendUvarint(packed, uint64(v))
+	}
+	return appendBytes(b, field, packed)
+}
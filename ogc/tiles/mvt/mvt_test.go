@@ -0,0 +1,185 @@
+package mvt
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-spatial/geom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// examples from the MVT specification (section 4.3.5)
+func TestEncodeGeometry(t *testing.T) {
+	tests := []struct {
+		name         string
+		geometry     geom.Geometry
+		wantType     geometryType
+		wantCommands []uint32
+	}{
+		{
+			name:         "point",
+			geometry:     geom.Point{25, 17},
+			wantType:     geometryTypePoint,
+			wantCommands: []uint32{9, 50, 34},
+		},
+		{
+			name:         "multipoint",
+			geometry:     geom.MultiPoint{{5, 7}, {3, 2}},
+			wantType:     geometryTypePoint,
+			wantCommands: []uint32{17, 10, 14, 3, 9},
+		},
+		{
+			name:         "linestring",
+			geometry:     geom.LineString{{2, 2}, {2, 10}, {10, 10}},
+			wantType:     geometryTypeLineString,
+			wantCommands: []uint32{9, 4, 4, 18, 0, 16, 16, 0},
+		},
+		{
+			name:         "multilinestring",
+			geometry:     geom.MultiLineString{{{2, 2}, {2, 10}, {10, 10}}, {{1, 1}, {3, 5}}},
+			wantType:     geometryTypeLineString,
+			wantCommands: []uint32{9, 4, 4, 18, 0, 16, 16, 0, 9, 17, 17, 10, 4, 8},
+		},
+		{
+			name:         "polygon",
+			geometry:     geom.Polygon{{{3, 6}, {8, 12}, {20, 34}, {3, 6}}},
+			wantType:     geometryTypePolygon,
+			wantCommands: []uint32{9, 6, 12, 18, 10, 12, 24, 44, 15},
+		},
+		{
+			name:         "polygon with wrong winding order",
+			geometry:     geom.Polygon{{{20, 34}, {8, 12}, {3, 6}}},
+			wantType:     geometryTypePolygon,
+			wantCommands: []uint32{9, 6, 12, 18, 10, 12, 24, 44, 15},
+		},
+		{
+			name: "multipolygon with hole",
+			geometry: geom.MultiPolygon{
+				{{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}},
+				{{{11, 11}, {20, 11}, {20, 20}, {11, 20}, {11, 11}}, {{13, 13}, {13, 17}, {17, 17}, {17, 13}, {13, 13}}},
+			},
+			wantType: geometryTypePolygon,
+			wantCommands: []uint32{9, 0, 0, 26, 20, 0, 0, 20, 19, 0, 15, 9, 22, 2, 26, 18, 0, 0, 18, 17, 0, 15,
+				9, 4, 13, 26, 0, 8, 8, 0, 0, 7, 15},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			geomType, commands := encodeGeometry(tt.geometry)
+			assert.Equal(t, tt.wantType, geomType)
+			assert.Equal(t, tt.wantCommands, commands)
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tile := Encode(Layer{
+		Name:   "addresses",
+		Extent: 4096,
+		Features: []Feature{
+			{
+				ID:         1,
+				Properties: map[string]any{"name": "foo", "number": 3, "area": 1.5, "missing": nil},
+				Geometry:   geom.Point{25, 17},
+			},
+			{
+				ID:         2,
+				Properties: map[string]any{"name": "foo", "number": int64(-4), "date": time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+				Geometry:   geom.Collection{geom.Point{1, 1}, geom.LineString{{2, 2}, {2, 10}}},
+			},
+			{
+				ID:       3,
+				Geometry: nil,
+			},
+		},
+	})
+
+	layers := readFields(t, tile)
+	require.Len(t, layers, 1)
+	assert.Equal(t, 3, layers[0].number)
+	layer := readFields(t, layers[0].bytes)
+
+	var name string
+	var features [][]field
+	var keys []string
+	var values [][]field
+	var extent, version uint64
+	for _, f := range layer {
+		switch f.number {
+		case 1:
+			name = string(f.bytes)
+		case 2:
+			features = append(features, readFields(t, f.bytes))
+		case 3:
+			keys = append(keys, string(f.bytes))
+		case 4:
+			values = append(values, readFields(t, f.bytes))
+		case 5:
+			extent = f.varint
+		case 15:
+			version = f.varint
+		}
+	}
+	assert.Equal(t, "addresses", name)
+	assert.Equal(t, uint64(4096), extent)
+	assert.Equal(t, uint64(2), version)
+	assert.Equal(t, []string{"area", "name", "number", "date"}, keys)
+
+	require.Len(t, values, 5)
+	assert.Equal(t, 1.5, math.Float64frombits(values[0][0].fixed64))
+	assert.Equal(t, "foo", string(values[1][0].bytes))
+	assert.Equal(t, field{number: 5, varint: 3}, values[2][0])
+	assert.Equal(t, "2024-05-01T00:00:00Z", string(values[3][0].bytes))
+	assert.Equal(t, field{number: 6, varint: 7}, values[4][0]) // zigzag encoded -4
+
+	require.Len(t, features, 3, "collection is split in separate features, feature without geometry is omitted")
+	assert.Equal(t, []field{
+		{number: 1, varint: 1},
+		{number: 2, bytes: []byte{0, 0, 1, 1, 2, 2}},
+		{number: 3, varint: 1},
+		{number: 4, bytes: []byte{9, 50, 34}},
+	}, features[0])
+	assert.Equal(t, []field{
+		{number: 1, varint: 2},
+		{number: 2, bytes: []byte{3, 3, 1, 1, 2, 4}},
+		{number: 3, varint: 1},
+		{number: 4, bytes: []byte{9, 2, 2}},
+	}, features[1])
+	assert.Equal(t, uint64(2), features[2][2].varint, "linestring")
+}
+
+// field minimal protocol buffers decoding, to verify the encoding
+type field struct {
+	number  int
+	varint  uint64
+	fixed64 uint64
+	bytes   []byte
+}
+
+func readFields(t *testing.T, b []byte) []field {
+	var result []field
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		b = b[n:]
+		f := field{number: int(key >> 3)}
+		switch key & 0x7 {
+		case wireVarint:
+			f.varint, n = binary.Uvarint(b)
+			b = b[n:]
+		case wireFixed64:
+			f.fixed64 = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			f.bytes = b[n : n+int(length)]
+			b = b[n+int(length):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&0x7)
+		}
+		result = append(result, f)
+	}
+	return result
+}
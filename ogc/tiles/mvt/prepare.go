@@ -0,0 +1,274 @@
+package mvt
+
+import (
+	"math"
+
+	"github.com/go-spatial/geom"
+)
+
+// Prepare converts the given geometry - in the CRS of the tile matrix set - to tile coordinates: the geometry
+// is clipped to the bounds of the tile (including the given buffer in tile coordinates), simplified and
+// quantized to the integer grid of the given extent. Returns nil when nothing of the geometry remains.
+func Prepare(geometry geom.Geometry, bounds geom.Extent, extent uint32, buffer uint32) geom.Geometry {
+	p := preparer{
+		bounds: bounds,
+		extent: float64(extent),
+		clip:   geom.Extent{-float64(buffer), -float64(buffer), float64(extent + buffer), float64(extent + buffer)},
+	}
+	return p.geometry(geometry)
+}
+
+// simplification tolerance in tile coordinates, smaller differences aren't visible
+// anyway since tiles are usually rendered at a (much) smaller size than their extent.
+const simplifyTolerance = 1.0
+
+type preparer struct {
+	bounds geom.Extent // of the tile in the CRS of the tile matrix set
+	extent float64
+	clip   geom.Extent // in tile coordinates
+}
+
+//nolint:cyclop
+func (p preparer) geometry(geometry geom.Geometry) geom.Geometry {
+	switch g := geometry.(type) {
+	case geom.Pointer:
+		if points := p.points([][2]float64{g.XY()}); len(points) > 0 {
+			return geom.Point(points[0])
+		}
+	case geom.LineStringer: // before MultiPointer, since linestrings implement both
+		if lines := p.lineString(g.Vertices()); len(lines) == 1 {
+			return geom.LineString(lines[0])
+		} else if len(lines) > 1 {
+			return geom.MultiLineString(lines)
+		}
+	case geom.MultiPointer:
+		if points := p.points(g.Points()); len(points) > 0 {
+			return geom.MultiPoint(points)
+		}
+	case geom.MultiLineStringer:
+		var lines [][][2]float64
+		for _, line := range g.LineStrings() {
+			lines = append(lines, p.lineString(line)...)
+		}
+		if len(lines) > 0 {
+			return geom.MultiLineString(lines)
+		}
+	case geom.Polygoner:
+		if polygon := p.polygon(g.LinearRings()); polygon != nil {
+			return geom.Polygon(polygon)
+		}
+	case geom.MultiPolygoner:
+		var polygons [][][][2]float64
+		for _, polygon := range g.Polygons() {
+			if prepared := p.polygon(polygon); prepared != nil {
+				polygons = append(polygons, prepared)
+			}
+		}
+		if len(polygons) > 0 {
+			return geom.MultiPolygon(polygons)
+		}
+	case geom.Collectioner:
+		var members geom.Collection
+		for _, member := range g.Geometries() {
+			if prepared := p.geometry(member); prepared != nil {
+				members = append(members, prepared)
+			}
+		}
+		if len(members) > 0 {
+			return members
+		}
+	}
+	return nil
+}
+
+func (p preparer) points(points [][2]float64) [][2]float64 {
+	var result [][2]float64
+	for _, point := range points {
+		point = p.transform(point)
+		if p.clip.ContainsPoint(point) {
+			result = append(result, quantize(point))
+		}
+	}
+	return result
+}
+
+// lineString returns the parts of the given line within the tile, since clipping may split a line in multiple parts
+func (p preparer) lineString(line [][2]float64) [][][2]float64 {
+	var result [][][2]float64
+	var part [][2]float64
+	addPart := func() {
+		if part = removeDuplicates(quantizeAll(simplify(part, simplifyTolerance))); len(part) > 1 {
+			result = append(result, part)
+		}
+		part = nil
+	}
+	for i := 1; i < len(line); i++ {
+		a, b, ok := clipSegment(p.clip, p.transform(line[i-1]), p.transform(line[i]))
+		if !ok {
+			addPart()
+			continue
+		}
+		if len(part) == 0 || part[len(part)-1] != a {
+			addPart()
+			part = append(part, a)
+		}
+		part = append(part, b)
+	}
+	addPart()
+	return result
+}
+
+// polygon returns the clipped rings of the given polygon, or nil when the exterior ring doesn't remain
+func (p preparer) polygon(rings [][][2]float64) [][][2]float64 {
+	var result [][][2]float64
+	for i, ring := range rings {
+		transformed := make([][2]float64, 0, len(ring))
+		for _, point := range ring {
+			transformed = append(transformed, p.transform(point))
+		}
+		prepared := openRing(removeDuplicates(quantizeAll(simplify(clipRing(p.clip, transformed), simplifyTolerance))))
+		if len(prepared) < 3 || area(prepared) == 0 {
+			if i == 0 {
+				return nil
+			}
+			continue // e.g. hole outside the tile
+		}
+		result = append(result, prepared)
+	}
+	return result
+}
+
+// transform converts the given coordinates to tile coordinates, with the origin at the top left of the tile
+func (p preparer) transform(point [2]float64) [2]float64 {
+	return [2]float64{
+		(point[0] - p.bounds.MinX()) * p.extent / (p.bounds.MaxX() - p.bounds.MinX()),
+		(p.bounds.MaxY() - point[1]) * p.extent / (p.bounds.MaxY() - p.bounds.MinY()),
+	}
+}
+
+func quantize(point [2]float64) [2]float64 {
+	return [2]float64{math.Round(point[0]), math.Round(point[1])}
+}
+
+func quantizeAll(points [][2]float64) [][2]float64 {
+	for i := range points {
+		points[i] = quantize(points[i])
+	}
+	return points
+}
+
+// removeDuplicates removes consecutive duplicate points
+func removeDuplicates(points [][2]float64) [][2]float64 {
+	var result [][2]float64
+	for _, point := range points {
+		if len(result) == 0 || result[len(result)-1] != point {
+			result = append(result, point)
+		}
+	}
+	return result
+}
+
+// clipSegment clips the line segment from a to b to the given extent (Liang-Barsky), returns false when
+// the segment is completely outside the extent.
+func clipSegment(extent geom.Extent, a, b [2]float64) ([2]float64, [2]float64, bool) {
+	tMin, tMax := 0.0, 1.0
+	d := [2]float64{b[0] - a[0], b[1] - a[1]}
+	for axis := 0; axis < 2; axis++ {
+		lower, upper := extent.Min()[axis], extent.Max()[axis]
+		if d[axis] == 0 {
+			if a[axis] < lower || a[axis] > upper {
+				return a, b, false
+			}
+			continue
+		}
+		t1, t2 := (lower-a[axis])/d[axis], (upper-a[axis])/d[axis]
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tMin, tMax = math.Max(tMin, t1), math.Min(tMax, t2)
+		if tMin > tMax {
+			return a, b, false
+		}
+	}
+	return [2]float64{a[0] + tMin*d[0], a[1] + tMin*d[1]}, [2]float64{a[0] + tMax*d[0], a[1] + tMax*d[1]}, true
+}
+
+// clipRing clips the given ring to the given extent (Sutherland-Hodgman), one edge of the extent at a time
+func clipRing(extent geom.Extent, ring [][2]float64) [][2]float64 {
+	ring = openRing(ring)
+	for edge := 0; edge < 4 && len(ring) > 0; edge++ {
+		axis := edge % 2
+		bound, isMin := extent.Min()[axis], edge < 2
+		if !isMin {
+			bound = extent.Max()[axis]
+		}
+		inside := func(point [2]float64) bool {
+			if isMin {
+				return point[axis] >= bound
+			}
+			return point[axis] <= bound
+		}
+		intersection := func(a, b [2]float64) [2]float64 {
+			t := (bound - a[axis]) / (b[axis] - a[axis])
+			return [2]float64{a[0] + t*(b[0]-a[0]), a[1] + t*(b[1]-a[1])}
+		}
+
+		clipped := make([][2]float64, 0, len(ring))
+		for i, current := range ring {
+			previous := ring[(i+len(ring)-1)%len(ring)]
+			switch {
+			case inside(current) && !inside(previous):
+				clipped = append(clipped, intersection(previous, current), current)
+			case inside(current):
+				clipped = append(clipped, current)
+			case inside(previous):
+				clipped = append(clipped, intersection(previous, current))
+			}
+		}
+		ring = clipped
+	}
+	return ring
+}
+
+// simplify the given line or (open) ring using the Douglas-Peucker algorithm
+func simplify(points [][2]float64, tolerance float64) [][2]float64 {
+	if len(points) < 3 {
+		return points
+	}
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		maxDistance, farthest := 0.0, -1
+		for i := first + 1; i < last; i++ {
+			if distance := distanceToSegment(points[i], points[first], points[last]); distance > maxDistance {
+				maxDistance, farthest = distance, i
+			}
+		}
+		if farthest >= 0 && maxDistance > tolerance {
+			keep[farthest] = true
+			stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+		}
+	}
+	result := make([][2]float64, 0, len(points))
+	for i, point := range points {
+		if keep[i] {
+			result = append(result, point)
+		}
+	}
+	return result
+}
+
+// distanceToSegment returns the distance of the given point to the line segment from a to b
+func distanceToSegment(point, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	if dx == 0 && dy == 0 {
+		return math.Hypot(point[0]-a[0], point[1]-a[1])
+	}
+	t := ((point[0]-a[0])*dx + (point[1]-a[1])*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(point[0]-(a[0]+t*dx), point[1]-(a[1]+t*dy))
+}
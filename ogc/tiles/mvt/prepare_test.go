@@ -0,0 +1,93 @@
+package mvt
+
+import (
+	"testing"
+
+	"github.com/go-spatial/geom"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepare(t *testing.T) {
+	// tile of 1000x1000 map units, with an extent of 100 so 1 tile unit = 10 map units
+	bounds := geom.Extent{1000, 5000, 2000, 6000}
+	tests := []struct {
+		name     string
+		geometry geom.Geometry
+		want     geom.Geometry
+	}{
+		{
+			name:     "point in tile, y-axis is flipped",
+			geometry: geom.Point{1104, 5896},
+			want:     geom.Point{10, 10},
+		},
+		{
+			name:     "point in buffer",
+			geometry: geom.Point{995, 5500},
+			want:     geom.Point{-1, 50},
+		},
+		{
+			name:     "point outside tile",
+			geometry: geom.Point{800, 5500},
+			want:     nil,
+		},
+		{
+			name:     "multipoint partially in tile",
+			geometry: geom.MultiPoint{{800, 5500}, {1500, 5500}},
+			want:     geom.MultiPoint{{50, 50}},
+		},
+		{
+			name:     "linestring clipped and simplified",
+			geometry: geom.LineString{{500, 5500}, {1500, 5501}, {2500, 5500}},
+			want:     geom.LineString{{-10, 50}, {110, 50}},
+		},
+		{
+			name:     "linestring leaving and entering tile",
+			geometry: geom.LineString{{1500, 5500}, {1500, 7000}, {1600, 7000}, {1600, 5500}},
+			want:     geom.MultiLineString{{{50, 50}, {50, -10}}, {{60, -10}, {60, 50}}},
+		},
+		{
+			name:     "linestring collapsed to a single point",
+			geometry: geom.LineString{{1500, 5500}, {1501, 5501}},
+			want:     nil,
+		},
+		{
+			name:     "polygon covering tile",
+			geometry: geom.Polygon{{{0, 0}, {0, 9000}, {9000, 9000}, {9000, 0}, {0, 0}}},
+			want:     geom.Polygon{{{110, 110}, {-10, 110}, {-10, -10}, {110, -10}}},
+		},
+		{
+			name: "polygon with hole outside tile",
+			geometry: geom.Polygon{
+				{{1100, 5100}, {1100, 5900}, {1900, 5900}, {1900, 5100}, {1100, 5100}},
+				{{3000, 3000}, {3000, 3100}, {3100, 3100}, {3000, 3000}},
+			},
+			want: geom.Polygon{{{10, 90}, {10, 10}, {90, 10}, {90, 90}}},
+		},
+		{
+			name:     "polygon too small",
+			geometry: geom.Polygon{{{1100, 5100}, {1101, 5100}, {1101, 5101}, {1100, 5100}}},
+			want:     nil,
+		},
+		{
+			name: "collection",
+			geometry: geom.Collection{
+				geom.Point{800, 5500},
+				geom.Point{1500, 5500},
+			},
+			want: geom.Collection{geom.Point{50, 50}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Prepare(tt.geometry, bounds, 100, 10))
+		})
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	points := [][2]float64{{0, 0}, {1, 0.1}, {2, -0.1}, {3, 5}, {4, 6}, {5, 7}}
+	assert.Equal(t, [][2]float64{{0, 0}, {2, -0.1}, {3, 5}, {5, 7}}, simplify(points, 0.5))
+	assert.Equal(t, points[:4], simplify(points[:4], 0.01))
+	assert.Equal(t, [][2]float64{{0, 0}, {5, 7}}, simplify([][2]float64{{0, 0}, {3, 4.2}, {5, 7}}, 0.01), "collinear")
+	assert.Equal(t, [][2]float64{{0, 0}, {1, 1}}, simplify([][2]float64{{0, 0}, {1, 1}}, 10))
+}
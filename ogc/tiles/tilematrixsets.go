@@ -0,0 +1,243 @@
+package tiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/PDOK/gokoala/config"
+	"github.com/PDOK/gokoala/ogc/features/datasources/reproject"
+	"github.com/go-spatial/geom"
+)
+
+const (
+	// built-in tile matrix set definitions, see https://docs.ogc.org/is/17-083r4/17-083r4.html
+	tileMatrixSetsDir = "ogc/tiles/tilematrixsets/"
+)
+
+// tileMatrixSet a tile matrix set (tiling scheme), along with the projection and the limits of
+// the available tiles (within the configured zoom levels and extent) when tiles are offered in it
+type tileMatrixSet struct {
+	config.TileMatrixSetDefinition
+	Srs    *config.SupportedSrs // nil when no tiles are offered in this tile matrix set
+	Limits []tileMatrixLimits
+}
+
+// tileMatrixLimits a tile matrix (zoom level) along with the range of available rows and columns
+type tileMatrixLimits struct {
+	config.TileMatrix
+	MinTileRow int
+	MaxTileRow int
+	MinTileCol int
+	MaxTileCol int
+}
+
+// newTileMatrixSets returns the tile matrix sets of the supported projections (in the same
+// order) followed by the remaining built-in tile matrix sets, in which no tiles are offered
+func newTileMatrixSets(cfg *config.OgcAPITiles) ([]*tileMatrixSet, error) {
+	var result []*tileMatrixSet
+	offered := make(map[string]bool)
+	for _, srs := range cfg.SupportedSrs {
+		definition, err := loadTileMatrixSet(cfg.TileMatrixSets, srs.TileMatrixSetID())
+		if err != nil {
+			return nil, err
+		}
+		tms := &tileMatrixSet{TileMatrixSetDefinition: definition, Srs: &srs}
+		var extent *geom.Extent
+		if cfg.Extent != nil {
+			if extent, err = datasetExtent(cfg.Extent, tms.srid()); err != nil {
+				return nil, fmt.Errorf("failed to determine extent in tile matrix set %s: %w", definition.ID, err)
+			}
+		}
+		for _, tm := range definition.TileMatrices {
+			zoom, err := strconv.Atoi(tm.ID)
+			if err != nil {
+				return nil, fmt.Errorf("tile matrix set %s has non-numeric tile matrix %s", definition.ID, tm.ID)
+			}
+			if zoom < srs.ZoomLevelRange.Start || zoom > srs.ZoomLevelRange.End {
+				continue
+			}
+			if limits, ok := tms.newLimits(tm, extent); ok {
+				tms.Limits = append(tms.Limits, limits)
+			}
+		}
+		result = append(result, tms)
+		offered[definition.ID] = true
+	}
+
+	builtIn, err := os.ReadDir(tileMatrixSetsDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range builtIn {
+		if offered[strings.TrimSuffix(file.Name(), ".json")] {
+			continue
+		}
+		definition, err := readTileMatrixSet(tileMatrixSetsDir + file.Name())
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &tileMatrixSet{TileMatrixSetDefinition: definition})
+	}
+	return result, nil
+}
+
+// loadTileMatrixSet returns the definition of the given tile matrix set: a custom
+// definition from config (inline or file) or otherwise the built-in definition
+func loadTileMatrixSet(custom []config.TileMatrixSet, id string) (config.TileMatrixSetDefinition, error) {
+	for _, tms := range custom {
+		definition := tms.TileMatrixSetDefinition
+		if tms.File != "" {
+			var err error
+			if definition, err = readTileMatrixSet(tms.File); err != nil {
+				return definition, err
+			}
+		}
+		if definition.ID == id {
+			return definition, nil
+		}
+	}
+	definition, err := readTileMatrixSet(tileMatrixSetsDir + id + ".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return definition, fmt.Errorf("no definition found for tile matrix set %s, "+
+			"add it to the tileMatrixSets in the config", id)
+	}
+	return definition, err
+}
+
+func readTileMatrixSet(file string) (config.TileMatrixSetDefinition, error) {
+	var definition config.TileMatrixSetDefinition
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return definition, err
+	}
+	if err = json.Unmarshal(b, &definition); err != nil {
+		return definition, fmt.Errorf("failed to parse tile matrix set %s: %w", file, err)
+	}
+	if definition.ID == "" || len(definition.TileMatrices) == 0 {
+		return definition, fmt.Errorf("tile matrix set %s should have an id and tile matrices", file)
+	}
+	return definition, nil
+}
+
+// datasetExtent returns the given extent in the given projection (SRID), in x/y order
+func datasetExtent(extent *config.Extent, srid int) (*geom.Extent, error) {
+	if len(extent.Bbox) != 4 {
+		return nil, errors.New("bbox of extent should have 4 values")
+	}
+	var bbox geom.Extent
+	for i, value := range extent.Bbox {
+		var err error
+		if bbox[i], err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+			return nil, fmt.Errorf("invalid bbox value %s: %w", value, err)
+		}
+	}
+	extentSrid, err := strconv.Atoi(strings.TrimPrefix(extent.Srs, "EPSG:"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid srs %s: %w", extent.Srs, err)
+	}
+	if reproject.IsGeographic(extentSrid) {
+		// lat/lon to lon/lat
+		bbox = geom.Extent{bbox[1], bbox[0], bbox[3], bbox[2]}
+	}
+	if extentSrid == srid {
+		return &bbox, nil
+	}
+	bbox, err = reproject.TransformExtent(bbox, extentSrid, srid)
+	return &bbox, err
+}
+
+// matrixHeights returns the number of rows by tile matrix (zoom level)
+func (tms *tileMatrixSet) matrixHeights() map[int]int {
+	result := make(map[int]int, len(tms.TileMatrices))
+	for _, tm := range tms.TileMatrices {
+		if zoom, err := strconv.Atoi(tm.ID); err == nil {
+			result[zoom] = tm.MatrixHeight
+		}
+	}
+	return result
+}
+
+// newLimits returns the limits of the given tile matrix: the whole tile matrix, or only the tiles covering
+// the given extent (when not nil). Returns false when the extent lies outside the tile matrix.
+func (tms *tileMatrixSet) newLimits(tm config.TileMatrix, extent *geom.Extent) (tileMatrixLimits, bool) {
+	limits := tileMatrixLimits{
+		TileMatrix: tm,
+		MaxTileRow: tm.MatrixHeight - 1,
+		MaxTileCol: tm.MatrixWidth - 1,
+	}
+	if extent == nil {
+		return limits, true
+	}
+	originX, originY := tms.origin(tm)
+	spanX, spanY := tileSpan(tm)
+	limits.MinTileCol = max(limits.MinTileCol, int(math.Floor((extent.MinX()-originX)/spanX)))
+	limits.MaxTileCol = min(limits.MaxTileCol, int(math.Ceil((extent.MaxX()-originX)/spanX))-1)
+	limits.MinTileRow = max(limits.MinTileRow, int(math.Floor((originY-extent.MaxY())/spanY)))
+	limits.MaxTileRow = min(limits.MaxTileRow, int(math.Ceil((originY-extent.MinY())/spanY))-1)
+	return limits, limits.MinTileCol <= limits.MaxTileCol && limits.MinTileRow <= limits.MaxTileRow
+}
+
+// srid returns the EPSG code of the projection of this tile matrix set, the last part of the CRS URI
+func (tms *tileMatrixSet) srid() int {
+	srid, _ := strconv.Atoi(path.Base(tms.CRS))
+	return srid
+}
+
+// limits returns the limits of the given tile matrix (zoom level), or false when not available
+func (tms *tileMatrixSet) limits(zoom int) (tileMatrixLimits, bool) {
+	id := strconv.Itoa(zoom)
+	for _, limits := range tms.Limits {
+		if limits.ID == id {
+			return limits, true
+		}
+	}
+	return tileMatrixLimits{}, false
+}
+
+// hasTile returns true when the given tile lies within the limits of this tile matrix set
+func (tms *tileMatrixSet) hasTile(zoom, row, col int) bool {
+	limits, ok := tms.limits(zoom)
+	return ok && row >= limits.MinTileRow && row <= limits.MaxTileRow && col >= limits.MinTileCol && col <= limits.MaxTileCol
+}
+
+// tileBounds returns the bounds of the given tile, or false when the tile isn't available in this tile matrix set
+func (tms *tileMatrixSet) tileBounds(zoom, row, col int) (geom.Extent, bool) {
+	if !tms.hasTile(zoom, row, col) {
+		return geom.Extent{}, false
+	}
+	limits, _ := tms.limits(zoom)
+	originX, originY := tms.origin(limits.TileMatrix)
+	spanX, spanY := tileSpan(limits.TileMatrix)
+	minX := originX + float64(col)*spanX
+	maxY := originY - float64(row)*spanY
+	return geom.Extent{minX, maxY - spanY, minX + spanX, maxY}, true
+}
+
+// origin returns the point of origin (top left corner) of the given tile matrix in x/y order,
+// while the point of origin is defined in the order of the axes of the tile matrix set
+func (tms *tileMatrixSet) origin(tm config.TileMatrix) (float64, float64) {
+	if len(tms.OrderedAxes) > 0 && isNorthing(tms.OrderedAxes[0]) {
+		return tm.PointOfOrigin[1], tm.PointOfOrigin[0]
+	}
+	return tm.PointOfOrigin[0], tm.PointOfOrigin[1]
+}
+
+// tileSpan returns the width and height of the tiles in the given tile matrix, in CRS units
+func tileSpan(tm config.TileMatrix) (float64, float64) {
+	return tm.CellSize * float64(tm.TileWidth), tm.CellSize * float64(tm.TileHeight)
+}
+
+func isNorthing(axis string) bool {
+	switch strings.ToUpper(axis) {
+	case "Y", "N", "LAT", "NORTHING":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package tiles
+
+import (
+	"testing"
+
+	"github.com/PDOK/gokoala/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTileMatrixSet_TileBounds(t *testing.T) {
+	tileMatrixSets, err := newTileMatrixSets(&config.OgcAPITiles{
+		SupportedSrs: []config.SupportedSrs{
+			{Srs: "EPSG:28992", ZoomLevelRange: config.ZoomLevelRange{Start: 0, End: 12}},
+			{Srs: "EPSG:3035", ZoomLevelRange: config.ZoomLevelRange{Start: 0, End: 12}},
+		},
+	})
+	require.NoError(t, err)
+	rd, etrs89 := tileMatrixSets[0], tileMatrixSets[1]
+	assert.Equal(t, "NetherlandsRDNewQuad", rd.ID)
+	assert.Len(t, tileMatrixSets, 3, "supported and remaining built-in tile matrix sets")
+	assert.Equal(t, 28992, rd.srid())
+	assert.Len(t, rd.Limits, 13)
+
+	bounds, ok := rd.tileBounds(0, 0, 0)
+	assert.True(t, ok)
+	assert.InDeltaSlice(t, []float64{-285401.92, 22598.08, 595401.92, 903401.92}, bounds[:], 0.001)
+
+	bounds, ok = rd.tileBounds(1, 1, 0)
+	assert.True(t, ok)
+	assert.InDeltaSlice(t, []float64{-285401.92, 22598.08, 155000, 463000}, bounds[:], 0.001)
+
+	_, ok = rd.tileBounds(1, 2, 0)
+	assert.False(t, ok, "row outside tile matrix")
+	_, ok = rd.tileBounds(13, 0, 0)
+	assert.False(t, ok, "zoom level outside configured zoom level range")
+	_, ok = rd.tileBounds(17, 0, 0)
+	assert.False(t, ok, "zoom level outside tile matrix set")
+
+	// point of origin is in northing, easting order
+	bounds, ok = etrs89.tileBounds(0, 0, 0)
+	assert.True(t, ok)
+	assert.InDeltaSlice(t, []float64{2000000, 1000000, 6500000, 5500000}, bounds[:], 0.001)
+}
+
+func TestTileMatrixSet_Custom(t *testing.T) {
+	tileMatrixSets, err := newTileMatrixSets(&config.OgcAPITiles{
+		SupportedSrs: []config.SupportedSrs{
+			{Srs: "EPSG:32631", TileMatrixSet: "UTM31Grid", ZoomLevelRange: config.ZoomLevelRange{Start: 0, End: 1}},
+		},
+		TileMatrixSets: []config.TileMatrixSet{
+			{TileMatrixSetDefinition: config.TileMatrixSetDefinition{
+				ID:  "UTM31Grid",
+				CRS: "http://www.opengis.net/def/crs/EPSG/0/32631",
+				TileMatrices: []config.TileMatrix{
+					{ID: "0", ScaleDenominator: 3571428.57, CellSize: 1000, PointOfOrigin: [2]float64{0, 10000000},
+						TileWidth: 256, TileHeight: 256, MatrixWidth: 4, MatrixHeight: 4},
+					{ID: "1", ScaleDenominator: 1785714.29, CellSize: 500, PointOfOrigin: [2]float64{0, 10000000},
+						TileWidth: 256, TileHeight: 256, MatrixWidth: 8, MatrixHeight: 8},
+				},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	utm := tileMatrixSets[0]
+	assert.Equal(t, 32631, utm.srid())
+	assert.Equal(t, []tileMatrixLimits{
+		{TileMatrix: utm.TileMatrices[0], MaxTileRow: 3, MaxTileCol: 3},
+		{TileMatrix: utm.TileMatrices[1], MaxTileRow: 7, MaxTileCol: 7},
+	}, utm.Limits)
+
+	bounds, ok := utm.tileBounds(0, 1, 2)
+	assert.True(t, ok)
+	assert.InDeltaSlice(t, []float64{512000, 9488000, 768000, 9744000}, bounds[:], 0.001)
+	_, ok = utm.tileBounds(0, 4, 0)
+	assert.False(t, ok, "row outside tile matrix")
+
+	_, err = newTileMatrixSets(&config.OgcAPITiles{
+		SupportedSrs: []config.SupportedSrs{{Srs: "EPSG:32631", TileMatrixSet: "UnknownGrid"}},
+	})
+	assert.ErrorContains(t, err, "no definition found for tile matrix set UnknownGrid")
+}
+
+func TestTileMatrixSet_Extent(t *testing.T) {
+	tileMatrixSets, err := newTileMatrixSets(&config.OgcAPITiles{
+		SupportedSrs: []config.SupportedSrs{
+			{Srs: "EPSG:28992", ZoomLevelRange: config.ZoomLevelRange{Start: 0, End: 12}},
+		},
+		Extent: &config.Extent{Srs: "EPSG:28992", Bbox: []string{"100000", "400000", "200000", "500000"}},
+	})
+	require.NoError(t, err)
+	rd := tileMatrixSets[0]
+	assert.Len(t, rd.Limits, 13)
+
+	limits, ok := rd.limits(0)
+	assert.True(t, ok)
+	assert.Equal(t, []int{0, 0, 0, 0}, []int{limits.MinTileRow, limits.MaxTileRow, limits.MinTileCol, limits.MaxTileCol})
+	limits, ok = rd.limits(5)
+	assert.True(t, ok)
+	assert.Equal(t, []int{14, 18, 14, 17}, []int{limits.MinTileRow, limits.MaxTileRow, limits.MinTileCol, limits.MaxTileCol})
+
+	assert.True(t, rd.hasTile(5, 14, 14))
+	assert.False(t, rd.hasTile(5, 13, 14), "row outside extent")
+	assert.False(t, rd.hasTile(5, 14, 18), "col outside extent")
+
+	// extent in WGS84 (lat/lon) outside the tile matrix set
+	tileMatrixSets, err = newTileMatrixSets(&config.OgcAPITiles{
+		SupportedSrs: []config.SupportedSrs{
+			{Srs: "EPSG:28992", ZoomLevelRange: config.ZoomLevelRange{Start: 0, End: 12}},
+		},
+		Extent: &config.Extent{Srs: "EPSG:4326", Bbox: []string{"40.0", "-10.0", "41.0", "-9.0"}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, tileMatrixSets[0].Limits)
+
+	_, err = newTileMatrixSets(&config.OgcAPITiles{
+		SupportedSrs: []config.SupportedSrs{{Srs: "EPSG:28992"}},
+		Extent:       &config.Extent{Srs: "EPSG:28992", Bbox: []string{"100000", "400000"}},
+	})
+	assert.ErrorContains(t, err, "bbox of extent should have 4 values")
+}